@@ -0,0 +1,121 @@
+// Package authz tests access control by replaying requests with the
+// credentials of other identities and comparing the responses with the
+// originals.
+package authz
+
+import (
+	"bytes"
+	"net/http"
+	"unicode"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// BypassSimilarity is the body similarity at or above which a successful
+// replay is considered to have returned the original content.
+const BypassSimilarity = 0.9
+
+// maxTokens bounds the work done comparing large bodies.
+const maxTokens = 50000
+
+// Apply returns a copy of e's request carrying id's credentials: headers in
+// id.Remove are dropped and id.Headers are set, replacing existing values.
+// The copy has no response.
+func Apply(id *storage.Identity, e *storage.Entry) *storage.Entry {
+	req := &storage.Entry{
+		Method:          e.Method,
+		Scheme:          e.Scheme,
+		Host:            e.Host,
+		Port:            e.Port,
+		Path:            e.Path,
+		Query:           e.Query,
+		RequestHeaders:  e.RequestHeaders.Clone(),
+		RequestBody:     e.RequestBody,
+		ResponseHeaders: http.Header{},
+	}
+	if req.RequestHeaders == nil {
+		req.RequestHeaders = http.Header{}
+	}
+
+	for _, name := range id.Remove {
+		req.RequestHeaders.Del(name)
+	}
+	for name, values := range id.Headers {
+		req.RequestHeaders.Del(name)
+		for _, v := range values {
+			req.RequestHeaders.Add(name, v)
+		}
+	}
+	return req
+}
+
+// Classify compares the response to a replayed request with the original and
+// returns a verdict and the similarity of the two bodies:
+//
+//   - the original failed (4xx or 5xx): uncertain, there is nothing to protect
+//   - 401 or 403, any other 4xx, or a redirect the original did not get:
+//     enforced
+//   - a 5xx: uncertain
+//   - otherwise bypassed if the bodies are at least BypassSimilarity alike,
+//     and uncertain if not, since the content may belong to the identity
+func Classify(original, replayed *storage.Entry) (string, float64) {
+	similarity := Similarity(original.ResponseBody, replayed.ResponseBody)
+
+	orig, status := original.StatusCode, replayed.StatusCode
+	switch {
+	case orig >= 400:
+		return storage.VerdictUncertain, similarity
+	case status >= 400 && status < 500:
+		return storage.VerdictEnforced, similarity
+	case status >= 300 && status < 400 && !(orig >= 300 && orig < 400):
+		return storage.VerdictEnforced, similarity
+	case status >= 500:
+		return storage.VerdictUncertain, similarity
+	case similarity >= BypassSimilarity:
+		return storage.VerdictBypassed, similarity
+	default:
+		return storage.VerdictUncertain, similarity
+	}
+}
+
+// Similarity returns how alike two bodies are, from 0 to 1, as the Dice
+// coefficient of their word multisets. Word order is ignored so that
+// reordered JSON keys or list items still compare as similar.
+func Similarity(a, b []byte) float64 {
+	if bytes.Equal(a, b) {
+		return 1
+	}
+
+	ta, tb := tokenCounts(a), tokenCounts(b)
+	na, nb := 0, 0
+	for _, n := range ta {
+		na += n
+	}
+	for _, n := range tb {
+		nb += n
+	}
+	if na+nb == 0 {
+		// both bodies are punctuation or whitespace only
+		return 0
+	}
+
+	shared := 0
+	for tok, n := range ta {
+		shared += min(n, tb[tok])
+	}
+	return 2 * float64(shared) / float64(na+nb)
+}
+
+func tokenCounts(body []byte) map[string]int {
+	counts := map[string]int{}
+	total := 0
+	for _, f := range bytes.FieldsFunc(body, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		counts[string(f)]++
+		if total++; total >= maxTokens {
+			break
+		}
+	}
+	return counts
+}
@@ -0,0 +1,95 @@
+package authz
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+func TestApply(t *testing.T) {
+	e := &storage.Entry{
+		Method: "GET",
+		Host:   "example.com",
+		Path:   "/account",
+		RequestHeaders: http.Header{
+			"Cookie":        {"session=admin"},
+			"Authorization": {"Bearer admin"},
+			"Accept":        {"*/*"},
+		},
+		StatusCode:   200,
+		ResponseBody: []byte("secret"),
+	}
+	id := &storage.Identity{
+		Name:    "user",
+		Headers: http.Header{"Cookie": {"session=user"}},
+		Remove:  []string{"authorization"},
+	}
+
+	req := Apply(id, e)
+	if got := req.RequestHeaders.Values("Cookie"); len(got) != 1 || got[0] != "session=user" {
+		t.Errorf("Cookie = %q, want replaced", got)
+	}
+	if req.RequestHeaders.Get("Authorization") != "" {
+		t.Error("Authorization not removed")
+	}
+	if req.RequestHeaders.Get("Accept") != "*/*" {
+		t.Error("unrelated header dropped")
+	}
+	if req.StatusCode != 0 || req.ResponseBody != nil {
+		t.Error("copy carries the original response")
+	}
+	if e.RequestHeaders.Get("Cookie") != "session=admin" || e.RequestHeaders.Get("Authorization") == "" {
+		t.Error("original entry modified")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	page := []byte(`{"user":"alice","email":"alice@example.com","role":"admin"}`)
+	tests := []struct {
+		name       string
+		origStatus int
+		status     int
+		body       []byte
+		want       string
+	}{
+		{"forbidden", 200, 403, []byte("forbidden"), storage.VerdictEnforced},
+		{"unauthorized", 200, 401, nil, storage.VerdictEnforced},
+		{"not found", 200, 404, []byte("not found"), storage.VerdictEnforced},
+		{"login redirect", 200, 302, nil, storage.VerdictEnforced},
+		{"same content", 200, 200, page, storage.VerdictBypassed},
+		{"reordered content", 200, 200, []byte(`{"role":"admin","user":"alice","email":"alice@example.com"}`), storage.VerdictBypassed},
+		{"different content", 200, 200, []byte(`{"user":"bob","email":"bob@example.com","role":"user"}`), storage.VerdictUncertain},
+		{"server error", 200, 500, page, storage.VerdictUncertain},
+		{"original failed", 403, 403, page, storage.VerdictUncertain},
+		{"same redirect", 302, 302, nil, storage.VerdictBypassed},
+	}
+	for _, tt := range tests {
+		orig := &storage.Entry{StatusCode: tt.origStatus, ResponseBody: page}
+		if tt.origStatus == 302 {
+			orig.ResponseBody = nil
+		}
+		replayed := &storage.Entry{StatusCode: tt.status, ResponseBody: tt.body}
+		if got, _ := Classify(orig, replayed); got != tt.want {
+			t.Errorf("%s: verdict = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if s := Similarity(nil, nil); s != 1 {
+		t.Errorf("empty bodies: %v, want 1", s)
+	}
+	if s := Similarity([]byte("a b c d"), []byte("d c b a")); s != 1 {
+		t.Errorf("reordered: %v, want 1", s)
+	}
+	if s := Similarity([]byte("a b c d"), []byte("a b x y")); s != 0.5 {
+		t.Errorf("half shared: %v, want 0.5", s)
+	}
+	if s := Similarity([]byte("a a b"), []byte("a b b")); s < 0.66 || s > 0.67 {
+		t.Errorf("repeated words: %v, want 2/3", s)
+	}
+	if s := Similarity([]byte("hello"), nil); s != 0 {
+		t.Errorf("one empty: %v, want 0", s)
+	}
+}
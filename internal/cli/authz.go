@@ -0,0 +1,362 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var authzCmd = &cobra.Command{
+	Use:   "authz",
+	Short: "Test access control by replaying requests as other identities",
+	Long: `Test access control by replaying captured requests with the credentials
+of other identities, such as a lower-privileged user or an anonymous
+visitor, and comparing each response with the original.
+
+Define identities with 'authz identity add', then replay selected entries
+with 'authz test' or turn on 'authz auto' to test in-scope traffic as it is
+proxied. Each replay is classified:
+
+  enforced   the identity was refused: 401, 403, another 4xx, or a
+             redirect the original request did not get
+  bypassed   same status and a near-identical body to the original
+  uncertain  anything else, e.g. a 5xx, different content, or an
+             original request that itself failed
+
+Replays are stored as entries linked to the original. 'authz report' shows
+the latest result per entry and identity.`,
+	Example: `  reaper authz identity add user -H 'Cookie: session=abc123'
+  reaper authz identity add anonymous --remove Cookie --remove Authorization
+  reaper authz test 42 43
+  reaper authz test --host api.example.com --identity user
+  reaper authz auto on
+  reaper authz report --verdict bypassed`,
+}
+
+var authzIdentityCmd = &cobra.Command{
+	Use:   "identity",
+	Short: "Manage the identities requests are replayed as",
+}
+
+var authzIdentityAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Create or replace an identity",
+	Long: `Create or replace an identity. Headers given with -H are set on replayed
+requests, replacing the original values; headers given with --remove are
+dropped. Session cookies and bearer tokens are the usual candidates.`,
+	Example: `  reaper authz identity add user -H 'Cookie: session=abc123'
+  reaper authz identity add api-user -H 'Authorization: Bearer eyJ...' --remove Cookie
+  reaper authz identity add anonymous --remove Cookie --remove Authorization`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runAuthzIdentityAdd,
+}
+
+var authzIdentityListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List identities",
+	SilenceUsage: true,
+	RunE:         runAuthzIdentityList,
+}
+
+var authzIdentityRemoveCmd = &cobra.Command{
+	Use:          "remove <name>",
+	Short:        "Remove an identity and its results",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runAuthzIdentityRemove,
+}
+
+var authzTestCmd = &cobra.Command{
+	Use:   "test [id...]",
+	Short: "Replay entries as each identity and classify the responses",
+	Long: `Replay entries as each identity and classify the responses. Select
+entries by ID or with filters; replays, fuzz requests and failed requests
+matched by filters are skipped. Only in-scope hosts can be tested.`,
+	Example: `  reaper authz test 42
+  reaper authz test --host api.example.com --method GET
+  reaper authz test --tag admin --identity user --identity anonymous`,
+	SilenceUsage: true,
+	RunE:         runAuthzTest,
+}
+
+var authzAutoCmd = &cobra.Command{
+	Use:   "auto [on|off|status]",
+	Short: "Test proxied traffic as every identity automatically",
+	Long: `Turn automatic testing on or off, or show its status. While on, every
+successful in-scope request the proxy captures is replayed as each
+identity. The setting lasts until the daemon stops.`,
+	Args:         cobra.MaximumNArgs(1),
+	ValidArgs:    []string{"on", "off", "status"},
+	SilenceUsage: true,
+	RunE:         runAuthzAuto,
+}
+
+var authzReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Show authz results as a matrix of entries and identities",
+	Example: `  reaper authz report
+  reaper authz report --verdict bypassed
+  reaper authz report --host api.example.com`,
+	SilenceUsage: true,
+	RunE:         runAuthzReport,
+}
+
+var (
+	authzHeaders    []string
+	authzRemove     []string
+	authzIdentities []string
+	authzLimit      int
+	authzVerdict    string
+	authzFilters    entryFilters
+	authzRepFilters entryFilters
+)
+
+func init() {
+	authzIdentityAddCmd.Flags().StringArrayVarP(&authzHeaders, "header", "H", nil, "Header to set, 'Name: value' (repeatable)")
+	authzIdentityAddCmd.Flags().StringArrayVar(&authzRemove, "remove", nil, "Header to remove (repeatable)")
+
+	authzTestCmd.Flags().StringArrayVarP(&authzIdentities, "identity", "i", nil, "Identity to test as (repeatable; default all)")
+	authzTestCmd.Flags().IntVarP(&authzLimit, "number", "n", 100, "Maximum entries to test when selecting by filter (0 for no limit)")
+	authzFilters.register(authzTestCmd)
+
+	authzReportCmd.Flags().StringVar(&authzVerdict, "verdict", "", "Only show entries with a result of this verdict: enforced, bypassed or uncertain")
+	authzRepFilters.register(authzReportCmd)
+
+	authzIdentityCmd.AddCommand(authzIdentityAddCmd, authzIdentityListCmd, authzIdentityRemoveCmd)
+	authzCmd.AddCommand(authzIdentityCmd, authzTestCmd, authzAutoCmd, authzReportCmd)
+	rootCmd.AddCommand(authzCmd)
+}
+
+func runAuthzIdentityAdd(cmd *cobra.Command, args []string) error {
+	_, err := sendCommand("authz-identity-add", daemon.IdentityParams{
+		Name:    args[0],
+		Headers: authzHeaders,
+		Remove:  authzRemove,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("saved identity %s\n", args[0])
+	return nil
+}
+
+func runAuthzIdentityList(cmd *cobra.Command, args []string) error {
+	data, err := sendCommand("authz-identity-list", nil)
+	if err != nil {
+		return err
+	}
+
+	var identities []storage.Identity
+	if err := json.Unmarshal(data, &identities); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	if len(identities) == 0 {
+		fmt.Println("no identities defined")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t\n", "NAME", "SETS", "REMOVES")
+	for _, id := range identities {
+		var sets []string
+		for name, values := range id.Headers {
+			for _, v := range values {
+				sets = append(sets, name+": "+truncate(v, 40))
+			}
+		}
+		sort.Strings(sets)
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", id.Name, orDash(strings.Join(sets, "; ")), orDash(strings.Join(id.Remove, ", ")))
+	}
+	w.Flush()
+	return nil
+}
+
+func runAuthzIdentityRemove(cmd *cobra.Command, args []string) error {
+	if _, err := sendCommand("authz-identity-remove", daemon.IdentityParams{Name: args[0]}); err != nil {
+		return err
+	}
+	fmt.Printf("removed identity %s\n", args[0])
+	return nil
+}
+
+func runAuthzTest(cmd *cobra.Command, args []string) error {
+	var ids []int64
+	switch {
+	case len(args) > 0 && authzFilters.isSet():
+		return fmt.Errorf("select entries by ID or by filter, not both")
+	case len(args) > 0:
+		for _, arg := range args {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid entry ID: %s", arg)
+			}
+			ids = append(ids, id)
+		}
+	case authzFilters.isSet():
+		entries, err := searchEntries(authzFilters.params(), authzLimit)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.ParentID == 0 && e.RunID == 0 && e.StatusCode < 400 {
+				ids = append(ids, e.ID)
+			}
+		}
+		if len(ids) == 0 {
+			fmt.Println("no entries to test")
+			return nil
+		}
+	default:
+		return fmt.Errorf("give entry IDs or filters to select entries")
+	}
+
+	counts := map[string]int{}
+	for _, id := range ids {
+		data, err := sendCommand("authz-test", daemon.AuthzTestParams{ID: id, Identities: authzIdentities})
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", id, err)
+		}
+
+		var results []storage.AuthzResult
+		if err := json.Unmarshal(data, &results); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+
+		cells := make([]string, len(results))
+		for i, r := range results {
+			cells[i] = r.Identity + " " + authzCell(&r)
+			counts[r.Verdict]++
+		}
+		fmt.Printf("%d\t%s\n", id, strings.Join(cells, "  "))
+	}
+
+	fmt.Printf("\n%d entries: %s\n", len(ids), verdictSummary(counts))
+	return nil
+}
+
+func runAuthzAuto(cmd *cobra.Command, args []string) error {
+	var p daemon.AuthzAutoParams
+	if len(args) > 0 {
+		switch args[0] {
+		case "on", "off":
+			enable := args[0] == "on"
+			p.Enable = &enable
+		case "status":
+		default:
+			return fmt.Errorf("want on, off or status, got %q", args[0])
+		}
+	}
+
+	data, err := sendCommand("authz-auto", p)
+	if err != nil {
+		return err
+	}
+	var result daemon.AuthzAutoResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	state := "off"
+	if result.Enabled {
+		state = "on"
+	}
+	fmt.Printf("automatic authz testing is %s (%d identities, %d queued)\n", state, result.Identities, result.Queued)
+	if result.Enabled && result.Identities == 0 {
+		fmt.Println("no identities defined; add one with 'reaper authz identity add'")
+	}
+	return nil
+}
+
+func runAuthzReport(cmd *cobra.Command, args []string) error {
+	switch authzVerdict {
+	case "", storage.VerdictEnforced, storage.VerdictBypassed, storage.VerdictUncertain:
+	default:
+		return fmt.Errorf("unknown verdict %q: want enforced, bypassed or uncertain", authzVerdict)
+	}
+
+	data, err := sendCommand("authz-report", daemon.AuthzReportParams{
+		Filter:  authzRepFilters.params(),
+		Verdict: authzVerdict,
+	})
+	if err != nil {
+		return err
+	}
+
+	var results []*storage.AuthzResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	if len(results) == 0 {
+		fmt.Println("no authz results found")
+		return nil
+	}
+
+	// Results arrive ordered by entry; one row per entry, one column per
+	// identity.
+	var identities []string
+	var rows [][]*storage.AuthzResult
+	counts := map[string]int{}
+	for _, r := range results {
+		if !slices.Contains(identities, r.Identity) {
+			identities = append(identities, r.Identity)
+		}
+		if n := len(rows); n > 0 && rows[n-1][0].EntryID == r.EntryID {
+			rows[n-1] = append(rows[n-1], r)
+		} else {
+			rows = append(rows, []*storage.AuthzResult{r})
+		}
+		counts[r.Verdict]++
+	}
+	sort.Strings(identities)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t", "ID", "METHOD", "URL", "ORIG")
+	for _, name := range identities {
+		fmt.Fprintf(w, "%s\t", strings.ToUpper(name))
+	}
+	fmt.Fprintln(w)
+
+	for _, row := range rows {
+		first := row[0]
+		url := truncate(first.Host+first.Path, 60)
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t", first.EntryID, first.Method, url, first.OriginalStatus)
+		for _, name := range identities {
+			cell := "-"
+			if i := slices.IndexFunc(row, func(r *storage.AuthzResult) bool { return r.Identity == name }); i >= 0 {
+				cell = authzCell(row[i])
+			}
+			fmt.Fprintf(w, "%s\t", cell)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d entries: %s\n", len(rows), verdictSummary(counts))
+	return nil
+}
+
+// authzCell describes a result briefly, e.g. "bypassed 200".
+func authzCell(r *storage.AuthzResult) string {
+	if r.Error != "" {
+		return r.Verdict + " (error)"
+	}
+	return fmt.Sprintf("%s %d", r.Verdict, r.Status)
+}
+
+func verdictSummary(counts map[string]int) string {
+	return fmt.Sprintf("%d bypassed, %d uncertain, %d enforced",
+		counts[storage.VerdictBypassed], counts[storage.VerdictUncertain], counts[storage.VerdictEnforced])
+}
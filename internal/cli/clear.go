@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -10,29 +11,43 @@ import (
 
 var clearCmd = &cobra.Command{
 	Use:   "clear",
-	Short: "Clear all proxy log entries",
-	RunE:  runClear,
+	Short: "Clear proxy log entries, optionally only those matching filters",
+	Example: `  reaper clear
+  reaper clear --host api.example.com --path /health
+  reaper clear --domains telemetry.example.com --dry-run`,
+	SilenceUsage: true,
+	RunE:         runClear,
 }
 
+var (
+	clearFilters entryFilters
+	clearDryRun  bool
+)
+
 func init() {
+	clearFilters.register(clearCmd)
+	clearCmd.Flags().BoolVar(&clearDryRun, "dry-run", false, "Show how many entries would be deleted without deleting them")
 	rootCmd.AddCommand(clearCmd)
 }
 
 func runClear(cmd *cobra.Command, args []string) error {
-	dataDir, err := daemon.DataDir()
+	data, err := sendCommand("clear", daemon.ClearParams{Filter: clearFilters.params(), DryRun: clearDryRun})
 	if err != nil {
 		return err
 	}
 
-	client := daemon.NewClient(dataDir)
-	resp, err := client.Send(daemon.Request{Command: "clear"})
-	if err != nil {
-		return fmt.Errorf("no running daemon found: %w", err)
-	}
-	if !resp.OK {
-		return fmt.Errorf("clear failed: %s", resp.Error)
+	var result daemon.ClearResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
 	}
 
-	fmt.Println("all entries cleared")
+	switch {
+	case result.DryRun:
+		fmt.Printf("would delete %d entries\n", result.Deleted)
+	case !clearFilters.isSet():
+		fmt.Println("all entries cleared")
+	default:
+		fmt.Printf("deleted %d entries\n", result.Deleted)
+	}
 	return nil
 }
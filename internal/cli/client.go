@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+)
+
+// sendCommand sends a command to the running daemon and returns the
+// response data. params may be nil. The error wraps daemon.ErrNoDaemon if
+// the daemon cannot be reached.
+func sendCommand(command string, params any) (json.RawMessage, error) {
+	dataDir, err := daemon.DataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if params != nil {
+		if raw, err = json.Marshal(params); err != nil {
+			return nil, fmt.Errorf("encoding params: %w", err)
+		}
+	}
+
+	resp, err := daemon.NewClient(dataDir).Send(daemon.Request{Command: command, Params: raw})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+	return resp.Data, nil
+}
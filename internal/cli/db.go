@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect and maintain the traffic database",
+	Long: `Inspect and maintain the traffic database.
+
+These commands open the database file directly and work whether or not the
+daemon is running. The daemon applies pending migrations when it starts.`,
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:          "migrate",
+	Short:        "Apply pending schema migrations",
+	SilenceUsage: true,
+	RunE:         runDBMigrate,
+}
+
+var dbVersionCmd = &cobra.Command{
+	Use:          "version",
+	Short:        "Show the database schema version",
+	SilenceUsage: true,
+	RunE:         runDBVersion,
+}
+
+var dbBackupCmd = &cobra.Command{
+	Use:          "backup [dest]",
+	Short:        "Write a consistent copy of the database",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE:         runDBBackup,
+}
+
+var dbPath string
+
+func init() {
+	dbCmd.PersistentFlags().StringVar(&dbPath, "db", "", "Database file (default: reaper.db in the data directory)")
+
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbVersionCmd)
+	dbCmd.AddCommand(dbBackupCmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+// openDB opens the database selected by --db without migrating it.
+func openDB() (*storage.SQLiteStore, error) {
+	path := dbPath
+	if path == "" {
+		dataDir, err := daemon.DataDir()
+		if err != nil {
+			return nil, err
+		}
+		path = daemon.DBPath(dataDir)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no database at %s", path)
+	}
+	return storage.OpenSQLiteStore(path)
+}
+
+func runDBMigrate(cmd *cobra.Command, args []string) error {
+	store, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	applied, err := store.Migrate()
+	for _, name := range applied {
+		fmt.Printf("applied: %s\n", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("database is at version %d\n", storage.SchemaVersion)
+	return nil
+}
+
+func runDBVersion(cmd *cobra.Command, args []string) error {
+	store, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("schema version: %d\n", version)
+	fmt.Printf("supported version: %d\n", storage.SchemaVersion)
+	if store.Encrypted() {
+		fmt.Println("encryption: on")
+	}
+	if pending := storage.SchemaVersion - version; pending > 0 {
+		fmt.Printf("pending migrations: %d (run 'reaper db migrate')\n", pending)
+	}
+	return nil
+}
+
+func runDBBackup(cmd *cobra.Command, args []string) error {
+	store, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var dest string
+	if len(args) > 0 {
+		dest = args[0]
+		if _, err := os.Stat(dest); err == nil {
+			return fmt.Errorf("%s already exists", dest)
+		}
+	}
+
+	path, err := store.Backup(dest)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("backed up to %s\n", path)
+	return nil
+}
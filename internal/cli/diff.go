@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/diff"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <id1> <id2>",
+	Short: "Compare the responses, or requests, of two entries",
+	Long: `Compare the responses of two entries, or their requests with --req.
+
+Headers are compared as sets, so reordered or repeated headers only show
+when their values differ. JSON bodies are compared structurally, ignoring
+formatting and key order, and differences are listed by path, e.g.
+user.roles[0]. Other text bodies get a unified line diff.
+
+Values that change between otherwise identical exchanges can be ignored:
+--ignore-header and --ignore-field name them explicitly (fields by key,
+path, or path with [] for any index), and --ignore-volatile ignores common
+date, timing and request-ID headers and masks timestamps, UUIDs, long hex
+tokens and epoch times in bodies.`,
+	Example: `  reaper diff 42 43
+  reaper diff 42 43 --req
+  reaper diff 42 43 --ignore-volatile
+  reaper diff 42 43 --ignore-header Set-Cookie --ignore-field csrf --ignore-field items[].updated_at`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE:         runDiff,
+}
+
+var (
+	diffReq           bool
+	diffRes           bool
+	diffIgnoreHeaders []string
+	diffIgnoreFields  []string
+	diffVolatile      bool
+	diffContext       int
+)
+
+func init() {
+	diffCmd.Flags().BoolVar(&diffReq, "req", false, "Compare the requests")
+	diffCmd.Flags().BoolVar(&diffRes, "res", false, "Compare the responses (default)")
+	diffCmd.Flags().StringSliceVar(&diffIgnoreHeaders, "ignore-header", nil, "Header to ignore (repeatable)")
+	diffCmd.Flags().StringSliceVar(&diffIgnoreFields, "ignore-field", nil, "JSON key or path to ignore (repeatable)")
+	diffCmd.Flags().BoolVarP(&diffVolatile, "ignore-volatile", "V", false, "Ignore dates, request IDs and similar values that change on every request")
+	diffCmd.Flags().IntVarP(&diffContext, "context", "U", 3, "Lines of context around line changes")
+	diffCmd.MarkFlagsMutuallyExclusive("req", "res")
+	rootCmd.AddCommand(diffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	if diffContext < 0 {
+		return fmt.Errorf("invalid context %d: must be zero or more", diffContext)
+	}
+
+	var entries [2]*storage.Entry
+	for i, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid entry ID: %s", arg)
+		}
+		data, err := sendCommand("get", daemon.GetParams{ID: id})
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", id, err)
+		}
+		var result struct {
+			Entry *storage.Entry `json:"entry"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+		entries[i] = result.Entry
+	}
+	a, b := entries[0], entries[1]
+
+	opts := diff.Options{
+		IgnoreHeaders: diffIgnoreHeaders,
+		IgnoreFields:  diffIgnoreFields,
+		Volatile:      diffVolatile,
+	}
+
+	var r *diff.Result
+	var first []string
+	if diffReq {
+		r = diff.Compare(a.RequestHeaders, b.RequestHeaders, a.RequestBody, b.RequestBody, opts)
+		if a.Method != b.Method || a.URL() != b.URL() {
+			first = append(first, fmt.Sprintf("request: %s %s → %s %s", a.Method, a.URL(), b.Method, b.URL()))
+		}
+	} else {
+		r = diff.Compare(a.ResponseHeaders, b.ResponseHeaders, a.ResponseBody, b.ResponseBody, opts)
+		if a.StatusCode != b.StatusCode {
+			first = append(first, fmt.Sprintf("status: %d → %d", a.StatusCode, b.StatusCode))
+		}
+	}
+
+	fmt.Printf("--- #%d %s %s  %d  %s\n", a.ID, a.Method, a.URL(), a.StatusCode, formatSize(r.BodyA))
+	fmt.Printf("+++ #%d %s %s  %d  %s\n", b.ID, b.Method, b.URL(), b.StatusCode, formatSize(r.BodyB))
+
+	if len(first) == 0 && r.Equal() {
+		fmt.Println("\nno differences")
+		return nil
+	}
+	for _, line := range first {
+		fmt.Printf("\n%s\n", line)
+	}
+
+	if len(r.Headers) > 0 {
+		fmt.Println("\nheaders:")
+		for _, c := range r.Headers {
+			switch c.Kind {
+			case diff.Added:
+				fmt.Printf("  + %s: %s\n", c.Name, strings.Join(c.New, ", "))
+			case diff.Removed:
+				fmt.Printf("  - %s: %s\n", c.Name, strings.Join(c.Old, ", "))
+			default:
+				fmt.Printf("  ~ %s: %s → %s\n", c.Name, strings.Join(c.Old, ", "), strings.Join(c.New, ", "))
+			}
+		}
+	}
+
+	if !r.BodyChanged() {
+		return nil
+	}
+	switch {
+	case r.JSON:
+		fmt.Println("\nbody (JSON):")
+		for _, f := range r.Fields {
+			switch f.Kind {
+			case diff.Added:
+				fmt.Printf("  + %s: %s\n", f.Path, truncate(f.New, 120))
+			case diff.Removed:
+				fmt.Printf("  - %s: %s\n", f.Path, truncate(f.Old, 120))
+			default:
+				fmt.Printf("  ~ %s: %s → %s\n", f.Path, truncate(f.Old, 60), truncate(f.New, 60))
+			}
+		}
+	case r.Binary:
+		fmt.Printf("\nbody: binary bodies differ (%s → %s)\n", formatSize(r.BodyA), formatSize(r.BodyB))
+	default:
+		fmt.Println("\nbody:")
+		for _, h := range diff.Hunks(r.Lines, diffContext) {
+			fmt.Printf("@@ -%d,%d +%d,%d @@\n", h.StartA, h.LenA, h.StartB, h.LenB)
+			for _, l := range h.Lines {
+				fmt.Printf("%c%s\n", l.Op, l.Text)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var endpointsCmd = &cobra.Command{
+	Use:   "endpoints",
+	Short: "List unique endpoints seen, with ID-like path segments templated",
+	Long: `List the unique endpoints in the proxy log, grouped per host. Path
+segments that look like identifiers are collapsed into placeholders:
+{id} (numeric), {uuid}, {hash} (long hex), {date}, {email} and {token}
+(long mixed alphanumeric), so /users/42 and /users/43 are one endpoint.`,
+	Example: `  reaper endpoints
+  reaper endpoints --host api.example.com
+  reaper endpoints --domains example.com --status 200`,
+	SilenceUsage: true,
+	RunE:         runEndpoints,
+}
+
+var endpointsFilters entryFilters
+
+func init() {
+	endpointsFilters.register(endpointsCmd)
+	rootCmd.AddCommand(endpointsCmd)
+}
+
+func runEndpoints(cmd *cobra.Command, args []string) error {
+	data, err := sendCommand("endpoints", daemon.EndpointsParams{Filter: endpointsFilters.params()})
+	if err != nil {
+		return err
+	}
+
+	var endpoints []storage.Endpoint
+	if err := json.Unmarshal(data, &endpoints); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	if len(endpoints) == 0 {
+		fmt.Println("no endpoints found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+		"HOST", "METHODS", "ENDPOINT", "STATUS", pad("COUNT", 5), "FIRST SEEN", "LAST SEEN", "EXAMPLES")
+
+	for _, ep := range endpoints {
+		statuses := make([]string, len(ep.Statuses))
+		for i, s := range ep.Statuses {
+			statuses[i] = strconv.Itoa(s)
+		}
+		examples := make([]string, len(ep.ExampleIDs))
+		for i, id := range ep.ExampleIDs {
+			examples[i] = strconv.FormatInt(id, 10)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%5d\t%s\t%s\t%s\t\n",
+			ep.Host, strings.Join(ep.Methods, ","), ep.Template, strings.Join(statuses, ","), ep.Count,
+			ep.FirstSeen.Local().Format("2006-01-02 15:04:05"), ep.LastSeen.Local().Format("2006-01-02 15:04:05"),
+			strings.Join(examples, ","))
+	}
+
+	w.Flush()
+	fmt.Printf("\n%d endpoints\n", len(endpoints))
+	return nil
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/exchange"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export entries as Burp Suite XML or raw .req/.res files",
+	Long: `Export entries as Burp Suite "save items" XML or as a directory of raw
+<id>.req and <id>.res files, usable with sqlmap -r and ffuf -request.
+
+The format is inferred from the output path when --format is not given:
+paths ending in .xml are written as Burp XML, anything else as a directory.`,
+	Example: `  reaper export -o items.xml --host api.example.com
+  reaper export --format raw -o ./requests --path /api/users`,
+	SilenceUsage: true,
+	RunE:         runExport,
+}
+
+var (
+	exportFilters entryFilters
+	exportFormat  string
+	exportOutput  string
+	exportLimit   int
+)
+
+func init() {
+	exportFilters.register(exportCmd)
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "Output format: burp or raw")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Output file (burp) or directory (raw)")
+	exportCmd.Flags().IntVarP(&exportLimit, "limit", "n", 0, "Max entries to export (0 for all)")
+	_ = exportCmd.MarkFlagRequired("output")
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	format, err := exchangeFormat(exportFormat, exportOutput)
+	if err != nil {
+		return err
+	}
+
+	entries, err := searchEntries(exportFilters.params(), exportLimit)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no entries found")
+	}
+
+	switch format {
+	case "burp":
+		f, err := os.OpenFile(exportOutput, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		if err := exchange.WriteBurp(f, entries); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	case "raw":
+		if err := exchange.WriteRawDir(exportOutput, entries); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("exported %d entries to %s\n", len(entries), exportOutput)
+	return nil
+}
+
+// exchangeFormat validates format, inferring it from path when empty.
+func exchangeFormat(format, path string) (string, error) {
+	switch format {
+	case "burp", "raw":
+		return format, nil
+	case "":
+		if strings.HasSuffix(strings.ToLower(path), ".xml") {
+			return "burp", nil
+		}
+		return "raw", nil
+	default:
+		return "", fmt.Errorf("unknown format %q (want burp or raw)", format)
+	}
+}
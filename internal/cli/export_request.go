@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/snippet"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var exportRequestCmd = &cobra.Command{
+	Use:   "export-request <id>",
+	Short: "Print an entry's request as a command or program that sends it",
+	Long: `Print the request of an entry as a curl or HTTPie command, or as a
+Python requests, Go, JavaScript fetch or PowerShell program, to hand to a
+developer reproducing a finding.
+
+The URL keeps a non-default port. Headers describing the original
+connection (Host, Content-Length, Transfer-Encoding, Connection and
+Accept-Encoding) are left to the client. Bodies are reproduced byte for
+byte: binary ones are written as escaped bytes rather than text.
+
+Formats: ` + strings.Join(snippet.Formats, ", "),
+	Example: `  reaper export-request 42
+  reaper export-request 42 --as python-requests > repro.py
+  reaper export-request 42 --as go > main.go`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runExportRequest,
+}
+
+var exportRequestAs string
+
+func init() {
+	exportRequestCmd.Flags().StringVar(&exportRequestAs, "as", snippet.Curl, "Format: "+strings.Join(snippet.Formats, "|"))
+	rootCmd.AddCommand(exportRequestCmd)
+}
+
+func runExportRequest(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid entry ID: %s", args[0])
+	}
+	data, err := sendCommand("get", daemon.GetParams{ID: id})
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Entry *storage.Entry `json:"entry"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	out, err := snippet.Generate(result.Entry, exportRequestAs)
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// entryFilters holds the entry selection flags shared by commands that
+// operate on a subset of stored entries.
+type entryFilters struct {
+	method   string
+	host     string
+	domains  []string
+	path     string
+	status   int
+	tag      string
+	contains string
+}
+
+func (f *entryFilters) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.method, "method", "", "Filter by HTTP method")
+	cmd.Flags().StringVar(&f.host, "host", "", "Filter by host (supports * wildcard)")
+	cmd.Flags().StringSliceVar(&f.domains, "domains", nil, "Filter by domain suffix")
+	cmd.Flags().StringVar(&f.path, "path", "", "Filter by path prefix or glob")
+	cmd.Flags().IntVar(&f.status, "status", 0, "Filter by status code")
+	cmd.Flags().StringVar(&f.tag, "tag", "", "Filter by tag")
+	cmd.Flags().StringVar(&f.contains, "contains", "", "Filter by text in headers or bodies (case-sensitive)")
+}
+
+// isSet reports whether any filter was given.
+func (f *entryFilters) isSet() bool {
+	return f.method != "" || f.host != "" || len(f.domains) > 0 || f.path != "" || f.status != 0 || f.tag != "" || f.contains != ""
+}
+
+func (f *entryFilters) params() daemon.SearchRequestParams {
+	return daemon.SearchRequestParams{
+		Method:   f.method,
+		Host:     f.host,
+		Domains:  f.domains,
+		Path:     f.path,
+		Status:   f.status,
+		Tag:      f.tag,
+		Contains: f.contains,
+	}
+}
+
+// searchPageSize bounds each search response so that entries with large
+// bodies stay within the IPC message limit.
+const searchPageSize = 20
+
+// searchEntries pages through all entries matching p, up to limit (0 for no
+// limit), and returns them oldest first.
+func searchEntries(p daemon.SearchRequestParams, limit int) ([]*storage.Entry, error) {
+	var all []*storage.Entry
+	for {
+		p.Limit = searchPageSize
+		if limit > 0 && limit-len(all) < p.Limit {
+			p.Limit = limit - len(all)
+		}
+		p.Offset = len(all)
+
+		data, err := sendCommand("search", p)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []*storage.Entry
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("decoding response: %w", err)
+		}
+		all = append(all, page...)
+
+		if len(page) < p.Limit || (limit > 0 && len(all) >= limit) {
+			break
+		}
+	}
+
+	// search returns newest first
+	slices.Reverse(all)
+	return all, nil
+}
@@ -0,0 +1,413 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/exchange"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var findingsCmd = &cobra.Command{
+	Use:   "findings",
+	Short: "Review, triage and export security findings",
+	Long: `Review, triage and export the issues found in traffic, by the passive
+scanner or recorded by hand.
+
+Every in-scope entry is checked in the background as it is saved, without
+slowing the proxy or sending requests. The built-in checks report:
+
+  security-headers  missing or weak HSTS, CSP, framing protection and
+                    X-Content-Type-Options
+  cookie-flags      cookies without Secure, HttpOnly or SameSite
+  cors              null, reflected, HTTP and wildcard allowed origins
+  verbose-errors    stack traces, debug pages and database errors
+  mixed-content     HTTPS pages loading or submitting over HTTP
+  reflection        request parameters echoed in the response, with the
+                    context they land in: script block, HTML attribute,
+                    HTML text, header, JSON string or text; candidates
+                    for XSS, header injection and open redirect testing
+  secrets           API keys, cloud credentials, private keys and tokens
+                    in request URLs, response headers and bodies; rules
+                    can be added or overridden with start --secret-rules
+
+An issue seen in many entries is one finding, linked to each entry it was
+seen in. A secret is one finding across all hosts; its evidence shows where
+it was found, redacted. Findings recorded with 'findings add' have the
+check name manual.
+
+Each finding has a triage status: new, confirmed, false-positive or fixed.
+A fixed finding the scanner sees again goes back to new.`,
+	Example: `  reaper findings list --severity medium
+  reaper findings show 12
+  reaper findings set-status 12 confirmed --note "reported as SEC-481"
+  reaper findings add 42 --title "IDOR on /api/orders" --severity high
+  reaper findings export -o findings.sarif`,
+}
+
+var findingsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List findings, most severe first",
+	Long: `List findings, most severe first. ENTRY is the entry a finding was first
+seen in and HITS counts the entries it was seen in.`,
+	Example: `  reaper findings list
+  reaper findings list --severity medium --status new
+  reaper findings list --check cors --host "*.example.com" --evidence`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runFindingsList,
+}
+
+var findingsShowCmd = &cobra.Command{
+	Use:          "show <id>",
+	Short:        "Show a finding with its evidence, note and linked entries",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runFindingsShow,
+}
+
+var findingsSetStatusCmd = &cobra.Command{
+	Use:   "set-status <id>... <status>",
+	Short: "Set the triage status of findings",
+	Long: `Set the triage status of one or more findings: new, confirmed,
+false-positive or fixed. --note replaces the note on each; --note ""
+clears it.`,
+	Example: `  reaper findings set-status 12 confirmed
+  reaper findings set-status 3 4 5 false-positive --note "static CDN, no cookies"`,
+	Args:         cobra.MinimumNArgs(2),
+	SilenceUsage: true,
+	RunE:         runFindingsSetStatus,
+}
+
+var findingsAddCmd = &cobra.Command{
+	Use:   "add <entry-id>...",
+	Short: "Record a finding by hand against one or more entries",
+	Long: `Record a finding by hand, linked to the given entries. Its host is the
+host of the first entry. Evidence can be given several times, as text or as
+@file, and is stored one snippet per line.`,
+	Example: `  reaper findings add 42 --title "IDOR on /api/orders" --severity high
+  reaper findings add 42 57 --title "SQL injection in search" --severity critical \
+    --evidence "q=' returns a PostgreSQL syntax error" --evidence @sqlmap.txt --status confirmed`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE:         runFindingsAdd,
+}
+
+var findingsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export findings as SARIF or JSON",
+	Long: `Export findings as SARIF 2.1.0 or as a JSON array, to a file or to
+standard output. The format is inferred from the output path when --format
+is not given: .sarif is SARIF, anything else JSON.
+
+In SARIF each check is a rule and each finding a result located at the URL
+of its first entry, with the reaper fingerprint as a partial fingerprint.
+False positives are marked as suppressed; status, confidence, host and
+entry IDs are result properties.`,
+	Example: `  reaper findings export -o findings.sarif
+  reaper findings export --format json --severity medium --status confirmed
+  reaper findings export --format sarif | jq '.runs[0].results | length'`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runFindingsExport,
+}
+
+var (
+	findingsCheck    string
+	findingsHost     string
+	findingsSeverity string
+	findingsStatus   string
+	findingsLimit    int
+	findingsEvidence bool
+	findingsNote     string
+	findingsFormat   string
+	findingsOutput   string
+
+	// findings add; separate from the filters, which default to empty
+	findingsAddTitle      string
+	findingsAddSeverity   string
+	findingsAddConfidence string
+	findingsAddStatus     string
+	findingsAddEvidence   []string
+	findingsAddNote       string
+)
+
+// registerFindingFilters adds the flags selecting findings to cmd.
+func registerFindingFilters(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&findingsCheck, "check", "", "Only findings of this check")
+	cmd.Flags().StringVar(&findingsHost, "host", "", "Only findings on this host (supports *.domain.com)")
+	cmd.Flags().StringVarP(&findingsSeverity, "severity", "s", "", "Minimum severity: "+strings.Join(storage.Severities, ", "))
+	cmd.Flags().StringVar(&findingsStatus, "status", "", "Only findings with this status: "+strings.Join(storage.FindingStatuses, ", "))
+	cmd.Flags().IntVarP(&findingsLimit, "limit", "n", 0, "Maximum number of findings")
+}
+
+func init() {
+	registerFindingFilters(findingsListCmd)
+	findingsListCmd.Flags().BoolVarP(&findingsEvidence, "evidence", "e", false, "Show evidence")
+
+	findingsSetStatusCmd.Flags().StringVar(&findingsNote, "note", "", "Replace the note")
+
+	findingsAddCmd.Flags().StringVar(&findingsAddTitle, "title", "", "Title (required)")
+	findingsAddCmd.Flags().StringVarP(&findingsAddSeverity, "severity", "s", storage.SeverityMedium, "Severity: "+strings.Join(storage.Severities, ", "))
+	findingsAddCmd.Flags().StringVar(&findingsAddConfidence, "confidence", storage.ConfidenceCertain, "Confidence: tentative, firm or certain")
+	findingsAddCmd.Flags().StringVar(&findingsAddStatus, "status", storage.StatusNew, "Status: "+strings.Join(storage.FindingStatuses, ", "))
+	findingsAddCmd.Flags().StringArrayVar(&findingsAddEvidence, "evidence", nil, "Evidence snippet, or @file (repeatable)")
+	findingsAddCmd.Flags().StringVar(&findingsAddNote, "note", "", "Note")
+	_ = findingsAddCmd.MarkFlagRequired("title")
+
+	registerFindingFilters(findingsExportCmd)
+	findingsExportCmd.Flags().StringVar(&findingsFormat, "format", "", "Output format: sarif or json")
+	findingsExportCmd.Flags().StringVarP(&findingsOutput, "output", "o", "", "Output file (default standard output)")
+
+	findingsCmd.AddCommand(findingsListCmd, findingsShowCmd, findingsSetStatusCmd, findingsAddCmd, findingsExportCmd)
+	rootCmd.AddCommand(findingsCmd)
+}
+
+// queryFindings fetches the findings selected by the filter flags.
+func queryFindings() ([]*storage.Finding, error) {
+	if findingsSeverity != "" && !slices.Contains(storage.Severities, findingsSeverity) {
+		return nil, fmt.Errorf("invalid severity %q: want one of %s", findingsSeverity, strings.Join(storage.Severities, ", "))
+	}
+	if findingsStatus != "" && !slices.Contains(storage.FindingStatuses, findingsStatus) {
+		return nil, fmt.Errorf("invalid status %q: want one of %s", findingsStatus, strings.Join(storage.FindingStatuses, ", "))
+	}
+
+	data, err := sendCommand("findings", daemon.FindingsParams{
+		Check:    findingsCheck,
+		Host:     findingsHost,
+		Severity: findingsSeverity,
+		Status:   findingsStatus,
+		Limit:    findingsLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []*storage.Finding
+	if err := json.Unmarshal(data, &findings); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return findings, nil
+}
+
+func runFindingsList(cmd *cobra.Command, args []string) error {
+	findings, err := queryFindings()
+	if err != nil {
+		return err
+	}
+
+	if len(findings) == 0 {
+		fmt.Println("no findings")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := []string{pad("ID", 5), "SEVERITY", "CONFIDENCE", "STATUS", "CHECK", "HOST", "TITLE", pad("HITS", 4), pad("ENTRY", 5)}
+	if findingsEvidence {
+		header = append(header, "EVIDENCE")
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+
+	counts := map[string]int{}
+	for _, f := range findings {
+		counts[f.Severity]++
+		fmt.Fprintf(w, "%5d\t%s\t%s\t%s\t%s\t%s\t%s\t%4d\t%5d\t",
+			f.ID, f.Severity, f.Confidence, f.Status, f.Check, orDash(f.Host), f.Title, f.Hits, f.EntryID)
+		if findingsEvidence {
+			fmt.Fprintf(w, "%s\t", truncate(strings.ReplaceAll(f.Evidence, "\n", " "), 100))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	var summary []string
+	for _, sev := range slices.Backward(storage.Severities) {
+		if counts[sev] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[sev], sev))
+		}
+	}
+	fmt.Printf("\n%d findings: %s\n", len(findings), strings.Join(summary, ", "))
+	return nil
+}
+
+func runFindingsShow(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid finding ID: %s", args[0])
+	}
+	data, err := sendCommand("findings-get", daemon.GetParams{ID: id})
+	if err != nil {
+		return err
+	}
+	var f storage.Finding
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	fmt.Printf("#%d %s\n\n", f.ID, f.Title)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "severity:\t%s\n", f.Severity)
+	fmt.Fprintf(w, "confidence:\t%s\n", f.Confidence)
+	fmt.Fprintf(w, "status:\t%s\n", f.Status)
+	fmt.Fprintf(w, "check:\t%s\n", f.Check)
+	fmt.Fprintf(w, "host:\t%s\n", orDash(f.Host))
+	if f.URL != "" {
+		fmt.Fprintf(w, "request:\t%s %s\n", f.Method, f.URL)
+	}
+	fmt.Fprintf(w, "first seen:\t%s\n", f.FirstSeen.Local().Format(time.DateTime))
+	fmt.Fprintf(w, "last seen:\t%s\n", f.LastSeen.Local().Format(time.DateTime))
+	fmt.Fprintf(w, "hits:\t%d\n", f.Hits)
+	fmt.Fprintf(w, "entries:\t%s\n", orDash(formatIDs(f.EntryIDs, 20)))
+	w.Flush()
+
+	if f.Evidence != "" {
+		fmt.Printf("\nevidence:\n")
+		for _, line := range strings.Split(f.Evidence, "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	if f.Note != "" {
+		fmt.Printf("\nnote:\n")
+		for _, line := range strings.Split(f.Note, "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	return nil
+}
+
+// formatIDs lists ids, showing at most n and a count of the rest.
+func formatIDs(ids []int64, n int) string {
+	var parts []string
+	for _, id := range ids[:min(n, len(ids))] {
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+	s := strings.Join(parts, ", ")
+	if len(ids) > n {
+		s += fmt.Sprintf(" and %d more", len(ids)-n)
+	}
+	return s
+}
+
+func runFindingsSetStatus(cmd *cobra.Command, args []string) error {
+	status := args[len(args)-1]
+	if !slices.Contains(storage.FindingStatuses, status) {
+		return fmt.Errorf("invalid status %q: want one of %s", status, strings.Join(storage.FindingStatuses, ", "))
+	}
+	ids, err := parseIDs(args[:len(args)-1], "finding")
+	if err != nil {
+		return err
+	}
+
+	_, err = sendCommand("findings-set-status", daemon.FindingStatusParams{
+		IDs:     ids,
+		Status:  status,
+		Note:    findingsNote,
+		SetNote: cmd.Flags().Changed("note"),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("marked %d findings %s\n", len(ids), status)
+	return nil
+}
+
+// parseIDs parses args as the IDs of kind, e.g. "entry".
+func parseIDs(args []string, kind string) ([]int64, error) {
+	ids := make([]int64, len(args))
+	for i, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s ID: %s", kind, arg)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func runFindingsAdd(cmd *cobra.Command, args []string) error {
+	ids, err := parseIDs(args, "entry")
+	if err != nil {
+		return err
+	}
+
+	var evidence []string
+	for _, arg := range findingsAddEvidence {
+		snippet, err := readBodyArg(arg)
+		if err != nil {
+			return err
+		}
+		evidence = append(evidence, strings.TrimRight(string(snippet), "\n"))
+	}
+
+	data, err := sendCommand("findings-add", daemon.FindingAddParams{
+		EntryIDs:   ids,
+		Title:      findingsAddTitle,
+		Severity:   findingsAddSeverity,
+		Confidence: findingsAddConfidence,
+		Status:     findingsAddStatus,
+		Evidence:   strings.Join(evidence, "\n"),
+		Note:       findingsAddNote,
+	})
+	if err != nil {
+		return err
+	}
+	var f storage.Finding
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	fmt.Printf("added finding #%d\n", f.ID)
+	return nil
+}
+
+func runFindingsExport(cmd *cobra.Command, args []string) error {
+	format := findingsFormat
+	if format == "" {
+		format = "json"
+		if strings.HasSuffix(strings.ToLower(findingsOutput), ".sarif") {
+			format = "sarif"
+		}
+	}
+	if format != "sarif" && format != "json" {
+		return fmt.Errorf("invalid format %q: want sarif or json", format)
+	}
+
+	findings, err := queryFindings()
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if findingsOutput != "" && findingsOutput != "-" {
+		f, err := os.OpenFile(findingsOutput, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if format == "sarif" {
+		err = exchange.WriteSARIF(out, findings)
+	} else {
+		err = exchange.WriteFindingsJSON(out, findings)
+	}
+	if err != nil {
+		return err
+	}
+	if out != os.Stdout {
+		if err := out.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "exported %d findings to %s\n", len(findings), findingsOutput)
+	}
+	return nil
+}
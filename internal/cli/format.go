@@ -2,29 +2,41 @@ package cli
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 	"time"
+	"unicode/utf8"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
 )
 
 // entryRow is a subset of storage.Entry used for table display (deserialized from JSON).
 type entryRow struct {
-	ID         int64       `json:"ID"`
-	Method     string      `json:"Method"`
-	Scheme     string      `json:"Scheme"`
-	Host       string      `json:"Host"`
-	Path       string      `json:"Path"`
-	Query      string      `json:"Query"`
-	StatusCode int         `json:"StatusCode"`
-	DurationMs int64       `json:"DurationMs"`
-	Timestamp  time.Time   `json:"Timestamp"`
+	ID         int64     `json:"ID"`
+	Method     string    `json:"Method"`
+	Scheme     string    `json:"Scheme"`
+	Host       string    `json:"Host"`
+	Port       int       `json:"Port"`
+	Path       string    `json:"Path"`
+	Query      string    `json:"Query"`
+	StatusCode int       `json:"StatusCode"`
+	DurationMs int64     `json:"DurationMs"`
+	Timestamp  time.Time `json:"Timestamp"`
+	Tags       []string  `json:"Tags"`
+	Note       string    `json:"Note"`
+	Highlight  string    `json:"Highlight"`
 	// These fields are present but not used for table display
 	RequestHeaders  http.Header `json:"RequestHeaders"`
 	RequestBody     []byte      `json:"RequestBody"`
 	ResponseHeaders http.Header `json:"ResponseHeaders"`
 	ResponseBody    []byte      `json:"ResponseBody"`
+	RawRequest      []byte      `json:"RawRequest"`
+	RawResponse     []byte      `json:"RawResponse"`
 }
 
 // entryFull has all fields for raw display.
@@ -38,22 +50,22 @@ func printTable(entries []entryRow) {
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
-	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
 		"ID", "METHOD", "HOST", "PATH", "STATUS",
-		pad("MS", 6), pad("REQ", 7), pad("RES", 7))
+		pad("MS", 6), pad("REQ", 7), pad("RES", 7), "HIGHLIGHT", "TAGS", "NOTE")
 
 	for _, e := range entries {
 		path := e.Path
 		if e.Query != "" {
 			path += "?" + e.Query
 		}
-		if len(path) > 60 {
-			path = path[:57] + "..."
-		}
+		path = truncate(path, 60)
+		note := truncate(strings.ReplaceAll(e.Note, "\n", " "), 40)
 
-		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%6d\t%7s\t%7s\t\n",
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%6d\t%7s\t%7s\t%s\t%s\t%s\t\n",
 			e.ID, e.Method, e.Host, path, e.StatusCode, e.DurationMs,
-			formatSize(len(e.RequestBody)), formatSize(len(e.ResponseBody)))
+			formatSize(len(e.RequestBody)), formatSize(len(e.ResponseBody)),
+			orDash(e.Highlight), orDash(strings.Join(e.Tags, ",")), orDash(note))
 	}
 
 	w.Flush()
@@ -61,6 +73,10 @@ func printTable(entries []entryRow) {
 }
 
 func printRawRequest(e entryFull) {
+	if e.RawRequest != nil {
+		os.Stdout.Write(e.RawRequest)
+		return
+	}
 	path := e.Path
 	if e.Query != "" {
 		path += "?" + e.Query
@@ -70,7 +86,7 @@ func printRawRequest(e entryFull) {
 	}
 
 	fmt.Printf("%s %s HTTP/1.1\r\n", e.Method, path)
-	fmt.Printf("Host: %s\r\n", e.Host)
+	fmt.Printf("Host: %s\r\n", (&storage.Entry{Scheme: e.Scheme, Host: e.Host, Port: e.Port}).Authority())
 	printHeaders(e.RequestHeaders)
 	fmt.Print("\r\n")
 	if len(e.RequestBody) > 0 {
@@ -79,6 +95,10 @@ func printRawRequest(e entryFull) {
 }
 
 func printRawResponse(e entryFull) {
+	if e.RawRequest != nil {
+		os.Stdout.Write(e.RawResponse)
+		return
+	}
 	statusText := http.StatusText(e.StatusCode)
 	if statusText == "" {
 		statusText = "Unknown"
@@ -91,6 +111,26 @@ func printRawResponse(e entryFull) {
 	}
 }
 
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// truncate shortens s to at most n bytes, marking the cut with "...". It
+// cuts between runes, so the result stays valid UTF-8.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	n = max(n-3, 0)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
 func pad(s string, width int) string {
 	return fmt.Sprintf("%*s", width, s)
 }
@@ -108,6 +148,41 @@ func formatSize(n int) string {
 	}
 }
 
+// parseSize parses a byte size such as "512KB", "500MB" or "2GB" using
+// binary multiples. A bare number is taken as bytes. The size must be at
+// least one byte and fit in an int64; hex, infinite and NaN numbers, which
+// strconv.ParseFloat accepts, are rejected.
+func parseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	units := []struct {
+		suffix string
+		mult   int64
+	}{
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	mult := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.mult
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || strings.ContainsAny(s, "XP") || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	total := n * float64(mult)
+	if total < 1 {
+		return 0, fmt.Errorf("invalid size %q: must be at least 1 byte", size)
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, itself out of range.
+	if total >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: too large", size)
+	}
+	return int64(total), nil
+}
+
 func printHeaders(h http.Header) {
 	keys := make([]string, 0, len(h))
 	for k := range h {
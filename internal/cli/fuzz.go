@@ -0,0 +1,311 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/fuzz"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var fuzzCmd = &cobra.Command{
+	Use:   "fuzz <id>",
+	Short: "Send variants of a stored request with payloads at marked positions",
+	Long: `Send variants of a stored request, inserting payloads from wordlists at
+positions marked with §...§ in the raw request. Mark positions in your
+editor with --edit, or pass a marked request file with --template (start
+from 'reaper req <id>'). Text between the markers is the position's
+default value.
+
+Attack modes:
+  sniper         one wordlist; each position in turn, others at their default
+  battering-ram  one wordlist; the same payload in every position
+  pitchfork      one wordlist per position, stepped through in parallel
+  cluster-bomb   one wordlist per position, every combination
+
+Requests are sent by the daemon and stored as entries grouped under a fuzz
+run. The command waits for the run and then shows the results, most
+anomalous first; with --detach it returns immediately. Only in-scope hosts
+can be fuzzed.`,
+	Example: `  reaper fuzz 42 --edit -w ids.txt
+  reaper fuzz 42 --template login.txt --mode cluster-bomb -w users.txt -w passwords.txt
+  reaper fuzz 42 --template search.txt -w xss.txt --urlencode --rate 5 --detach
+  reaper fuzz results 3`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runFuzz,
+}
+
+var fuzzResultsCmd = &cobra.Command{
+	Use:          "results <run>",
+	Short:        "Show the results of a fuzz run, most anomalous first",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runFuzzResults,
+}
+
+var fuzzRunsCmd = &cobra.Command{
+	Use:          "runs",
+	Short:        "List fuzz runs",
+	SilenceUsage: true,
+	RunE:         runFuzzRuns,
+}
+
+var fuzzStopCmd = &cobra.Command{
+	Use:          "stop <run>",
+	Short:        "Stop a fuzz run in progress",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runFuzzStop,
+}
+
+var (
+	fuzzWordlists   []string
+	fuzzMode        string
+	fuzzTemplate    string
+	fuzzEdit        bool
+	fuzzConcurrency int
+	fuzzRate        float64
+	fuzzURLEncode   bool
+	fuzzDetach      bool
+	fuzzResultsN    int
+)
+
+func init() {
+	fuzzCmd.Flags().StringArrayVarP(&fuzzWordlists, "wordlist", "w", nil, "Payload file, one payload per line (repeat for pitchfork and cluster-bomb)")
+	fuzzCmd.Flags().StringVarP(&fuzzMode, "mode", "m", string(fuzz.Sniper), "Attack mode: sniper, battering-ram, pitchfork or cluster-bomb")
+	fuzzCmd.Flags().StringVarP(&fuzzTemplate, "template", "t", "", "Raw request file with §-marked payload positions")
+	fuzzCmd.Flags().BoolVarP(&fuzzEdit, "edit", "e", false, "Mark payload positions in the raw request in $EDITOR")
+	fuzzCmd.Flags().IntVarP(&fuzzConcurrency, "concurrency", "c", fuzz.DefaultConcurrency, "Parallel requests")
+	fuzzCmd.Flags().Float64Var(&fuzzRate, "rate", 0, "Maximum requests per second (0 for no limit)")
+	fuzzCmd.Flags().BoolVar(&fuzzURLEncode, "urlencode", false, "URL-encode payloads before inserting them")
+	fuzzCmd.Flags().BoolVarP(&fuzzDetach, "detach", "d", false, "Start the run and return without waiting for results")
+	fuzzCmd.Flags().IntVarP(&fuzzResultsN, "number", "n", 20, "Number of results to show (0 for all)")
+	_ = fuzzCmd.MarkFlagRequired("wordlist")
+
+	fuzzResultsCmd.Flags().IntVarP(&fuzzResultsN, "number", "n", 50, "Number of results to show (0 for all)")
+
+	fuzzCmd.AddCommand(fuzzResultsCmd, fuzzRunsCmd, fuzzStopCmd)
+	rootCmd.AddCommand(fuzzCmd)
+}
+
+func runFuzz(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid entry ID: %s", args[0])
+	}
+
+	var template []byte
+	switch {
+	case fuzzTemplate != "" && fuzzEdit:
+		return fmt.Errorf("--template and --edit are mutually exclusive")
+	case fuzzTemplate != "":
+		if template, err = os.ReadFile(fuzzTemplate); err != nil {
+			return fmt.Errorf("reading template: %w", err)
+		}
+	case fuzzEdit:
+		if template, err = editRequest(id); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("mark payload positions with --edit or --template")
+	}
+
+	payloads := make([][]string, 0, len(fuzzWordlists))
+	for _, path := range fuzzWordlists {
+		list, err := readWordlist(path)
+		if err != nil {
+			return err
+		}
+		payloads = append(payloads, list)
+	}
+
+	data, err := sendCommand("fuzz", daemon.FuzzParams{
+		ID:          id,
+		Template:    template,
+		Mode:        fuzzMode,
+		Payloads:    payloads,
+		Concurrency: fuzzConcurrency,
+		Rate:        fuzzRate,
+		URLEncode:   fuzzURLEncode,
+	})
+	if err != nil {
+		return err
+	}
+
+	var run storage.FuzzRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "fuzz run %d: %d requests (%s)\n", run.ID, run.Total, run.Mode)
+
+	if fuzzDetach {
+		fmt.Fprintf(os.Stderr, "follow with: reaper fuzz results %d\n", run.ID)
+		return nil
+	}
+
+	if err := waitForFuzzRun(run.ID); err != nil {
+		return err
+	}
+	return showFuzzResults(run.ID, fuzzResultsN)
+}
+
+// waitForFuzzRun polls a run until it finishes, printing progress. An
+// interrupt stops the run instead of abandoning it.
+func waitForFuzzRun(runID int64) error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sigCh:
+			fmt.Fprintln(os.Stderr, "\nstopping run...")
+			if _, err := sendCommand("fuzz-stop", daemon.FuzzRunParams{RunID: runID}); err != nil {
+				return err
+			}
+		case <-ticker.C:
+		}
+
+		data, err := sendCommand("fuzz-status", daemon.FuzzRunParams{RunID: runID})
+		if err != nil {
+			return err
+		}
+		var run storage.FuzzRun
+		if err := json.Unmarshal(data, &run); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "\rsent %d/%d, %d errors", run.Sent, run.Total, run.Errors)
+		if run.Status != storage.FuzzRunning {
+			fmt.Fprintf(os.Stderr, " (%s)\n\n", run.Status)
+			return nil
+		}
+	}
+}
+
+func runFuzzResults(cmd *cobra.Command, args []string) error {
+	runID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %s", args[0])
+	}
+	return showFuzzResults(runID, fuzzResultsN)
+}
+
+func showFuzzResults(runID int64, limit int) error {
+	data, err := sendCommand("fuzz-results", daemon.FuzzRunParams{RunID: runID, Limit: limit})
+	if err != nil {
+		return err
+	}
+
+	var result daemon.FuzzResultsResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	if len(result.Results) == 0 {
+		fmt.Println("no results yet")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+		"SEQ", "ENTRY", "POS", "PAYLOAD", "STATUS", pad("LENGTH", 7), pad("MS", 6), "ANOMALIES")
+
+	for _, r := range result.Results {
+		payload := truncate(strings.ReplaceAll(strings.Join(r.Payloads, " | "), "\n", " "), 40)
+
+		entry, status, pos := "-", "-", "-"
+		if r.EntryID != 0 {
+			entry = strconv.FormatInt(r.EntryID, 10)
+			status = strconv.Itoa(r.StatusCode)
+		}
+		if r.Position != 0 {
+			pos = strconv.Itoa(r.Position)
+		}
+		anomalies := strings.Join(r.Anomalies, "; ")
+		if r.Error != "" {
+			anomalies = "error: " + r.Error
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%7d\t%6d\t%s\t\n",
+			r.Seq, entry, pos, orDash(payload), status, r.Length, r.DurationMs, orDash(anomalies))
+	}
+
+	w.Flush()
+	run := result.Run
+	fmt.Printf("\nrun %d (%s, %s): showing %d of %d results, %d errors\n",
+		run.ID, run.Mode, run.Status, len(result.Results), run.Sent, run.Errors)
+	return nil
+}
+
+func runFuzzRuns(cmd *cobra.Command, args []string) error {
+	data, err := sendCommand("fuzz-runs", nil)
+	if err != nil {
+		return err
+	}
+
+	var runs []storage.FuzzRun
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("no fuzz runs found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", "RUN", "ENTRY", "MODE", "STATUS", "SENT", "ERRORS", "STARTED")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%d/%d\t%d\t%s\t\n",
+			run.ID, run.EntryID, run.Mode, run.Status, run.Sent, run.Total, run.Errors,
+			run.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	w.Flush()
+	return nil
+}
+
+func runFuzzStop(cmd *cobra.Command, args []string) error {
+	runID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid run ID: %s", args[0])
+	}
+	if _, err := sendCommand("fuzz-stop", daemon.FuzzRunParams{RunID: runID}); err != nil {
+		return err
+	}
+	fmt.Printf("stopping fuzz run %d\n", runID)
+	return nil
+}
+
+// readWordlist returns the non-empty lines of the file at path.
+func readWordlist(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading wordlist: %w", err)
+	}
+
+	var words []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line != "" {
+			words = append(words, line)
+		}
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("wordlist %s is empty", path)
+	}
+	return words, nil
+}
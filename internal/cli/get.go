@@ -18,39 +18,32 @@ var getCmd = &cobra.Command{
 }
 
 func init() {
+	registerOffline(getCmd)
 	rootCmd.AddCommand(getCmd)
 }
 
 func runGet(cmd *cobra.Command, args []string) error {
-	return fetchAndPrint(args[0], "get")
+	return fetchAndPrint(cmd, args[0], "get")
 }
 
-func fetchAndPrint(idStr, command string) error {
+// fetchAndPrint prints an entry, read through the daemon or, if none is
+// running, from the database directly.
+func fetchAndPrint(cmd *cobra.Command, idStr, command string) error {
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
 		return fmt.Errorf("invalid entry ID: %s", idStr)
 	}
 
-	dataDir, err := daemon.DataDir()
+	data, err := readCommand(cmd, command, daemon.GetParams{ID: id})
 	if err != nil {
 		return err
 	}
 
-	params, _ := json.Marshal(daemon.GetParams{ID: id})
-	client := daemon.NewClient(dataDir)
-	resp, err := client.Send(daemon.Request{Command: command, Params: params})
-	if err != nil {
-		return fmt.Errorf("no running daemon found: %w", err)
-	}
-	if !resp.OK {
-		return fmt.Errorf("%s", resp.Error)
-	}
-
 	var result struct {
 		Command string    `json:"command"`
 		Entry   entryFull `json:"entry"`
 	}
-	if err := json.Unmarshal(resp.Data, &result); err != nil {
+	if err := json.Unmarshal(data, &result); err != nil {
 		return fmt.Errorf("decoding response: %w", err)
 	}
 
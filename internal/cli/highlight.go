@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var highlightCmd = &cobra.Command{
+	Use:          "highlight <id> <color>",
+	Short:        "Highlight an entry with a color (\"none\" clears it)",
+	Long:         "Highlight an entry with a color. Colors: " + strings.Join(storage.HighlightColors, ", ") + ".",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE:         runHighlight,
+}
+
+func init() {
+	rootCmd.AddCommand(highlightCmd)
+}
+
+func runHighlight(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid entry ID: %s", args[0])
+	}
+
+	color := strings.ToLower(args[1])
+	if color == "none" {
+		color = ""
+	}
+
+	if _, err := sendCommand("highlight", daemon.HighlightParams{ID: id, Color: color}); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/exchange"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <path>",
+	Short: "Import entries from Burp Suite XML or raw .req/.res files",
+	Long: `Import entries from a Burp Suite "save items" XML file or from a directory
+of raw .req files, each optionally paired with a .res file of the same name.
+
+Raw files do not record the scheme; requests whose target is not an
+absolute URL are imported with --scheme.`,
+	Example: `  reaper import items.xml
+  reaper import --format raw --scheme http ./requests`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runImport,
+}
+
+var (
+	importFormat string
+	importScheme string
+)
+
+// importBatchBytes caps the body bytes sent per import request so each
+// message stays well within the IPC message limit.
+const importBatchBytes = 4 * 1024 * 1024
+
+func init() {
+	importCmd.Flags().StringVar(&importFormat, "format", "", "Input format: burp or raw (inferred from path)")
+	importCmd.Flags().StringVar(&importScheme, "scheme", "https", "Scheme for raw requests without an absolute URL")
+
+	rootCmd.AddCommand(importCmd)
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	format := importFormat
+	if format == "" {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			format = "raw"
+		}
+	}
+	format, err := exchangeFormat(format, path)
+	if err != nil {
+		return err
+	}
+
+	var entries []*storage.Entry
+	switch format {
+	case "burp":
+		f, err := os.Open(path) //nolint:gosec
+		if err != nil {
+			return err
+		}
+		entries, err = exchange.ReadBurp(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	case "raw":
+		entries, err = exchange.ReadRawDir(path, importScheme)
+		if err != nil {
+			return err
+		}
+	}
+
+	imported := 0
+	for len(entries) > 0 {
+		n, size := 0, 0
+		for n < len(entries) && (n == 0 || size < importBatchBytes) {
+			size += len(entries[n].RequestBody) + len(entries[n].ResponseBody)
+			n++
+		}
+
+		if _, err := sendCommand("import", daemon.ImportParams{Entries: entries[:n]}); err != nil {
+			return fmt.Errorf("import failed after %d entries: %w", imported, err)
+		}
+
+		imported += n
+		entries = entries[n:]
+	}
+
+	fmt.Printf("imported %d entries\n", imported)
+	return nil
+}
@@ -0,0 +1,461 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/authz"
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/jwt"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var jwtCmd = &cobra.Command{
+	Use:   "jwt",
+	Short: "Inspect, test and forge JSON Web Tokens seen in traffic",
+	Long: `Inspect, test and forge JSON Web Tokens seen in traffic.
+
+Commands that take a token also take an entry ID, meaning the JWTs in that
+entry's request, or in its response if the request has none. Forged tokens
+can be sent directly with replay, which rewrites every JWT in the request:
+
+  reaper replay 42 --jwt-claim role=admin --jwt-alg none`,
+	Example: `  reaper jwt list
+  reaper jwt decode 42
+  reaper jwt check 42 --wordlist secrets.txt
+  reaper jwt forge 42 --claim role=admin --secret secret`,
+}
+
+var jwtListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the distinct JWTs seen in traffic",
+	Long: `List the distinct JWTs seen in requests and responses, newest first, with
+their algorithm, subject and expiry. SEEN counts the entries a token
+appears in and LAST is the latest of them; ISSUED marks tokens a server
+sent in a response.`,
+	Example: `  reaper jwt list
+  reaper jwt list --host api.example.com --token`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runJWTList,
+}
+
+var jwtDecodeCmd = &cobra.Command{
+	Use:   "decode <token|id>",
+	Short: "Show the header and claims of a JWT",
+	Example: `  reaper jwt decode 42
+  reaper jwt decode eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runJWTDecode,
+}
+
+var jwtCheckCmd = &cobra.Command{
+	Use:   "check <token|id>",
+	Short: "Look for weaknesses in a JWT and the server that accepts it",
+	Long: `Look for weaknesses in a JWT: an unsigned token, a missing or distant
+expiry, kid, jku, x5u and jwk headers worth attacking, and an HMAC secret
+found in a wordlist. Common default secrets are always tried.
+
+Given an entry ID, the request is also replayed, unless --passive is set,
+to see whether the server accepts the token with alg none and with a
+signature made with the wrong key. A server that accepts either does not
+verify tokens and will take any claims. Only in-scope hosts can be
+replayed.`,
+	Example: `  reaper jwt check 42
+  reaper jwt check 42 --wordlist /usr/share/wordlists/jwt.secrets.list
+  reaper jwt check eyJhbGciOi... --passive`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runJWTCheck,
+}
+
+var jwtForgeCmd = &cobra.Command{
+	Use:   "forge <token|id>",
+	Short: "Print a JWT with modified claims, header or signature",
+	Long: `Print a JWT with modified claims, header or signature. Values are read as
+JSON when they are valid JSON, so admin=true sets a boolean and role=admin a
+string; name= removes the claim.
+
+With --alg none the token is unsigned. With --secret it is signed with
+HMAC. Otherwise the original signature is kept, which only a server that
+does not verify signatures accepts.`,
+	Example: `  reaper jwt forge 42 --claim role=admin --alg none
+  reaper jwt forge 42 --claim sub=1001 --claim exp= --secret secret
+  reaper jwt forge 42 --header kid=../../../dev/null --secret ''`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runJWTForge,
+}
+
+var (
+	jwtFilters   entryFilters
+	jwtLimit     int
+	jwtShowToken bool
+	jwtWordlist  string
+	jwtPassive   bool
+	jwtClaims    []string
+	jwtHeaders   []string
+	jwtAlg       string
+	jwtSecret    string
+)
+
+func init() {
+	jwtListCmd.Flags().IntVarP(&jwtLimit, "number", "n", 0, "Maximum entries to search, newest first (0 for all)")
+	jwtListCmd.Flags().BoolVar(&jwtShowToken, "token", false, "Show full tokens")
+	jwtFilters.register(jwtListCmd)
+
+	jwtCheckCmd.Flags().StringVarP(&jwtWordlist, "wordlist", "w", "", "File of HMAC secrets to try, one per line")
+	jwtCheckCmd.Flags().BoolVar(&jwtPassive, "passive", false, "Do not replay the request")
+
+	jwtForgeCmd.Flags().StringArrayVar(&jwtClaims, "claim", nil, "Set a claim, name=value (repeatable; name= removes)")
+	jwtForgeCmd.Flags().StringArrayVar(&jwtHeaders, "header", nil, "Set a header parameter, name=value (repeatable)")
+	jwtForgeCmd.Flags().StringVar(&jwtAlg, "alg", "", "Signing algorithm: none, HS256, HS384 or HS512")
+	jwtForgeCmd.Flags().StringVar(&jwtSecret, "secret", "", "HMAC secret to sign with")
+
+	jwtCmd.AddCommand(jwtListCmd, jwtDecodeCmd, jwtCheckCmd, jwtForgeCmd)
+	rootCmd.AddCommand(jwtCmd)
+}
+
+func runJWTList(cmd *cobra.Command, args []string) error {
+	p := daemon.JWTListParams{Filter: jwtFilters.params()}
+	p.Filter.Limit = jwtLimit
+	data, err := sendCommand("jwt-list", p)
+	if err != nil {
+		return err
+	}
+
+	var summaries []daemon.JWTSummary
+	if err := json.Unmarshal(data, &summaries); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	if len(summaries) == 0 {
+		fmt.Println("no JWTs found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+		pad("LAST", 5), "ALG", "SUBJECT", "EXPIRES", pad("SEEN", 4), "ISSUED", "HOSTS", "KID", "TOKEN")
+	for _, s := range summaries {
+		t, err := jwt.Parse(s.Token)
+		if err != nil {
+			continue
+		}
+		issued := ""
+		if s.Issued {
+			issued = "yes"
+		}
+		token := s.Token
+		if !jwtShowToken {
+			token = truncate(token, 40)
+		}
+		kid := ""
+		if v, ok := t.Header["kid"]; ok {
+			kid = fmt.Sprint(v)
+		}
+		fmt.Fprintf(w, "%5d\t%s\t%s\t%s\t%4d\t%s\t%s\t%s\t%s\t\n",
+			s.LastID, orDash(t.Alg()), orDash(truncate(claimString(t, "sub"), 30)), expiry(t), s.Count,
+			orDash(issued), strings.Join(s.Hosts, ","), orDash(truncate(kid, 20)), token)
+	}
+	w.Flush()
+	return nil
+}
+
+func runJWTDecode(cmd *cobra.Command, args []string) error {
+	tokens, _, err := resolveJWTs(args[0])
+	if err != nil {
+		return err
+	}
+	for i, t := range tokens {
+		if i > 0 {
+			fmt.Println()
+		}
+		if len(tokens) > 1 {
+			fmt.Printf("# token %d of %d\n", i+1, len(tokens))
+		}
+		printJWT(t)
+	}
+	return nil
+}
+
+func runJWTCheck(cmd *cobra.Command, args []string) error {
+	tokens, entry, err := resolveJWTs(args[0])
+	if err != nil {
+		return err
+	}
+	secrets := jwt.CommonSecrets
+	if jwtWordlist != "" {
+		f, err := os.Open(jwtWordlist)
+		if err != nil {
+			return fmt.Errorf("opening wordlist: %w", err)
+		}
+		words, err := jwt.ReadWordlist(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("reading wordlist: %w", err)
+		}
+		secrets = append(words, secrets...)
+	}
+
+	for i, t := range tokens {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("token: %s\n", truncate(t.Raw, 60))
+		weaknesses := jwt.Check(t, time.Now())
+		if secret, ok := jwt.Crack(t, secrets); ok {
+			weaknesses = append(weaknesses, jwt.Weakness{
+				Severity: storage.SeverityHigh,
+				Title:    "Weak HMAC secret",
+				Detail:   fmt.Sprintf("signed with %q; forge tokens with --jwt-secret", secret),
+			})
+		}
+		if entry != nil && !jwtPassive && i == 0 {
+			ws, err := activeJWTChecks(entry, t)
+			if err != nil {
+				return err
+			}
+			weaknesses = append(weaknesses, ws...)
+		}
+		printWeaknesses(weaknesses)
+	}
+	if entry != nil && !jwtPassive && len(tokens) > 1 {
+		fmt.Fprintln(os.Stderr, "\nreplay tests rewrite every JWT in the request; results are shown for the first")
+	}
+	return nil
+}
+
+// activeJWTChecks replays entry with its JWTs unsigned and signed with a
+// random key, and reports the forgeries the server accepts like the
+// original. A control replay without the JWTs comes first: a forgery only
+// counts as accepted if the control is rejected, so endpoints that ignore
+// the token are not reported.
+func activeJWTChecks(entry *storage.Entry, t *jwt.Token) ([]jwt.Weakness, error) {
+	if len(jwt.FindInRequest(entry)) == 0 {
+		return []jwt.Weakness{{
+			Severity: storage.SeverityInfo,
+			Title:    "Replay tests skipped",
+			Detail:   "the token was issued in the response; check an entry that sends it",
+		}}, nil
+	}
+	if entry.StatusCode == 0 || entry.StatusCode >= 400 {
+		return []jwt.Weakness{{
+			Severity: storage.SeverityInfo,
+			Title:    "Replay tests skipped",
+			Detail:   fmt.Sprintf("the original request got status %d; pick an entry the token was accepted in", entry.StatusCode),
+		}}, nil
+	}
+
+	replay := func(params daemon.ReplayParams) (*entryFull, error) {
+		data, err := sendCommand("replay", params)
+		if err != nil {
+			return nil, err
+		}
+		var e entryFull
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("decoding response: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "replayed #%d as #%d: %d (original %d)\n", entry.ID, e.ID, e.StatusCode, entry.StatusCode)
+		return &e, nil
+	}
+
+	control, err := replay(daemon.ReplayParams{ID: entry.ID, JWTRemove: true})
+	if err != nil {
+		return nil, err
+	}
+	if sameResponse(entry.StatusCode, entry.ResponseBody, control) {
+		return []jwt.Weakness{{
+			Severity: storage.SeverityInfo,
+			Title:    "Replay tests inconclusive",
+			Detail:   fmt.Sprintf("replay #%d without the token got the same response as the original; the endpoint may not check it", control.ID),
+		}}, nil
+	}
+
+	key := make([]byte, 16)
+	rand.Read(key)
+	wrongAlg := t.Alg()
+	if !strings.HasPrefix(wrongAlg, "HS") {
+		wrongAlg = "HS256"
+	}
+
+	tests := []struct {
+		params   daemon.ReplayParams
+		severity string
+		title    string
+	}{
+		{daemon.ReplayParams{ID: entry.ID, JWTAlg: "none"}, storage.SeverityCritical, "Server accepts alg none"},
+		{daemon.ReplayParams{ID: entry.ID, JWTAlg: wrongAlg, JWTSecret: hex.EncodeToString(key)}, storage.SeverityCritical, "Server accepts an invalid signature"},
+	}
+	var ws []jwt.Weakness
+	for _, tt := range tests {
+		if strings.EqualFold(t.Alg(), "none") && tt.params.JWTAlg == "none" {
+			continue
+		}
+		e, err := replay(tt.params)
+		if err != nil {
+			return nil, err
+		}
+		if sameResponse(entry.StatusCode, entry.ResponseBody, e) && !sameResponse(control.StatusCode, control.ResponseBody, e) {
+			ws = append(ws, jwt.Weakness{
+				Severity: tt.severity,
+				Title:    tt.title,
+				Detail: fmt.Sprintf("replay #%d got the original response, %d, while #%d without the token got %d",
+					e.ID, e.StatusCode, control.ID, control.StatusCode),
+			})
+		}
+	}
+	return ws, nil
+}
+
+// sameResponse reports whether a replay got the response with status and
+// body: the same status and a body at least authz.BypassSimilarity alike, so
+// that timestamps and IDs within it do not matter.
+func sameResponse(status int, body []byte, replayed *entryFull) bool {
+	return replayed.StatusCode == status && authz.Similarity(body, replayed.ResponseBody) >= authz.BypassSimilarity
+}
+
+func printWeaknesses(ws []jwt.Weakness) {
+	if len(ws) == 0 {
+		fmt.Println("no weaknesses found")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t\n", "SEVERITY", "WEAKNESS", "DETAIL")
+	for _, weak := range ws {
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", weak.Severity, weak.Title, weak.Detail)
+	}
+	w.Flush()
+}
+
+func runJWTForge(cmd *cobra.Command, args []string) error {
+	tokens, _, err := resolveJWTs(args[0])
+	if err != nil {
+		return err
+	}
+
+	opts := jwt.ForgeOptions{Alg: jwtAlg}
+	if len(jwtClaims) > 0 {
+		opts.Claims = map[string]any{}
+		for _, kv := range jwtClaims {
+			k, v, err := jwt.ParseClaim(kv)
+			if err != nil {
+				return err
+			}
+			opts.Claims[k] = v
+		}
+	}
+	if len(jwtHeaders) > 0 {
+		opts.Header = map[string]any{}
+		for _, kv := range jwtHeaders {
+			k, v, err := jwt.ParseClaim(kv)
+			if err != nil {
+				return err
+			}
+			if v == nil {
+				v = ""
+			}
+			opts.Header[k] = v
+		}
+	}
+	if cmd.Flags().Changed("secret") {
+		opts.Secret = []byte(jwtSecret)
+	}
+
+	for _, t := range tokens {
+		forged, err := jwt.Forge(t, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Println(forged)
+	}
+	return nil
+}
+
+// resolveJWTs returns the token in arg or, if arg is an entry ID, the
+// tokens in that entry and the entry itself.
+func resolveJWTs(arg string) ([]*jwt.Token, *storage.Entry, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		t, err := jwt.Parse(arg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("not an entry ID or a JWT: %w", err)
+		}
+		return []*jwt.Token{t}, nil, nil
+	}
+
+	data, err := sendCommand("get", daemon.GetParams{ID: id})
+	if err != nil {
+		return nil, nil, err
+	}
+	var result struct {
+		Entry *storage.Entry `json:"entry"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	raw := jwt.FindInRequest(result.Entry)
+	if len(raw) == 0 {
+		raw = jwt.FindInResponse(result.Entry)
+	}
+	if len(raw) == 0 {
+		return nil, nil, errors.New("no JWT in entry " + arg)
+	}
+	tokens := make([]*jwt.Token, len(raw))
+	for i, s := range raw {
+		if tokens[i], err = jwt.Parse(s); err != nil {
+			return nil, nil, err
+		}
+	}
+	return tokens, result.Entry, nil
+}
+
+func printJWT(t *jwt.Token) {
+	header, _ := json.MarshalIndent(t.Header, "", "  ")
+	claims, _ := json.MarshalIndent(t.Claims, "", "  ")
+	fmt.Printf("header:\n%s\n\nclaims:\n%s\n\n", header, claims)
+	for _, name := range []string{"iat", "nbf", "exp"} {
+		if n, ok := t.Claims[name].(json.Number); ok {
+			if sec, err := n.Int64(); err == nil {
+				fmt.Printf("%s: %s\n", name, time.Unix(sec, 0).Local().Format(time.RFC3339))
+			}
+		}
+	}
+	if len(t.Signature) == 0 {
+		fmt.Println("signature: none")
+	} else {
+		fmt.Printf("signature: %d bytes\n", len(t.Signature))
+	}
+}
+
+func claimString(t *jwt.Token, name string) string {
+	v, ok := t.Claims[name]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// expiry describes when t expires relative to now.
+func expiry(t *jwt.Token) string {
+	exp, ok := t.Expiry()
+	if !ok {
+		return "never"
+	}
+	d := time.Until(exp).Round(time.Minute)
+	if d < 0 {
+		return "expired " + strings.TrimSuffix((-d).String(), "0s") + " ago"
+	}
+	return "in " + strings.TrimSuffix(d.String(), "0s")
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+)
+
+var noteCmd = &cobra.Command{
+	Use:          "note <id> <text>",
+	Short:        "Set the note on an entry (an empty text clears it)",
+	Example:      `  reaper note 42 "returns other users' orders when id is changed"`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE:         runNote,
+}
+
+func init() {
+	rootCmd.AddCommand(noteCmd)
+}
+
+func runNote(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid entry ID: %s", args[0])
+	}
+
+	if _, err := sendCommand("note", daemon.NoteParams{ID: id, Note: args[1]}); err != nil {
+		return err
+	}
+	return nil
+}
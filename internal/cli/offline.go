@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// registerOffline adds the flags of commands that can read the database
+// directly when no daemon is running.
+func registerOffline(cmd *cobra.Command) {
+	cmd.Flags().Bool("offline", false, "Read the database directly, even if the daemon is running")
+	cmd.Flags().String("key-file", "", "Key file of an encrypted database, when reading it directly")
+}
+
+// readCommand sends a read-only command to the daemon, or answers it from
+// the database directly if no daemon is running or --offline is given.
+func readCommand(cmd *cobra.Command, command string, params any) (json.RawMessage, error) {
+	if offline, _ := cmd.Flags().GetBool("offline"); !offline {
+		data, err := sendCommand(command, params)
+		if !errors.Is(err, daemon.ErrNoDaemon) {
+			return data, err
+		}
+	}
+
+	keyFile, _ := cmd.Flags().GetString("key-file")
+	store, err := openOffline(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	var raw json.RawMessage
+	if params != nil {
+		if raw, err = json.Marshal(params); err != nil {
+			return nil, fmt.Errorf("encoding params: %w", err)
+		}
+	}
+	resp := daemon.Local(store, daemon.Request{Command: command, Params: raw})
+	if !resp.OK {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+	return resp.Data, nil
+}
+
+// openOffline opens the database read-only, unlocking it if it is
+// encrypted.
+func openOffline(keyFile string) (*storage.SQLiteStore, error) {
+	dataDir, err := daemon.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	path := daemon.DBPath(dataDir)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no running daemon found and no database at %s", path)
+	}
+
+	store, err := storage.OpenSQLiteStoreReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	if store.Encrypted() {
+		secret, err := readSecret(keyFile, false)
+		if err == nil {
+			err = store.Unlock(secret)
+		}
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+	return store, nil
+}
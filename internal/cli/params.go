@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var paramsCmd = &cobra.Command{
+	Use:   "params",
+	Short: "List request parameters seen per endpoint",
+	Long: `List the parameters sent to each endpoint: query string, url-encoded,
+multipart and JSON body fields, cookies and notable headers (Authorization,
+Origin, Referer and X- headers). Nested JSON keys use dotted paths and
+array elements a [] suffix, e.g. items[].id.`,
+	Example: `  reaper params --host api.example.com
+  reaper params --endpoint /users/{id}
+  reaper params --endpoint /users/42 --method POST`,
+	SilenceUsage: true,
+	RunE:         runParams,
+}
+
+var (
+	paramsFilters  entryFilters
+	paramsEndpoint string
+)
+
+func init() {
+	paramsFilters.register(paramsCmd)
+	paramsCmd.Flags().StringVar(&paramsEndpoint, "endpoint", "", "Only show parameters for this endpoint (path or template)")
+	rootCmd.AddCommand(paramsCmd)
+}
+
+func runParams(cmd *cobra.Command, args []string) error {
+	data, err := sendCommand("params", daemon.ParamsParams{Filter: paramsFilters.params(), Endpoint: paramsEndpoint})
+	if err != nil {
+		return err
+	}
+
+	var summaries []storage.ParamSummary
+	if err := json.Unmarshal(data, &summaries); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	if len(summaries) == 0 {
+		fmt.Println("no parameters found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+		"HOST", "ENDPOINT", "METHODS", "LOCATION", "NAME", "TYPES", pad("COUNT", 5), "SAMPLES", "EXAMPLES")
+
+	for _, p := range summaries {
+		samples := truncate(strings.ReplaceAll(strings.Join(p.Samples, ", "), "\n", " "), 50)
+		examples := make([]string, len(p.ExampleIDs))
+		for i, id := range p.ExampleIDs {
+			examples[i] = strconv.FormatInt(id, 10)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%5d\t%s\t%s\t\n",
+			p.Host, p.Endpoint, strings.Join(p.Methods, ","), p.Location, p.Name,
+			strings.Join(p.Types, ","), p.Count, orDash(samples), strings.Join(examples, ","))
+	}
+
+	w.Flush()
+	fmt.Printf("\n%d parameters\n", len(summaries))
+	return nil
+}
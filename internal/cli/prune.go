@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete entries by filter, age, count or database size",
+	Long: `Delete entries and reclaim the space they used.
+
+Filters select the entries to consider. Limits then decide which of those
+are removed: --older-than removes by age, --keep retains only the newest N
+and --max-size removes the oldest until the database fits. With filters and
+no limits, every matching entry is removed.`,
+	Example: `  reaper prune --older-than 48h
+  reaper prune --host telemetry.example.com
+  reaper prune --max-size 200MB`,
+	SilenceUsage: true,
+	RunE:         runPrune,
+}
+
+var (
+	pruneFilters   entryFilters
+	pruneOlderThan time.Duration
+	pruneKeep      int
+	pruneMaxSize   string
+)
+
+func init() {
+	pruneFilters.register(pruneCmd)
+	pruneCmd.Flags().DurationVar(&pruneOlderThan, "older-than", 0, "Remove entries older than this (e.g. 24h)")
+	pruneCmd.Flags().IntVar(&pruneKeep, "keep", 0, "Keep only the newest N matching entries")
+	pruneCmd.Flags().StringVar(&pruneMaxSize, "max-size", "", "Remove oldest entries until the database is under this size (e.g. 500MB)")
+
+	rootCmd.AddCommand(pruneCmd)
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	p := daemon.PruneParams{
+		Filter:     pruneFilters.params(),
+		MaxAge:     pruneOlderThan,
+		MaxEntries: pruneKeep,
+	}
+	if pruneMaxSize != "" {
+		size, err := parseSize(pruneMaxSize)
+		if err != nil {
+			return fmt.Errorf("--max-size: %w", err)
+		}
+		p.MaxSize = size
+	}
+
+	noLimits := p.MaxAge <= 0 && p.MaxEntries <= 0 && p.MaxSize <= 0
+	if noLimits && !pruneFilters.isSet() {
+		return fmt.Errorf("specify a filter or limit (use 'reaper clear' to remove everything)")
+	}
+
+	data, err := sendCommand("prune", p)
+	if err != nil {
+		return err
+	}
+
+	var result daemon.PruneResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	fmt.Printf("pruned %d entries\n", result.Deleted)
+	return nil
+}
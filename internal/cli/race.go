@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/proxy"
+)
+
+var raceCmd = &cobra.Command{
+	Use:   "race <id>",
+	Short: "Send a burst of copies or variants of a request simultaneously",
+	Long: `Send N copies of a stored request as close to simultaneously as possible,
+to find limit-overrun bugs such as coupon reuse or double withdrawal.
+
+Over HTTP/1.1 each request gets its own connection and everything but its
+last byte is sent up front; the last bytes are then released together.
+Over HTTP/2 all requests share one connection and the final frame of every
+request goes out in a single packet. HTTP/2 is used when an HTTPS server
+supports it; force a protocol with --protocol.
+
+An edited request (--edit or --template) is sent N times as written. To
+send different variants instead of copies, mark positions with §...§ in
+the raw request (--edit or --template) and give a wordlist: one request is
+sent per line, with the line in every position.
+
+Responses are stored as entries grouped under a run, and summarized by
+status and body: more than one group means the requests were not all
+treated alike. The run is listed by 'reaper fuzz runs' and its requests by
+'reaper fuzz results'. Only in-scope hosts can be raced.`,
+	Example: `  reaper race 42 -n 30
+  reaper race 42 -n 20 --protocol http1
+  reaper race 42 --edit -w codes.txt`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runRace,
+}
+
+var (
+	raceCount     int
+	raceTemplate  string
+	raceEdit      bool
+	raceWordlist  string
+	raceURLEncode bool
+	raceProtocol  string
+	raceInsecure  bool
+)
+
+func init() {
+	raceCmd.Flags().IntVarP(&raceCount, "number", "n", 20, fmt.Sprintf("Number of requests to send (at most %d)", proxy.MaxRaceRequests))
+	raceCmd.Flags().StringVarP(&raceTemplate, "template", "t", "", "Raw request file to send instead of the stored request, optionally with §-marked positions")
+	raceCmd.Flags().BoolVarP(&raceEdit, "edit", "e", false, "Edit the raw request in $EDITOR before sending")
+	raceCmd.Flags().StringVarP(&raceWordlist, "wordlist", "w", "", "Payload file: one variant per line, inserted at the marked positions")
+	raceCmd.Flags().BoolVar(&raceURLEncode, "urlencode", false, "URL-encode payloads before inserting them")
+	raceCmd.Flags().StringVar(&raceProtocol, "protocol", "", "Protocol to race over: http1 or http2 (default: http2 if the server supports it)")
+	raceCmd.Flags().BoolVarP(&raceInsecure, "insecure", "k", false, "Skip TLS certificate verification")
+	rootCmd.AddCommand(raceCmd)
+}
+
+func runRace(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid entry ID: %s", args[0])
+	}
+
+	var template []byte
+	switch {
+	case raceTemplate != "" && raceEdit:
+		return fmt.Errorf("--template and --edit are mutually exclusive")
+	case raceTemplate != "":
+		if template, err = os.ReadFile(raceTemplate); err != nil {
+			return fmt.Errorf("reading template: %w", err)
+		}
+	case raceEdit:
+		if template, err = editRequest(id); err != nil {
+			return err
+		}
+	}
+
+	var payloads []string
+	if raceWordlist != "" {
+		if template == nil {
+			return fmt.Errorf("--wordlist needs positions marked with --edit or --template")
+		}
+		if cmd.Flags().Changed("number") {
+			return fmt.Errorf("--number and --wordlist are mutually exclusive: one request is sent per payload")
+		}
+		if payloads, err = readWordlist(raceWordlist); err != nil {
+			return err
+		}
+	} else if raceCount < 1 || raceCount > proxy.MaxRaceRequests {
+		return fmt.Errorf("invalid --number %d: must be between 1 and %d", raceCount, proxy.MaxRaceRequests)
+	}
+
+	data, err := sendCommand("race", daemon.RaceParams{
+		ID:        id,
+		Count:     raceCount,
+		Template:  template,
+		Payloads:  payloads,
+		URLEncode: raceURLEncode,
+		Protocol:  raceProtocol,
+		Insecure:  raceInsecure,
+	})
+	if err != nil {
+		return err
+	}
+
+	var result daemon.RaceResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	proto := "HTTP/1.1, last-byte sync"
+	if result.Protocol == proxy.RaceHTTP2 {
+		proto = "HTTP/2, single packet"
+	}
+	run := result.Run
+	fmt.Printf("race run %d: %d requests over %s, %d errors\n\n", run.ID, run.Total, proto, run.Errors)
+
+	if len(result.Groups) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", "STATUS", pad("LENGTH", 7), pad("COUNT", 5), "ENTRIES")
+		for _, g := range result.Groups {
+			ids := make([]string, 0, 5)
+			for _, id := range g.EntryIDs[:min(len(g.EntryIDs), 5)] {
+				ids = append(ids, strconv.FormatInt(id, 10))
+			}
+			if len(g.EntryIDs) > 5 {
+				ids = append(ids, "...")
+			}
+			fmt.Fprintf(w, "%d\t%7d\t%5d\t%s\t\n", g.StatusCode, g.Length, len(g.EntryIDs), strings.Join(ids, ", "))
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
+	for _, r := range result.Results {
+		if r.Error != "" {
+			fmt.Printf("request %d: %s\n", r.Seq, r.Error)
+		}
+	}
+
+	switch len(result.Groups) {
+	case 0:
+		fmt.Println("no responses received")
+	case 1:
+		fmt.Println("all responses identical")
+	default:
+		fmt.Printf("%d distinct responses; compare them with 'reaper fuzz results %d'\n", len(result.Groups), run.ID)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/exchange"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <id>",
+	Short: "Re-send a stored request, optionally modified, and store the result",
+	Long: `Re-send the request of a stored entry through the proxy and store the
+exchange as a new entry linked to the original. Only in-scope hosts can be
+replayed.
+
+Changes are applied in order: --edit, then --method, --path, --query,
+--remove-header, --header and --body. The --jwt flags then rewrite every
+JWT in the request, as 'jwt forge' does: --jwt-claim sets claims, --jwt-alg
+none strips the signature and --jwt-secret re-signs with HMAC.`,
+	Example: `  reaper replay 42
+  reaper replay 42 --header 'Authorization: Bearer other-user-token'
+  reaper replay 42 --method PUT --body @payload.json
+  reaper replay 42 --path /api/users/43 --query debug=true
+  reaper replay 42 --edit
+  reaper replay 42 --jwt-claim role=admin --jwt-alg none
+  reaper replay 42 --jwt-claim sub=1001 --jwt-secret secret`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runReplay,
+}
+
+var (
+	replayHeaders       []string
+	replayRemoveHeaders []string
+	replayBody          string
+	replayMethod        string
+	replayPath          string
+	replayQuery         []string
+	replayEdit          bool
+	replayJWTClaims     []string
+	replayJWTAlg        string
+	replayJWTSecret     string
+)
+
+func init() {
+	replayCmd.Flags().StringArrayVarP(&replayHeaders, "header", "H", nil, "Set a header, replacing existing values ('Name: value', repeatable)")
+	replayCmd.Flags().StringArrayVar(&replayRemoveHeaders, "remove-header", nil, "Remove a header (repeatable)")
+	replayCmd.Flags().StringVar(&replayBody, "body", "", "Replace the body (@file reads a file, @- reads stdin)")
+	replayCmd.Flags().StringVarP(&replayMethod, "method", "X", "", "Replace the method")
+	replayCmd.Flags().StringVar(&replayPath, "path", "", "Replace the path (a ?query replaces the query too)")
+	replayCmd.Flags().StringArrayVar(&replayQuery, "query", nil, "Set a query parameter, replacing existing values (k=v, repeatable)")
+	replayCmd.Flags().BoolVarP(&replayEdit, "edit", "e", false, "Edit the raw request in $EDITOR before sending")
+	replayCmd.Flags().StringArrayVar(&replayJWTClaims, "jwt-claim", nil, "Set a claim in every JWT, name=value (repeatable; name= removes)")
+	replayCmd.Flags().StringVar(&replayJWTAlg, "jwt-alg", "", "Set the algorithm of every JWT: none, HS256, HS384 or HS512")
+	replayCmd.Flags().StringVar(&replayJWTSecret, "jwt-secret", "", "Re-sign every JWT with this HMAC secret")
+	rootCmd.AddCommand(replayCmd)
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid entry ID: %s", args[0])
+	}
+
+	params := daemon.ReplayParams{
+		ID:            id,
+		Method:        replayMethod,
+		Path:          replayPath,
+		Query:         replayQuery,
+		SetHeaders:    replayHeaders,
+		RemoveHeaders: replayRemoveHeaders,
+		JWTClaims:     replayJWTClaims,
+		JWTAlg:        replayJWTAlg,
+		JWTSecret:     replayJWTSecret,
+	}
+
+	if cmd.Flags().Changed("body") {
+		if params.Body, err = readBodyArg(replayBody); err != nil {
+			return err
+		}
+		params.ReplaceBody = true
+	}
+
+	if replayEdit {
+		if params.Raw, err = editRequest(id); err != nil {
+			return err
+		}
+	}
+
+	data, err := sendCommand("replay", params)
+	if err != nil {
+		return err
+	}
+
+	var e entryFull
+	if err := json.Unmarshal(data, &e); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "replayed #%d as #%d (%dms)\n", id, e.ID, e.DurationMs)
+	printRawResponse(e)
+	return nil
+}
+
+// readBodyArg returns the body named by arg: the contents of a file for
+// @path, standard input for @-, or arg itself.
+func readBodyArg(arg string) ([]byte, error) {
+	switch {
+	case arg == "@-":
+		return io.ReadAll(os.Stdin)
+	case strings.HasPrefix(arg, "@"):
+		body, err := os.ReadFile(arg[1:])
+		if err != nil {
+			return nil, fmt.Errorf("reading body: %w", err)
+		}
+		return body, nil
+	default:
+		return []byte(arg), nil
+	}
+}
+
+// editRequest opens the raw request of entry id in the user's editor and
+// returns the edited message.
+func editRequest(id int64) ([]byte, error) {
+	data, err := sendCommand("req", daemon.GetParams{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Entry storage.Entry `json:"entry"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	f, err := os.CreateTemp("", fmt.Sprintf("reaper-%d-*.http", id))
+	if err != nil {
+		return nil, fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	original := exchange.DumpRequest(&result.Entry)
+	if _, err := f.Write(original); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("writing temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("writing temp file: %w", err)
+	}
+
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+		if runtime.GOOS == "windows" {
+			editor = []string{"notepad"}
+		}
+	}
+	c := exec.Command(editor[0], append(editor[1:], f.Name())...) //nolint:gosec
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("running editor: %w", err)
+	}
+
+	edited, err := os.ReadFile(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("reading edited request: %w", err)
+	}
+	if len(bytes.TrimSpace(edited)) == 0 {
+		return nil, fmt.Errorf("edited request is empty, aborting")
+	}
+	// Most editors end the file with a newline; don't let one leak into
+	// a body that did not have it.
+	if !bytes.HasSuffix(original, []byte("\n")) {
+		edited = bytes.TrimSuffix(edited, []byte("\n"))
+	}
+	return edited, nil
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/report"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Render an engagement report as HTML or Markdown",
+	Long: `Render an engagement report from the stored traffic and findings: the
+scope, the hosts and endpoints tested, the findings grouped by severity,
+and a timeline of the engagement.
+
+Each finding comes with the request and response of its first entry as
+evidence, and a curl command that reproduces the request. Credentials and
+the secrets the scanner recognises are redacted, and bodies are cut to
+2 KB. Findings marked false-positive are left out unless --all is given.
+
+The format follows the output file's extension (.html or .md) unless
+--format is given. --template replaces the built-in template: an
+html/template file for HTML, a text/template file for Markdown. Templates
+receive the report data and can use the functions date, join, statuses,
+title, plural, fence and cell.`,
+	Example: `  reaper report -o report.html
+  reaper report -o report.md --title "ACME API assessment" --severity low
+  reaper report -o report.html --template branded.html.tmpl`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runReport,
+}
+
+var (
+	reportOutput   string
+	reportFormat   string
+	reportTemplate string
+	reportTitle    string
+	reportSeverity string
+	reportAll      bool
+)
+
+func init() {
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "File to write the report to")
+	reportCmd.Flags().StringVar(&reportFormat, "format", "", "Format: "+strings.Join(report.Formats, ", ")+" (default from the file extension)")
+	reportCmd.Flags().StringVar(&reportTemplate, "template", "", "Template file to render instead of the built-in one")
+	reportCmd.Flags().StringVar(&reportTitle, "title", "", "Report title")
+	reportCmd.Flags().StringVarP(&reportSeverity, "severity", "s", "", "Minimum severity of findings: "+strings.Join(storage.Severities, ", "))
+	reportCmd.Flags().BoolVar(&reportAll, "all", false, "Include findings marked false-positive")
+	_ = reportCmd.MarkFlagRequired("output")
+	rootCmd.AddCommand(reportCmd)
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	format := reportFormat
+	if format == "" {
+		var ok bool
+		if format, ok = report.FormatFromPath(reportOutput); !ok {
+			return fmt.Errorf("cannot tell the format of %s: use --format %s", reportOutput, strings.Join(report.Formats, "|"))
+		}
+	} else if !slices.Contains(report.Formats, format) {
+		return fmt.Errorf("invalid format %q: want one of %s", format, strings.Join(report.Formats, ", "))
+	}
+	if reportSeverity != "" && !slices.Contains(storage.Severities, reportSeverity) {
+		return fmt.Errorf("invalid severity %q: want one of %s", reportSeverity, strings.Join(storage.Severities, ", "))
+	}
+
+	in, err := reportInput()
+	if err != nil {
+		return err
+	}
+	r := report.Build(in)
+
+	f, err := os.Create(reportOutput)
+	if err != nil {
+		return err
+	}
+	if err := report.Render(f, r, format, reportTemplate); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s report to %s: %d hosts, %d endpoints, %d findings\n",
+		format, reportOutput, len(r.Hosts), r.Endpoints, r.Findings)
+	return nil
+}
+
+// reportInput gathers the report data from the daemon.
+func reportInput() (report.Input, error) {
+	in := report.Input{Title: reportTitle, Entries: map[int64]*storage.Entry{}, Now: time.Now()}
+
+	data, err := sendCommand("scope", nil)
+	if err != nil {
+		return in, err
+	}
+	var scope daemon.ScopeResult
+	if err := json.Unmarshal(data, &scope); err != nil {
+		return in, fmt.Errorf("decoding response: %w", err)
+	}
+	in.Scope = report.Scope{Domains: scope.Domains, Hosts: scope.Hosts, Started: scope.Started}
+
+	data, err = sendCommand("endpoints", daemon.EndpointsParams{})
+	if err != nil {
+		return in, err
+	}
+	if err := json.Unmarshal(data, &in.Endpoints); err != nil {
+		return in, fmt.Errorf("decoding response: %w", err)
+	}
+
+	data, err = sendCommand("findings", daemon.FindingsParams{Severity: reportSeverity})
+	if err != nil {
+		return in, err
+	}
+	var findings []*storage.Finding
+	if err := json.Unmarshal(data, &findings); err != nil {
+		return in, fmt.Errorf("decoding response: %w", err)
+	}
+	for _, f := range findings {
+		if f.Status == storage.StatusFalsePositive && !reportAll {
+			continue
+		}
+		in.Findings = append(in.Findings, f)
+
+		id := report.EvidenceID(f)
+		if _, ok := in.Entries[id]; ok || id == 0 {
+			continue
+		}
+		data, err := sendCommand("get", daemon.GetParams{ID: id})
+		if err != nil {
+			continue // deleted since: the finding is reported without evidence
+		}
+		var result struct {
+			Entry *storage.Entry `json:"entry"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return in, fmt.Errorf("decoding response: %w", err)
+		}
+		in.Entries[id] = result.Entry
+	}
+
+	data, err = sendCommand("fuzz-runs", nil)
+	if err != nil {
+		return in, err
+	}
+	if err := json.Unmarshal(data, &in.FuzzRuns); err != nil {
+		return in, fmt.Errorf("decoding response: %w", err)
+	}
+	return in, nil
+}
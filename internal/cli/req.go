@@ -12,9 +12,10 @@ var reqCmd = &cobra.Command{
 }
 
 func init() {
+	registerOffline(reqCmd)
 	rootCmd.AddCommand(reqCmd)
 }
 
 func runReq(cmd *cobra.Command, args []string) error {
-	return fetchAndPrint(args[0], "req")
+	return fetchAndPrint(cmd, args[0], "req")
 }
@@ -12,9 +12,10 @@ var resCmd = &cobra.Command{
 }
 
 func init() {
+	registerOffline(resCmd)
 	rootCmd.AddCommand(resCmd)
 }
 
 func runRes(cmd *cobra.Command, args []string) error {
-	return fetchAndPrint(args[0], "res")
+	return fetchAndPrint(cmd, args[0], "res")
 }
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// passphraseEnv names the environment variable holding the passphrase of an
+// encrypted database.
+const passphraseEnv = "REAPER_PASSPHRASE"
+
+// readSecret returns the secret for an encrypted database: the key in
+// keyFile if given, else the passphrase in $REAPER_PASSPHRASE, else one typed
+// at the terminal. confirm asks for a typed passphrase twice, for setting up
+// encryption.
+func readSecret(keyFile string, confirm bool) (storage.Secret, error) {
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return storage.Secret{}, fmt.Errorf("reading key file: %w", err)
+		}
+		key, err := storage.ParseKey(data)
+		if err != nil {
+			return storage.Secret{}, fmt.Errorf("%s: %w", keyFile, err)
+		}
+		return storage.Secret{Key: key}, nil
+	}
+
+	if p := os.Getenv(passphraseEnv); p != "" {
+		return storage.Secret{Passphrase: p}, nil
+	}
+
+	p, err := readPassword("passphrase: ")
+	if err != nil {
+		return storage.Secret{}, fmt.Errorf("reading passphrase (or set %s, or use --key-file): %w", passphraseEnv, err)
+	}
+	if p == "" {
+		return storage.Secret{}, errors.New("empty passphrase")
+	}
+	if confirm {
+		again, err := readPassword("repeat passphrase: ")
+		if err != nil {
+			return storage.Secret{}, fmt.Errorf("reading passphrase: %w", err)
+		}
+		if again != p {
+			return storage.Secret{}, errors.New("passphrases do not match")
+		}
+	}
+	return storage.Secret{Passphrase: p}, nil
+}
+
+// readLine reads a line from f a byte at a time, so that nothing after it is
+// consumed, and returns it without the line ending.
+func readLine(f *os.File) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := f.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			if len(line) > 0 {
+				break
+			}
+			return "", err
+		}
+	}
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return string(line), nil
+}
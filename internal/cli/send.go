@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+var sendCmd = &cobra.Command{
+	Use:   "send [file]",
+	Short: "Send a raw HTTP request byte-for-byte and store the exchange",
+	Long: `Send a raw HTTP request read from a file, or from stdin when no file or
+"-" is given, to a target over TLS or plain TCP. The bytes are written
+exactly as given: line endings, header case and framing are not changed,
+so malformed and smuggling requests reach the target intact. The raw
+response is printed and the exchange is stored as an entry.
+
+The target host defaults to the request's Host header. Only in-scope hosts
+can be sent to.`,
+	Example: `  reaper send request.txt
+  reaper send --host api.example.com --port 8443 smuggle.txt
+  printf 'GET / HTTP/1.0\r\n\r\n' | reaper send --host example.com --scheme http`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE:         runSend,
+}
+
+var (
+	sendHost     string
+	sendPort     int
+	sendScheme   string
+	sendInsecure bool
+	sendCRLF     bool
+)
+
+func init() {
+	sendCmd.Flags().StringVar(&sendHost, "host", "", "Target host (defaults to the Host header)")
+	sendCmd.Flags().IntVar(&sendPort, "port", 0, "Target port (defaults to the Host header port or the scheme default)")
+	sendCmd.Flags().StringVar(&sendScheme, "scheme", "https", "https to send over TLS, http for plain TCP")
+	sendCmd.Flags().BoolVarP(&sendInsecure, "insecure", "k", false, "Skip TLS certificate verification")
+	sendCmd.Flags().BoolVar(&sendCRLF, "crlf", false, "Convert bare LF line endings to CRLF before sending")
+	rootCmd.AddCommand(sendCmd)
+}
+
+func runSend(cmd *cobra.Command, args []string) error {
+	if sendScheme != "https" && sendScheme != "http" {
+		return fmt.Errorf("invalid scheme %q: must be https or http", sendScheme)
+	}
+
+	var raw []byte
+	var err error
+	if len(args) == 0 || args[0] == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("reading request: %w", err)
+	}
+	if len(raw) == 0 {
+		return fmt.Errorf("request is empty")
+	}
+	if sendCRLF {
+		raw = bytes.ReplaceAll(bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n"))
+	}
+
+	host, port := sendHost, sendPort
+	if host == "" {
+		authority := hostHeader(raw)
+		if authority == "" {
+			return fmt.Errorf("request has no Host header; use --host")
+		}
+		host = authority
+		if h, p, err := net.SplitHostPort(authority); err == nil {
+			host = h
+			if port == 0 {
+				port, _ = strconv.Atoi(p)
+			}
+		}
+	}
+	if port == 0 {
+		port = storage.DefaultPort(sendScheme)
+	}
+
+	data, err := sendCommand("send", daemon.SendParams{
+		Host:     strings.Trim(host, "[]"),
+		Port:     port,
+		TLS:      sendScheme == "https",
+		Insecure: sendInsecure,
+		Raw:      raw,
+	})
+	if err != nil {
+		return err
+	}
+
+	var result daemon.SendResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "stored as #%d\n", result.ID)
+	_, err = os.Stdout.Write(result.Response)
+	return err
+}
+
+// hostHeader returns the value of the first Host header in raw.
+func hostHeader(raw []byte) string {
+	lines := strings.Split(string(raw), "\n")
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			break
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(k), "Host") {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
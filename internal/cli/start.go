@@ -1,19 +1,59 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/ghostsecurity/reaper/internal/daemon"
+	"github.com/ghostsecurity/reaper/internal/redact"
+	"github.com/ghostsecurity/reaper/internal/scanner"
+	"github.com/ghostsecurity/reaper/internal/storage"
 )
 
 var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start the proxy",
-	RunE:  runStart,
+	Long: `Start the proxy, recording traffic to in-scope hosts.
+
+With --redact, sensitive data is removed from each entry before it is
+stored; traffic is forwarded unchanged. By default, credentials in the
+Authorization, Proxy-Authorization, Cookie and Set-Cookie headers are
+replaced with keyed hashes, so requests made as different users can still
+be told apart, and card numbers, SSNs and emails are masked wherever they
+appear. Replaying a redacted entry sends the redacted values.
+
+--redact-rules adds rules, or overrides the defaults by id, from a JSON
+array. Each rule names one header, JSON path or regular expression and a
+mode: mask, hash or drop.
+
+  [
+    {"id": "email", "disabled": true},
+    {"id": "password", "json_path": "$..password", "mode": "drop"},
+    {"id": "api-key", "header": "X-Api-Key", "mode": "hash"},
+    {"id": "token-param", "pattern": "token=(?P<value>[^&]+)"}
+  ]
+
+With --encrypt, request and response headers and bodies, and the headers
+of authz identities, are sealed with AES-256-GCM before they are stored,
+and those already stored are sealed too. Methods, hosts, paths, queries,
+status codes, tags, notes, finding evidence and notes, and fuzz payloads
+stay in the clear. The key is derived from a passphrase, read from $REAPER_PASSPHRASE
+or typed at the prompt, or read from --key-file, holding 32 bytes raw or as
+64 hex digits. Once the database is encrypted, every start needs the same
+passphrase or key file; there is no way to recover entries without it.`,
+	Example: `  reaper start --domains example.com
+  reaper start --domains example.com --redact -d
+  reaper start --hosts api.example.com --redact-rules redact.json
+  reaper start --domains example.com --encrypt -d
+  reaper start --domains example.com --encrypt --key-file ~/.reaper.key`,
+	RunE: runStart,
 }
 
 var (
@@ -22,6 +62,19 @@ var (
 	startPort     int
 	startDaemon   bool
 	startInternal bool
+
+	startMaxAge     time.Duration
+	startMaxEntries int
+	startMaxDBSize  string
+
+	startSecretRules string
+
+	startRedact      bool
+	startRedactRules string
+
+	startEncrypt  bool
+	startKeyFile  string
+	startKeyStdin bool
 )
 
 func init() {
@@ -31,6 +84,16 @@ func init() {
 	startCmd.Flags().BoolVarP(&startDaemon, "daemon", "d", false, "Run as background daemon")
 	startCmd.Flags().BoolVar(&startInternal, "internal", false, "Internal flag for daemon child process")
 	_ = startCmd.Flags().MarkHidden("internal")
+	startCmd.Flags().DurationVar(&startMaxAge, "max-age", 0, "Prune entries older than this (e.g. 72h)")
+	startCmd.Flags().IntVar(&startMaxEntries, "max-entries", 0, "Keep at most this many entries")
+	startCmd.Flags().StringVar(&startMaxDBSize, "max-db-size", "", "Prune oldest entries to keep the database under this size (e.g. 500MB)")
+	startCmd.Flags().StringVar(&startSecretRules, "secret-rules", "", "JSON file of secret detection rules adding to or overriding the defaults")
+	startCmd.Flags().BoolVar(&startRedact, "redact", false, "Redact credentials, card numbers, SSNs and emails before storing traffic")
+	startCmd.Flags().StringVar(&startRedactRules, "redact-rules", "", "JSON file of redaction rules adding to or overriding the defaults (implies --redact)")
+	startCmd.Flags().BoolVar(&startEncrypt, "encrypt", false, "Encrypt stored headers and bodies, including those already stored")
+	startCmd.Flags().StringVar(&startKeyFile, "key-file", "", "File holding the encryption key, instead of a passphrase")
+	startCmd.Flags().BoolVar(&startKeyStdin, "key-stdin", false, "Internal flag: read the encryption secret from stdin")
+	_ = startCmd.Flags().MarkHidden("key-stdin")
 
 	rootCmd.AddCommand(startCmd)
 }
@@ -45,6 +108,54 @@ func runStart(cmd *cobra.Command, args []string) error {
 		Hosts:   startHosts,
 		Port:    startPort,
 		Daemon:  startInternal,
+		Retention: storage.RetentionPolicy{
+			MaxAge:     startMaxAge,
+			MaxEntries: startMaxEntries,
+		},
+	}
+	if startMaxDBSize != "" {
+		size, err := parseSize(startMaxDBSize)
+		if err != nil {
+			return fmt.Errorf("--max-db-size: %w", err)
+		}
+		cfg.Retention.MaxSize = size
+	}
+
+	if startSecretRules != "" {
+		// The daemon runs from /, and bad rules should fail here, not there.
+		path, err := filepath.Abs(startSecretRules)
+		if err != nil {
+			return fmt.Errorf("--secret-rules: %w", err)
+		}
+		if _, err := scanner.LoadSecretRules(path); err != nil {
+			return fmt.Errorf("--secret-rules: %w", err)
+		}
+		cfg.SecretRules = path
+	}
+
+	cfg.Redact = startRedact
+	if startRedactRules != "" {
+		path, err := filepath.Abs(startRedactRules)
+		if err != nil {
+			return fmt.Errorf("--redact-rules: %w", err)
+		}
+		if _, err := redact.LoadRules(path); err != nil {
+			return fmt.Errorf("--redact-rules: %w", err)
+		}
+		cfg.RedactRules = path
+	}
+
+	cfg.Encrypt = startEncrypt
+	if startKeyStdin {
+		if err := json.NewDecoder(os.Stdin).Decode(&cfg.Secret); err != nil {
+			return fmt.Errorf("reading encryption secret: %w", err)
+		}
+	} else {
+		secret, err := startSecret()
+		if err != nil {
+			return err
+		}
+		cfg.Secret = secret
 	}
 
 	if startDaemon && !startInternal {
@@ -54,6 +165,49 @@ func runStart(cmd *cobra.Command, args []string) error {
 	return daemon.Run(cfg)
 }
 
+// startSecret returns the secret of the database if it is encrypted, or
+// will be because of --encrypt, checking it against the database so that a
+// wrong one fails here rather than in the daemon.
+func startSecret() (storage.Secret, error) {
+	dataDir, err := daemon.DataDir()
+	if err != nil {
+		return storage.Secret{}, err
+	}
+	path := daemon.DBPath(dataDir)
+
+	encrypted := false
+	if _, err := os.Stat(path); err == nil {
+		store, err := storage.OpenSQLiteStore(path)
+		if err != nil {
+			return storage.Secret{}, err
+		}
+		defer store.Close()
+		encrypted = store.Encrypted()
+		if encrypted {
+			secret, err := readSecret(startKeyFile, false)
+			if err != nil {
+				return storage.Secret{}, err
+			}
+			return secret, store.Unlock(secret)
+		}
+	}
+
+	if !startEncrypt {
+		if startKeyFile != "" {
+			return storage.Secret{}, fmt.Errorf("--key-file: the database is not encrypted (use --encrypt)")
+		}
+		return storage.Secret{}, nil
+	}
+	if startKeyFile != "" {
+		path, err := filepath.Abs(startKeyFile)
+		if err != nil {
+			return storage.Secret{}, fmt.Errorf("--key-file: %w", err)
+		}
+		startKeyFile = path
+	}
+	return readSecret(startKeyFile, true)
+}
+
 func daemonize(cfg daemon.Config) error {
 	exe, err := os.Executable()
 	if err != nil {
@@ -67,15 +221,59 @@ func daemonize(cfg daemon.Config) error {
 	if len(cfg.Hosts) > 0 {
 		daemonArgs = append(daemonArgs, "--hosts", strings.Join(cfg.Hosts, ","))
 	}
+	if cfg.Retention.MaxAge > 0 {
+		daemonArgs = append(daemonArgs, "--max-age", cfg.Retention.MaxAge.String())
+	}
+	if cfg.Retention.MaxEntries > 0 {
+		daemonArgs = append(daemonArgs, "--max-entries", strconv.Itoa(cfg.Retention.MaxEntries))
+	}
+	if cfg.Retention.MaxSize > 0 {
+		daemonArgs = append(daemonArgs, "--max-db-size", strconv.FormatInt(cfg.Retention.MaxSize, 10))
+	}
+
+	if cfg.SecretRules != "" {
+		daemonArgs = append(daemonArgs, "--secret-rules", cfg.SecretRules)
+	}
+	if cfg.Redact {
+		daemonArgs = append(daemonArgs, "--redact")
+	}
+	if cfg.RedactRules != "" {
+		daemonArgs = append(daemonArgs, "--redact-rules", cfg.RedactRules)
+	}
+	if cfg.Encrypt {
+		daemonArgs = append(daemonArgs, "--encrypt")
+	}
+
+	// The secret goes to the child through a pipe on its stdin, where other
+	// users cannot see it as they could arguments or the environment.
+	stdin := os.Stdin
+	var secretPipe *os.File
+	if !cfg.Secret.IsZero() {
+		daemonArgs = append(daemonArgs, "--key-stdin")
+		if stdin, secretPipe, err = os.Pipe(); err != nil {
+			return fmt.Errorf("starting daemon: %w", err)
+		}
+		defer stdin.Close()
+	}
 
 	proc, err := os.StartProcess(exe, append([]string{exe}, daemonArgs...), &os.ProcAttr{
 		Dir:   "/",
-		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr},
+		Files: []*os.File{stdin, os.Stdout, os.Stderr},
 		Sys:   daemonSysProcAttr(),
 	})
 	if err != nil {
+		if secretPipe != nil {
+			secretPipe.Close()
+		}
 		return fmt.Errorf("starting daemon: %w", err)
 	}
+	if secretPipe != nil {
+		err := json.NewEncoder(secretPipe).Encode(cfg.Secret)
+		secretPipe.Close()
+		if err != nil {
+			return fmt.Errorf("passing encryption secret to daemon: %w", err)
+		}
+	}
 
 	// Detach from child — let it run independently
 	if err := proc.Release(); err != nil {
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ghostsecurity/reaper/internal/daemon"
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag <id> <tag>...",
+	Short: "Add or remove tags on an entry",
+	Example: `  reaper tag 42 idor-candidate
+  reaper tag 42 idor-candidate --remove
+  reaper search --tag idor-candidate`,
+	Args:         cobra.MinimumNArgs(2),
+	SilenceUsage: true,
+	RunE:         runTag,
+}
+
+var tagRemove bool
+
+func init() {
+	tagCmd.Flags().BoolVar(&tagRemove, "remove", false, "Remove the tags instead of adding them")
+	rootCmd.AddCommand(tagCmd)
+}
+
+func runTag(cmd *cobra.Command, args []string) error {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid entry ID: %s", args[0])
+	}
+
+	if _, err := sendCommand("tag", daemon.TagParams{ID: id, Tags: args[1:], Remove: tagRemove}); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,11 +1,11 @@
 package cli
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/spf13/cobra"
 
@@ -13,13 +13,29 @@ import (
 )
 
 var tailCmd = &cobra.Command{
-	Use:          "tail",
-	Short:        "Stream new proxy log entries in real-time",
+	Use:   "tail",
+	Short: "Stream new proxy log entries in real-time",
+	Long: `Stream new proxy log entries in real-time.
+
+The daemon pushes each request as it completes, over a single connection.
+Filters select which are shown; --all also shows requests outside the
+scope, which are forwarded but not stored.`,
+	Example: `  reaper tail
+  reaper tail --host api.example.com --status 500
+  reaper tail --path '/api/*' --method POST`,
 	SilenceUsage: true,
 	RunE:         runTail,
 }
 
+var tailParams daemon.SubscribeParams
+
 func init() {
+	tailCmd.Flags().StringVar(&tailParams.Method, "method", "", "Filter by HTTP method")
+	tailCmd.Flags().StringVar(&tailParams.Host, "host", "", "Filter by host (supports * wildcard)")
+	tailCmd.Flags().StringSliceVar(&tailParams.Domains, "domains", nil, "Filter by domain suffix")
+	tailCmd.Flags().StringVar(&tailParams.Path, "path", "", "Filter by path prefix or glob")
+	tailCmd.Flags().IntVar(&tailParams.Status, "status", 0, "Filter by status code")
+	tailCmd.Flags().BoolVar(&tailParams.All, "all", false, "Include requests outside the scope, which are not stored")
 	rootCmd.AddCommand(tailCmd)
 }
 
@@ -29,59 +45,39 @@ func runTail(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	client := daemon.NewClient(dataDir)
-
-	// Establish high-water mark from existing entries
-	params, _ := json.Marshal(daemon.LogsParams{Limit: 1})
-	resp, err := client.Send(daemon.Request{Command: "logs", Params: params})
+	sub, err := daemon.NewClient(dataDir).Subscribe(tailParams)
 	if err != nil {
-		return fmt.Errorf("no running daemon found: %w", err)
-	}
-	if !resp.OK {
-		return fmt.Errorf("%s", resp.Error)
-	}
-
-	var lastID int64
-	var existing []entryRow
-	if err := json.Unmarshal(resp.Data, &existing); err == nil && len(existing) > 0 {
-		lastID = existing[0].ID // logs returns DESC order, so first is highest
+		return err
 	}
+	defer sub.Close()
 
 	ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
+	context.AfterFunc(ctx, func() { sub.Close() })
 
 	fmt.Println("tailing proxy logs... (ctrl+c to stop)")
 
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
-
 	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case <-ticker.C:
-			tailParams, _ := json.Marshal(daemon.TailParams{AfterID: lastID})
-			resp, err := client.Send(daemon.Request{Command: "tail", Params: tailParams})
-			if err != nil {
-				return fmt.Errorf("daemon connection lost")
-			}
-			if !resp.OK {
-				return fmt.Errorf("%s", resp.Error)
-			}
-
-			var entries []entryRow
-			if err := json.Unmarshal(resp.Data, &entries); err != nil {
-				continue
+		ev, err := sub.Next()
+		if err == io.EOF {
+			if ctx.Err() != nil {
+				return nil
 			}
+			return fmt.Errorf("daemon connection lost")
+		}
+		if err != nil {
+			return err
+		}
 
-			for _, e := range entries {
-				ts := e.Timestamp.Local().Format("15:04:05")
-				url := fmt.Sprintf("%s://%s%s", e.Scheme, e.Host, e.Path)
-				fmt.Printf("%s %s %s %d %dms\n", ts, e.Method, url, e.StatusCode, e.DurationMs)
-				if e.ID > lastID {
-					lastID = e.ID
-				}
-			}
+		if ev.Dropped > 0 {
+			fmt.Printf("... %d entries skipped\n", ev.Dropped)
+		}
+		ts := ev.Time.Local().Format("15:04:05")
+		url := fmt.Sprintf("%s://%s%s", ev.Scheme, ev.Host, ev.Path)
+		line := fmt.Sprintf("%s %s %s %d %dms", ts, ev.Method, url, ev.StatusCode, ev.DurationMs)
+		if !ev.Intercepted {
+			line += " (not stored)"
 		}
+		fmt.Println(line)
 	}
 }
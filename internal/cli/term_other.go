@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !windows
+
+package cli
+
+import "errors"
+
+// readPassword is not supported on this platform.
+func readPassword(prompt string) (string, error) {
+	return "", errors.New("cannot read a passphrase without echo on this platform")
+}
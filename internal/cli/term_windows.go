@@ -0,0 +1,31 @@
+//go:build windows
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// readPassword prints prompt to stderr and reads a line from the console
+// on stdin without echoing it.
+func readPassword(prompt string) (string, error) {
+	h := windows.Handle(os.Stdin.Fd())
+	var old uint32
+	if err := windows.GetConsoleMode(h, &old); err != nil {
+		return "", errors.New("stdin is not a terminal")
+	}
+
+	mode := old&^windows.ENABLE_ECHO_INPUT | windows.ENABLE_LINE_INPUT | windows.ENABLE_PROCESSED_INPUT
+	if err := windows.SetConsoleMode(h, mode); err != nil {
+		return "", err
+	}
+	defer windows.SetConsoleMode(h, old) //nolint:errcheck
+
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+	return readLine(os.Stdin)
+}
@@ -0,0 +1,231 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/authz"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// authzQueueSize bounds the proxied entries waiting to be tested
+// automatically. Entries arriving while the queue is full are not tested.
+const authzQueueSize = 256
+
+func (s *IPCServer) handleIdentityAdd(params json.RawMessage) Response {
+	var p IdentityParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+	if p.Name == "" {
+		return Response{Error: "identity name is required"}
+	}
+	if len(p.Headers) == 0 && len(p.Remove) == 0 {
+		return Response{Error: "identity needs headers to set or remove"}
+	}
+
+	id := &storage.Identity{Name: p.Name, Headers: http.Header{}}
+	for _, kv := range p.Headers {
+		k, v, ok := strings.Cut(kv, ":")
+		if !ok || strings.TrimSpace(k) == "" {
+			return Response{Error: fmt.Sprintf("invalid header %q: want 'Name: value'", kv)}
+		}
+		id.Headers.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	for _, name := range p.Remove {
+		id.Remove = append(id.Remove, http.CanonicalHeaderKey(strings.TrimSpace(name)))
+	}
+
+	if err := s.store.SaveIdentity(id); err != nil {
+		return Response{Error: err.Error()}
+	}
+	return Response{OK: true}
+}
+
+func (s *IPCServer) handleIdentityList() Response {
+	identities, err := s.store.ListIdentities()
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(identities)
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleIdentityRemove(params json.RawMessage) Response {
+	var p IdentityParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	if err := s.store.DeleteIdentity(p.Name); err != nil {
+		return Response{Error: err.Error()}
+	}
+	return Response{OK: true}
+}
+
+func (s *IPCServer) handleAuthzTest(params json.RawMessage) Response {
+	var p AuthzTestParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	identities, err := s.store.ListIdentities()
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	if len(p.Identities) > 0 {
+		var selected []*storage.Identity
+		for _, name := range p.Identities {
+			i := slices.IndexFunc(identities, func(id *storage.Identity) bool { return id.Name == name })
+			if i < 0 {
+				return Response{Error: fmt.Sprintf("identity %q not found", name)}
+			}
+			selected = append(selected, identities[i])
+		}
+		identities = selected
+	}
+	if len(identities) == 0 {
+		return Response{Error: "no identities defined; add one with 'reaper authz identity add'"}
+	}
+
+	entry, err := s.store.Get(p.ID)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(s.testAuthz(entry, identities))
+	return Response{OK: true, Data: data}
+}
+
+// testAuthz replays entry as each identity in parallel and records how the
+// responses compare with the original.
+func (s *IPCServer) testAuthz(entry *storage.Entry, identities []*storage.Identity) []*storage.AuthzResult {
+	results := make([]*storage.AuthzResult, len(identities))
+
+	var wg sync.WaitGroup
+	for i, id := range identities {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := &storage.AuthzResult{
+				EntryID:        entry.ID,
+				Identity:       id.Name,
+				OriginalStatus: entry.StatusCode,
+				TestedAt:       time.Now(),
+			}
+
+			req := authz.Apply(id, entry)
+			req.ParentID = entry.ID
+			replayed, err := s.proxy.Replay(req)
+			if err != nil {
+				result.Verdict = storage.VerdictUncertain
+				result.Error = err.Error()
+			} else {
+				result.ReplayID = replayed.ID
+				result.Status = replayed.StatusCode
+				result.Verdict, result.Similarity = authz.Classify(entry, replayed)
+			}
+
+			_ = s.store.SaveAuthzResult(result)
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// queueAuthz queues a proxied entry for automatic testing, if enabled,
+// without blocking.
+func (s *IPCServer) queueAuthz(e *storage.Entry) {
+	s.authzMu.Lock()
+	enabled := s.authzAuto
+	s.authzMu.Unlock()
+
+	// Failed requests have no access to bypass.
+	if !enabled || e.ParentID != 0 || e.RunID != 0 || e.StatusCode >= 400 {
+		return
+	}
+	select {
+	case s.authzQueue <- e:
+	default:
+	}
+}
+
+// runAutoAuthz tests queued entries as every identity.
+func (s *IPCServer) runAutoAuthz() {
+	for e := range s.authzQueue {
+		s.authzMu.Lock()
+		enabled := s.authzAuto
+		s.authzMu.Unlock()
+		if !enabled {
+			continue
+		}
+
+		identities, err := s.store.ListIdentities()
+		if err != nil || len(identities) == 0 {
+			continue
+		}
+		s.testAuthz(e, identities)
+	}
+}
+
+func (s *IPCServer) handleAuthzAuto(params json.RawMessage) Response {
+	var p AuthzAutoParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return Response{Error: "invalid params"}
+		}
+	}
+
+	s.authzMu.Lock()
+	if p.Enable != nil {
+		s.authzAuto = *p.Enable
+	}
+	result := AuthzAutoResult{Enabled: s.authzAuto, Queued: len(s.authzQueue)}
+	s.authzMu.Unlock()
+
+	identities, err := s.store.ListIdentities()
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	result.Identities = len(identities)
+
+	data, _ := json.Marshal(result)
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleAuthzReport(params json.RawMessage) Response {
+	var p AuthzReportParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return Response{Error: "invalid params"}
+		}
+	}
+
+	results, err := s.store.AuthzResults(p.Filter.storageParams())
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	if p.Verdict != "" {
+		matched := map[int64]bool{}
+		for _, r := range results {
+			if r.Verdict == p.Verdict {
+				matched[r.EntryID] = true
+			}
+		}
+		results = slices.DeleteFunc(results, func(r *storage.AuthzResult) bool {
+			return !matched[r.EntryID]
+		})
+	}
+
+	data, _ := json.Marshal(results)
+	return Response{OK: true, Data: data}
+}
@@ -3,12 +3,19 @@ package daemon
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"path/filepath"
 	"time"
 )
 
+// ErrNoDaemon is returned by Client methods when the daemon's socket cannot
+// be dialed, as when no daemon is running. Failures after connecting, such
+// as a timeout waiting for the response, are not wrapped in it.
+var ErrNoDaemon = errors.New("no running daemon found")
+
 type Client struct {
 	sockPath string
 }
@@ -22,7 +29,7 @@ func NewClient(dataDir string) *Client {
 func (c *Client) Send(req Request) (*Response, error) {
 	conn, err := net.DialTimeout("unix", c.sockPath, 5*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("connecting to daemon: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrNoDaemon, err)
 	}
 	defer conn.Close()
 
@@ -55,6 +62,65 @@ func (c *Client) Send(req Request) (*Response, error) {
 	return &resp, nil
 }
 
+// Subscription is a connection on which the daemon pushes events.
+type Subscription struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+// Subscribe asks the daemon to push the events matching p.
+func (c *Client) Subscribe(p SubscribeParams) (*Subscription, error) {
+	conn, err := net.DialTimeout("unix", c.sockPath, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoDaemon, err)
+	}
+
+	params, _ := json.Marshal(p)
+	data, _ := json.Marshal(Request{Command: "subscribe", Params: params})
+	data = append(data, '\n')
+	if _, err := conn.Write(data); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("writing request: %w", err)
+	}
+
+	sub := &Subscription{conn: conn, scanner: bufio.NewScanner(conn)}
+	if !sub.scanner.Scan() {
+		conn.Close()
+		return nil, fmt.Errorf("no response from daemon")
+	}
+	var resp Response
+	if err := json.Unmarshal(sub.scanner.Bytes(), &resp); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	if !resp.OK {
+		conn.Close()
+		return nil, errors.New(resp.Error)
+	}
+	return sub, nil
+}
+
+// Next waits for the next event. It returns io.EOF once the subscription
+// is closed, by Close or by the daemon shutting down.
+func (s *Subscription) Next() (SubscribeEvent, error) {
+	var ev SubscribeEvent
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+			return ev, fmt.Errorf("reading event: %w", err)
+		}
+		return ev, io.EOF
+	}
+	if err := json.Unmarshal(s.scanner.Bytes(), &ev); err != nil {
+		return ev, fmt.Errorf("decoding event: %w", err)
+	}
+	return ev, nil
+}
+
+// Close ends the subscription. It may be called while Next is waiting.
+func (s *Subscription) Close() error {
+	return s.conn.Close()
+}
+
 func WaitForSocket(dataDir string) error {
 	sockPath := filepath.Join(dataDir, "reaper.sock")
 	for i := 0; i < 30; i++ {
@@ -11,15 +11,26 @@ import (
 	"time"
 
 	"github.com/ghostsecurity/reaper/internal/proxy"
+	"github.com/ghostsecurity/reaper/internal/redact"
+	"github.com/ghostsecurity/reaper/internal/scanner"
 	"github.com/ghostsecurity/reaper/internal/storage"
 	"github.com/ghostsecurity/reaper/version"
 )
 
 type Config struct {
-	Domains []string
-	Hosts   []string
-	Port    int
-	Daemon  bool
+	Domains   []string
+	Hosts     []string
+	Port      int
+	Daemon    bool
+	Retention storage.RetentionPolicy
+
+	SecretRules string // JSON file of secret rules adding to or overriding the defaults
+
+	Redact      bool   // redact entries before storing them
+	RedactRules string // JSON file of redaction rules adding to or overriding the defaults; implies Redact
+
+	Encrypt bool           // encrypt the database if it is not already
+	Secret  storage.Secret // passphrase or key of an encrypted database
 }
 
 func DataDir() (string, error) {
@@ -36,6 +47,11 @@ func DataDir() (string, error) {
 	return dir, nil
 }
 
+// DBPath returns the path of the traffic database in dataDir.
+func DBPath(dataDir string) string {
+	return filepath.Join(dataDir, "reaper.db")
+}
+
 func Run(cfg Config) error {
 	dataDir, err := DataDir()
 	if err != nil {
@@ -55,33 +71,87 @@ func Run(cfg Config) error {
 	}
 
 	// Init storage
-	dbPath := filepath.Join(dataDir, "reaper.db")
-	store, err := storage.NewSQLiteStore(dbPath)
+	store, err := storage.NewSQLiteStore(DBPath(dataDir))
 	if err != nil {
 		return fmt.Errorf("opening storage: %w", err)
 	}
 	defer store.Close()
 
+	switch {
+	case store.Encrypted():
+		if cfg.Secret.IsZero() {
+			return storage.ErrLocked
+		}
+		if err := store.Unlock(cfg.Secret); err != nil {
+			return err
+		}
+		cfg.Encrypt = true
+	case cfg.Encrypt:
+		if err := store.Encrypt(cfg.Secret); err != nil {
+			return fmt.Errorf("encrypting database: %w", err)
+		}
+	}
+
+	// Everything saved goes through the redactor, if configured: proxied
+	// traffic, replays, fuzz and race requests and imports.
+	var saveStore storage.Store = store
+	var redactor *redact.Redactor
+	if cfg.Redact || cfg.RedactRules != "" {
+		redactor, err = newRedactor(cfg, dataDir)
+		if err != nil {
+			return err
+		}
+		saveStore = &redact.Store{Store: store, Redactor: redactor}
+	}
+
 	// Create proxy
 	scope := proxy.NewScope(cfg.Domains, cfg.Hosts)
 	p := &proxy.Proxy{
 		Scope: scope,
-		Store: store,
+		Store: saveStore,
 		CA:    ca,
 	}
-	if !cfg.Daemon {
-		p.OnEvent = printEvent
-	}
 
 	// Start IPC server
 	shutdown := make(chan struct{})
-	ipcServer, err := NewIPCServer(dataDir, store, shutdown)
+	ipcServer, err := NewIPCServer(dataDir, saveStore, p, shutdown)
 	if err != nil {
 		return fmt.Errorf("starting IPC server: %w", err)
 	}
 	defer ipcServer.Close()
+	ipcServer.redactor = redactor
+	if cfg.SecretRules != "" {
+		rules, err := scanner.LoadSecretRules(cfg.SecretRules)
+		if err != nil {
+			return err
+		}
+		secrets, err := scanner.NewSecrets(rules)
+		if err != nil {
+			return err
+		}
+		for i, c := range ipcServer.checks {
+			if c.Name() == secrets.Name() {
+				ipcServer.checks[i] = secrets
+			}
+		}
+	}
+	p.OnSave = ipcServer.entrySaved
+	p.OnEvent = ipcServer.publish
+	if !cfg.Daemon {
+		p.OnEvent = func(e proxy.Event) {
+			printEvent(e)
+			ipcServer.publish(e)
+		}
+	}
 	go ipcServer.Serve()
 
+	// Enforce retention in the background
+	done := make(chan struct{})
+	defer close(done)
+	if !cfg.Retention.IsZero() {
+		go enforceRetention(store, cfg.Retention, !cfg.Daemon, done)
+	}
+
 	// Write PID file
 	pidPath := filepath.Join(dataDir, "reaper.pid")
 	_ = os.WriteFile(pidPath, []byte(strconv.Itoa(os.Getpid())), 0600)
@@ -139,5 +209,16 @@ func printBanner(cfg Config, dataDir string) {
 	if len(cfg.Hosts) > 0 {
 		fmt.Printf("hosts: %v\n", cfg.Hosts)
 	}
+	if !cfg.Retention.IsZero() {
+		fmt.Printf("retention: %s\n", describeRetention(cfg.Retention))
+	}
+	if cfg.RedactRules != "" {
+		fmt.Printf("redaction: default rules and %s\n", cfg.RedactRules)
+	} else if cfg.Redact {
+		fmt.Println("redaction: default rules")
+	}
+	if cfg.Encrypt {
+		fmt.Println("encryption: on")
+	}
 	fmt.Printf("started at %s\n\n", time.Now().Format(time.DateTime))
 }
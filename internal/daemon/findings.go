@@ -0,0 +1,131 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/ghostsecurity/reaper/internal/scanner"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// scanQueueSize bounds the proxied entries waiting for passive checks.
+// Entries arriving while the queue is full are not scanned.
+const scanQueueSize = 1024
+
+// queueScan queues a proxied entry for passive checks without blocking.
+func (s *IPCServer) queueScan(e *storage.Entry) {
+	select {
+	case s.scanQueue <- e:
+	default:
+	}
+}
+
+// runScanner runs the passive checks on queued entries and records their
+// findings. With redaction on, the checks see the entry as stored, since
+// findings quote it.
+func (s *IPCServer) runScanner() {
+	for e := range s.scanQueue {
+		if s.redactor != nil {
+			e = s.redactor.Redact(e)
+		}
+		for _, f := range scanner.Scan(e, s.checks) {
+			_ = s.store.SaveFinding(f)
+		}
+	}
+}
+
+func (s *IPCServer) handleFindings(params json.RawMessage) Response {
+	var p FindingsParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return Response{Error: "invalid params"}
+		}
+	}
+	if p.Check != "" && p.Check != storage.CheckManual &&
+		!slices.ContainsFunc(s.checks, func(c scanner.Check) bool { return c.Name() == p.Check }) {
+		return Response{Error: "unknown check: " + p.Check}
+	}
+
+	findings, err := s.store.Findings(storage.FindingParams{
+		Check:    p.Check,
+		Host:     p.Host,
+		Severity: p.Severity,
+		Status:   p.Status,
+		Limit:    p.Limit,
+	})
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(findings)
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleFindingGet(params json.RawMessage) Response {
+	var p GetParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	f, err := s.store.GetFinding(p.ID)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(f)
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleFindingAdd(params json.RawMessage) Response {
+	var p FindingAddParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+	if len(p.EntryIDs) == 0 {
+		return Response{Error: "at least one entry is required"}
+	}
+
+	f := &storage.Finding{
+		Title:      p.Title,
+		Severity:   p.Severity,
+		Confidence: p.Confidence,
+		Status:     p.Status,
+		Evidence:   p.Evidence,
+		Note:       p.Note,
+		EntryIDs:   p.EntryIDs,
+	}
+	if err := s.store.AddFinding(f); err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(f)
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleFindingStatus(params json.RawMessage) Response {
+	var p FindingStatusParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+	if p.Status == "" && !p.SetNote {
+		return Response{Error: "nothing to change"}
+	}
+	if p.Status != "" && !slices.Contains(storage.FindingStatuses, p.Status) {
+		return Response{Error: "unknown status: " + p.Status}
+	}
+
+	for _, id := range p.IDs {
+		if p.Status != "" {
+			if err := s.store.SetFindingStatus(id, p.Status); err != nil {
+				return Response{Error: fmt.Sprintf("finding %d: %v", id, err)}
+			}
+		}
+		if p.SetNote {
+			if err := s.store.SetFindingNote(id, p.Note); err != nil {
+				return Response{Error: fmt.Sprintf("finding %d: %v", id, err)}
+			}
+		}
+	}
+	return Response{OK: true}
+}
@@ -0,0 +1,195 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ghostsecurity/reaper/internal/exchange"
+	"github.com/ghostsecurity/reaper/internal/fuzz"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+func (s *IPCServer) handleFuzz(params json.RawMessage) Response {
+	var p FuzzParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	opts := fuzz.Options{Concurrency: p.Concurrency, Rate: p.Rate}
+	if err := opts.Validate(); err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	entry, err := s.store.Get(p.ID)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	mode, err := fuzz.ParseMode(p.Mode)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	tmpl, err := fuzz.ParseTemplate(p.Template)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	attack := &fuzz.Attack{Template: tmpl, Mode: mode, Payloads: p.Payloads, URLEncode: p.URLEncode}
+	if err := attack.Validate(); err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	// Fail fast on a template that does not parse or targets an
+	// out-of-scope host, rather than recording an error per request.
+	base, err := exchange.ParseRequest(tmpl.Defaults(), entry.Scheme)
+	if err != nil {
+		return Response{Error: fmt.Sprintf("template: %v", err)}
+	}
+	if !s.proxy.Scope.InScope(base.Host) {
+		return Response{Error: fmt.Sprintf("host %s is out of scope", base.Host)}
+	}
+
+	run := &storage.FuzzRun{EntryID: entry.ID, Mode: string(mode), Total: attack.Count(), Status: storage.FuzzRunning}
+	if err := s.store.CreateFuzzRun(run); err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.fuzzMu.Lock()
+	s.fuzzCancels[run.ID] = cancel
+	s.fuzzMu.Unlock()
+
+	go s.runFuzz(ctx, run, entry.Scheme, attack, opts)
+
+	data, _ := json.Marshal(run)
+	return Response{OK: true, Data: data}
+}
+
+// runFuzz sends every variant of attack and records a result for each.
+func (s *IPCServer) runFuzz(ctx context.Context, run *storage.FuzzRun, scheme string, attack *fuzz.Attack, opts fuzz.Options) {
+	defer func() {
+		s.fuzzMu.Lock()
+		cancel := s.fuzzCancels[run.ID]
+		delete(s.fuzzCancels, run.ID)
+		s.fuzzMu.Unlock()
+		cancel()
+	}()
+
+	err := fuzz.Run(ctx, attack, opts, func(ctx context.Context, v fuzz.Variant) {
+		result := &storage.FuzzResult{RunID: run.ID, Seq: v.Seq, Position: v.Position, Payloads: v.Payloads}
+
+		req, err := exchange.ParseRequest(v.Raw, scheme)
+		if err == nil {
+			req.ParentID = run.EntryID
+			req.RunID = run.ID
+			var e *storage.Entry
+			if e, err = s.proxy.ReplayContext(ctx, req); err == nil {
+				result.EntryID = e.ID
+				result.StatusCode = e.StatusCode
+				result.Length = len(e.ResponseBody)
+				result.DurationMs = e.DurationMs
+			}
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		_ = s.store.SaveFuzzResult(result)
+	})
+
+	status := storage.FuzzDone
+	if err != nil {
+		status = storage.FuzzStopped
+	}
+	_ = s.store.FinishFuzzRun(run.ID, status)
+}
+
+// fuzzRunning reports whether run id is in progress in this daemon.
+func (s *IPCServer) fuzzRunning(id int64) bool {
+	s.fuzzMu.Lock()
+	defer s.fuzzMu.Unlock()
+	_, ok := s.fuzzCancels[id]
+	return ok
+}
+
+// fuzzRun loads a run, marking it interrupted if it was left running by a
+// daemon that has since exited.
+func (s *IPCServer) fuzzRun(id int64) (*storage.FuzzRun, error) {
+	run, err := s.store.GetFuzzRun(id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == storage.FuzzRunning && !s.fuzzRunning(id) {
+		run.Status = storage.FuzzInterrupted
+	}
+	return run, nil
+}
+
+func (s *IPCServer) handleFuzzStatus(params json.RawMessage) Response {
+	var p FuzzRunParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	run, err := s.fuzzRun(p.RunID)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(run)
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleFuzzRuns() Response {
+	runs, err := s.store.ListFuzzRuns()
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	for _, run := range runs {
+		if run.Status == storage.FuzzRunning && !s.fuzzRunning(run.ID) {
+			run.Status = storage.FuzzInterrupted
+		}
+	}
+
+	data, _ := json.Marshal(runs)
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleFuzzResults(params json.RawMessage) Response {
+	var p FuzzRunParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	run, err := s.fuzzRun(p.RunID)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	results, err := s.store.FuzzResults(p.RunID)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	ranked := fuzz.Rank(results)
+	if p.Limit > 0 && len(ranked) > p.Limit {
+		ranked = ranked[:p.Limit]
+	}
+
+	data, _ := json.Marshal(FuzzResultsResult{Run: run, Results: ranked})
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleFuzzStop(params json.RawMessage) Response {
+	var p FuzzRunParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	s.fuzzMu.Lock()
+	cancel, ok := s.fuzzCancels[p.RunID]
+	s.fuzzMu.Unlock()
+	if !ok {
+		return Response{Error: fmt.Sprintf("fuzz run %d is not running", p.RunID)}
+	}
+	cancel()
+	return Response{OK: true}
+}
@@ -0,0 +1,132 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ghostsecurity/reaper/internal/jwt"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// jwtPageSize is the number of entries read at a time when collecting JWTs.
+const jwtPageSize = 500
+
+func (s *IPCServer) handleJWTList(params json.RawMessage) Response {
+	var p JWTListParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return Response{Error: "invalid params"}
+		}
+	}
+
+	byToken := map[string]*JWTSummary{}
+	var summaries []*JWTSummary
+	see := func(e *storage.Entry, token string, issued bool) {
+		sum, ok := byToken[token]
+		if !ok {
+			sum = &JWTSummary{Token: token, FirstID: e.ID, LastID: e.ID, LastSeen: e.Timestamp}
+			byToken[token] = sum
+			summaries = append(summaries, sum)
+		}
+		sum.Issued = sum.Issued || issued
+		if sum.Count > 0 && sum.FirstID == e.ID {
+			return // seen in both halves of the same entry
+		}
+		sum.Count++
+		sum.FirstID = min(sum.FirstID, e.ID)
+		if !slices.Contains(sum.Hosts, e.Host) {
+			sum.Hosts = append(sum.Hosts, e.Host)
+		}
+	}
+
+	filter := p.Filter.storageParams()
+	limit := filter.Limit
+	filter.Limit = jwtPageSize
+	for scanned := 0; limit <= 0 || scanned < limit; {
+		entries, err := s.store.Search(filter)
+		if err != nil {
+			return Response{Error: err.Error()}
+		}
+		for _, e := range entries {
+			// Entries come newest first, so the first sighting of a token
+			// is its latest.
+			for _, t := range jwt.FindInRequest(e) {
+				see(e, t, false)
+			}
+			for _, t := range jwt.FindInResponse(e) {
+				see(e, t, true)
+			}
+		}
+		scanned += len(entries)
+		if len(entries) < jwtPageSize {
+			break
+		}
+		filter.Offset += jwtPageSize
+	}
+
+	if summaries == nil {
+		summaries = []*JWTSummary{}
+	}
+	data, _ := json.Marshal(summaries)
+	return Response{OK: true, Data: data}
+}
+
+// applyJWT forges every JWT in the request of e with the JWT changes of p,
+// or removes them with JWTRemove.
+func (p ReplayParams) applyJWT(e *storage.Entry) error {
+	if len(p.JWTClaims) == 0 && p.JWTAlg == "" && p.JWTSecret == "" && !p.JWTRemove {
+		return nil
+	}
+
+	opts := jwt.ForgeOptions{Alg: p.JWTAlg}
+	if len(p.JWTClaims) > 0 {
+		opts.Claims = map[string]any{}
+		for _, kv := range p.JWTClaims {
+			k, v, err := jwt.ParseClaim(kv)
+			if err != nil {
+				return err
+			}
+			opts.Claims[k] = v
+		}
+	}
+	if p.JWTSecret != "" {
+		opts.Secret = []byte(p.JWTSecret)
+	}
+
+	tokens := jwt.FindInRequest(e)
+	if len(tokens) == 0 {
+		return errors.New("no JWT in the request")
+	}
+	replacements := make([]string, 0, 2*len(tokens))
+	for _, raw := range tokens {
+		if p.JWTRemove {
+			replacements = append(replacements, raw, "")
+			continue
+		}
+		t, err := jwt.Parse(raw)
+		if err != nil {
+			return err
+		}
+		forged, err := jwt.Forge(t, opts)
+		if err != nil {
+			return fmt.Errorf("forging JWT: %w", err)
+		}
+		replacements = append(replacements, raw, forged)
+	}
+
+	r := strings.NewReplacer(replacements...)
+	for _, values := range e.RequestHeaders {
+		for i, v := range values {
+			values[i] = r.Replace(v)
+		}
+	}
+	e.Path = r.Replace(e.Path)
+	e.Query = r.Replace(e.Query)
+	if len(e.RequestBody) > 0 {
+		e.RequestBody = []byte(r.Replace(string(e.RequestBody)))
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package daemon
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// localCommands are the commands Local serves: those that only read
+// entries, and so need no proxy.
+var localCommands = []string{"logs", "search", "get", "req", "res"}
+
+// Local answers a read-only command from store directly, as the daemon
+// would, for reading the database while no daemon is running.
+func Local(store storage.Store, req Request) Response {
+	if !slices.Contains(localCommands, req.Command) {
+		return Response{Error: fmt.Sprintf("%s needs a running daemon", req.Command)}
+	}
+	s := &IPCServer{store: store}
+	return s.route(req)
+}
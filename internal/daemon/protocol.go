@@ -1,9 +1,16 @@
 package daemon
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/fuzz"
+	"github.com/ghostsecurity/reaper/internal/proxy"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
 
 type Request struct {
-	Command string          `json:"command"` // "logs", "search", "get", "req", "res", "tail", "clear", "shutdown"
+	Command string          `json:"command"` // "logs", "search", "get", "req", "res", "tail", "import", "tag", "note", "highlight", "endpoints", "params", "replay", "send", "fuzz", "fuzz-status", "fuzz-runs", "fuzz-results", "fuzz-stop", "race", "authz-identity-add", "authz-identity-list", "authz-identity-remove", "authz-test", "authz-auto", "authz-report", "findings", "findings-get", "findings-add", "findings-set-status", "jwt-list", "scope", "subscribe", "prune", "clear", "shutdown"
 	Params  json.RawMessage `json:"params"`
 }
 
@@ -19,20 +26,282 @@ type LogsParams struct {
 }
 
 type SearchRequestParams struct {
+	Method   string   `json:"method,omitempty"`
+	Host     string   `json:"host,omitempty"`
+	Domains  []string `json:"domains,omitempty"`
+	Path     string   `json:"path,omitempty"`
+	Status   int      `json:"status,omitempty"`
+	Tag      string   `json:"tag,omitempty"`
+	Contains string   `json:"contains,omitempty"`
+	Limit    int      `json:"limit,omitempty"`
+	Offset   int      `json:"offset,omitempty"`
+}
+
+func (p SearchRequestParams) storageParams() storage.SearchParams {
+	return storage.SearchParams{
+		Method:   p.Method,
+		Host:     p.Host,
+		Domains:  p.Domains,
+		Path:     p.Path,
+		Status:   p.Status,
+		Tag:      p.Tag,
+		Contains: p.Contains,
+		Limit:    p.Limit,
+		Offset:   p.Offset,
+	}
+}
+
+type TailParams struct {
+	AfterID int64 `json:"after_id"`
+	Limit   int   `json:"limit"`
+}
+
+// SubscribeParams filters the events pushed to a subscriber, as the fields
+// of SearchRequestParams filter entries; an event matching any of Domains
+// passes. All includes traffic outside the scope, which is not stored.
+type SubscribeParams struct {
 	Method  string   `json:"method,omitempty"`
 	Host    string   `json:"host,omitempty"`
 	Domains []string `json:"domains,omitempty"`
 	Path    string   `json:"path,omitempty"`
 	Status  int      `json:"status,omitempty"`
-	Limit   int      `json:"limit,omitempty"`
-	Offset  int      `json:"offset,omitempty"`
+	All     bool     `json:"all,omitempty"`
 }
 
-type TailParams struct {
-	AfterID int64 `json:"after_id"`
-	Limit   int   `json:"limit"`
+// SubscribeEvent summarizes a request handled by the proxy. After the
+// Response accepting a subscribe command, the daemon writes one per line as
+// requests complete, until either side closes the connection.
+type SubscribeEvent struct {
+	ID          int64     `json:"id,omitempty"` // 0 for traffic that was not stored
+	Time        time.Time `json:"time"`
+	Method      string    `json:"method"`
+	Scheme      string    `json:"scheme"`
+	Host        string    `json:"host"`
+	Path        string    `json:"path"`
+	StatusCode  int       `json:"status_code"`
+	DurationMs  int64     `json:"duration_ms"`
+	Intercepted bool      `json:"intercepted"`
+	Dropped     int       `json:"dropped,omitempty"` // events skipped before this one because the subscriber fell behind
 }
 
 type GetParams struct {
 	ID int64 `json:"id"`
 }
+
+type ImportParams struct {
+	Entries []*storage.Entry `json:"entries"`
+}
+
+type ImportResult struct {
+	IDs []int64 `json:"ids"`
+}
+
+type TagParams struct {
+	ID     int64    `json:"id"`
+	Tags   []string `json:"tags"`
+	Remove bool     `json:"remove,omitempty"`
+}
+
+type NoteParams struct {
+	ID   int64  `json:"id"`
+	Note string `json:"note"`
+}
+
+type HighlightParams struct {
+	ID    int64  `json:"id"`
+	Color string `json:"color"`
+}
+
+type PruneParams struct {
+	Filter     SearchRequestParams `json:"filter"`
+	MaxAge     time.Duration       `json:"max_age,omitempty"`
+	MaxEntries int                 `json:"max_entries,omitempty"`
+	MaxSize    int64               `json:"max_size,omitempty"`
+}
+
+type PruneResult struct {
+	Deleted int64 `json:"deleted"`
+}
+
+type ClearParams struct {
+	Filter SearchRequestParams `json:"filter"`
+	DryRun bool                `json:"dry_run,omitempty"`
+}
+
+type ClearResult struct {
+	Deleted int64 `json:"deleted"`
+	DryRun  bool  `json:"dry_run,omitempty"`
+}
+
+type EndpointsParams struct {
+	Filter SearchRequestParams `json:"filter"`
+}
+
+type ParamsParams struct {
+	Filter   SearchRequestParams `json:"filter"`
+	Endpoint string              `json:"endpoint,omitempty"`
+}
+
+// ReplayParams selects an entry to replay and the changes to make to its
+// request. Raw, if set, replaces the stored request before the other
+// changes are applied.
+type ReplayParams struct {
+	ID            int64    `json:"id"`
+	Raw           []byte   `json:"raw,omitempty"`
+	Method        string   `json:"method,omitempty"`
+	Path          string   `json:"path,omitempty"`
+	Query         []string `json:"query,omitempty"`       // k=v, replacing existing values of k
+	SetHeaders    []string `json:"set_headers,omitempty"` // "K: V", replacing existing values of K
+	RemoveHeaders []string `json:"remove_headers,omitempty"`
+	Body          []byte   `json:"body,omitempty"`
+	ReplaceBody   bool     `json:"replace_body,omitempty"` // distinguishes an empty Body from no change
+
+	// JWT changes apply to every JWT in the request after the changes above.
+	JWTClaims []string `json:"jwt_claims,omitempty"` // name=value, JSON values allowed; name= deletes
+	JWTAlg    string   `json:"jwt_alg,omitempty"`
+	JWTSecret string   `json:"jwt_secret,omitempty"`
+	JWTRemove bool     `json:"jwt_remove,omitempty"` // remove every JWT instead, e.g. for a control request
+}
+
+type SendParams struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	TLS      bool   `json:"tls"`
+	Insecure bool   `json:"insecure,omitempty"`
+	Raw      []byte `json:"raw"`
+}
+
+type SendResult struct {
+	ID       int64  `json:"id"`
+	Response []byte `json:"response"`
+}
+
+// FuzzParams starts a fuzz run against a stored entry. Template is the raw
+// request with payload positions marked; see fuzz.ParseTemplate.
+type FuzzParams struct {
+	ID          int64      `json:"id"`
+	Template    []byte     `json:"template"`
+	Mode        string     `json:"mode"`
+	Payloads    [][]string `json:"payloads"`
+	Concurrency int        `json:"concurrency,omitempty"`
+	Rate        float64    `json:"rate,omitempty"`
+	URLEncode   bool       `json:"url_encode,omitempty"`
+}
+
+type FuzzRunParams struct {
+	RunID int64 `json:"run_id"`
+	Limit int   `json:"limit,omitempty"`
+}
+
+type FuzzResultsResult struct {
+	Run     *storage.FuzzRun `json:"run"`
+	Results []fuzz.Ranked    `json:"results"`
+}
+
+// RaceParams races copies of a stored entry. With a Template, the requests
+// are rendered from it instead: one per payload if it has §-marked
+// positions, otherwise Count copies.
+type RaceParams struct {
+	ID        int64    `json:"id"`
+	Count     int      `json:"count,omitempty"`
+	Template  []byte   `json:"template,omitempty"`
+	Payloads  []string `json:"payloads,omitempty"`
+	URLEncode bool     `json:"url_encode,omitempty"`
+	Protocol  string   `json:"protocol,omitempty"` // "http1" or "http2"; chosen per server if empty
+	Insecure  bool     `json:"insecure,omitempty"`
+}
+
+type RaceResult struct {
+	Run      *storage.FuzzRun      `json:"run"`
+	Protocol string                `json:"protocol"`
+	Results  []*storage.FuzzResult `json:"results"`
+	Groups   []proxy.RaceGroup     `json:"groups"`
+}
+
+// IdentityParams defines an identity for authz testing. Headers are
+// "Name: value" lines; Remove names headers to drop. Only Name is used when
+// removing an identity.
+type IdentityParams struct {
+	Name    string   `json:"name"`
+	Headers []string `json:"headers,omitempty"`
+	Remove  []string `json:"remove,omitempty"`
+}
+
+// AuthzTestParams replays an entry as each of Identities, or as every
+// identity if none are given.
+type AuthzTestParams struct {
+	ID         int64    `json:"id"`
+	Identities []string `json:"identities,omitempty"`
+}
+
+// AuthzAutoParams turns automatic testing of proxied traffic on or off, or
+// leaves it unchanged when Enable is nil.
+type AuthzAutoParams struct {
+	Enable *bool `json:"enable,omitempty"`
+}
+
+type AuthzAutoResult struct {
+	Enabled    bool `json:"enabled"`
+	Queued     int  `json:"queued"`
+	Identities int  `json:"identities"`
+}
+
+// AuthzReportParams selects results for entries matching Filter. With a
+// Verdict, only entries with at least one result of that verdict are
+// included, with all their results.
+type AuthzReportParams struct {
+	Filter  SearchRequestParams `json:"filter"`
+	Verdict string              `json:"verdict,omitempty"`
+}
+
+// JWTListParams selects the entries searched for JWTs.
+type JWTListParams struct {
+	Filter SearchRequestParams `json:"filter"`
+}
+
+// JWTSummary is a distinct JWT seen in traffic.
+type JWTSummary struct {
+	Token    string    `json:"token"`
+	Count    int       `json:"count"` // entries it was seen in
+	Hosts    []string  `json:"hosts"`
+	FirstID  int64     `json:"first_id"`
+	LastID   int64     `json:"last_id"`
+	LastSeen time.Time `json:"last_seen"`
+	Issued   bool      `json:"issued,omitempty"` // seen in a response
+}
+
+// ScopeResult describes the running proxy.
+type ScopeResult struct {
+	Domains []string  `json:"domains"`
+	Hosts   []string  `json:"hosts"`
+	Started time.Time `json:"started"`
+}
+
+// FindingsParams filters findings. Severity is a minimum.
+type FindingsParams struct {
+	Check    string `json:"check,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+}
+
+// FindingAddParams records a finding by hand against one or more entries.
+type FindingAddParams struct {
+	EntryIDs   []int64 `json:"entry_ids"`
+	Title      string  `json:"title"`
+	Severity   string  `json:"severity"`
+	Confidence string  `json:"confidence,omitempty"`
+	Status     string  `json:"status,omitempty"`
+	Evidence   string  `json:"evidence,omitempty"`
+	Note       string  `json:"note,omitempty"`
+}
+
+// FindingStatusParams sets the triage status of findings, and their note
+// if SetNote is true.
+type FindingStatusParams struct {
+	IDs     []int64 `json:"ids"`
+	Status  string  `json:"status,omitempty"`
+	Note    string  `json:"note,omitempty"`
+	SetNote bool    `json:"set_note,omitempty"` // distinguishes clearing the note from no change
+}
@@ -0,0 +1,136 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ghostsecurity/reaper/internal/exchange"
+	"github.com/ghostsecurity/reaper/internal/fuzz"
+	"github.com/ghostsecurity/reaper/internal/proxy"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+func (s *IPCServer) handleRace(params json.RawMessage) Response {
+	var p RaceParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	entry, err := s.store.Get(p.ID)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	reqs, payloads, err := p.requests(entry)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	if !s.proxy.Scope.InScope(reqs[0].Host) {
+		return Response{Error: fmt.Sprintf("host %s is out of scope", reqs[0].Host)}
+	}
+
+	run := &storage.FuzzRun{EntryID: entry.ID, Mode: "race", Total: len(reqs), Status: storage.FuzzRunning}
+	if err := s.store.CreateFuzzRun(run); err != nil {
+		return Response{Error: err.Error()}
+	}
+	for _, r := range reqs {
+		r.ParentID = entry.ID
+		r.RunID = run.ID
+	}
+
+	results, proto, err := s.proxy.Race(reqs, proxy.RaceOptions{Protocol: p.Protocol, Insecure: p.Insecure})
+	if err != nil {
+		_ = s.store.FinishFuzzRun(run.ID, storage.FuzzStopped)
+		return Response{Error: err.Error()}
+	}
+
+	for i, r := range results {
+		result := &storage.FuzzResult{RunID: run.ID, Seq: i + 1}
+		if payloads != nil {
+			result.Payloads = []string{payloads[i]}
+		}
+		if r.Err != nil {
+			result.Error = r.Err.Error()
+		} else {
+			result.EntryID = r.Entry.ID
+			result.StatusCode = r.Entry.StatusCode
+			result.Length = len(r.Entry.ResponseBody)
+			result.DurationMs = r.Entry.DurationMs
+		}
+		_ = s.store.SaveFuzzResult(result)
+	}
+	_ = s.store.FinishFuzzRun(run.ID, storage.FuzzDone)
+
+	if run, err = s.store.GetFuzzRun(run.ID); err != nil {
+		return Response{Error: err.Error()}
+	}
+	stored, err := s.store.FuzzResults(run.ID)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(RaceResult{Run: run, Protocol: proto, Results: stored, Groups: proxy.GroupRaceResults(results)})
+	return Response{OK: true, Data: data}
+}
+
+// requests builds the requests to race from entry, and the payload used in
+// each when the template has positions. Without payloads, Count copies of
+// the entry or the template are raced.
+func (p RaceParams) requests(entry *storage.Entry) ([]*storage.Entry, []string, error) {
+	if len(p.Payloads) == 0 && (p.Count <= 0 || p.Count > proxy.MaxRaceRequests) {
+		return nil, nil, fmt.Errorf("count must be between 1 and %d", proxy.MaxRaceRequests)
+	}
+	if len(p.Payloads) > proxy.MaxRaceRequests {
+		return nil, nil, fmt.Errorf("%d payloads exceeds the limit of %d requests", len(p.Payloads), proxy.MaxRaceRequests)
+	}
+
+	var raws [][]byte
+	switch {
+	case len(p.Template) == 0:
+		reqs := make([]*storage.Entry, p.Count)
+		for i := range reqs {
+			c := *entry
+			reqs[i] = &c
+		}
+		return reqs, nil, nil
+	case !bytes.Contains(p.Template, []byte(fuzz.Marker)):
+		if len(p.Payloads) > 0 {
+			return nil, nil, fmt.Errorf("payloads given but the template has no %s-marked positions", fuzz.Marker)
+		}
+		for range p.Count {
+			raws = append(raws, p.Template)
+		}
+	default:
+		tmpl, err := fuzz.ParseTemplate(p.Template)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(p.Payloads) == 0 {
+			for range p.Count {
+				raws = append(raws, tmpl.Defaults())
+			}
+			break
+		}
+		// Every position takes the same payload, one request per payload.
+		attack := &fuzz.Attack{Template: tmpl, Mode: fuzz.BatteringRam, Payloads: [][]string{p.Payloads}, URLEncode: p.URLEncode}
+		if err := attack.Validate(); err != nil {
+			return nil, nil, err
+		}
+		attack.Each(func(v fuzz.Variant) bool {
+			raws = append(raws, v.Raw)
+			return true
+		})
+	}
+
+	reqs := make([]*storage.Entry, len(raws))
+	for i, raw := range raws {
+		var err error
+		if reqs[i], err = exchange.ParseRequest(raw, entry.Scheme); err != nil {
+			return nil, nil, fmt.Errorf("template: %v", err)
+		}
+	}
+	if len(p.Payloads) == 0 {
+		return reqs, nil, nil
+	}
+	return reqs, p.Payloads, nil
+}
@@ -0,0 +1,55 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghostsecurity/reaper/internal/proxy"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+func TestRaceRequests(t *testing.T) {
+	entry := &storage.Entry{ID: 42, Method: "POST", Scheme: "https", Host: "shop.acme.com", Path: "/redeem"}
+	plain := []byte("POST /redeem HTTP/1.1\r\nHost: shop.acme.com\r\nContent-Length: 10\r\n\r\ncode=SAVE5")
+	marked := []byte("POST /redeem HTTP/1.1\r\nHost: shop.acme.com\r\nContent-Length: 10\r\n\r\ncode=§SAVE5§")
+
+	// A template without markers is sent as written, Count times.
+	reqs, payloads, err := RaceParams{Count: 3, Template: plain}.requests(entry)
+	if err != nil {
+		t.Fatalf("template without markers: %v", err)
+	}
+	if len(reqs) != 3 || payloads != nil {
+		t.Fatalf("got %d requests with payloads %v, want 3 copies", len(reqs), payloads)
+	}
+	for _, r := range reqs {
+		if r.Host != "shop.acme.com" || string(r.RequestBody) != "code=SAVE5" {
+			t.Errorf("request = %s %s %q", r.Host, r.Path, r.RequestBody)
+		}
+	}
+
+	// With markers but no payloads, the marked defaults are sent.
+	if reqs, _, err := (RaceParams{Count: 2, Template: marked}).requests(entry); err != nil || len(reqs) != 2 || string(reqs[1].RequestBody) != "code=SAVE5" {
+		t.Errorf("marked template without payloads: %d requests, %v", len(reqs), err)
+	}
+
+	reqs, payloads, err = RaceParams{Count: 20, Template: marked, Payloads: []string{"A1", "B2"}}.requests(entry)
+	if err != nil || len(reqs) != 2 || string(reqs[1].RequestBody) != "code=B2" || strings.Join(payloads, ",") != "A1,B2" {
+		t.Errorf("payloads: %d requests, payloads %v, %v", len(reqs), payloads, err)
+	}
+
+	if reqs, _, err := (RaceParams{Count: 4}).requests(entry); err != nil || len(reqs) != 4 || reqs[0] == entry || reqs[0].Path != "/redeem" {
+		t.Errorf("copies of the entry: %d requests, %v", len(reqs), err)
+	}
+
+	for name, p := range map[string]RaceParams{
+		"zero count":               {Count: 0},
+		"count over the limit":     {Count: 1_000_000_000},
+		"template over the limit":  {Count: proxy.MaxRaceRequests + 1, Template: plain},
+		"payloads without markers": {Template: plain, Payloads: []string{"x"}},
+		"too many payloads":        {Template: marked, Payloads: make([]string, proxy.MaxRaceRequests+1)},
+	} {
+		if _, _, err := p.requests(entry); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ghostsecurity/reaper/internal/redact"
+)
+
+// redactKeySize is the size of the key redaction hashes are made with.
+const redactKeySize = 32
+
+// newRedactor returns the redactor configured by cfg.
+func newRedactor(cfg Config, dataDir string) (*redact.Redactor, error) {
+	rules := redact.DefaultRules
+	if cfg.RedactRules != "" {
+		var err error
+		if rules, err = redact.LoadRules(cfg.RedactRules); err != nil {
+			return nil, err
+		}
+	}
+	key, err := redactKey(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	return redact.New(rules, key)
+}
+
+// redactKey returns the key redaction hashes are made with, creating it on
+// first use. It is kept in the data directory so that hashes stay
+// comparable across runs; deleting it makes new hashes unrelated to old
+// ones. A key of the wrong size is an error rather than replaced, since
+// replacing it would silently do just that.
+func redactKey(dataDir string) ([]byte, error) {
+	path := filepath.Join(dataDir, "redact.key")
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != redactKeySize {
+			return nil, fmt.Errorf("redaction key %s is %d bytes, want %d; delete it to generate a new one", path, len(key), redactKeySize)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading redaction key: %w", err)
+	}
+
+	key = make([]byte, redactKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generating redaction key: %w", err)
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, fmt.Errorf("writing redaction key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ghostsecurity/reaper/internal/exchange"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// apply makes the changes described by p to the request half of e.
+func (p ReplayParams) apply(e *storage.Entry) error {
+	if len(p.Raw) > 0 {
+		edited, err := exchange.ParseRequest(p.Raw, e.Scheme)
+		if err != nil {
+			return fmt.Errorf("parsing edited request: %w", err)
+		}
+		e.Method = edited.Method
+		e.Scheme = edited.Scheme
+		e.Host = edited.Host
+		e.Port = edited.Port
+		e.Path = edited.Path
+		e.Query = edited.Query
+		e.RequestHeaders = edited.RequestHeaders
+		e.RequestBody = edited.RequestBody
+	}
+
+	if p.Method != "" {
+		e.Method = strings.ToUpper(p.Method)
+	}
+	if p.Path != "" {
+		path, query, hasQuery := strings.Cut(p.Path, "?")
+		e.Path = path
+		if hasQuery {
+			e.Query = query
+		}
+	}
+
+	if len(p.Query) > 0 {
+		values, err := url.ParseQuery(e.Query)
+		if err != nil {
+			return fmt.Errorf("parsing stored query: %w", err)
+		}
+		for _, kv := range p.Query {
+			k, v, _ := strings.Cut(kv, "=")
+			if k == "" {
+				return fmt.Errorf("invalid query parameter %q: want k=v", kv)
+			}
+			values.Set(k, v)
+		}
+		e.Query = values.Encode()
+	}
+
+	if e.RequestHeaders == nil {
+		e.RequestHeaders = http.Header{}
+	}
+	for _, name := range p.RemoveHeaders {
+		e.RequestHeaders.Del(strings.TrimSpace(name))
+	}
+	for _, kv := range p.SetHeaders {
+		k, v, ok := strings.Cut(kv, ":")
+		if !ok || strings.TrimSpace(k) == "" {
+			return fmt.Errorf("invalid header %q: want 'Name: value'", kv)
+		}
+		e.RequestHeaders.Set(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+
+	if p.ReplaceBody {
+		e.RequestBody = p.Body
+	}
+	return p.applyJWT(e)
+}
@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// retentionInterval is how often the retention policy is enforced.
+const retentionInterval = time.Minute
+
+// enforceRetention prunes the store according to policy at startup and then
+// every retentionInterval until done is closed.
+func enforceRetention(store storage.Store, policy storage.RetentionPolicy, verbose bool, done <-chan struct{}) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := store.Prune(storage.SearchParams{}, policy)
+		if verbose {
+			switch {
+			case err != nil:
+				fmt.Printf("%s retention: %v\n", time.Now().Format("15:04:05"), err)
+			case deleted > 0:
+				fmt.Printf("%s retention: pruned %d entries\n", time.Now().Format("15:04:05"), deleted)
+			}
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func describeRetention(p storage.RetentionPolicy) string {
+	var parts []string
+	if p.MaxAge > 0 {
+		parts = append(parts, "max age "+p.MaxAge.String())
+	}
+	if p.MaxEntries > 0 {
+		parts = append(parts, fmt.Sprintf("max %d entries", p.MaxEntries))
+	}
+	if p.MaxSize > 0 {
+		parts = append(parts, fmt.Sprintf("max size %.1fMB", float64(p.MaxSize)/(1024*1024)))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -2,22 +2,46 @@ package daemon
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/ghostsecurity/reaper/internal/proxy"
+	"github.com/ghostsecurity/reaper/internal/redact"
+	"github.com/ghostsecurity/reaper/internal/scanner"
 	"github.com/ghostsecurity/reaper/internal/storage"
 )
 
 type IPCServer struct {
 	listener net.Listener
 	store    storage.Store
+	proxy    *proxy.Proxy
 	shutdown chan struct{}
+	started  time.Time
+
+	fuzzMu      sync.Mutex
+	fuzzCancels map[int64]context.CancelFunc // fuzz runs in progress
+
+	authzMu    sync.Mutex
+	authzAuto  bool                // test proxied traffic as every identity
+	authzQueue chan *storage.Entry // proxied entries awaiting automatic testing
+
+	checks    []scanner.Check
+	scanQueue chan *storage.Entry // proxied entries awaiting passive checks
+
+	redactor *redact.Redactor // applied before saving, or nil
+
+	subsMu  sync.Mutex
+	subs    map[*subscriber]struct{}
+	closing chan struct{} // closed by Close, ending subscriptions
 }
 
-func NewIPCServer(dataDir string, store storage.Store, shutdown chan struct{}) (*IPCServer, error) {
+func NewIPCServer(dataDir string, store storage.Store, p *proxy.Proxy, shutdown chan struct{}) (*IPCServer, error) {
 	sockPath := filepath.Join(dataDir, "reaper.sock")
 
 	// Remove stale socket
@@ -31,11 +55,23 @@ func NewIPCServer(dataDir string, store storage.Store, shutdown chan struct{}) (
 	return &IPCServer{
 		listener: listener,
 		store:    store,
+		proxy:    p,
 		shutdown: shutdown,
+		started:  time.Now(),
+
+		fuzzCancels: map[int64]context.CancelFunc{},
+		authzQueue:  make(chan *storage.Entry, authzQueueSize),
+		checks:      scanner.Builtin(),
+		scanQueue:   make(chan *storage.Entry, scanQueueSize),
+		subs:        map[*subscriber]struct{}{},
+		closing:     make(chan struct{}),
 	}, nil
 }
 
 func (s *IPCServer) Serve() {
+	go s.runAutoAuthz()
+	go s.runScanner()
+
 	for {
 		conn, err := s.listener.Accept()
 		if err != nil {
@@ -50,7 +86,15 @@ func (s *IPCServer) Serve() {
 	}
 }
 
+// entrySaved hands a proxied entry to the background workers. It is called
+// from the proxy's request path, so it never blocks.
+func (s *IPCServer) entrySaved(e *storage.Entry) {
+	s.queueScan(e)
+	s.queueAuthz(e)
+}
+
 func (s *IPCServer) Close() error {
+	close(s.closing)
 	return s.listener.Close()
 }
 
@@ -69,6 +113,12 @@ func (s *IPCServer) handleConn(conn net.Conn) {
 		return
 	}
 
+	// subscribe holds the connection open to push events.
+	if req.Command == "subscribe" {
+		s.handleSubscribe(conn, req.Params)
+		return
+	}
+
 	resp := s.route(req)
 	writeResponse(conn, resp)
 }
@@ -83,8 +133,62 @@ func (s *IPCServer) route(req Request) Response {
 		return s.handleGet(req.Command, req.Params)
 	case "tail":
 		return s.handleTail(req.Params)
+	case "endpoints":
+		return s.handleEndpoints(req.Params)
+	case "params":
+		return s.handleParams(req.Params)
+	case "import":
+		return s.handleImport(req.Params)
+	case "tag":
+		return s.handleTag(req.Params)
+	case "note":
+		return s.handleNote(req.Params)
+	case "highlight":
+		return s.handleHighlight(req.Params)
+	case "replay":
+		return s.handleReplay(req.Params)
+	case "send":
+		return s.handleSend(req.Params)
+	case "fuzz":
+		return s.handleFuzz(req.Params)
+	case "fuzz-status":
+		return s.handleFuzzStatus(req.Params)
+	case "fuzz-runs":
+		return s.handleFuzzRuns()
+	case "fuzz-results":
+		return s.handleFuzzResults(req.Params)
+	case "fuzz-stop":
+		return s.handleFuzzStop(req.Params)
+	case "race":
+		return s.handleRace(req.Params)
+	case "authz-identity-add":
+		return s.handleIdentityAdd(req.Params)
+	case "authz-identity-list":
+		return s.handleIdentityList()
+	case "authz-identity-remove":
+		return s.handleIdentityRemove(req.Params)
+	case "authz-test":
+		return s.handleAuthzTest(req.Params)
+	case "authz-auto":
+		return s.handleAuthzAuto(req.Params)
+	case "authz-report":
+		return s.handleAuthzReport(req.Params)
+	case "findings":
+		return s.handleFindings(req.Params)
+	case "findings-get":
+		return s.handleFindingGet(req.Params)
+	case "findings-add":
+		return s.handleFindingAdd(req.Params)
+	case "findings-set-status":
+		return s.handleFindingStatus(req.Params)
+	case "jwt-list":
+		return s.handleJWTList(req.Params)
+	case "scope":
+		return s.handleScope()
+	case "prune":
+		return s.handlePrune(req.Params)
 	case "clear":
-		return s.handleClear()
+		return s.handleClear(req.Params)
 	case "shutdown":
 		return s.handleShutdown()
 	case "ping":
@@ -94,6 +198,15 @@ func (s *IPCServer) route(req Request) Response {
 	}
 }
 
+func (s *IPCServer) handleScope() Response {
+	data, _ := json.Marshal(ScopeResult{
+		Domains: s.proxy.Scope.Domains(),
+		Hosts:   s.proxy.Scope.Hosts(),
+		Started: s.started,
+	})
+	return Response{OK: true, Data: data}
+}
+
 func (s *IPCServer) handleLogs(params json.RawMessage) Response {
 	var p LogsParams
 	if len(params) > 0 {
@@ -122,15 +235,7 @@ func (s *IPCServer) handleSearch(params json.RawMessage) Response {
 		}
 	}
 
-	entries, err := s.store.Search(storage.SearchParams{
-		Method:  p.Method,
-		Host:    p.Host,
-		Domains: p.Domains,
-		Path:    p.Path,
-		Status:  p.Status,
-		Limit:   p.Limit,
-		Offset:  p.Offset,
-	})
+	entries, err := s.store.Search(p.storageParams())
 	if err != nil {
 		return Response{Error: err.Error()}
 	}
@@ -176,13 +281,193 @@ func (s *IPCServer) handleTail(params json.RawMessage) Response {
 	return Response{OK: true, Data: data}
 }
 
-func (s *IPCServer) handleClear() Response {
-	if err := s.store.Clear(); err != nil {
+func (s *IPCServer) handleImport(params json.RawMessage) Response {
+	var p ImportParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	result := ImportResult{IDs: make([]int64, 0, len(p.Entries))}
+	for _, e := range p.Entries {
+		e.ID = 0
+		if err := s.store.Save(e); err != nil {
+			return Response{Error: err.Error()}
+		}
+		result.IDs = append(result.IDs, e.ID)
+	}
+
+	data, _ := json.Marshal(result)
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleTag(params json.RawMessage) Response {
+	var p TagParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	var err error
+	if p.Remove {
+		err = s.store.RemoveTags(p.ID, p.Tags)
+	} else {
+		err = s.store.AddTags(p.ID, p.Tags)
+	}
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	return Response{OK: true}
+}
+
+func (s *IPCServer) handleNote(params json.RawMessage) Response {
+	var p NoteParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	if err := s.store.SetNote(p.ID, p.Note); err != nil {
+		return Response{Error: err.Error()}
+	}
+	return Response{OK: true}
+}
+
+func (s *IPCServer) handleHighlight(params json.RawMessage) Response {
+	var p HighlightParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	if err := s.store.SetHighlight(p.ID, p.Color); err != nil {
 		return Response{Error: err.Error()}
 	}
 	return Response{OK: true}
 }
 
+func (s *IPCServer) handleEndpoints(params json.RawMessage) Response {
+	var p EndpointsParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return Response{Error: "invalid params"}
+		}
+	}
+
+	endpoints, err := s.store.Endpoints(p.Filter.storageParams())
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(endpoints)
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleParams(params json.RawMessage) Response {
+	var p ParamsParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return Response{Error: "invalid params"}
+		}
+	}
+
+	summaries, err := s.store.Params(p.Filter.storageParams(), p.Endpoint)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(summaries)
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleReplay(params json.RawMessage) Response {
+	var p ReplayParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	entry, err := s.store.Get(p.ID)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	if err := p.apply(entry); err != nil {
+		return Response{Error: err.Error()}
+	}
+	entry.ParentID = entry.ID
+	entry.RunID = 0
+
+	replayed, err := s.proxy.Replay(entry)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(replayed)
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleSend(params json.RawMessage) Response {
+	var p SendParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+	if p.Host == "" || p.Port <= 0 || len(p.Raw) == 0 {
+		return Response{Error: "host, port and request are required"}
+	}
+
+	entry, raw, err := s.proxy.SendRaw(proxy.RawTarget{Host: p.Host, Port: p.Port, TLS: p.TLS, Insecure: p.Insecure}, p.Raw)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(SendResult{ID: entry.ID, Response: raw})
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handlePrune(params json.RawMessage) Response {
+	var p PruneParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return Response{Error: "invalid params"}
+	}
+
+	deleted, err := s.store.Prune(p.Filter.storageParams(), storage.RetentionPolicy{
+		MaxAge:     p.MaxAge,
+		MaxEntries: p.MaxEntries,
+		MaxSize:    p.MaxSize,
+	})
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(PruneResult{Deleted: deleted})
+	return Response{OK: true, Data: data}
+}
+
+func (s *IPCServer) handleClear(params json.RawMessage) Response {
+	var p ClearParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return Response{Error: "invalid params"}
+		}
+	}
+	filter := p.Filter.storageParams()
+
+	var result ClearResult
+	var err error
+	switch {
+	case p.DryRun:
+		result.DryRun = true
+		result.Deleted, err = s.store.Count(filter)
+	case !filter.HasFilter():
+		if result.Deleted, err = s.store.Count(filter); err == nil {
+			err = s.store.Clear()
+		}
+	default:
+		result.Deleted, err = s.store.DeleteWhere(filter)
+	}
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, _ := json.Marshal(result)
+	return Response{OK: true, Data: data}
+}
+
 func (s *IPCServer) handleShutdown() Response {
 	go func() {
 		close(s.shutdown)
@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/proxy"
+)
+
+// subscriberBuffer is the number of events queued for a subscriber before
+// further ones are dropped, so that a slow reader never holds up the proxy.
+const subscriberBuffer = 256
+
+// subscriberWriteTimeout bounds each write to a subscriber.
+const subscriberWriteTimeout = 10 * time.Second
+
+type subscriber struct {
+	filter  eventFilter
+	events  chan SubscribeEvent
+	done    chan struct{} // closed when the server shuts down
+	dropped int           // guarded by IPCServer.subsMu
+}
+
+// publish sends e to every subscriber whose filter it matches. It is the
+// proxy's OnEvent callback.
+func (s *IPCServer) publish(e proxy.Event) {
+	ev := SubscribeEvent{
+		ID:          e.ID,
+		Time:        time.Now(),
+		Method:      e.Method,
+		Scheme:      e.Scheme,
+		Host:        e.Host,
+		Path:        e.Path,
+		StatusCode:  e.StatusCode,
+		DurationMs:  e.DurationMs,
+		Intercepted: e.Intercepted,
+	}
+
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	for sub := range s.subs {
+		if !sub.filter.match(ev) {
+			continue
+		}
+		ev.Dropped = sub.dropped
+		select {
+		case sub.events <- ev:
+			sub.dropped = 0
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// handleSubscribe keeps conn open and writes the events matching params to
+// it until the client disconnects or the server closes.
+func (s *IPCServer) handleSubscribe(conn net.Conn, params json.RawMessage) {
+	var p SubscribeParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			writeResponse(conn, Response{Error: "invalid params"})
+			return
+		}
+	}
+	sub := &subscriber{
+		filter: newEventFilter(p),
+		events: make(chan SubscribeEvent, subscriberBuffer),
+		done:   s.closing,
+	}
+	s.subsMu.Lock()
+	s.subs[sub] = struct{}{}
+	s.subsMu.Unlock()
+	defer func() {
+		s.subsMu.Lock()
+		delete(s.subs, sub)
+		s.subsMu.Unlock()
+	}()
+
+	_ = conn.SetWriteDeadline(time.Now().Add(subscriberWriteTimeout))
+	writeResponse(conn, Response{OK: true})
+
+	// The client sends nothing more; a read returns when it disconnects.
+	gone := make(chan struct{})
+	go func() {
+		_, _ = conn.Read(make([]byte, 1))
+		close(gone)
+	}()
+
+	enc := json.NewEncoder(conn)
+	for {
+		select {
+		case ev := <-sub.events:
+			_ = conn.SetWriteDeadline(time.Now().Add(subscriberWriteTimeout))
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		case <-sub.done:
+			return
+		}
+	}
+}
+
+// eventFilter matches events as searchConditions in the storage package
+// matches entries.
+type eventFilter struct {
+	method  string
+	host    *regexp.Regexp
+	domains []string
+	path    *regexp.Regexp
+	status  int
+	all     bool
+}
+
+func newEventFilter(p SubscribeParams) eventFilter {
+	f := eventFilter{method: strings.ToUpper(p.Method), status: p.Status, all: p.All}
+	if p.Host != "" {
+		f.host = wildcard(p.Host, false)
+	}
+	for _, d := range p.Domains {
+		f.domains = append(f.domains, strings.ToLower(strings.TrimPrefix(d, ".")))
+	}
+	if p.Path != "" {
+		// A path without a wildcard is a prefix.
+		f.path = wildcard(p.Path, !strings.Contains(p.Path, "*"))
+	}
+	return f
+}
+
+// wildcard compiles a pattern in which * matches any text, matched without
+// regard to case as SQL LIKE does.
+func wildcard(pattern string, prefix bool) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "(?is)^" + strings.Join(parts, ".*")
+	if !prefix {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+func (f eventFilter) match(ev SubscribeEvent) bool {
+	if !ev.Intercepted && !f.all {
+		return false
+	}
+	if f.method != "" && ev.Method != f.method {
+		return false
+	}
+	if f.host != nil && !f.host.MatchString(ev.Host) {
+		return false
+	}
+	if len(f.domains) > 0 {
+		host := strings.ToLower(ev.Host)
+		matched := false
+		for _, d := range f.domains {
+			if host == d || strings.HasSuffix(host, "."+d) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if f.path != nil && !f.path.MatchString(ev.Path) {
+		return false
+	}
+	return f.status == 0 || ev.StatusCode == f.status
+}
@@ -0,0 +1,132 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/proxy"
+)
+
+func TestEventFilter(t *testing.T) {
+	ev := SubscribeEvent{Method: "POST", Host: "API.acme.com", Path: "/v1/Users/7", StatusCode: 201, Intercepted: true}
+	tests := []struct {
+		name string
+		p    SubscribeParams
+		ev   SubscribeEvent
+		want bool
+	}{
+		{"no filter", SubscribeParams{}, ev, true},
+		{"method", SubscribeParams{Method: "post"}, ev, true},
+		{"other method", SubscribeParams{Method: "GET"}, ev, false},
+		{"host ignores case", SubscribeParams{Host: "api.ACME.com"}, ev, true},
+		{"host glob", SubscribeParams{Host: "*.acme.com"}, ev, true},
+		{"host is not a prefix", SubscribeParams{Host: "api.acme"}, ev, false},
+		{"domain", SubscribeParams{Domains: []string{"other.com", ".acme.com"}}, ev, true},
+		{"domain suffix only at a dot", SubscribeParams{Domains: []string{"me.com"}}, ev, false},
+		{"path prefix", SubscribeParams{Path: "/v1/users"}, ev, true},
+		{"path glob is anchored", SubscribeParams{Path: "/v1/*/7"}, ev, true},
+		{"path glob must match to the end", SubscribeParams{Path: "/v1/*s"}, ev, false},
+		{"regexp characters are literal", SubscribeParams{Path: "/v1/.sers"}, ev, false},
+		{"status", SubscribeParams{Status: 201}, ev, true},
+		{"other status", SubscribeParams{Status: 200}, ev, false},
+		{"out of scope", SubscribeParams{}, SubscribeEvent{Host: "evil.test"}, false},
+		{"out of scope with all", SubscribeParams{All: true}, SubscribeEvent{Host: "evil.test"}, true},
+	}
+	for _, tt := range tests {
+		if got := newEventFilter(tt.p).match(tt.ev); got != tt.want {
+			t.Errorf("%s: match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPublishDropped(t *testing.T) {
+	s := &IPCServer{subs: map[*subscriber]struct{}{}}
+	slow := &subscriber{filter: newEventFilter(SubscribeParams{}), events: make(chan SubscribeEvent, 2)}
+	gets := &subscriber{filter: newEventFilter(SubscribeParams{Method: "GET"}), events: make(chan SubscribeEvent, 2)}
+	s.subs[slow] = struct{}{}
+	s.subs[gets] = struct{}{}
+
+	for i := range 5 {
+		s.publish(proxy.Event{ID: int64(i + 1), Method: "POST", Intercepted: true})
+	}
+	for _, want := range []int64{1, 2} {
+		if ev := <-slow.events; ev.ID != want || ev.Dropped != 0 {
+			t.Errorf("event %d: dropped %d, want event %d with none dropped", ev.ID, ev.Dropped, want)
+		}
+	}
+	if slow.dropped != 3 {
+		t.Errorf("dropped = %d, want 3", slow.dropped)
+	}
+	if gets.dropped != 0 || len(gets.events) != 0 {
+		t.Errorf("subscriber filtering the events out: dropped %d, queued %d", gets.dropped, len(gets.events))
+	}
+
+	s.publish(proxy.Event{ID: 6, Method: "POST", Intercepted: true})
+	if ev := <-slow.events; ev.ID != 6 || ev.Dropped != 3 {
+		t.Errorf("event %d after falling behind: dropped %d, want event 6 with 3 dropped", ev.ID, ev.Dropped)
+	}
+	if slow.dropped != 0 {
+		t.Errorf("dropped = %d after delivering, want 0", slow.dropped)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	dir := t.TempDir()
+	shutdown := make(chan struct{})
+	srv, err := NewIPCServer(dir, nil, nil, shutdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+
+	client := NewClient(dir)
+	sub, err := client.Subscribe(SubscribeParams{Path: "/api"})
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	srv.publish(proxy.Event{ID: 1, Method: "GET", Host: "acme.com", Path: "/static/app.js", Intercepted: true})
+	srv.publish(proxy.Event{Method: "GET", Host: "evil.test", Path: "/api/x"})
+	srv.publish(proxy.Event{ID: 3, Method: "GET", Host: "acme.com", Path: "/api/users", StatusCode: 200, Intercepted: true})
+
+	ev, err := sub.Next()
+	if err != nil {
+		t.Fatalf("reading event: %v", err)
+	}
+	if ev.ID != 3 || ev.Path != "/api/users" || ev.StatusCode != 200 {
+		t.Errorf("event = %+v, want entry 3", ev)
+	}
+
+	// Closing the subscription ends it on both sides.
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next after Close: %v, want io.EOF", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); subscribers(srv) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("server kept the closed subscription")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// So does shutting the server down.
+	sub, err = client.Subscribe(SubscribeParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	close(shutdown)
+	srv.Close()
+	if _, err := sub.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next after the server closed: %v, want io.EOF", err)
+	}
+}
+
+func subscribers(s *IPCServer) int {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	return len(s.subs)
+}
@@ -0,0 +1,175 @@
+// Package diff compares HTTP messages: headers as sets of values, JSON
+// bodies structurally and other bodies line by line.
+package diff
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options controls what a comparison ignores.
+type Options struct {
+	IgnoreHeaders []string // header names, case-insensitive
+	IgnoreFields  []string // JSON keys or paths, e.g. "id", "data.updated_at" or "items[].id"
+	Volatile      bool     // ignore values that change between identical requests; see VolatileHeaders
+}
+
+// VolatileHeaders are ignored with Options.Volatile: dates, caching
+// validators, timings and request or trace IDs.
+var VolatileHeaders = []string{
+	"Age", "Cf-Ray", "Date", "Etag", "Expires", "Last-Modified", "Report-To", "Server-Timing",
+	"Traceparent", "Tracestate", "X-Amz-Cf-Id", "X-Amzn-Requestid", "X-Amzn-Trace-Id",
+	"X-Correlation-Id", "X-Request-Id", "X-Response-Time", "X-Runtime", "X-Trace-Id",
+}
+
+// volatileValues matches values within bodies that are ignored with
+// Options.Volatile, each replaced by its placeholder before comparing.
+var volatileValues = []struct {
+	re          *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), "{timestamp}"},
+	{regexp.MustCompile(`\b(Mon|Tue|Wed|Thu|Fri|Sat|Sun), \d{2} [A-Z][a-z]{2} \d{4} \d{2}:\d{2}:\d{2} GMT\b`), "{timestamp}"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "{uuid}"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{32,}\b`), "{hex}"},
+	{regexp.MustCompile(`\b1[4-9]\d{8}(\d{3})?\b`), "{epoch}"},
+}
+
+// Change kinds.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// HeaderChange is a header whose values differ. Old is empty for an added
+// header and New for a removed one.
+type HeaderChange struct {
+	Name string
+	Kind string
+	Old  []string
+	New  []string
+}
+
+// Result is the difference between two messages. Bodies are compared as JSON
+// when both parse as JSON objects or arrays, reported as Fields; text bodies
+// are compared by line, reported as Lines; binary bodies are only compared
+// for equality.
+type Result struct {
+	Headers []HeaderChange
+	JSON    bool
+	Fields  []FieldChange
+	Lines   []Line // the whole body, unchanged lines included; nil if the bodies are equal
+	Binary  bool   // the bodies differ and are not both text
+	BodyA   int    // body lengths
+	BodyB   int
+}
+
+// Equal reports whether the messages compared equal.
+func (r *Result) Equal() bool {
+	return len(r.Headers) == 0 && !r.BodyChanged()
+}
+
+// BodyChanged reports whether the bodies differ.
+func (r *Result) BodyChanged() bool {
+	return len(r.Fields) > 0 || r.Lines != nil || r.Binary
+}
+
+// Compare compares the headers and bodies of two messages.
+func Compare(headersA, headersB http.Header, bodyA, bodyB []byte, opts Options) *Result {
+	r := &Result{
+		Headers: compareHeaders(headersA, headersB, opts),
+		BodyA:   len(bodyA),
+		BodyB:   len(bodyB),
+	}
+
+	if bytes.Equal(bodyA, bodyB) {
+		return r
+	}
+	if va, vb, ok := parseJSON(bodyA, bodyB); ok {
+		r.JSON = true
+		r.Fields = compareJSON(va, vb, opts)
+		return r
+	}
+	if isBinary(bodyA) || isBinary(bodyB) {
+		r.Binary = true
+		return r
+	}
+
+	a, b := string(bodyA), string(bodyB)
+	if opts.Volatile {
+		a, b = maskVolatile(a), maskVolatile(b)
+	}
+	if a != b {
+		r.Lines = Lines(splitLines(a), splitLines(b))
+	}
+	return r
+}
+
+func compareHeaders(a, b http.Header, opts Options) []HeaderChange {
+	ignored := func(name string) bool {
+		return slices.ContainsFunc(opts.IgnoreHeaders, func(h string) bool { return strings.EqualFold(h, name) }) ||
+			(opts.Volatile && slices.ContainsFunc(VolatileHeaders, func(h string) bool { return strings.EqualFold(h, name) }))
+	}
+
+	names := map[string]bool{}
+	for name := range a {
+		names[http.CanonicalHeaderKey(name)] = true
+	}
+	for name := range b {
+		names[http.CanonicalHeaderKey(name)] = true
+	}
+
+	var changes []HeaderChange
+	for name := range names {
+		if ignored(name) {
+			continue
+		}
+		// Values are compared as sets: repeated headers may be reordered.
+		old, cur := sortedValues(a, name), sortedValues(b, name)
+		switch {
+		case slices.Equal(old, cur):
+		case len(old) == 0:
+			changes = append(changes, HeaderChange{Name: name, Kind: Added, New: cur})
+		case len(cur) == 0:
+			changes = append(changes, HeaderChange{Name: name, Kind: Removed, Old: old})
+		default:
+			changes = append(changes, HeaderChange{Name: name, Kind: Changed, Old: old, New: cur})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+func sortedValues(h http.Header, name string) []string {
+	values := slices.Clone(h.Values(name))
+	sort.Strings(values)
+	return values
+}
+
+// maskVolatile replaces volatile values in s with placeholders.
+func maskVolatile(s string) string {
+	for _, v := range volatileValues {
+		s = v.re.ReplaceAllString(s, v.placeholder)
+	}
+	return s
+}
+
+// isBinary reports whether a body is not text: invalid UTF-8 or containing
+// NUL bytes.
+func isBinary(body []byte) bool {
+	return !utf8.Valid(body) || bytes.IndexByte(body, 0) >= 0
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	return strings.Split(s, "\n")
+}
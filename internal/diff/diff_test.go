@@ -0,0 +1,173 @@
+package diff
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCompareHeaders(t *testing.T) {
+	a := http.Header{
+		"Content-Type": {"text/html"},
+		"Set-Cookie":   {"a=1", "b=2"},
+		"X-Old":        {"gone"},
+		"Date":         {"Mon, 01 Jan 2024 00:00:00 GMT"},
+	}
+	b := http.Header{
+		"Content-Type": {"application/json"},
+		"Set-Cookie":   {"b=2", "a=1"},
+		"X-New":        {"here"},
+		"Date":         {"Mon, 01 Jan 2024 00:00:01 GMT"},
+	}
+
+	r := Compare(a, b, nil, nil, Options{IgnoreHeaders: []string{"date"}})
+	want := []HeaderChange{
+		{Name: "Content-Type", Kind: Changed, Old: []string{"text/html"}, New: []string{"application/json"}},
+		{Name: "X-New", Kind: Added, New: []string{"here"}},
+		{Name: "X-Old", Kind: Removed, Old: []string{"gone"}},
+	}
+	if len(r.Headers) != len(want) {
+		t.Fatalf("headers = %+v, want %+v", r.Headers, want)
+	}
+	for i, c := range r.Headers {
+		w := want[i]
+		if c.Name != w.Name || c.Kind != w.Kind || strings.Join(c.Old, ",") != strings.Join(w.Old, ",") || strings.Join(c.New, ",") != strings.Join(w.New, ",") {
+			t.Errorf("change %d = %+v, want %+v", i, c, w)
+		}
+	}
+	if r.BodyChanged() {
+		t.Error("empty bodies reported as changed")
+	}
+}
+
+func TestCompareJSON(t *testing.T) {
+	a := []byte(`{"user": {"name": "alice", "role": "admin"}, "items": [1, 2, 3], "id": 5, "tags": ["x"]}`)
+	b := []byte(`{"tags":["x"],"id":6,"items":[1,2],"user":{"role":"user","name":"alice","email":"a@example.com"}}`)
+
+	r := Compare(nil, nil, a, b, Options{IgnoreFields: []string{"id"}})
+	if !r.JSON {
+		t.Fatal("bodies not compared as JSON")
+	}
+	want := []FieldChange{
+		{Path: "items[2]", Kind: Removed, Old: "3"},
+		{Path: "user.email", Kind: Added, New: `"a@example.com"`},
+		{Path: "user.role", Kind: Changed, Old: `"admin"`, New: `"user"`},
+	}
+	if len(r.Fields) != len(want) {
+		t.Fatalf("fields = %+v, want %+v", r.Fields, want)
+	}
+	for i, f := range r.Fields {
+		if f != want[i] {
+			t.Errorf("field %d = %+v, want %+v", i, f, want[i])
+		}
+	}
+
+	// Reformatting and key order alone are not differences.
+	if r := Compare(nil, nil, []byte(`{"a":1,"b":[true,null]}`), []byte("{\n  \"b\": [true, null],\n  \"a\": 1\n}"), Options{}); !r.Equal() {
+		t.Errorf("reformatted JSON reported as changed: %+v", r.Fields)
+	}
+
+	r = Compare(nil, nil, []byte(`{"items":[{"id":1,"v":"a"}]}`), []byte(`{"items":[{"id":2,"v":"a"},{"id":3,"v":"b"}]}`),
+		Options{IgnoreFields: []string{"items[].id", "items[1]"}})
+	if !r.Equal() {
+		t.Errorf("ignored array fields reported: %+v", r.Fields)
+	}
+}
+
+func TestCompareVolatile(t *testing.T) {
+	a := []byte(`{"requestId":"3f2b8c1e-4d5a-4b6c-8d7e-9f0a1b2c3d4e","at":"2024-05-01T10:00:00Z","ts":1714557600,"ok":true}`)
+	b := []byte(`{"requestId":"a1b2c3d4-e5f6-4a7b-8c9d-0e1f2a3b4c5d","at":"2024-05-01T10:00:07.123Z","ts":1714557607,"ok":true}`)
+	if r := Compare(nil, nil, a, b, Options{}); len(r.Fields) != 3 {
+		t.Errorf("without volatile: %d changes, want 3", len(r.Fields))
+	}
+	if r := Compare(nil, nil, a, b, Options{Volatile: true}); !r.Equal() {
+		t.Errorf("with volatile: %+v", r.Fields)
+	}
+
+	ha := http.Header{"Date": {"x"}, "X-Request-Id": {"1"}, "Server": {"nginx"}}
+	hb := http.Header{"Date": {"y"}, "X-Request-Id": {"2"}, "Server": {"nginx"}}
+	textA := []byte("<p>Generated at 2024-05-01 10:00:00</p>\n<input name=csrf value=0123456789abcdef0123456789abcdef>\n")
+	textB := []byte("<p>Generated at 2024-05-01 10:00:09</p>\n<input name=csrf value=fedcba9876543210fedcba9876543210>\n")
+	if r := Compare(ha, hb, textA, textB, Options{Volatile: true}); !r.Equal() {
+		t.Errorf("with volatile: headers %+v, lines %+v", r.Headers, r.Lines)
+	}
+}
+
+func TestCompareText(t *testing.T) {
+	a := []byte("one\ntwo\nthree\nfour\n")
+	b := []byte("one\n2\nthree\nfour\nfive\n")
+	r := Compare(nil, nil, a, b, Options{})
+	if r.JSON || r.Binary {
+		t.Fatalf("text compared as JSON %v or binary %v", r.JSON, r.Binary)
+	}
+
+	var got []string
+	for _, l := range r.Lines {
+		got = append(got, string(l.Op)+l.Text)
+	}
+	want := " one|-two|+2| three| four|+five"
+	if strings.Join(got, "|") != want {
+		t.Errorf("lines = %s, want %s", strings.Join(got, "|"), want)
+	}
+
+	if r := Compare(nil, nil, []byte("a\x00b"), []byte("a\x00c"), Options{}); !r.Binary || r.Lines != nil {
+		t.Errorf("binary bodies: %+v", r)
+	}
+}
+
+func TestLinesLarge(t *testing.T) {
+	var a, b []string
+	for i := range 3000 {
+		a = append(a, "line "+strings.Repeat("x", i%7))
+		if i%100 == 0 {
+			b = append(b, "changed")
+		} else {
+			b = append(b, a[i])
+		}
+	}
+
+	deleted, inserted := 0, 0
+	for _, l := range Lines(a, b) {
+		switch l.Op {
+		case Delete:
+			deleted++
+		case Insert:
+			inserted++
+		}
+	}
+	if deleted != 30 || inserted != 30 {
+		t.Errorf("%d deleted, %d inserted; want 30 each", deleted, inserted)
+	}
+}
+
+func TestHunks(t *testing.T) {
+	var a []string
+	for i := range 20 {
+		a = append(a, string(rune('a'+i)))
+	}
+	b := append([]string(nil), a...)
+	b[1] = "B"  // near the start
+	b[4] = "E"  // within 2*context of the first change: same hunk
+	b[15] = "P" // far away: separate hunk
+
+	hunks := Hunks(Lines(a, b), 3)
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2: %+v", len(hunks), hunks)
+	}
+	if h := hunks[0]; h.StartA != 1 || h.LenA != 8 || h.StartB != 1 || h.LenB != 8 {
+		t.Errorf("first hunk = -%d,%d +%d,%d, want -1,8 +1,8", h.StartA, h.LenA, h.StartB, h.LenB)
+	}
+	if h := hunks[1]; h.StartA != 13 || h.LenA != 7 || len(h.Lines) != 8 {
+		t.Errorf("second hunk = -%d,%d with %d lines, want -13,7 with 8", h.StartA, h.LenA, len(h.Lines))
+	}
+
+	if h := Hunks(Lines(nil, []string{"x"}), 3); len(h) != 1 || h[0].StartA != 0 || h[0].StartB != 1 {
+		t.Errorf("insertion into empty = %+v, want -0,0 +1,1", h)
+	}
+
+	for _, context := range []int{0, -1} {
+		if h := Hunks(Lines(a, b), context); len(h) != 3 || len(h[0].Lines) != 2 || h[0].StartA != 2 {
+			t.Errorf("context %d: hunks = %+v, want 3 with only the changed lines", context, h)
+		}
+	}
+}
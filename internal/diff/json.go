@@ -0,0 +1,157 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// FieldChange is a JSON value that differs, identified by its path, e.g.
+// "user.roles[1]". Values are compact JSON; Old is empty for an added field
+// and New for a removed one.
+type FieldChange struct {
+	Path string
+	Kind string
+	Old  string
+	New  string
+}
+
+var arrayIndex = regexp.MustCompile(`\[\d+\]`)
+
+// parseJSON decodes both bodies if both are JSON objects or arrays.
+func parseJSON(a, b []byte) (any, any, bool) {
+	va, ok := decodeJSON(a)
+	if !ok {
+		return nil, nil, false
+	}
+	vb, ok := decodeJSON(b)
+	if !ok {
+		return nil, nil, false
+	}
+	return va, vb, true
+}
+
+func decodeJSON(body []byte) (any, bool) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return nil, false
+	}
+	return v, true
+}
+
+// compareJSON returns the differences between two decoded JSON values.
+// Object keys are compared regardless of order and array elements by index.
+func compareJSON(a, b any, opts Options) []FieldChange {
+	var changes []FieldChange
+	walkJSON("", "", a, b, opts, &changes)
+	return changes
+}
+
+func walkJSON(path, key string, a, b any, opts Options, changes *[]FieldChange) {
+	if path != "" && fieldIgnored(path, key, opts.IgnoreFields) {
+		return
+	}
+
+	switch va := a.(type) {
+	case map[string]any:
+		if vb, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(va)+len(vb))
+			for k := range va {
+				keys = append(keys, k)
+			}
+			for k := range vb {
+				if _, ok := va[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				child := k
+				if path != "" {
+					child = path + "." + k
+				}
+				oldV, inA := va[k]
+				newV, inB := vb[k]
+				switch {
+				case !inA:
+					if !fieldIgnored(child, k, opts.IgnoreFields) {
+						*changes = append(*changes, FieldChange{Path: child, Kind: Added, New: compact(newV)})
+					}
+				case !inB:
+					if !fieldIgnored(child, k, opts.IgnoreFields) {
+						*changes = append(*changes, FieldChange{Path: child, Kind: Removed, Old: compact(oldV)})
+					}
+				default:
+					walkJSON(child, k, oldV, newV, opts, changes)
+				}
+			}
+			return
+		}
+	case []any:
+		if vb, ok := b.([]any); ok {
+			for i := range max(len(va), len(vb)) {
+				child := path + "[" + strconv.Itoa(i) + "]"
+				switch {
+				case (i >= len(va) || i >= len(vb)) && fieldIgnored(child, "", opts.IgnoreFields):
+				case i >= len(va):
+					*changes = append(*changes, FieldChange{Path: child, Kind: Added, New: compact(vb[i])})
+				case i >= len(vb):
+					*changes = append(*changes, FieldChange{Path: child, Kind: Removed, Old: compact(va[i])})
+				default:
+					walkJSON(child, "", va[i], vb[i], opts, changes)
+				}
+			}
+			return
+		}
+	}
+
+	if opts.Volatile {
+		a, b = maskJSON(a), maskJSON(b)
+	}
+	if !reflect.DeepEqual(a, b) {
+		if path == "" {
+			path = "."
+		}
+		*changes = append(*changes, FieldChange{Path: path, Kind: Changed, Old: compact(a), New: compact(b)})
+	}
+}
+
+// fieldIgnored reports whether the field at path, with the given key, is
+// named by ignore: by key, by path, or by path with indices written as [].
+func fieldIgnored(path, key string, ignore []string) bool {
+	if len(ignore) == 0 {
+		return false
+	}
+	generic := arrayIndex.ReplaceAllString(path, "[]")
+	return slices.ContainsFunc(ignore, func(f string) bool {
+		return (key != "" && f == key) || f == path || f == generic
+	})
+}
+
+// maskJSON masks volatile values in a scalar JSON value.
+func maskJSON(v any) any {
+	switch v := v.(type) {
+	case string:
+		return maskVolatile(v)
+	case json.Number:
+		if masked := maskVolatile(v.String()); masked != v.String() {
+			return masked
+		}
+	}
+	return v
+}
+
+func compact(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,208 @@
+package diff
+
+// Line operations.
+const (
+	Equal  = ' '
+	Delete = '-'
+	Insert = '+'
+)
+
+// maxEdits bounds the work and memory of a line diff, which grow with the
+// square of the number of edits. Inputs needing more edits are reported as
+// the differing lines of a replaced by those of b.
+const maxEdits = 2000
+
+// Line is a line of a diff.
+type Line struct {
+	Op   byte // Equal, Delete or Insert
+	Text string
+}
+
+// Hunk is a run of changed lines with surrounding context. Starts are
+// 1-based line numbers, as in a unified diff.
+type Hunk struct {
+	StartA, LenA int
+	StartB, LenB int
+	Lines        []Line
+}
+
+// Lines returns a shortest edit script turning a into b, using Myers'
+// algorithm, with unchanged lines included.
+func Lines(a, b []string) []Line {
+	// Common prefixes and suffixes are cheap to strip and are most of a
+	// typical response.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out []Line
+	for _, s := range a[:prefix] {
+		out = append(out, Line{Equal, s})
+	}
+	out = append(out, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, s := range a[len(a)-suffix:] {
+		out = append(out, Line{Equal, s})
+	}
+	return out
+}
+
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] holds the frontier before step d, for diagonals -d-1..d+1.
+	var trace [][]int
+
+	found := -1
+	for d := 0; d <= limit && found < 0; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = d
+				break
+			}
+		}
+	}
+	if found < 0 {
+		out := make([]Line, 0, n+m)
+		for _, s := range a {
+			out = append(out, Line{Delete, s})
+		}
+		for _, s := range b {
+			out = append(out, Line{Insert, s})
+		}
+		return out
+	}
+
+	// Walk back through the saved frontiers to recover the edits.
+	var rev []Line
+	x, y := n, m
+	for d := found; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, Line{Equal, a[x]})
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, Line{Insert, b[y]})
+		} else {
+			x--
+			rev = append(rev, Line{Delete, a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		rev = append(rev, Line{Equal, a[x]})
+	}
+
+	out := make([]Line, len(rev))
+	for i, l := range rev {
+		out[len(rev)-1-i] = l
+	}
+	return out
+}
+
+// Hunks groups the changed lines of a diff into hunks with up to context
+// unchanged lines around each change. A negative context counts as zero.
+func Hunks(lines []Line, context int) []Hunk {
+	context = max(context, 0)
+	var hunks []Hunk
+	lineA, lineB := 1, 1 // line numbers at lines[i]
+	i := 0
+	for i < len(lines) {
+		if lines[i].Op == Equal {
+			i++
+			lineA++
+			lineB++
+			continue
+		}
+
+		// Start a hunk with up to context preceding lines.
+		start := max(i-context, 0)
+		h := Hunk{StartA: lineA - (i - start), StartB: lineB - (i - start)}
+		for _, l := range lines[start:i] {
+			h.Lines = append(h.Lines, l)
+			h.LenA++
+			h.LenB++
+		}
+
+		// Extend it while changes are closer than 2*context lines apart.
+		for i < len(lines) {
+			if lines[i].Op == Equal {
+				run := 0
+				for i+run < len(lines) && lines[i+run].Op == Equal {
+					run++
+				}
+				if i+run == len(lines) || run > 2*context {
+					for _, l := range lines[i : i+min(run, context)] {
+						h.Lines = append(h.Lines, l)
+						h.LenA++
+						h.LenB++
+					}
+					i += run
+					lineA += run
+					lineB += run
+					break
+				}
+				for _, l := range lines[i : i+run] {
+					h.Lines = append(h.Lines, l)
+				}
+				h.LenA += run
+				h.LenB += run
+				i += run
+				lineA += run
+				lineB += run
+				continue
+			}
+			h.Lines = append(h.Lines, lines[i])
+			if lines[i].Op == Delete {
+				h.LenA++
+				lineA++
+			} else {
+				h.LenB++
+				lineB++
+			}
+			i++
+		}
+		// An empty range starts at the line before it, as in diff -u.
+		if h.LenA == 0 {
+			h.StartA--
+		}
+		if h.LenB == 0 {
+			h.StartB--
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
@@ -0,0 +1,208 @@
+package exchange
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+	"github.com/ghostsecurity/reaper/version"
+)
+
+// burpTimeLayout is the java.util.Date format Burp writes in <time>.
+const burpTimeLayout = "Mon Jan 02 15:04:05 MST 2006"
+
+// burpItems is the root of a Burp Suite "save items" XML document.
+type burpItems struct {
+	XMLName     xml.Name   `xml:"items"`
+	BurpVersion string     `xml:"burpVersion,attr,omitempty"`
+	ExportTime  string     `xml:"exportTime,attr,omitempty"`
+	Items       []burpItem `xml:"item"`
+}
+
+type burpItem struct {
+	Time           string    `xml:"time"`
+	URL            burpCDATA `xml:"url"`
+	Host           burpHost  `xml:"host"`
+	Port           int       `xml:"port"`
+	Protocol       string    `xml:"protocol"`
+	Method         burpCDATA `xml:"method"`
+	Path           burpCDATA `xml:"path"`
+	Extension      string    `xml:"extension"`
+	Request        burpData  `xml:"request"`
+	Status         int       `xml:"status"`
+	ResponseLength int       `xml:"responselength"`
+	MimeType       string    `xml:"mimetype"`
+	Response       burpData  `xml:"response"`
+	Comment        string    `xml:"comment"`
+}
+
+type burpHost struct {
+	IP   string `xml:"ip,attr"`
+	Name string `xml:",chardata"`
+}
+
+type burpCDATA struct {
+	Text string `xml:",cdata"`
+}
+
+type burpData struct {
+	Base64 bool   `xml:"base64,attr"`
+	Data   string `xml:",cdata"`
+}
+
+func (d burpData) bytes() ([]byte, error) {
+	if !d.Base64 {
+		return []byte(d.Data), nil
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(d.Data))
+}
+
+// WriteBurp writes entries as a Burp Suite "save items" XML document.
+// Requests and responses are base64 encoded.
+func WriteBurp(w io.Writer, entries []*storage.Entry) error {
+	doc := burpItems{
+		BurpVersion: "reaper " + version.Version,
+		ExportTime:  time.Now().Format(burpTimeLayout),
+	}
+
+	for _, e := range entries {
+		target := e.Path
+		if e.Query != "" {
+			target += "?" + e.Query
+		}
+
+		resp := DumpResponse(e)
+		item := burpItem{
+			Time:           e.Timestamp.Format(burpTimeLayout),
+			URL:            burpCDATA{e.URL()},
+			Host:           burpHost{Name: e.Host},
+			Port:           e.EffectivePort(),
+			Protocol:       e.Scheme,
+			Method:         burpCDATA{e.Method},
+			Path:           burpCDATA{target},
+			Extension:      burpExtension(e.Path),
+			Request:        burpData{Base64: true, Data: base64.StdEncoding.EncodeToString(DumpRequest(e))},
+			Status:         e.StatusCode,
+			ResponseLength: len(resp),
+			MimeType:       burpMimeType(e.ResponseHeaders.Get("Content-Type")),
+			Comment:        e.Note,
+		}
+		if e.StatusCode != 0 {
+			item.Response = burpData{Base64: true, Data: base64.StdEncoding.EncodeToString(resp)}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encoding burp items: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadBurp parses a Burp Suite "save items" XML document into entries.
+func ReadBurp(r io.Reader) ([]*storage.Entry, error) {
+	var doc burpItems
+	dec := xml.NewDecoder(r)
+	// Burp emits a DOCTYPE with an internal subset; no entities are needed.
+	dec.Strict = false
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding burp items: %w", err)
+	}
+
+	entries := make([]*storage.Entry, 0, len(doc.Items))
+	for i, item := range doc.Items {
+		e, err := item.entry()
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i+1, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (item burpItem) entry() (*storage.Entry, error) {
+	rawReq, err := item.Request.bytes()
+	if err != nil {
+		return nil, fmt.Errorf("decoding request: %w", err)
+	}
+
+	scheme := strings.ToLower(item.Protocol)
+	e, err := ParseRequest(rawReq, scheme)
+	if err != nil {
+		return nil, fmt.Errorf("parsing request: %w", err)
+	}
+
+	// The item's connection fields are authoritative; the Host header may
+	// name a virtual host or omit the port.
+	if scheme != "" {
+		e.Scheme = scheme
+	}
+	if name := strings.TrimSpace(item.Host.Name); name != "" {
+		e.Host = name
+	}
+	if item.Port > 0 {
+		e.Port = item.Port
+	}
+
+	if ts, err := time.Parse(burpTimeLayout, strings.TrimSpace(item.Time)); err == nil {
+		e.Timestamp = ts
+	}
+	e.Note = strings.TrimSpace(item.Comment)
+
+	rawResp, err := item.Response.bytes()
+	if err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	if len(rawResp) > 0 {
+		if err := ParseResponse(rawResp, e); err != nil {
+			return nil, fmt.Errorf("parsing response: %w", err)
+		}
+	}
+
+	return e, nil
+}
+
+func burpExtension(p string) string {
+	ext := strings.TrimPrefix(path.Ext(p), ".")
+	if ext == "" {
+		return "null"
+	}
+	return ext
+}
+
+// burpMimeType maps a Content-Type to the coarse type names Burp uses.
+func burpMimeType(contentType string) string {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mt == "":
+		return ""
+	case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+		return "JSON"
+	case mt == "text/html":
+		return "HTML"
+	case mt == "text/css":
+		return "CSS"
+	case strings.Contains(mt, "javascript") || strings.Contains(mt, "ecmascript"):
+		return "script"
+	case mt == "application/xml" || mt == "text/xml" || strings.HasSuffix(mt, "+xml"):
+		return "XML"
+	case strings.HasPrefix(mt, "image/"):
+		return strings.TrimPrefix(mt, "image/")
+	case strings.HasPrefix(mt, "text/"):
+		return "text"
+	default:
+		return "app"
+	}
+}
@@ -0,0 +1,140 @@
+package exchange
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+const burpSample = `<?xml version="1.0"?>
+<!DOCTYPE items [
+<!ELEMENT items (item*)>
+<!ATTLIST items burpVersion CDATA "">
+]>
+<items burpVersion="2024.1" exportTime="Tue Jan 02 10:00:00 UTC 2024">
+  <item>
+    <time>Tue Jan 02 09:59:00 UTC 2024</time>
+    <url><![CDATA[https://shop.acme.com:8443/cart?id=3]]></url>
+    <host ip="10.1.1.1">shop.acme.com</host>
+    <port>8443</port>
+    <protocol>https</protocol>
+    <method><![CDATA[GET]]></method>
+    <path><![CDATA[/cart?id=3]]></path>
+    <extension>null</extension>
+    <request base64="true"><![CDATA[R0VUIC9jYXJ0P2lkPTMgSFRUUC8xLjENCkhvc3Q6IHNob3AuYWNtZS5jb20NCg0K]]></request>
+    <status>200</status>
+    <responselength>38</responselength>
+    <mimetype>JSON</mimetype>
+    <response base64="false"><![CDATA[HTTP/1.1 200 OK
+Content-Type: application/json
+
+{"n":1}]]></response>
+    <comment></comment>
+  </item>
+</items>
+`
+
+func TestReadBurp(t *testing.T) {
+	entries, err := ReadBurp(strings.NewReader(burpSample))
+	if err != nil {
+		t.Fatalf("reading burp xml: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+
+	e := entries[0]
+	if e.Host != "shop.acme.com" || e.Port != 8443 || e.Scheme != "https" {
+		t.Errorf("got %s://%s:%d, want https://shop.acme.com:8443", e.Scheme, e.Host, e.Port)
+	}
+	if e.Path != "/cart" || e.Query != "id=3" {
+		t.Errorf("path = %q query = %q", e.Path, e.Query)
+	}
+	if e.StatusCode != 200 {
+		t.Errorf("status = %d, want 200", e.StatusCode)
+	}
+	if string(e.ResponseBody) != `{"n":1}` {
+		t.Errorf("response body = %q", e.ResponseBody)
+	}
+	if e.Timestamp.IsZero() {
+		t.Error("expected timestamp to be parsed")
+	}
+}
+
+func TestBurpRoundTrip(t *testing.T) {
+	orig := []*storage.Entry{{
+		ID:              4,
+		Method:          "POST",
+		Scheme:          "http",
+		Host:            "127.0.0.1",
+		Port:            3000,
+		Path:            "/graphql",
+		RequestHeaders:  http.Header{"Content-Type": []string{"application/json"}},
+		RequestBody:     []byte("{\"query\":\"{ me { id } }\"}\x00"),
+		StatusCode:      500,
+		ResponseHeaders: http.Header{"Content-Type": []string{"text/plain"}},
+		ResponseBody:    []byte("boom"),
+		Timestamp:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteBurp(&buf, orig); err != nil {
+		t.Fatalf("writing burp xml: %v", err)
+	}
+
+	got, err := ReadBurp(&buf)
+	if err != nil {
+		t.Fatalf("reading burp xml: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d entries, want 1", len(got))
+	}
+
+	e := got[0]
+	if e.Port != 3000 {
+		t.Errorf("port = %d, want 3000", e.Port)
+	}
+	if !bytes.Equal(e.RequestBody, orig[0].RequestBody) {
+		t.Errorf("request body = %q, want %q", e.RequestBody, orig[0].RequestBody)
+	}
+	if e.StatusCode != 500 || string(e.ResponseBody) != "boom" {
+		t.Errorf("response = %d %q, want 500 boom", e.StatusCode, e.ResponseBody)
+	}
+	if !e.Timestamp.Equal(orig[0].Timestamp) {
+		t.Errorf("timestamp = %v, want %v", e.Timestamp, orig[0].Timestamp)
+	}
+}
+
+func TestRawDirRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	orig := []*storage.Entry{
+		{ID: 10, Method: "GET", Scheme: "https", Host: "a.com", Path: "/x", RequestHeaders: http.Header{}, StatusCode: 204, ResponseHeaders: http.Header{}},
+		{ID: 2, Method: "GET", Scheme: "https", Host: "a.com", Path: "/y", RequestHeaders: http.Header{}},
+	}
+
+	if err := WriteRawDir(dir, orig); err != nil {
+		t.Fatalf("writing raw dir: %v", err)
+	}
+
+	got, err := ReadRawDir(dir, "https")
+	if err != nil {
+		t.Fatalf("reading raw dir: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d entries, want 2", len(got))
+	}
+	if got[0].Path != "/y" || got[1].Path != "/x" {
+		t.Errorf("order = %s, %s; want /y, /x", got[0].Path, got[1].Path)
+	}
+	if got[0].StatusCode != 0 {
+		t.Errorf("entry without .res has status %d", got[0].StatusCode)
+	}
+	if got[1].StatusCode != 204 {
+		t.Errorf("status = %d, want 204", got[1].StatusCode)
+	}
+}
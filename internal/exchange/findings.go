@@ -0,0 +1,224 @@
+package exchange
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+	"github.com/ghostsecurity/reaper/version"
+)
+
+// jsonFinding is the stable JSON form of a finding.
+type jsonFinding struct {
+	ID          int64     `json:"id"`
+	Fingerprint string    `json:"fingerprint"`
+	Check       string    `json:"check"`
+	Title       string    `json:"title"`
+	Severity    string    `json:"severity"`
+	Confidence  string    `json:"confidence"`
+	Status      string    `json:"status"`
+	Host        string    `json:"host"`
+	Method      string    `json:"method,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	EntryIDs    []int64   `json:"entry_ids"`
+	Hits        int       `json:"hits"`
+	Evidence    string    `json:"evidence,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// WriteFindingsJSON writes findings as an indented JSON array with
+// snake_case keys.
+func WriteFindingsJSON(w io.Writer, findings []*storage.Finding) error {
+	out := make([]jsonFinding, len(findings))
+	for i, f := range findings {
+		out[i] = jsonFinding{
+			ID:          f.ID,
+			Fingerprint: f.Fingerprint,
+			Check:       f.Check,
+			Title:       f.Title,
+			Severity:    f.Severity,
+			Confidence:  f.Confidence,
+			Status:      f.Status,
+			Host:        f.Host,
+			Method:      f.Method,
+			URL:         f.URL,
+			EntryIDs:    f.EntryIDs,
+			Hits:        f.Hits,
+			Evidence:    f.Evidence,
+			Note:        f.Note,
+			FirstSeen:   f.FirstSeen,
+			LastSeen:    f.LastSeen,
+		}
+		if out[i].EntryIDs == nil {
+			out[i].EntryIDs = []int64{}
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// SARIF 2.1.0 structures, limited to what findings use.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string             `json:"ruleId"`
+	RuleIndex           int                `json:"ruleIndex"`
+	Level               string             `json:"level"`
+	Message             sarifMessage       `json:"message"`
+	Locations           []sarifLocation    `json:"locations,omitempty"`
+	PartialFingerprints map[string]string  `json:"partialFingerprints"`
+	Suppressions        []sarifSuppression `json:"suppressions,omitempty"`
+	Properties          map[string]any     `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Status        string `json:"status,omitempty"`
+	Justification string `json:"justification,omitempty"`
+}
+
+// sarifLevel maps a severity to a SARIF result level.
+func sarifLevel(severity string) string {
+	switch severity {
+	case storage.SeverityCritical, storage.SeverityHigh:
+		return "error"
+	case storage.SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// WriteSARIF writes findings as a SARIF 2.1.0 log with one rule per check.
+// Each result is located at the URL of the finding's first entry and keeps
+// the reaper fingerprint, so tools can track it across exports. Findings
+// marked false-positive are suppressed and fixed ones are reported with
+// their status in the result properties.
+func WriteSARIF(w io.Writer, findings []*storage.Finding) error {
+	driver := sarifDriver{
+		Name:           "reaper",
+		Version:        version.Version,
+		InformationURI: version.URL(),
+		Rules:          []sarifRule{},
+	}
+	results := []sarifResult{}
+	for _, f := range findings {
+		rule := slices.IndexFunc(driver.Rules, func(r sarifRule) bool { return r.ID == f.Check })
+		if rule < 0 {
+			rule = len(driver.Rules)
+			driver.Rules = append(driver.Rules, sarifRule{ID: f.Check, ShortDescription: sarifMessage{Text: checkDescription(f.Check)}})
+		}
+
+		msg := f.Title
+		if f.Evidence != "" {
+			msg += ": " + strings.ReplaceAll(f.Evidence, "\n", " ")
+		}
+		r := sarifResult{
+			RuleID:              f.Check,
+			RuleIndex:           rule,
+			Level:               sarifLevel(f.Severity),
+			Message:             sarifMessage{Text: msg},
+			PartialFingerprints: map[string]string{"reaper/v1": f.Fingerprint},
+			Properties: map[string]any{
+				"id":         f.ID,
+				"severity":   f.Severity,
+				"confidence": f.Confidence,
+				"status":     f.Status,
+				"host":       f.Host,
+				"entryIds":   f.EntryIDs,
+				"hits":       f.Hits,
+				"firstSeen":  f.FirstSeen,
+				"lastSeen":   f.LastSeen,
+			},
+		}
+		if f.Note != "" {
+			r.Properties["note"] = f.Note
+		}
+		if f.URL != "" {
+			r.Locations = []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: f.URL}}}}
+		}
+		if f.Status == storage.StatusFalsePositive {
+			r.Suppressions = []sarifSuppression{{Kind: "external", Status: "accepted", Justification: f.Note}}
+		}
+		results = append(results, r)
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// checkDescription describes the issues a check reports.
+func checkDescription(check string) string {
+	switch check {
+	case "security-headers":
+		return "Missing or weak security headers"
+	case "cookie-flags":
+		return "Cookies without Secure, HttpOnly or SameSite"
+	case "cors":
+		return "Permissive cross-origin resource sharing"
+	case "verbose-errors":
+		return "Stack traces, debug pages and database errors"
+	case "mixed-content":
+		return "HTTPS pages loading or submitting over HTTP"
+	case "reflection":
+		return "Request parameters reflected in the response"
+	case "secrets":
+		return "Secrets exposed in traffic"
+	case storage.CheckManual:
+		return "Findings recorded by hand"
+	}
+	return check
+}
@@ -0,0 +1,111 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+func testFindings() []*storage.Finding {
+	seen := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	return []*storage.Finding{
+		{ID: 1, Fingerprint: "abc", Check: "cors", Title: "CORS trusts the null origin", Severity: storage.SeverityHigh,
+			Confidence: storage.ConfidenceFirm, Status: storage.StatusConfirmed, Host: "api.acme.com", EntryID: 4,
+			EntryIDs: []int64{4, 9}, Hits: 2, Evidence: "Access-Control-Allow-Origin: null", Method: "GET",
+			URL: "https://api.acme.com/v1/me", FirstSeen: seen, LastSeen: seen},
+		{ID: 2, Fingerprint: "def", Check: "security-headers", Title: "Missing HSTS", Severity: storage.SeverityLow,
+			Confidence: storage.ConfidenceCertain, Status: storage.StatusFalsePositive, Host: "api.acme.com",
+			Note: "internal only", FirstSeen: seen, LastSeen: seen},
+		{ID: 3, Fingerprint: "ghi", Check: "cors", Title: "CORS allows any origin", Severity: storage.SeverityInfo,
+			Confidence: storage.ConfidenceCertain, Status: storage.StatusNew, Host: "cdn.acme.com", FirstSeen: seen, LastSeen: seen},
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, testFindings()); err != nil {
+		t.Fatalf("writing sarif: %v", err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string `json:"name"`
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex int    `json:"ruleIndex"`
+				Level     string `json:"level"`
+				Message   struct {
+					Text string `json:"text"`
+				} `json:"message"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+				PartialFingerprints map[string]string `json:"partialFingerprints"`
+				Suppressions        []struct {
+					Justification string `json:"justification"`
+				} `json:"suppressions"`
+				Properties map[string]any `json:"properties"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("decoding sarif: %v\n%s", err, buf.String())
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("log = %+v", log)
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "reaper" || len(run.Tool.Driver.Rules) != 2 || len(run.Results) != 3 {
+		t.Fatalf("run = %+v", run)
+	}
+
+	r := run.Results[0]
+	if r.RuleID != "cors" || r.Level != "error" || r.PartialFingerprints["reaper/v1"] != "abc" ||
+		r.Message.Text != "CORS trusts the null origin: Access-Control-Allow-Origin: null" {
+		t.Errorf("result 0 = %+v", r)
+	}
+	if len(r.Locations) != 1 || r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "https://api.acme.com/v1/me" {
+		t.Errorf("result 0 locations = %+v", r.Locations)
+	}
+	if r.Properties["status"] != "confirmed" || len(r.Suppressions) != 0 {
+		t.Errorf("result 0 status = %v, suppressions %v", r.Properties["status"], r.Suppressions)
+	}
+	if r := run.Results[1]; r.Level != "note" || r.RuleIndex != 1 || len(r.Suppressions) != 1 || r.Suppressions[0].Justification != "internal only" {
+		t.Errorf("false positive = %+v", r)
+	}
+	if r := run.Results[2]; r.RuleIndex != 0 || len(r.Locations) != 0 {
+		t.Errorf("result 2 = %+v", r)
+	}
+}
+
+func TestWriteFindingsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFindingsJSON(&buf, testFindings()); err != nil {
+		t.Fatalf("writing json: %v", err)
+	}
+	var out []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("decoding json: %v", err)
+	}
+	if len(out) != 3 || out[0]["status"] != "confirmed" || out[0]["url"] != "https://api.acme.com/v1/me" {
+		t.Fatalf("findings = %v", out)
+	}
+	if ids, ok := out[1]["entry_ids"].([]any); !ok || len(ids) != 0 {
+		t.Errorf("entry_ids without entries = %v, want []", out[1]["entry_ids"])
+	}
+}
@@ -0,0 +1,240 @@
+// Package exchange converts stored entries to and from formats used by other
+// security tools: raw HTTP messages, Burp Suite saved items and directories
+// of .req/.res files.
+package exchange
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// DumpRequest renders the request half of e as an HTTP/1.1 message. The
+// Host header carries the port when it is not the scheme default, and
+// Content-Length is set to match the stored body. The request of a raw
+// entry is returned as it was sent.
+func DumpRequest(e *storage.Entry) []byte {
+	if e.RawRequest != nil {
+		return e.RawRequest
+	}
+	target := e.Path
+	if target == "" {
+		target = "/"
+	}
+	if e.Query != "" {
+		target += "?" + e.Query
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", e.Method, target)
+	fmt.Fprintf(&b, "Host: %s\r\n", e.Authority())
+	writeHeaders(&b, e.RequestHeaders, len(e.RequestBody))
+	b.WriteString("\r\n")
+	b.Write(e.RequestBody)
+	return b.Bytes()
+}
+
+// DumpResponse renders the response half of e as an HTTP/1.1 message, or
+// returns the response of a raw entry as it was received.
+func DumpResponse(e *storage.Entry) []byte {
+	if e.RawRequest != nil {
+		return e.RawResponse
+	}
+	statusText := http.StatusText(e.StatusCode)
+	if statusText == "" {
+		statusText = "Unknown"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "HTTP/1.1 %d %s\r\n", e.StatusCode, statusText)
+	writeHeaders(&b, e.ResponseHeaders, len(e.ResponseBody))
+	b.WriteString("\r\n")
+	b.Write(e.ResponseBody)
+	return b.Bytes()
+}
+
+func writeHeaders(b *bytes.Buffer, h http.Header, bodyLen int) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		switch textproto.CanonicalMIMEHeaderKey(k) {
+		case "Host", "Content-Length", "Transfer-Encoding":
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(b, "%s: %s\r\n", k, v)
+		}
+	}
+
+	// Stored bodies are already de-chunked, so the original framing
+	// headers no longer describe them.
+	if bodyLen > 0 || h.Get("Content-Length") != "" {
+		fmt.Fprintf(b, "Content-Length: %d\r\n", bodyLen)
+	}
+}
+
+// ParseRequest parses a raw HTTP request into a new entry. Parsing is lenient:
+// the body is everything after the header block, regardless of
+// Content-Length. The host and port are taken from an absolute request
+// target or the Host header; scheme applies when the target is not absolute.
+func ParseRequest(raw []byte, scheme string) (*storage.Entry, error) {
+	startLine, header, body, err := splitMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Fields(startLine)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("malformed request line: %q", startLine)
+	}
+
+	target, err := url.ParseRequestURI(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed request target: %w", err)
+	}
+
+	authority := header.Get("Host")
+	if target.IsAbs() {
+		scheme = target.Scheme
+		authority = target.Host
+	}
+	header.Del("Host")
+	if authority == "" {
+		return nil, fmt.Errorf("request has no Host header")
+	}
+	if scheme == "" {
+		scheme = "https"
+	}
+
+	host, port, err := splitAuthority(authority, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage.Entry{
+		Method:          parts[0],
+		Scheme:          scheme,
+		Host:            host,
+		Port:            port,
+		Path:            target.Path,
+		Query:           target.RawQuery,
+		RequestHeaders:  header,
+		RequestBody:     decodeBody(header, body),
+		ResponseHeaders: http.Header{},
+	}, nil
+}
+
+// ParseResponse parses a raw HTTP response and fills the response fields of e.
+func ParseResponse(raw []byte, e *storage.Entry) error {
+	startLine, header, body, err := splitMessage(raw)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Fields(startLine)
+	if len(parts) < 2 || !strings.HasPrefix(parts[0], "HTTP/") {
+		return fmt.Errorf("malformed status line: %q", startLine)
+	}
+	status, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return fmt.Errorf("malformed status code: %q", parts[1])
+	}
+
+	e.StatusCode = status
+	e.ResponseHeaders = header
+	e.ResponseBody = decodeBody(header, body)
+	return nil
+}
+
+// splitMessage splits a raw HTTP message into its start line, headers and
+// body. Both CRLF and bare LF line endings are accepted.
+func splitMessage(raw []byte) (string, http.Header, []byte, error) {
+	head, body := raw, []byte(nil)
+	if i := bytes.Index(raw, []byte("\r\n\r\n")); i != -1 {
+		head, body = raw[:i], raw[i+4:]
+	} else if i := bytes.Index(raw, []byte("\n\n")); i != -1 {
+		head, body = raw[:i], raw[i+2:]
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(head), "\r\n", "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
+		return "", nil, nil, fmt.Errorf("empty message")
+	}
+
+	header := http.Header{}
+	var lastKey string
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && lastKey != "" {
+			// obsolete line folding
+			vv := header[lastKey]
+			vv[len(vv)-1] += " " + strings.TrimSpace(line)
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return "", nil, nil, fmt.Errorf("malformed header line: %q", line)
+		}
+		lastKey = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(k))
+		header.Add(lastKey, strings.TrimSpace(v))
+	}
+
+	if len(body) == 0 {
+		body = nil
+	}
+	return strings.TrimSpace(lines[0]), header, body, nil
+}
+
+// decodeBody removes chunked transfer coding and gzip content coding, as the
+// proxy does before storing, and drops the framing headers that no longer
+// apply. The body is returned unchanged if decoding fails.
+func decodeBody(header http.Header, body []byte) []byte {
+	if strings.EqualFold(header.Get("Transfer-Encoding"), "chunked") {
+		if decoded, err := io.ReadAll(httputil.NewChunkedReader(bytes.NewReader(body))); err == nil {
+			body = decoded
+			header.Del("Transfer-Encoding")
+			header.Del("Content-Length")
+		}
+	}
+
+	if strings.EqualFold(header.Get("Content-Encoding"), "gzip") && len(body) > 0 {
+		if zr, err := gzip.NewReader(bytes.NewReader(body)); err == nil {
+			if decoded, err := io.ReadAll(zr); err == nil {
+				body = decoded
+				header.Del("Content-Encoding")
+				header.Del("Content-Length")
+			}
+		}
+	}
+
+	return body
+}
+
+func splitAuthority(authority, scheme string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(authority)
+	if err != nil {
+		// no port present
+		return strings.Trim(authority, "[]"), storage.DefaultPort(scheme), nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in %q", authority)
+	}
+	return host, port, nil
+}
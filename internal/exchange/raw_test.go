@@ -0,0 +1,131 @@
+package exchange
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+func TestParseRequest(t *testing.T) {
+	raw := "POST /api/login?next=%2Fhome HTTP/1.1\r\n" +
+		"Host: api.acme.com:8443\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Content-Length: 99\r\n" +
+		"\r\n" +
+		`{"user":"admin"}`
+
+	e, err := ParseRequest([]byte(raw), "https")
+	if err != nil {
+		t.Fatalf("parsing request: %v", err)
+	}
+
+	if e.Method != "POST" {
+		t.Errorf("method = %q, want POST", e.Method)
+	}
+	if e.Host != "api.acme.com" {
+		t.Errorf("host = %q, want api.acme.com", e.Host)
+	}
+	if e.Port != 8443 {
+		t.Errorf("port = %d, want 8443", e.Port)
+	}
+	if e.Path != "/api/login" {
+		t.Errorf("path = %q, want /api/login", e.Path)
+	}
+	if e.Query != "next=%2Fhome" {
+		t.Errorf("query = %q, want next=%%2Fhome", e.Query)
+	}
+	if e.RequestHeaders.Get("Host") != "" {
+		t.Error("Host should not be kept in request headers")
+	}
+	if string(e.RequestBody) != `{"user":"admin"}` {
+		t.Errorf("body = %q", e.RequestBody)
+	}
+}
+
+func TestParseRequestAbsoluteTargetLF(t *testing.T) {
+	raw := "GET http://example.com/a HTTP/1.1\nAccept: */*\n\n"
+
+	e, err := ParseRequest([]byte(raw), "https")
+	if err != nil {
+		t.Fatalf("parsing request: %v", err)
+	}
+	if e.Scheme != "http" || e.Host != "example.com" || e.Port != 80 {
+		t.Errorf("got %s://%s:%d, want http://example.com:80", e.Scheme, e.Host, e.Port)
+	}
+	if e.RequestHeaders.Get("Accept") != "*/*" {
+		t.Errorf("accept = %q", e.RequestHeaders.Get("Accept"))
+	}
+	if e.RequestBody != nil {
+		t.Errorf("body = %q, want empty", e.RequestBody)
+	}
+}
+
+func TestParseResponseChunked(t *testing.T) {
+	raw := "HTTP/1.1 201 Created\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"\r\n" +
+		"5\r\nhello\r\n0\r\n\r\n"
+
+	var e storage.Entry
+	if err := ParseResponse([]byte(raw), &e); err != nil {
+		t.Fatalf("parsing response: %v", err)
+	}
+	if e.StatusCode != 201 {
+		t.Errorf("status = %d, want 201", e.StatusCode)
+	}
+	if string(e.ResponseBody) != "hello" {
+		t.Errorf("body = %q, want hello", e.ResponseBody)
+	}
+	if e.ResponseHeaders.Get("Transfer-Encoding") != "" {
+		t.Error("Transfer-Encoding should be removed after decoding")
+	}
+}
+
+func TestDumpRequestRoundTrip(t *testing.T) {
+	orig := &storage.Entry{
+		Method: "PUT",
+		Scheme: "http",
+		Host:   "10.0.0.5",
+		Port:   8080,
+		Path:   "/items/7",
+		RequestHeaders: http.Header{
+			"Content-Type":   []string{"text/plain"},
+			"Content-Length": []string{"1"},
+		},
+		RequestBody: []byte("updated"),
+	}
+
+	e, err := ParseRequest(DumpRequest(orig), "http")
+	if err != nil {
+		t.Fatalf("parsing dumped request: %v", err)
+	}
+	if e.Host != orig.Host || e.Port != orig.Port || e.Path != orig.Path {
+		t.Errorf("got %s:%d%s, want %s:%d%s", e.Host, e.Port, e.Path, orig.Host, orig.Port, orig.Path)
+	}
+	if got := e.RequestHeaders.Get("Content-Length"); got != "7" {
+		t.Errorf("content-length = %q, want 7", got)
+	}
+	if string(e.RequestBody) != "updated" {
+		t.Errorf("body = %q, want updated", e.RequestBody)
+	}
+}
+
+func TestRawEntry(t *testing.T) {
+	raw := []byte("POST /a?x=1 HTTP/1.1\nHost: h.com\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\nGET /smuggled HTTP/1.1\r\n\r\n")
+	e := &storage.Entry{
+		ID: 7, Method: "POST", Scheme: "https", Host: "h.com", Path: "/a", Query: "x=1", RequestBody: []byte("abc"),
+		RawRequest: raw, RawResponse: []byte("HTTP/1.0 200 OK\n\nhi"),
+	}
+	if !bytes.Equal(DumpRequest(e), raw) || string(DumpResponse(e)) != "HTTP/1.0 200 OK\n\nhi" {
+		t.Error("raw entry not dumped verbatim")
+	}
+
+	// Without the raw messages, the parsed fields are dumped.
+	e.RawRequest, e.RawResponse = nil, nil
+	if got := string(DumpRequest(e)); !strings.HasPrefix(got, "POST /a?x=1 HTTP/1.1\r\n") || !strings.HasSuffix(got, "\r\n\r\nabc") {
+		t.Errorf("parsed request = %q", got)
+	}
+}
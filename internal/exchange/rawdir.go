@@ -0,0 +1,99 @@
+package exchange
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// WriteRawDir writes each entry as <id>.req and <id>.res files in dir, the
+// layout accepted by sqlmap (-r) and ffuf (-request). Entries without a
+// response get no .res file.
+func WriteRawDir(dir string, entries []*storage.Entry) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+
+	for _, e := range entries {
+		base := filepath.Join(dir, strconv.FormatInt(e.ID, 10))
+		if err := os.WriteFile(base+".req", DumpRequest(e), 0600); err != nil {
+			return fmt.Errorf("writing request %d: %w", e.ID, err)
+		}
+		if e.StatusCode == 0 {
+			continue
+		}
+		if err := os.WriteFile(base+".res", DumpResponse(e), 0600); err != nil {
+			return fmt.Errorf("writing response %d: %w", e.ID, err)
+		}
+	}
+	return nil
+}
+
+// ReadRawDir reads every .req file in dir, pairing it with the .res file of
+// the same name when present. scheme is used for requests whose target is
+// not an absolute URL, since raw files do not record it.
+func ReadRawDir(dir, scheme string) ([]*storage.Entry, error) {
+	reqFiles, err := filepath.Glob(filepath.Join(dir, "*.req"))
+	if err != nil {
+		return nil, err
+	}
+	if len(reqFiles) == 0 {
+		return nil, fmt.Errorf("no .req files found in %s", dir)
+	}
+	sortNatural(reqFiles)
+
+	entries := make([]*storage.Entry, 0, len(reqFiles))
+	for _, reqPath := range reqFiles {
+		rawReq, err := os.ReadFile(reqPath) //nolint:gosec
+		if err != nil {
+			return nil, err
+		}
+		e, err := ParseRequest(rawReq, scheme)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(reqPath), err)
+		}
+
+		if info, err := os.Stat(reqPath); err == nil {
+			e.Timestamp = info.ModTime()
+		}
+
+		resPath := strings.TrimSuffix(reqPath, ".req") + ".res"
+		rawResp, err := os.ReadFile(resPath) //nolint:gosec
+		switch {
+		case err == nil:
+			if err := ParseResponse(rawResp, e); err != nil {
+				return nil, fmt.Errorf("%s: %w", filepath.Base(resPath), err)
+			}
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// sortNatural orders paths so that numeric names sort by value (2.req
+// before 10.req), preserving export order on re-import.
+func sortNatural(paths []string) {
+	key := func(p string) (int64, bool) {
+		n, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(p), ".req"), 10, 64)
+		return n, err == nil
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		a, aNum := key(paths[i])
+		b, bNum := key(paths[j])
+		if aNum && bNum {
+			return a < b
+		}
+		if aNum != bNum {
+			return aNum
+		}
+		return paths[i] < paths[j]
+	})
+}
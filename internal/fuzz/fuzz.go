@@ -0,0 +1,245 @@
+// Package fuzz generates request variants from a raw HTTP request template
+// with marked payload positions, in the attack modes of Burp Intruder.
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Marker delimits a payload position in a template: §default§.
+const Marker = "§"
+
+// MaxRequests caps the number of requests a single attack may generate.
+const MaxRequests = 100000
+
+// Mode selects how payloads are assigned to positions.
+type Mode string
+
+const (
+	// Sniper uses one payload list and targets each position in turn,
+	// leaving the others at their default.
+	Sniper Mode = "sniper"
+	// BatteringRam uses one payload list and places the same payload in
+	// every position.
+	BatteringRam Mode = "battering-ram"
+	// Pitchfork uses one list per position and steps through them in
+	// parallel, stopping at the end of the shortest.
+	Pitchfork Mode = "pitchfork"
+	// ClusterBomb uses one list per position and tries every combination.
+	ClusterBomb Mode = "cluster-bomb"
+)
+
+// Modes lists the supported attack modes.
+var Modes = []Mode{Sniper, BatteringRam, Pitchfork, ClusterBomb}
+
+// ParseMode returns the mode named s.
+func ParseMode(s string) (Mode, error) {
+	for _, m := range Modes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown attack mode %q (want sniper, battering-ram, pitchfork or cluster-bomb)", s)
+}
+
+// Template is a raw request split around its payload positions.
+type Template struct {
+	literals []string // len(defaults)+1 pieces surrounding the positions
+	defaults []string // text originally between each pair of markers
+}
+
+// ParseTemplate splits raw at § markers. Markers must come in pairs and at
+// least one position is required.
+func ParseTemplate(raw []byte) (*Template, error) {
+	parts := strings.Split(string(raw), Marker)
+	if len(parts) == 1 {
+		return nil, fmt.Errorf("no payload positions marked (wrap each with %s)", Marker)
+	}
+	if len(parts)%2 == 0 {
+		return nil, fmt.Errorf("unbalanced %s markers", Marker)
+	}
+
+	t := &Template{}
+	for i, part := range parts {
+		if i%2 == 0 {
+			t.literals = append(t.literals, part)
+		} else {
+			t.defaults = append(t.defaults, part)
+		}
+	}
+	return t, nil
+}
+
+// Positions returns the number of payload positions.
+func (t *Template) Positions() int {
+	return len(t.defaults)
+}
+
+// Render returns the request with values inserted at each position.
+func (t *Template) Render(values []string) []byte {
+	var b bytes.Buffer
+	for i, lit := range t.literals {
+		b.WriteString(lit)
+		if i < len(values) {
+			b.WriteString(values[i])
+		}
+	}
+	return b.Bytes()
+}
+
+// Defaults returns the request with every position at its default text.
+func (t *Template) Defaults() []byte {
+	return t.Render(t.defaults)
+}
+
+// Attack is a template, a mode and the payload lists to apply.
+type Attack struct {
+	Template  *Template
+	Mode      Mode
+	Payloads  [][]string
+	URLEncode bool // query-escape payloads before inserting them
+}
+
+// Variant is one generated request.
+type Variant struct {
+	Seq      int      // 1-based
+	Position int      // 1-based position targeted by a sniper variant, otherwise 0
+	Payloads []string // payloads used: one for sniper and battering-ram, one per position otherwise
+	Raw      []byte
+}
+
+// Validate checks that the payload lists fit the mode and template.
+func (a *Attack) Validate() error {
+	switch a.Mode {
+	case Sniper, BatteringRam:
+		if len(a.Payloads) != 1 {
+			return fmt.Errorf("%s takes exactly one payload list, got %d", a.Mode, len(a.Payloads))
+		}
+	case Pitchfork, ClusterBomb:
+		if len(a.Payloads) != a.Template.Positions() {
+			return fmt.Errorf("%s takes one payload list per position: %d positions, %d lists",
+				a.Mode, a.Template.Positions(), len(a.Payloads))
+		}
+	default:
+		return fmt.Errorf("unknown attack mode %q", a.Mode)
+	}
+
+	for i, list := range a.Payloads {
+		if len(list) == 0 {
+			return fmt.Errorf("payload list %d is empty", i+1)
+		}
+	}
+	if n := a.Count(); n > MaxRequests {
+		return fmt.Errorf("attack would send %d requests, more than the limit of %d", n, MaxRequests)
+	}
+	return nil
+}
+
+// Count returns the number of variants the attack generates.
+func (a *Attack) Count() int {
+	positions := a.Template.Positions()
+	switch a.Mode {
+	case Sniper:
+		return positions * len(a.Payloads[0])
+	case BatteringRam:
+		return len(a.Payloads[0])
+	case Pitchfork:
+		n := len(a.Payloads[0])
+		for _, list := range a.Payloads[1:] {
+			n = min(n, len(list))
+		}
+		return n
+	case ClusterBomb:
+		n := 1
+		for _, list := range a.Payloads {
+			n *= len(list)
+			if n > MaxRequests {
+				return n
+			}
+		}
+		return n
+	}
+	return 0
+}
+
+// Each calls fn with every variant in order until fn returns false. The
+// attack must be valid.
+func (a *Attack) Each(fn func(Variant) bool) {
+	seq := 0
+	emit := func(position int, payloads, values []string) bool {
+		seq++
+		return fn(Variant{Seq: seq, Position: position, Payloads: payloads, Raw: a.Template.Render(values)})
+	}
+	positions := a.Template.Positions()
+
+	switch a.Mode {
+	case Sniper:
+		for pos := 0; pos < positions; pos++ {
+			for _, p := range a.Payloads[0] {
+				values := append([]string(nil), a.Template.defaults...)
+				values[pos] = a.encode(p)
+				if !emit(pos+1, []string{p}, values) {
+					return
+				}
+			}
+		}
+	case BatteringRam:
+		for _, p := range a.Payloads[0] {
+			values := make([]string, positions)
+			for i := range values {
+				values[i] = a.encode(p)
+			}
+			if !emit(0, []string{p}, values) {
+				return
+			}
+		}
+	case Pitchfork:
+		for i := 0; i < a.Count(); i++ {
+			payloads := make([]string, positions)
+			values := make([]string, positions)
+			for pos := range payloads {
+				payloads[pos] = a.Payloads[pos][i]
+				values[pos] = a.encode(payloads[pos])
+			}
+			if !emit(0, payloads, values) {
+				return
+			}
+		}
+	case ClusterBomb:
+		// odometer over the lists, last position varying fastest
+		idx := make([]int, positions)
+		for {
+			payloads := make([]string, positions)
+			values := make([]string, positions)
+			for pos, i := range idx {
+				payloads[pos] = a.Payloads[pos][i]
+				values[pos] = a.encode(payloads[pos])
+			}
+			if !emit(0, payloads, values) {
+				return
+			}
+
+			pos := positions - 1
+			for ; pos >= 0; pos-- {
+				idx[pos]++
+				if idx[pos] < len(a.Payloads[pos]) {
+					break
+				}
+				idx[pos] = 0
+			}
+			if pos < 0 {
+				return
+			}
+		}
+	}
+}
+
+func (a *Attack) encode(p string) string {
+	if a.URLEncode {
+		return url.QueryEscape(p)
+	}
+	return p
+}
@@ -0,0 +1,210 @@
+package fuzz
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+const testTemplate = "GET /users/§1§?sort=§asc§ HTTP/1.1\r\nHost: example.com\r\n\r\n"
+
+func variants(t *testing.T, a *Attack) []Variant {
+	t.Helper()
+	if err := a.Validate(); err != nil {
+		t.Fatalf("validating: %v", err)
+	}
+	var out []Variant
+	a.Each(func(v Variant) bool {
+		out = append(out, v)
+		return true
+	})
+	if len(out) != a.Count() {
+		t.Errorf("generated %d variants, Count() = %d", len(out), a.Count())
+	}
+	return out
+}
+
+func requestLine(v Variant) string {
+	line, _, _ := strings.Cut(string(v.Raw), "\r\n")
+	return line
+}
+
+func TestParseTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate([]byte(testTemplate))
+	if err != nil {
+		t.Fatalf("parsing: %v", err)
+	}
+	if tmpl.Positions() != 2 {
+		t.Errorf("positions = %d, want 2", tmpl.Positions())
+	}
+	if got := string(tmpl.Defaults()); got != strings.ReplaceAll(testTemplate, Marker, "") {
+		t.Errorf("defaults = %q", got)
+	}
+
+	if _, err := ParseTemplate([]byte("GET / HTTP/1.1\r\n\r\n")); err == nil {
+		t.Error("expected error for template without positions")
+	}
+	if _, err := ParseTemplate([]byte("GET /§a HTTP/1.1\r\n\r\n")); err == nil {
+		t.Error("expected error for unbalanced markers")
+	}
+}
+
+func TestAttackModes(t *testing.T) {
+	tmpl, _ := ParseTemplate([]byte(testTemplate))
+
+	sniper := variants(t, &Attack{Template: tmpl, Mode: Sniper, Payloads: [][]string{{"x", "y"}}})
+	want := []string{
+		"GET /users/x?sort=asc HTTP/1.1",
+		"GET /users/y?sort=asc HTTP/1.1",
+		"GET /users/1?sort=x HTTP/1.1",
+		"GET /users/1?sort=y HTTP/1.1",
+	}
+	for i, v := range sniper {
+		if got := requestLine(v); got != want[i] {
+			t.Errorf("sniper %d = %q, want %q", i, got, want[i])
+		}
+	}
+	if sniper[2].Position != 2 || sniper[2].Seq != 3 {
+		t.Errorf("sniper variant 3 position = %d seq = %d, want 2 and 3", sniper[2].Position, sniper[2].Seq)
+	}
+
+	ram := variants(t, &Attack{Template: tmpl, Mode: BatteringRam, Payloads: [][]string{{"z"}}})
+	if got := requestLine(ram[0]); got != "GET /users/z?sort=z HTTP/1.1" {
+		t.Errorf("battering-ram = %q", got)
+	}
+
+	fork := variants(t, &Attack{Template: tmpl, Mode: Pitchfork, Payloads: [][]string{{"1", "2", "3"}, {"a", "b"}}})
+	if len(fork) != 2 || requestLine(fork[1]) != "GET /users/2?sort=b HTTP/1.1" {
+		t.Errorf("pitchfork = %v", fork)
+	}
+
+	bomb := variants(t, &Attack{Template: tmpl, Mode: ClusterBomb, Payloads: [][]string{{"1", "2"}, {"a", "b", "c"}}})
+	if len(bomb) != 6 {
+		t.Fatalf("cluster-bomb generated %d variants, want 6", len(bomb))
+	}
+	if got := requestLine(bomb[4]); got != "GET /users/2?sort=b HTTP/1.1" {
+		t.Errorf("cluster-bomb 5 = %q", got)
+	}
+	if p := bomb[5].Payloads; len(p) != 2 || p[0] != "2" || p[1] != "c" {
+		t.Errorf("cluster-bomb 6 payloads = %v, want [2 c]", p)
+	}
+
+	encoded := variants(t, &Attack{Template: tmpl, Mode: BatteringRam, Payloads: [][]string{{"a b&c"}}, URLEncode: true})
+	if got := requestLine(encoded[0]); got != "GET /users/a+b%26c?sort=a+b%26c HTTP/1.1" {
+		t.Errorf("encoded = %q", got)
+	}
+	if encoded[0].Payloads[0] != "a b&c" {
+		t.Errorf("recorded payload = %q, want the unencoded payload", encoded[0].Payloads[0])
+	}
+}
+
+func TestAttackValidate(t *testing.T) {
+	tmpl, _ := ParseTemplate([]byte(testTemplate))
+
+	tests := []struct {
+		name string
+		a    *Attack
+	}{
+		{"sniper with two lists", &Attack{Template: tmpl, Mode: Sniper, Payloads: [][]string{{"a"}, {"b"}}}},
+		{"pitchfork with one list", &Attack{Template: tmpl, Mode: Pitchfork, Payloads: [][]string{{"a"}}}},
+		{"empty list", &Attack{Template: tmpl, Mode: BatteringRam, Payloads: [][]string{{}}}},
+		{"too many requests", &Attack{Template: tmpl, Mode: ClusterBomb, Payloads: [][]string{make([]string, 1000), make([]string, 1000)}}},
+	}
+	for _, tt := range tests {
+		if err := tt.a.Validate(); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestRunConcurrencyAndCancel(t *testing.T) {
+	tmpl, _ := ParseTemplate([]byte(testTemplate))
+	payloads := make([]string, 50)
+	a := &Attack{Template: tmpl, Mode: BatteringRam, Payloads: [][]string{payloads}}
+
+	var mu sync.Mutex
+	inFlight, peak, sent := 0, 0, 0
+	err := Run(context.Background(), a, Options{Concurrency: 3}, func(ctx context.Context, v Variant) {
+		mu.Lock()
+		inFlight++
+		sent++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("running: %v", err)
+	}
+	if sent != 50 {
+		t.Errorf("sent %d, want 50", sent)
+	}
+	if peak > 3 {
+		t.Errorf("peak concurrency = %d, want at most 3", peak)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sent = 0
+	err = Run(ctx, a, Options{Concurrency: 1}, func(ctx context.Context, v Variant) {
+		sent++
+		if sent == 5 {
+			cancel()
+		}
+	})
+	if err == nil {
+		t.Error("expected cancellation error")
+	}
+	if sent >= 50 {
+		t.Errorf("sent %d after cancellation, want fewer than 50", sent)
+	}
+}
+
+func TestRunRejectsBadRate(t *testing.T) {
+	tmpl, _ := ParseTemplate([]byte(testTemplate))
+	a := &Attack{Template: tmpl, Mode: BatteringRam, Payloads: [][]string{{"x"}}}
+	for _, rate := range []float64{-1, 1e-10, MinRate / 2, 2e9, math.Inf(1), math.NaN()} {
+		sent := false
+		err := Run(context.Background(), a, Options{Rate: rate}, func(ctx context.Context, v Variant) { sent = true })
+		if err == nil || sent {
+			t.Errorf("rate %v: err = %v, sent = %v; want rejected", rate, err, sent)
+		}
+	}
+	for _, rate := range []float64{0, MinRate, MaxRate} {
+		if err := (Options{Rate: rate}).Validate(); err != nil {
+			t.Errorf("rate %v rejected: %v", rate, err)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	results := []*storage.FuzzResult{
+		{Seq: 1, StatusCode: 200, Length: 1000, DurationMs: 50, Payloads: []string{"a"}},
+		{Seq: 2, StatusCode: 200, Length: 1009, DurationMs: 55, Payloads: []string{"reflected!"}},
+		{Seq: 3, StatusCode: 500, Length: 1000, DurationMs: 50, Payloads: []string{"'"}},
+		{Seq: 4, StatusCode: 200, Length: 4000, DurationMs: 60, Payloads: []string{"b"}},
+		{Seq: 5, StatusCode: 200, Length: 1000, DurationMs: 3000, Payloads: []string{"sleep"}},
+		{Seq: 6, Error: "connection refused", Payloads: []string{"c"}},
+	}
+
+	ranked := Rank(results)
+	order := make([]int, len(ranked))
+	for i, r := range ranked {
+		order[i] = r.Seq
+	}
+	want := []int{3, 6, 4, 5, 1, 2}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+	if len(ranked[5].Anomalies) != 0 {
+		t.Errorf("reflected payload flagged: %v", ranked[5].Anomalies)
+	}
+}
@@ -0,0 +1,122 @@
+package fuzz
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// Ranked is a fuzz result with the ways it stands out from the rest of its
+// run.
+type Ranked struct {
+	*storage.FuzzResult
+	Score     int
+	Anomalies []string
+}
+
+// Anomaly weights: a different status code says more than a different length,
+// which says more than a slow response.
+const (
+	scoreError  = 3
+	scoreStatus = 3
+	scoreLength = 2
+	scoreTime   = 1
+)
+
+// Rank compares each result with the run's baseline, the most common status
+// code and the median response length and time among responses with that
+// status, and returns the results most anomalous first. Lengths are
+// compared after subtracting the payload lengths, so payloads merely
+// reflected in the response do not stand out. Results with equal scores
+// keep sequence order.
+func Rank(results []*storage.FuzzResult) []Ranked {
+	var statuses []int
+	for _, r := range results {
+		if r.Error == "" {
+			statuses = append(statuses, r.StatusCode)
+		}
+	}
+	baseStatus := mode(statuses)
+
+	// Length and time baselines come from the typical responses only, so a
+	// run split between two statuses does not flag both halves.
+	var lengths []int
+	var durations []int64
+	for _, r := range results {
+		if r.Error == "" && r.StatusCode == baseStatus {
+			lengths = append(lengths, adjustedLength(r))
+			durations = append(durations, r.DurationMs)
+		}
+	}
+	baseLength := median(lengths)
+	baseDuration := median(durations)
+
+	ranked := make([]Ranked, len(results))
+	for i, r := range results {
+		rk := Ranked{FuzzResult: r}
+		if r.Error != "" {
+			rk.Score += scoreError
+			rk.Anomalies = append(rk.Anomalies, "error")
+		} else {
+			if r.StatusCode != baseStatus {
+				rk.Score += scoreStatus
+				rk.Anomalies = append(rk.Anomalies, fmt.Sprintf("status %d (usually %d)", r.StatusCode, baseStatus))
+			}
+			if diff := adjustedLength(r) - baseLength; abs(diff) > max(baseLength/20, 16) {
+				rk.Score += scoreLength
+				rk.Anomalies = append(rk.Anomalies, fmt.Sprintf("length %+d", diff))
+			}
+			if r.DurationMs >= 2*baseDuration && r.DurationMs-baseDuration >= 250 {
+				rk.Score += scoreTime
+				rk.Anomalies = append(rk.Anomalies, fmt.Sprintf("slow %dms (median %dms)", r.DurationMs, baseDuration))
+			}
+		}
+		ranked[i] = rk
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked
+}
+
+func adjustedLength(r *storage.FuzzResult) int {
+	n := r.Length
+	for _, p := range r.Payloads {
+		n -= len(p)
+	}
+	return n
+}
+
+// mode returns the most common value, preferring the smallest on ties.
+func mode(values []int) int {
+	counts := map[int]int{}
+	best, bestCount := 0, 0
+	for _, v := range values {
+		counts[v]++
+	}
+	for v, c := range counts {
+		if c > bestCount || (c == bestCount && v < best) {
+			best, bestCount = v, c
+		}
+	}
+	return best
+}
+
+func median[T int | int64](values []T) T {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
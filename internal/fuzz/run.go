@@ -0,0 +1,90 @@
+package fuzz
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is the number of parallel requests when none is set.
+const DefaultConcurrency = 10
+
+// MaxRate and MinRate bound the rate limit accepted, in requests per
+// second. Below MinRate, one request an hour, the interval between
+// requests is too long to be meaningful and eventually overflows.
+const (
+	MaxRate = 10000
+	MinRate = 1.0 / 3600
+)
+
+// Options controls how an attack is sent.
+type Options struct {
+	Concurrency int     // parallel requests; DefaultConcurrency if 0
+	Rate        float64 // maximum requests per second; 0 for no limit
+}
+
+// Validate reports an error if the options are out of range.
+func (o Options) Validate() error {
+	if math.IsNaN(o.Rate) || o.Rate < 0 || o.Rate > MaxRate || (o.Rate > 0 && o.Rate < MinRate) {
+		return fmt.Errorf("rate must be 0 for no limit, or between %g (one an hour) and %d requests per second", MinRate, MaxRate)
+	}
+	return nil
+}
+
+// Run calls send for every variant of a, with up to opts.Concurrency calls in
+// flight, and returns once all have returned. It stops generating variants
+// when ctx is cancelled and returns ctx.Err().
+func Run(ctx context.Context, a *Attack, opts Options, send func(context.Context, Variant)) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = DefaultConcurrency
+	}
+
+	var tick <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	variants := make(chan Variant)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range variants {
+				send(ctx, v)
+			}
+		}()
+	}
+
+	first := true
+	a.Each(func(v Variant) bool {
+		// the first request goes out immediately; later ones wait their turn
+		if tick != nil && !first {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		first = false
+
+		select {
+		case variants <- v:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+	close(variants)
+	wg.Wait()
+
+	return ctx.Err()
+}
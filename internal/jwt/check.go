@@ -0,0 +1,115 @@
+package jwt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// maxLifetime is the longest token lifetime not reported as excessive.
+const maxLifetime = 24 * time.Hour
+
+// CommonSecrets are HMAC keys tried when no wordlist is given: framework
+// defaults, documentation examples and placeholder values.
+var CommonSecrets = []string{
+	"secret", "secretkey", "secret_key", "secret-key", "mysecret", "my_secret", "jwtsecret", "jwt_secret",
+	"jwt-secret", "your-256-bit-secret", "your-384-bit-secret", "your-512-bit-secret", "your_jwt_secret",
+	"changeme", "change_me", "changeit", "password", "passw0rd", "123456", "12345678", "admin", "test",
+	"key", "private", "default", "development", "dev", "shhhhh", "keyboard cat", "supersecret",
+	"super-secret", "s3cr3t", "hello", "qwerty", "token", "auth", "example", "",
+}
+
+// kidSuspicious matches kid values that look like they are used as a path
+// or in a query, where injection is most likely to matter.
+var kidSuspicious = regexp.MustCompile(`[/\\.'";]|\.\.|(?i)\b(?:select|union|file|http)\b`)
+
+// Weakness is a problem with a token.
+type Weakness struct {
+	Severity string `json:"severity"`
+	Title    string `json:"title"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// Check returns the weaknesses visible in t itself. Whether the server
+// accepts unsigned tokens and whether the key is guessable need the replay
+// and Crack.
+func Check(t *Token, now time.Time) []Weakness {
+	var ws []Weakness
+	add := func(severity, title, detail string) {
+		ws = append(ws, Weakness{Severity: severity, Title: title, Detail: detail})
+	}
+
+	switch alg := t.Alg(); {
+	case alg == "":
+		add(storage.SeverityMedium, "No algorithm in header", "the alg header parameter is missing")
+	case strings.EqualFold(alg, "none"):
+		add(storage.SeverityHigh, "Unsigned token", fmt.Sprintf("alg is %q and the token has no signature", alg))
+	case strings.HasPrefix(alg, "HS"):
+		add(storage.SeverityInfo, "HMAC-signed token",
+			"signed with a shared secret; anyone who guesses it can forge tokens")
+	}
+
+	exp, hasExp := t.Expiry()
+	if !hasExp {
+		add(storage.SeverityMedium, "No expiry", "the token has no exp claim and is valid until the key changes")
+	} else {
+		if iat, ok := t.IssuedAt(); ok && exp.Sub(iat) > maxLifetime {
+			add(storage.SeverityLow, "Long-lived token", fmt.Sprintf("valid for %s after issue", exp.Sub(iat).Round(time.Minute)))
+		}
+		if exp.Before(now) {
+			add(storage.SeverityInfo, "Expired token", "expired "+exp.UTC().Format(time.RFC3339)+"; a server accepting it does not check exp")
+		}
+	}
+
+	if kid, ok := t.Header["kid"]; ok {
+		s := fmt.Sprint(kid)
+		if kidSuspicious.MatchString(s) {
+			add(storage.SeverityMedium, "kid injection candidate",
+				fmt.Sprintf("kid %q looks like a path or query value; try ../../dev/null with an empty secret, or SQL injection", s))
+		} else {
+			add(storage.SeverityInfo, "kid header present",
+				fmt.Sprintf("kid %q selects the key; test it for path traversal and injection", s))
+		}
+	}
+	for _, name := range []string{"jku", "x5u"} {
+		if v, ok := t.Header[name]; ok {
+			add(storage.SeverityMedium, name+" header present",
+				fmt.Sprintf("the key is fetched from %v; a server that follows an attacker's URL accepts forged tokens", v))
+		}
+	}
+	if _, ok := t.Header["jwk"]; ok {
+		add(storage.SeverityMedium, "Embedded jwk header",
+			"the token carries its own key; a server that trusts it accepts tokens signed with any key")
+	}
+	return ws
+}
+
+// Crack tries each secret against an HMAC-signed token and returns the one
+// that produced its signature.
+func Crack(t *Token, secrets []string) (string, bool) {
+	if !strings.HasPrefix(t.Alg(), "HS") {
+		return "", false
+	}
+	for _, s := range secrets {
+		if Verify(t, []byte(s)) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// ReadWordlist reads one secret per line from r.
+func ReadWordlist(r io.Reader) ([]string, error) {
+	var words []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		words = append(words, strings.TrimRight(sc.Text(), "\r"))
+	}
+	return words, sc.Err()
+}
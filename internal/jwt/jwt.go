@@ -0,0 +1,283 @@
+// Package jwt decodes, inspects and forges JSON Web Tokens found in traffic.
+// It handles the compact JWS form; signatures are only created and checked
+// for the HMAC algorithms and "none".
+package jwt
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// pattern matches a compact JWT: a JSON header and payload, which encode to
+// a leading "eyJ", and a signature, empty for alg none.
+var pattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]{2,}\.eyJ[A-Za-z0-9_-]{2,}\.[A-Za-z0-9_-]*`)
+
+var b64 = base64.RawURLEncoding
+
+// Token is a decoded JWT.
+type Token struct {
+	Raw       string
+	Header    map[string]any
+	Claims    map[string]any
+	Signature []byte
+
+	header, payload string // encoded segments as received
+}
+
+// Parse decodes a compact JWT without verifying it.
+func Parse(s string) (*Token, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("not a JWT: want three dot-separated parts")
+	}
+	t := &Token{Raw: strings.Join(parts, "."), header: parts[0], payload: parts[1]}
+
+	var err error
+	if t.Header, err = decodeSegment(parts[0]); err != nil {
+		return nil, fmt.Errorf("decoding header: %w", err)
+	}
+	if t.Claims, err = decodeSegment(parts[1]); err != nil {
+		return nil, fmt.Errorf("decoding claims: %w", err)
+	}
+	if t.Signature, err = b64.DecodeString(strings.TrimRight(parts[2], "=")); err != nil {
+		return nil, fmt.Errorf("decoding signature: %w", err)
+	}
+	return t, nil
+}
+
+func decodeSegment(seg string) (map[string]any, error) {
+	data, err := b64.DecodeString(strings.TrimRight(seg, "="))
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // keep exp and iat exact when re-encoding
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, errors.New("not a JSON object")
+	}
+	return m, nil
+}
+
+// Alg returns the signing algorithm named in the header.
+func (t *Token) Alg() string {
+	alg, _ := t.Header["alg"].(string)
+	return alg
+}
+
+// Expiry returns the time of the exp claim, if the token has one.
+func (t *Token) Expiry() (time.Time, bool) {
+	return t.timeClaim("exp")
+}
+
+// IssuedAt returns the time of the iat claim, if the token has one.
+func (t *Token) IssuedAt() (time.Time, bool) {
+	return t.timeClaim("iat")
+}
+
+func (t *Token) timeClaim(name string) (time.Time, bool) {
+	n, ok := t.Claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+// Find returns the distinct JWTs in s, in order of appearance.
+func Find(s string) []string {
+	var tokens []string
+	for _, m := range pattern.FindAllString(s, -1) {
+		if _, err := Parse(m); err == nil && !slices.Contains(tokens, m) {
+			tokens = append(tokens, m)
+		}
+	}
+	return tokens
+}
+
+// FindInRequest returns the distinct JWTs in the request of e: in headers,
+// including cookies, the URL and the body.
+func FindInRequest(e *storage.Entry) []string {
+	var tokens []string
+	add := func(s string) {
+		for _, t := range Find(s) {
+			if !slices.Contains(tokens, t) {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	for _, values := range e.RequestHeaders {
+		for _, v := range values {
+			add(v)
+		}
+	}
+	add(e.Path)
+	if q, err := url.QueryUnescape(e.Query); err == nil {
+		add(q)
+	} else {
+		add(e.Query)
+	}
+	add(string(e.RequestBody))
+	return tokens
+}
+
+// FindInResponse returns the distinct JWTs in the response of e, where
+// servers issue them: in headers, including Set-Cookie, and text bodies.
+func FindInResponse(e *storage.Entry) []string {
+	var text strings.Builder
+	for _, values := range e.ResponseHeaders {
+		for _, v := range values {
+			text.WriteString(v)
+			text.WriteByte('\n')
+		}
+	}
+	ct := e.ResponseHeaders.Get("Content-Type")
+	if ct == "" || strings.HasPrefix(ct, "text/") || strings.Contains(ct, "json") ||
+		strings.Contains(ct, "javascript") || strings.Contains(ct, "x-www-form-urlencoded") {
+		text.Write(e.ResponseBody)
+	}
+	return Find(text.String())
+}
+
+// ForgeOptions describes changes to a token.
+type ForgeOptions struct {
+	Claims map[string]any // claims to set; a nil value deletes the claim
+	Header map[string]any // header parameters to set, e.g. kid
+	Alg    string         // signing algorithm: "none", an HS algorithm, or empty to keep the token's
+	Secret []byte         // HMAC key; without it the original signature is kept
+}
+
+// Forge returns t modified as described by opts. With alg none the token is
+// unsigned. Otherwise it is signed with Secret if one is given, which needs
+// an HS algorithm; without a secret the original signature is kept, which a
+// server that verifies signatures will reject.
+func Forge(t *Token, opts ForgeOptions) (string, error) {
+	header, payload := t.header, t.payload
+
+	alg := opts.Alg
+	if len(opts.Header) > 0 || (alg != "" && alg != t.Alg()) {
+		h := maps.Clone(t.Header)
+		maps.Copy(h, opts.Header)
+		if alg != "" {
+			h["alg"] = alg
+		}
+		enc, err := encodeSegment(h)
+		if err != nil {
+			return "", fmt.Errorf("encoding header: %w", err)
+		}
+		header = enc
+	}
+	if alg == "" {
+		alg = t.Alg()
+	}
+
+	if len(opts.Claims) > 0 {
+		c := maps.Clone(t.Claims)
+		for k, v := range opts.Claims {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		enc, err := encodeSegment(c)
+		if err != nil {
+			return "", fmt.Errorf("encoding claims: %w", err)
+		}
+		payload = enc
+	}
+
+	input := header + "." + payload
+	switch {
+	case strings.EqualFold(alg, "none"):
+		return input + ".", nil
+	case opts.Secret != nil:
+		sig, err := Sign(alg, input, opts.Secret)
+		if err != nil {
+			return "", err
+		}
+		return input + "." + b64.EncodeToString(sig), nil
+	default:
+		return input + "." + b64.EncodeToString(t.Signature), nil
+	}
+}
+
+func encodeSegment(m map[string]any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(m); err != nil {
+		return "", err
+	}
+	return b64.EncodeToString(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
+
+// Sign returns the HMAC signature of input for an HS algorithm.
+func Sign(alg, input string, secret []byte) ([]byte, error) {
+	h, err := hmacHash(alg)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(h, secret)
+	mac.Write([]byte(input))
+	return mac.Sum(nil), nil
+}
+
+func hmacHash(alg string) (func() hash.Hash, error) {
+	switch alg {
+	case "HS256":
+		return sha256.New, nil
+	case "HS384":
+		return sha512.New384, nil
+	case "HS512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("cannot sign with %q: only HS256, HS384, HS512 and none are supported", alg)
+}
+
+// Verify reports whether secret produced t's HMAC signature.
+func Verify(t *Token, secret []byte) bool {
+	sig, err := Sign(t.Alg(), t.header+"."+t.payload, secret)
+	return err == nil && hmac.Equal(sig, t.Signature)
+}
+
+// ParseClaim parses a name=value assignment. The value is read as JSON if
+// it is valid JSON, so role=admin is a string and admin=true a boolean;
+// name= with no value deletes the claim.
+func ParseClaim(kv string) (string, any, error) {
+	k, v, ok := strings.Cut(kv, "=")
+	if !ok || k == "" {
+		return "", nil, fmt.Errorf("invalid claim %q: want name=value", kv)
+	}
+	if v == "" {
+		return k, nil, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err == nil && !dec.More() {
+		return k, value, nil
+	}
+	return k, v, nil
+}
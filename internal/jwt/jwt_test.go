@@ -0,0 +1,177 @@
+package jwt
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// jwtIO is the example token from jwt.io, signed with HS256 and the secret
+// "your-256-bit-secret".
+const jwtIO = "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9." +
+	"eyJzdWIiOiIxMjM0NTY3ODkwIiwibmFtZSI6IkpvaG4gRG9lIiwiaWF0IjoxNTE2MjM5MDIyfQ." +
+	"SflKxwRJSMeKKF2QT4fwpMeJf36POk6yJV_adQssw5c"
+
+func TestParse(t *testing.T) {
+	tok, err := Parse(jwtIO)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if tok.Alg() != "HS256" || tok.Claims["name"] != "John Doe" || len(tok.Signature) != 32 {
+		t.Errorf("token = %+v", tok)
+	}
+	if iat, ok := tok.IssuedAt(); !ok || iat.Unix() != 1516239022 {
+		t.Errorf("iat = %v, %v", iat, ok)
+	}
+	if _, ok := tok.Expiry(); ok {
+		t.Error("token has no exp")
+	}
+
+	for _, bad := range []string{"", "a.b", "eyJhbGciOiJub25lIn0.bm90IGpzb24.", "e30.e30.!!"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q): expected error", bad)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	text := "Authorization: Bearer " + jwtIO + "\nset again " + jwtIO + " and eyJnot.eyJvalid."
+	if got := Find(text); len(got) != 1 || got[0] != jwtIO {
+		t.Errorf("Find = %q", got)
+	}
+
+	e := &storage.Entry{
+		Path:           "/api",
+		Query:          "token=" + jwtIO,
+		RequestHeaders: http.Header{"Cookie": {"session=" + jwtIO}},
+	}
+	if got := FindInRequest(e); len(got) != 1 {
+		t.Errorf("FindInRequest = %q", got)
+	}
+
+	e.ResponseHeaders = http.Header{"Content-Type": {"application/json"}}
+	e.ResponseBody = []byte(`{"access_token":"` + jwtIO + `"}`)
+	if got := FindInResponse(e); len(got) != 1 {
+		t.Errorf("FindInResponse = %q", got)
+	}
+	e.ResponseHeaders.Set("Content-Type", "image/png")
+	if got := FindInResponse(e); len(got) != 0 {
+		t.Errorf("FindInResponse in binary body = %q", got)
+	}
+}
+
+func TestForge(t *testing.T) {
+	tok, _ := Parse(jwtIO)
+
+	// Without changes the token is unchanged, byte for byte.
+	same, err := Forge(tok, ForgeOptions{})
+	if err != nil || same != jwtIO {
+		t.Errorf("Forge() = %q, %v", same, err)
+	}
+
+	forged, err := Forge(tok, ForgeOptions{
+		Claims: map[string]any{"role": "admin", "name": nil},
+		Secret: []byte("your-256-bit-secret"),
+	})
+	if err != nil {
+		t.Fatalf("Forge: %v", err)
+	}
+	got, err := Parse(forged)
+	if err != nil {
+		t.Fatalf("parsing forged token: %v", err)
+	}
+	if got.Claims["role"] != "admin" || got.Claims["name"] != nil || got.Claims["iat"] != json.Number("1516239022") {
+		t.Errorf("forged claims = %v", got.Claims)
+	}
+	if !Verify(got, []byte("your-256-bit-secret")) {
+		t.Error("forged token does not verify with the secret")
+	}
+	if !strings.HasPrefix(forged, jwtIO[:strings.Index(jwtIO, ".")+1]) {
+		t.Error("header re-encoded although unchanged")
+	}
+
+	none, err := Forge(tok, ForgeOptions{Alg: "none"})
+	if err != nil {
+		t.Fatalf("Forge none: %v", err)
+	}
+	got, _ = Parse(none)
+	if got.Alg() != "none" || len(got.Signature) != 0 || !strings.HasSuffix(none, ".") {
+		t.Errorf("alg none token = %q", none)
+	}
+
+	if _, err := Forge(tok, ForgeOptions{Alg: "RS256", Secret: []byte("k")}); err == nil {
+		t.Error("expected error signing RS256")
+	}
+}
+
+func TestParseClaim(t *testing.T) {
+	tests := []struct {
+		in    string
+		key   string
+		value any
+	}{
+		{"role=admin", "role", "admin"},
+		{"admin=true", "admin", true},
+		{`groups=["a"]`, "groups", []any{"a"}},
+		{"name=John Doe", "name", "John Doe"},
+		{"id=42", "id", json.Number("42")},
+		{"exp=", "exp", nil},
+	}
+	for _, tt := range tests {
+		k, v, err := ParseClaim(tt.in)
+		if err != nil || k != tt.key || !reflect.DeepEqual(v, tt.value) {
+			t.Errorf("ParseClaim(%q) = %q, %#v, %v", tt.in, k, v, err)
+		}
+	}
+	if _, _, err := ParseClaim("role"); err == nil {
+		t.Error("expected error without =")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tok, _ := Parse(jwtIO)
+	now := time.Unix(1516239022, 0)
+	if got := titles(Check(tok, now)); got != "HMAC-signed token,No expiry" {
+		t.Errorf("jwt.io token: %s", got)
+	}
+
+	forged, _ := Forge(tok, ForgeOptions{
+		Alg:    "none",
+		Header: map[string]any{"kid": "../keys/app.pem", "jku": "https://evil.example/jwks.json"},
+		Claims: map[string]any{"exp": 1516239022 + 7*24*3600},
+	})
+	tok, _ = Parse(forged)
+	got := titles(Check(tok, now.Add(30*24*time.Hour)))
+	want := "Unsigned token,Long-lived token,Expired token,kid injection candidate,jku header present"
+	if got != want {
+		t.Errorf("weak token:\n got %s\nwant %s", got, want)
+	}
+}
+
+func titles(ws []Weakness) string {
+	var out []string
+	for _, w := range ws {
+		out = append(out, w.Title)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestCrack(t *testing.T) {
+	tok, _ := Parse(jwtIO)
+	if secret, ok := Crack(tok, CommonSecrets); !ok || secret != "your-256-bit-secret" {
+		t.Errorf("Crack = %q, %v", secret, ok)
+	}
+	if _, ok := Crack(tok, []string{"nope"}); ok {
+		t.Error("cracked with the wrong secret")
+	}
+
+	words, err := ReadWordlist(strings.NewReader("a\r\nb\n\nc"))
+	if err != nil || strings.Join(words, "|") != "a|b||c" {
+		t.Errorf("ReadWordlist = %q, %v", words, err)
+	}
+}
@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
@@ -12,7 +13,7 @@ import (
 	"math/big"
 	"net"
 	"net/http"
-	"net/http/httputil"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -38,8 +39,9 @@ type Proxy struct {
 	Scope     *Scope
 	Store     storage.Store
 	CA        *CA
-	Transport http.RoundTripper // optional; defaults to http.DefaultTransport
-	OnEvent   func(Event)       // optional callback for live activity display
+	Transport http.RoundTripper    // optional; defaults to http.DefaultTransport
+	OnEvent   func(Event)          // optional callback for live activity display
+	OnSave    func(*storage.Entry) // optional; called after a proxied entry is stored
 
 	certCache sync.Map // host → *tls.Certificate
 }
@@ -57,6 +59,16 @@ func (p *Proxy) emit(e Event) {
 	}
 }
 
+// record stores an entry captured from proxied traffic.
+func (p *Proxy) record(entry *storage.Entry) {
+	if err := p.Store.Save(entry); err != nil {
+		return
+	}
+	if p.OnSave != nil {
+		p.OnSave(entry)
+	}
+}
+
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodConnect {
 		p.handleConnect(w, r)
@@ -165,6 +177,20 @@ func (p *Proxy) handleHTTP(w http.ResponseWriter, r *http.Request) {
 	r.RequestURI = ""
 	r.Header.Del("Accept-Encoding")
 
+	// Keep the body for the entry; the round trip consumes it.
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Forward an empty body with Content-Length: 0, as it arrived, rather
+	// than as chunked.
+	r.Body = http.NoBody
+	r.ContentLength = int64(len(reqBody))
+	if len(reqBody) > 0 {
+		r.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
 	resp, err := p.transport().RoundTrip(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
@@ -181,22 +207,22 @@ func (p *Proxy) handleHTTP(w http.ResponseWriter, r *http.Request) {
 	duration := time.Since(start).Milliseconds()
 
 	if inScope {
-		reqDump, _ := httputil.DumpRequest(r, true)
 		entry := &storage.Entry{
 			Method:          r.Method,
 			Scheme:          "http",
 			Host:            hostname,
+			Port:            urlPort(r.URL),
 			Path:            r.URL.Path,
 			Query:           r.URL.RawQuery,
 			RequestHeaders:  r.Header.Clone(),
-			RequestBody:     extractBody(reqDump),
+			RequestBody:     reqBody,
 			StatusCode:      resp.StatusCode,
 			ResponseHeaders: resp.Header.Clone(),
 			ResponseBody:    body,
 			Timestamp:       time.Now(),
 			DurationMs:      duration,
 		}
-		_ = p.Store.Save(entry)
+		p.record(entry)
 		p.emit(Event{
 			ID:          entry.ID,
 			Method:      r.Method,
@@ -262,6 +288,7 @@ func (p *Proxy) proxyAndLog(clientConn net.Conn, req *http.Request, scheme, host
 		Method:          req.Method,
 		Scheme:          scheme,
 		Host:            hostname,
+		Port:            urlPort(req.URL),
 		Path:            req.URL.Path,
 		Query:           req.URL.RawQuery,
 		RequestHeaders:  req.Header.Clone(),
@@ -272,7 +299,7 @@ func (p *Proxy) proxyAndLog(clientConn net.Conn, req *http.Request, scheme, host
 		Timestamp:       time.Now(),
 		DurationMs:      duration,
 	}
-	_ = p.Store.Save(entry)
+	p.record(entry)
 	p.emit(Event{
 		ID:          entry.ID,
 		Method:      entry.Method,
@@ -339,16 +366,12 @@ func (p *Proxy) getCertForHost(host string) (*tls.Certificate, error) {
 	return tlsCert, nil
 }
 
-func extractBody(dump []byte) []byte {
-	idx := strings.Index(string(dump), "\r\n\r\n")
-	if idx == -1 {
-		return nil
-	}
-	body := dump[idx+4:]
-	if len(body) == 0 {
-		return nil
+// urlPort returns the explicit or scheme-default port of u.
+func urlPort(u *url.URL) int {
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		return port
 	}
-	return body
+	return storage.DefaultPort(u.Scheme)
 }
 
 // StatusText returns standard status text — used by response formatting.
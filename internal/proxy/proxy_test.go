@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -22,8 +23,46 @@ func (s *nullStore) Get(id int64) (*storage.Entry, error)
 func (s *nullStore) List(l, o int) ([]*storage.Entry, error)                      { return nil, nil }
 func (s *nullStore) Search(p storage.SearchParams) ([]*storage.Entry, error)      { return nil, nil }
 func (s *nullStore) ListAfter(afterID int64, limit int) ([]*storage.Entry, error) { return nil, nil }
-func (s *nullStore) Clear() error                                                 { return nil }
-func (s *nullStore) Close() error                                                 { return nil }
+func (s *nullStore) Count(p storage.SearchParams) (int64, error)                  { return 0, nil }
+func (s *nullStore) AddTags(id int64, tags []string) error                        { return nil }
+func (s *nullStore) RemoveTags(id int64, tags []string) error                     { return nil }
+func (s *nullStore) SetNote(id int64, note string) error                          { return nil }
+func (s *nullStore) SetHighlight(id int64, color string) error                    { return nil }
+func (s *nullStore) Prune(f storage.SearchParams, p storage.RetentionPolicy) (int64, error) {
+	return 0, nil
+}
+func (s *nullStore) Endpoints(p storage.SearchParams) ([]storage.Endpoint, error) {
+	return nil, nil
+}
+func (s *nullStore) Params(p storage.SearchParams, endpoint string) ([]storage.ParamSummary, error) {
+	return nil, nil
+}
+func (s *nullStore) CreateFuzzRun(run *storage.FuzzRun) error    { return nil }
+func (s *nullStore) FinishFuzzRun(id int64, status string) error { return nil }
+func (s *nullStore) GetFuzzRun(id int64) (*storage.FuzzRun, error) {
+	return nil, fmt.Errorf("not found")
+}
+func (s *nullStore) ListFuzzRuns() ([]*storage.FuzzRun, error)              { return nil, nil }
+func (s *nullStore) SaveFuzzResult(r *storage.FuzzResult) error             { return nil }
+func (s *nullStore) FuzzResults(runID int64) ([]*storage.FuzzResult, error) { return nil, nil }
+func (s *nullStore) SaveIdentity(id *storage.Identity) error                { return nil }
+func (s *nullStore) DeleteIdentity(name string) error                       { return nil }
+func (s *nullStore) ListIdentities() ([]*storage.Identity, error)           { return nil, nil }
+func (s *nullStore) SaveAuthzResult(r *storage.AuthzResult) error           { return nil }
+func (s *nullStore) AuthzResults(p storage.SearchParams) ([]*storage.AuthzResult, error) {
+	return nil, nil
+}
+func (s *nullStore) SaveFinding(f *storage.Finding) error { return nil }
+func (s *nullStore) Findings(p storage.FindingParams) ([]*storage.Finding, error) {
+	return nil, nil
+}
+func (s *nullStore) AddFinding(f *storage.Finding) error               { return nil }
+func (s *nullStore) GetFinding(id int64) (*storage.Finding, error)     { return nil, nil }
+func (s *nullStore) SetFindingStatus(id int64, status string) error    { return nil }
+func (s *nullStore) SetFindingNote(id int64, note string) error        { return nil }
+func (s *nullStore) DeleteWhere(p storage.SearchParams) (int64, error) { return 0, nil }
+func (s *nullStore) Clear() error                                      { return nil }
+func (s *nullStore) Close() error                                      { return nil }
 
 func startTestProxy(t *testing.T, domains []string, transport http.RoundTripper) (*Proxy, net.Listener) {
 	t.Helper()
@@ -151,3 +190,63 @@ func TestProxyHTTP(t *testing.T) {
 
 	t.Logf("completed in %v", elapsed)
 }
+
+func TestProxyHTTPRequestBody(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	p, proxyLn := startTestProxy(t, []string{"127.0.0.1"}, nil)
+	saved := make(chan *storage.Entry, 1)
+	p.OnSave = func(e *storage.Entry) { saved <- e }
+
+	proxyURL, _ := url.Parse("http://" + proxyLn.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+	resp, err := client.Post(upstream.URL+"/echo", "text/plain", strings.NewReader("a=1&b=2"))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	echoed, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(echoed) != "a=1&b=2" {
+		t.Errorf("upstream got %q, want a=1&b=2", echoed)
+	}
+
+	select {
+	case e := <-saved:
+		if string(e.RequestBody) != "a=1&b=2" {
+			t.Errorf("saved request body = %q, want a=1&b=2", e.RequestBody)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("entry not saved")
+	}
+}
+
+func TestProxyHTTPEmptyBody(t *testing.T) {
+	type seen struct {
+		length   int64
+		encoding []string
+	}
+	got := make(chan seen, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- seen{r.ContentLength, r.TransferEncoding}
+	}))
+	defer upstream.Close()
+
+	_, proxyLn := startTestProxy(t, []string{"127.0.0.1"}, nil)
+	proxyURL, _ := url.Parse("http://" + proxyLn.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+
+	resp, err := client.Post(upstream.URL+"/empty", "text/plain", http.NoBody)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	s := <-got
+	if s.length != 0 || len(s.encoding) != 0 {
+		t.Errorf("upstream got Content-Length %d, Transfer-Encoding %v; want 0 and none", s.length, s.encoding)
+	}
+}
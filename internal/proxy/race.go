@@ -0,0 +1,300 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/exchange"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// Race protocols. RaceAuto uses HTTP/2 when a TLS server negotiates it and
+// HTTP/1.1 otherwise.
+const (
+	RaceAuto  = ""
+	RaceHTTP1 = "http1"
+	RaceHTTP2 = "http2"
+)
+
+// MaxRaceRequests bounds the requests in one race: one connection each over
+// HTTP/1.1, one stream each over HTTP/2.
+const MaxRaceRequests = 100
+
+// raceSettle is how long the partial requests are given to reach the server
+// before the final bytes are released.
+const raceSettle = 100 * time.Millisecond
+
+// RaceOptions configures a race.
+type RaceOptions struct {
+	Protocol string // RaceAuto, RaceHTTP1 or RaceHTTP2
+	Insecure bool   // skip TLS certificate verification
+}
+
+// RaceResult is the outcome of one request of a race. Entry is nil when the
+// request failed; Err says why.
+type RaceResult struct {
+	Entry *storage.Entry
+	Err   error
+}
+
+// RaceGroup is a set of race responses with the same status and body.
+type RaceGroup struct {
+	StatusCode int
+	Length     int
+	EntryIDs   []int64
+}
+
+// Race sends reqs to their host as close to simultaneously as possible and
+// stores each exchange as a new entry, carrying over the requests' ParentID
+// and RunID. All requests must go to the same host and port. It returns a
+// result per request, in order, and the protocol used.
+//
+// Over HTTP/1.1 each request gets its own connection, and everything but its
+// last byte is written up front; the last bytes are then written in one
+// tight loop. Over HTTP/2 all requests share a connection, and everything but
+// the final DATA frame of each stream is sent up front; the final frames then
+// go out in a single write, and so usually a single packet. Servers that act
+// on a request before it is complete see it early either way.
+func (p *Proxy) Race(reqs []*storage.Entry, opts RaceOptions) ([]RaceResult, string, error) {
+	if len(reqs) == 0 {
+		return nil, "", fmt.Errorf("no requests to race")
+	}
+	if len(reqs) > MaxRaceRequests {
+		return nil, "", fmt.Errorf("%d requests exceeds the limit of %d", len(reqs), MaxRaceRequests)
+	}
+	first := reqs[0]
+	for _, r := range reqs[1:] {
+		if r.Scheme != first.Scheme || r.Host != first.Host || r.EffectivePort() != first.EffectivePort() {
+			return nil, "", fmt.Errorf("all requests in a race must go to %s", first.Authority())
+		}
+	}
+	if !p.Scope.InScope(first.Host) {
+		return nil, "", fmt.Errorf("host %s is out of scope", first.Host)
+	}
+
+	proto := opts.Protocol
+	switch proto {
+	case RaceAuto, RaceHTTP1, RaceHTTP2:
+	default:
+		return nil, "", fmt.Errorf("unknown protocol %q: want %s or %s", proto, RaceHTTP1, RaceHTTP2)
+	}
+	secure := first.Scheme == "https"
+	deadline := time.Now().Add(replayTimeout)
+
+	entries := make([]*storage.Entry, len(reqs))
+	for i, r := range reqs {
+		entries[i] = raceEntry(r)
+	}
+
+	var errs []error
+	var err error
+	if proto == RaceHTTP2 || (proto == RaceAuto && secure) {
+		alpn := []string{"h2"}
+		if proto == RaceAuto {
+			alpn = append(alpn, "http/1.1")
+		}
+		conn, dialErr := dialRace(first, alpn, opts.Insecure, deadline)
+		if dialErr != nil {
+			return nil, "", dialErr
+		}
+		// Plain HTTP/2 is only used when asked for, with prior knowledge.
+		if tc, ok := conn.(*tls.Conn); !ok || tc.ConnectionState().NegotiatedProtocol == "h2" {
+			proto = RaceHTTP2
+			errs, err = raceHTTP2(conn, entries)
+		} else if proto == RaceHTTP2 {
+			err = fmt.Errorf("%s does not support HTTP/2", first.Authority())
+		}
+		conn.Close()
+	}
+	if proto != RaceHTTP2 {
+		proto = RaceHTTP1
+		errs, err = raceHTTP1(entries, opts.Insecure, deadline)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	results := make([]RaceResult, len(entries))
+	for i, entry := range entries {
+		if errs[i] != nil {
+			results[i].Err = errs[i]
+			continue
+		}
+		entry.Timestamp = time.Now()
+		if err := p.Store.Save(entry); err != nil {
+			results[i].Err = fmt.Errorf("saving entry: %w", err)
+			continue
+		}
+		p.emit(Event{
+			ID:          entry.ID,
+			Method:      entry.Method,
+			Scheme:      entry.Scheme,
+			Host:        entry.Host,
+			Path:        entry.Path,
+			StatusCode:  entry.StatusCode,
+			DurationMs:  entry.DurationMs,
+			Intercepted: true,
+		})
+		results[i].Entry = entry
+	}
+	return results, proto, nil
+}
+
+// GroupRaceResults groups the responses of a race by status and body,
+// largest group first. Failed requests are left out.
+func GroupRaceResults(results []RaceResult) []RaceGroup {
+	var groups []RaceGroup
+	var bodies [][]byte
+outer:
+	for _, r := range results {
+		if r.Entry == nil {
+			continue
+		}
+		for i, g := range groups {
+			if g.StatusCode == r.Entry.StatusCode && bytes.Equal(bodies[i], r.Entry.ResponseBody) {
+				groups[i].EntryIDs = append(groups[i].EntryIDs, r.Entry.ID)
+				continue outer
+			}
+		}
+		groups = append(groups, RaceGroup{
+			StatusCode: r.Entry.StatusCode,
+			Length:     len(r.Entry.ResponseBody),
+			EntryIDs:   []int64{r.Entry.ID},
+		})
+		bodies = append(bodies, r.Entry.ResponseBody)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].EntryIDs) > len(groups[j].EntryIDs)
+	})
+	return groups
+}
+
+// raceEntry copies the request half of req, dropping the headers that are
+// recomputed when it is sent, as Replay does.
+func raceEntry(req *storage.Entry) *storage.Entry {
+	headers := req.RequestHeaders.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	headers.Del("Host")
+	headers.Del("Content-Length")
+	headers.Del("Transfer-Encoding")
+	headers.Del("Accept-Encoding")
+
+	return &storage.Entry{
+		ParentID:        req.ParentID,
+		RunID:           req.RunID,
+		Method:          req.Method,
+		Scheme:          req.Scheme,
+		Host:            req.Host,
+		Port:            req.EffectivePort(),
+		Path:            req.Path,
+		Query:           req.Query,
+		RequestHeaders:  headers,
+		RequestBody:     req.RequestBody,
+		ResponseHeaders: http.Header{},
+	}
+}
+
+// dialRace connects to e's host, over TLS offering alpn for https.
+func dialRace(e *storage.Entry, alpn []string, insecure bool, deadline time.Time) (net.Conn, error) {
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(e.EffectivePort()))
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Deadline: deadline}
+
+	var conn net.Conn
+	var err error
+	if e.Scheme == "https" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+			ServerName:         e.Host,
+			NextProtos:         alpn,
+			InsecureSkipVerify: insecure, //nolint:gosec
+		})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	_ = conn.SetDeadline(deadline)
+	return conn, nil
+}
+
+// raceHTTP1 races entries over one HTTP/1.1 connection each, filling in
+// their responses. A failure to connect fails the race; later failures are
+// reported per request.
+func raceHTTP1(entries []*storage.Entry, insecure bool, deadline time.Time) ([]error, error) {
+	conns := make([]net.Conn, len(entries))
+	defer func() {
+		for _, c := range conns {
+			if c != nil {
+				c.Close()
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	dialErrs := make([]error, len(entries))
+	for i, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conns[i], dialErrs[i] = dialRace(e, []string{"http/1.1"}, insecure, deadline)
+		}()
+	}
+	wg.Wait()
+	for _, err := range dialErrs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	errs := make([]error, len(entries))
+	raws := make([][]byte, len(entries))
+	for i, e := range entries {
+		raws[i] = exchange.DumpRequest(e)
+		if _, err := conns[i].Write(raws[i][:len(raws[i])-1]); err != nil {
+			errs[i] = fmt.Errorf("writing request: %w", err)
+		}
+	}
+
+	time.Sleep(raceSettle)
+	release := time.Now()
+	for i, c := range conns {
+		if errs[i] == nil {
+			if _, err := c.Write(raws[i][len(raws[i])-1:]); err != nil {
+				errs[i] = fmt.Errorf("writing request: %w", err)
+			}
+		}
+	}
+
+	for i, e := range entries {
+		if errs[i] != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			raw, err := readRawResponse(conns[i], e.Method)
+			if err != nil && len(raw) == 0 {
+				errs[i] = fmt.Errorf("reading response: %w", err)
+				return
+			}
+			e.DurationMs = time.Since(release).Milliseconds()
+			if err := exchange.ParseResponse(raw, e); err != nil {
+				e.StatusCode = 0
+				e.ResponseHeaders = http.Header{}
+				e.ResponseBody = raw
+			}
+		}()
+	}
+	wg.Wait()
+	return errs, nil
+}
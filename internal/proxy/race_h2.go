@@ -0,0 +1,276 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+const (
+	// h2Window is the flow-control window granted to the server, large
+	// enough that responses never wait for a WINDOW_UPDATE.
+	h2Window = 1 << 30
+
+	// h2DefaultWindow is the window the server grants before any
+	// WINDOW_UPDATE; request bodies sent up front must fit in it.
+	h2DefaultWindow = 65535
+
+	// h2MaxFrame is the largest frame every server accepts.
+	h2MaxFrame = 16384
+)
+
+// h2Skip lists the connection-specific headers HTTP/2 forbids, and those
+// carried by pseudo-headers or recomputed.
+var h2Skip = map[string]bool{
+	"connection":        true,
+	"content-length":    true,
+	"host":              true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"te":                true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// raceHTTP2 races entries as streams of one HTTP/2 connection, filling in
+// their responses. A failure before the requests are released fails the
+// race; later failures are reported per request.
+func raceHTTP2(conn net.Conn, entries []*storage.Entry) ([]error, error) {
+	bw := bufio.NewWriterSize(conn, 64<<10)
+	fr := http2.NewFramer(bw, bufio.NewReader(conn))
+	fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+
+	bodies := 0
+	for _, e := range entries {
+		bodies += len(e.RequestBody)
+	}
+	if bodies > h2DefaultWindow {
+		return nil, fmt.Errorf("request bodies total %d bytes, more than HTTP/2 allows before the server responds; race over HTTP/1.1 instead", bodies)
+	}
+
+	if _, err := bw.WriteString(http2.ClientPreface); err != nil {
+		return nil, fmt.Errorf("writing preface: %w", err)
+	}
+	_ = fr.WriteSettings(
+		http2.Setting{ID: http2.SettingEnablePush, Val: 0},
+		http2.Setting{ID: http2.SettingInitialWindowSize, Val: h2Window},
+	)
+	_ = fr.WriteWindowUpdate(0, h2Window-h2DefaultWindow)
+	if err := bw.Flush(); err != nil {
+		return nil, fmt.Errorf("writing settings: %w", err)
+	}
+
+	// The server's settings come first and bound the streams we may open.
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			return nil, fmt.Errorf("server did not start HTTP/2: %w", err)
+		}
+		sf, ok := f.(*http2.SettingsFrame)
+		if !ok || sf.IsAck() {
+			continue
+		}
+		if n, ok := sf.Value(http2.SettingMaxConcurrentStreams); ok && int(n) < len(entries) {
+			return nil, fmt.Errorf("server allows %d concurrent streams, fewer than %d requests", n, len(entries))
+		}
+		break
+	}
+	_ = fr.WriteSettingsAck()
+
+	// Send all of each request but the last byte of its body, leaving the
+	// streams open.
+	var block bytes.Buffer
+	enc := hpack.NewEncoder(&block)
+	for i, e := range entries {
+		block.Reset()
+		for _, hf := range h2Fields(e) {
+			_ = enc.WriteField(hf)
+		}
+		id := h2StreamID(i)
+		if err := writeHeaderBlock(fr, id, block.Bytes()); err != nil {
+			return nil, fmt.Errorf("writing headers: %w", err)
+		}
+		if n := len(e.RequestBody); n > 1 {
+			if err := writeData(fr, id, e.RequestBody[:n-1]); err != nil {
+				return nil, fmt.Errorf("writing body: %w", err)
+			}
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, fmt.Errorf("writing requests: %w", err)
+	}
+
+	time.Sleep(raceSettle)
+
+	// Close every stream in one write.
+	for i, e := range entries {
+		var last []byte
+		if n := len(e.RequestBody); n > 0 {
+			last = e.RequestBody[n-1:]
+		}
+		_ = fr.WriteData(h2StreamID(i), true, last)
+	}
+	release := time.Now()
+	if err := bw.Flush(); err != nil {
+		return nil, fmt.Errorf("releasing requests: %w", err)
+	}
+
+	errs := make([]error, len(entries))
+	done := make([]bool, len(entries))
+	pending := len(entries)
+	finish := func(i int, err error) {
+		if !done[i] {
+			done[i] = true
+			errs[i] = err
+			entries[i].DurationMs = time.Since(release).Milliseconds()
+			pending--
+		}
+	}
+	stream := func(id uint32) (int, bool) {
+		i := int(id-1) / 2
+		return i, id%2 == 1 && i < len(entries) && !done[i]
+	}
+
+	for pending > 0 {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			for i := range entries {
+				finish(i, fmt.Errorf("reading response: %w", err))
+			}
+			break
+		}
+
+		switch f := f.(type) {
+		case *http2.MetaHeadersFrame:
+			i, ok := stream(f.StreamID)
+			if !ok {
+				continue
+			}
+			e := entries[i]
+			if e.StatusCode == 0 {
+				status, _ := strconv.Atoi(f.PseudoValue("status"))
+				if status < 200 {
+					continue // informational
+				}
+				e.StatusCode = status
+			}
+			// Later header blocks are trailers; keep them with the headers.
+			for _, hf := range f.RegularFields() {
+				e.ResponseHeaders.Add(http.CanonicalHeaderKey(hf.Name), hf.Value)
+			}
+			if f.StreamEnded() {
+				finish(i, nil)
+			}
+		case *http2.DataFrame:
+			i, ok := stream(f.StreamID)
+			if !ok {
+				continue
+			}
+			entries[i].ResponseBody = append(entries[i].ResponseBody, f.Data()...)
+			if f.StreamEnded() {
+				finish(i, nil)
+			}
+		case *http2.RSTStreamFrame:
+			if i, ok := stream(f.StreamID); ok {
+				finish(i, fmt.Errorf("stream reset by server: %v", f.ErrCode))
+			}
+		case *http2.GoAwayFrame:
+			for i := range entries {
+				if h2StreamID(i) > f.LastStreamID {
+					finish(i, fmt.Errorf("refused by server: %v", f.ErrCode))
+				}
+			}
+		case *http2.SettingsFrame:
+			if !f.IsAck() {
+				_ = fr.WriteSettingsAck()
+				_ = bw.Flush()
+			}
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				_ = fr.WritePing(true, f.Data)
+				_ = bw.Flush()
+			}
+		}
+	}
+	return errs, nil
+}
+
+func h2StreamID(i int) uint32 {
+	return uint32(2*i + 1)
+}
+
+// h2Fields returns the header fields of e's request: the pseudo-headers,
+// then the regular headers in sorted order.
+func h2Fields(e *storage.Entry) []hpack.HeaderField {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	if e.Query != "" {
+		path += "?" + e.Query
+	}
+	fields := []hpack.HeaderField{
+		{Name: ":method", Value: e.Method},
+		{Name: ":scheme", Value: e.Scheme},
+		{Name: ":authority", Value: e.Authority()},
+		{Name: ":path", Value: path},
+	}
+
+	keys := make([]string, 0, len(e.RequestHeaders))
+	for k := range e.RequestHeaders {
+		if !h2Skip[strings.ToLower(k)] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range e.RequestHeaders[k] {
+			fields = append(fields, hpack.HeaderField{Name: strings.ToLower(k), Value: v})
+		}
+	}
+	if len(e.RequestBody) > 0 {
+		fields = append(fields, hpack.HeaderField{Name: "content-length", Value: strconv.Itoa(len(e.RequestBody))})
+	}
+	return fields
+}
+
+// writeHeaderBlock writes a HEADERS frame, followed by CONTINUATION frames if
+// the block does not fit in one.
+func writeHeaderBlock(fr *http2.Framer, id uint32, block []byte) error {
+	chunk := block[:min(len(block), h2MaxFrame)]
+	block = block[len(chunk):]
+	err := fr.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      id,
+		BlockFragment: chunk,
+		EndHeaders:    len(block) == 0,
+	})
+	for err == nil && len(block) > 0 {
+		chunk = block[:min(len(block), h2MaxFrame)]
+		block = block[len(chunk):]
+		err = fr.WriteContinuation(id, len(block) == 0, chunk)
+	}
+	return err
+}
+
+// writeData writes data as DATA frames without ending the stream.
+func writeData(fr *http2.Framer, id uint32, data []byte) error {
+	for len(data) > 0 {
+		chunk := data[:min(len(data), h2MaxFrame)]
+		data = data[len(chunk):]
+		if err := fr.WriteData(id, false, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
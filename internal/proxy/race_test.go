@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// couponServer redeems a coupon once and refuses later attempts, recording
+// the protocol and body of each request.
+type couponServer struct {
+	mu       sync.Mutex
+	redeemed bool
+	protos   []int
+	bodies   []string
+}
+
+func (s *couponServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.protos = append(s.protos, r.ProtoMajor)
+	s.bodies = append(s.bodies, string(body))
+	if s.redeemed {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("already redeemed"))
+		return
+	}
+	s.redeemed = true
+	w.Write([]byte("redeemed"))
+}
+
+func raceRequests(t *testing.T, serverURL string, n int) []*storage.Entry {
+	t.Helper()
+	u, _ := url.Parse(serverURL)
+	port, _ := strconv.Atoi(u.Port())
+
+	reqs := make([]*storage.Entry, n)
+	for i := range reqs {
+		reqs[i] = &storage.Entry{
+			ParentID:       7,
+			RunID:          3,
+			Method:         "POST",
+			Scheme:         u.Scheme,
+			Host:           "127.0.0.1",
+			Port:           port,
+			Path:           "/redeem",
+			RequestHeaders: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			RequestBody:    []byte("code=SAVE10&n=" + strconv.Itoa(i)),
+		}
+	}
+	return reqs
+}
+
+func checkRace(t *testing.T, srv *couponServer, results []RaceResult, wantProto int) {
+	t.Helper()
+	if len(srv.bodies) != len(results) {
+		t.Fatalf("server received %d requests, want %d", len(srv.bodies), len(results))
+	}
+	for _, p := range srv.protos {
+		if p != wantProto {
+			t.Errorf("request over HTTP/%d, want HTTP/%d", p, wantProto)
+		}
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Fatalf("request %d: %v", i, r.Err)
+		}
+		e := r.Entry
+		if e.ID == 0 || e.ParentID != 7 || e.RunID != 3 {
+			t.Errorf("request %d: entry %d, parent %d, run %d not stored with links", i, e.ID, e.ParentID, e.RunID)
+		}
+		if want := "code=SAVE10&n=" + strconv.Itoa(i); string(e.RequestBody) != want {
+			t.Errorf("request %d: body %q, want %q", i, e.RequestBody, want)
+		}
+	}
+
+	groups := GroupRaceResults(results)
+	if len(groups) != 2 {
+		t.Fatalf("groups = %+v, want 2", groups)
+	}
+	if g := groups[0]; g.StatusCode != http.StatusConflict || len(g.EntryIDs) != len(results)-1 {
+		t.Errorf("largest group = %+v, want %d conflicts", g, len(results)-1)
+	}
+	if g := groups[1]; g.StatusCode != http.StatusOK || g.Length != len("redeemed") || len(g.EntryIDs) != 1 {
+		t.Errorf("second group = %+v, want one redemption", g)
+	}
+}
+
+func TestRaceHTTP1(t *testing.T) {
+	srv := &couponServer{}
+	upstream := httptest.NewServer(srv)
+	defer upstream.Close()
+
+	p := &Proxy{Scope: NewScope([]string{"127.0.0.1"}, nil), Store: &recordingStore{}}
+	results, proto, err := p.Race(raceRequests(t, upstream.URL, 10), RaceOptions{})
+	if err != nil {
+		t.Fatalf("racing: %v", err)
+	}
+	if proto != RaceHTTP1 {
+		t.Errorf("protocol = %s, want %s", proto, RaceHTTP1)
+	}
+	checkRace(t, srv, results, 1)
+}
+
+func TestRaceHTTP2(t *testing.T) {
+	srv := &couponServer{}
+	upstream := httptest.NewUnstartedServer(srv)
+	upstream.EnableHTTP2 = true
+	upstream.StartTLS()
+	defer upstream.Close()
+
+	p := &Proxy{Scope: NewScope([]string{"127.0.0.1"}, nil), Store: &recordingStore{}}
+	results, proto, err := p.Race(raceRequests(t, upstream.URL, 10), RaceOptions{Insecure: true})
+	if err != nil {
+		t.Fatalf("racing: %v", err)
+	}
+	if proto != RaceHTTP2 {
+		t.Errorf("protocol = %s, want %s", proto, RaceHTTP2)
+	}
+	checkRace(t, srv, results, 2)
+}
+
+func TestRaceHTTP2Unsupported(t *testing.T) {
+	upstream := httptest.NewTLSServer(&couponServer{})
+	defer upstream.Close()
+
+	p := &Proxy{Scope: NewScope([]string{"127.0.0.1"}, nil), Store: &recordingStore{}}
+	reqs := raceRequests(t, upstream.URL, 2)
+	if _, _, err := p.Race(reqs, RaceOptions{Protocol: RaceHTTP2, Insecure: true}); err == nil {
+		t.Error("expected error racing over HTTP/2 with an HTTP/1.1-only server")
+	}
+
+	// Auto falls back to HTTP/1.1.
+	_, proto, err := p.Race(reqs, RaceOptions{Insecure: true})
+	if err != nil || proto != RaceHTTP1 {
+		t.Errorf("auto race: protocol %q, err %v; want %s", proto, err, RaceHTTP1)
+	}
+}
+
+func TestRaceMixedHosts(t *testing.T) {
+	p := &Proxy{Scope: NewScope([]string{"example.com"}, nil), Store: &recordingStore{}}
+	reqs := []*storage.Entry{
+		{Method: "GET", Scheme: "https", Host: "a.example.com", Path: "/"},
+		{Method: "GET", Scheme: "https", Host: "b.example.com", Path: "/"},
+	}
+	if _, _, err := p.Race(reqs, RaceOptions{}); err == nil {
+		t.Error("expected error racing requests to different hosts")
+	}
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// replayTimeout bounds a replayed exchange. It is shorter than the IPC client
+// deadline so that a slow upstream surfaces as an error rather than a
+// dropped connection.
+const replayTimeout = 25 * time.Second
+
+// Replay sends the request described by req through the proxy transport and
+// stores the exchange as a new entry, carrying over req's ParentID and RunID.
+// Requests to out-of-scope hosts are refused.
+func (p *Proxy) Replay(req *storage.Entry) (*storage.Entry, error) {
+	return p.ReplayContext(context.Background(), req)
+}
+
+// ReplayContext is like Replay, but gives up on the exchange when ctx is
+// cancelled.
+func (p *Proxy) ReplayContext(ctx context.Context, req *storage.Entry) (*storage.Entry, error) {
+	if !p.Scope.InScope(req.Host) {
+		return nil, fmt.Errorf("host %s is out of scope", req.Host)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, replayTimeout)
+	defer cancel()
+
+	upstreamReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL(), bytes.NewReader(req.RequestBody))
+	if err != nil {
+		return nil, fmt.Errorf("building request: %w", err)
+	}
+	upstreamReq.Header = req.RequestHeaders.Clone()
+	if upstreamReq.Header == nil {
+		upstreamReq.Header = http.Header{}
+	}
+	// A Host header, as set with --header, overrides the Host sent to the
+	// upstream, which is still the one req.Host names.
+	if host := upstreamReq.Header.Get("Host"); host != "" {
+		upstreamReq.Host = host
+	}
+	// Framing headers are recomputed from the body; the stored body is
+	// already decoded, so ask for an unencoded response as the proxy does.
+	upstreamReq.Header.Del("Content-Length")
+	upstreamReq.Header.Del("Transfer-Encoding")
+	upstreamReq.Header.Del("Accept-Encoding")
+
+	start := time.Now()
+	resp, err := p.transport().RoundTrip(upstreamReq)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	duration := time.Since(start).Milliseconds()
+
+	entry := &storage.Entry{
+		ParentID:        req.ParentID,
+		RunID:           req.RunID,
+		Method:          req.Method,
+		Scheme:          req.Scheme,
+		Host:            req.Host,
+		Port:            req.EffectivePort(),
+		Path:            req.Path,
+		Query:           req.Query,
+		RequestHeaders:  upstreamReq.Header.Clone(),
+		RequestBody:     req.RequestBody,
+		StatusCode:      resp.StatusCode,
+		ResponseHeaders: resp.Header.Clone(),
+		ResponseBody:    respBody,
+		Timestamp:       time.Now(),
+		DurationMs:      duration,
+	}
+	if err := p.Store.Save(entry); err != nil {
+		return nil, fmt.Errorf("saving entry: %w", err)
+	}
+	p.emit(Event{
+		ID:          entry.ID,
+		Method:      entry.Method,
+		Scheme:      entry.Scheme,
+		Host:        entry.Host,
+		Path:        entry.Path,
+		StatusCode:  entry.StatusCode,
+		DurationMs:  duration,
+		Intercepted: true,
+	})
+
+	return entry, nil
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// recordingStore keeps saved entries in memory.
+type recordingStore struct {
+	nullStore
+	saved []*storage.Entry
+}
+
+func (s *recordingStore) Save(e *storage.Entry) error {
+	e.ID = int64(len(s.saved) + 1)
+	s.saved = append(s.saved, e)
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		w.Header().Set("X-Length", strconv.FormatInt(r.ContentLength, 10))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	store := &recordingStore{}
+	p := &Proxy{Scope: NewScope([]string{"127.0.0.1"}, nil), Store: store}
+
+	req := &storage.Entry{
+		ParentID: 41,
+		Method:   "PUT",
+		Scheme:   "http",
+		Host:     "127.0.0.1",
+		Port:     port,
+		Path:     "/items/7",
+		RequestHeaders: http.Header{
+			"X-Token":        []string{"abc"},
+			"Content-Length": []string{"999"},
+		},
+		RequestBody: []byte(`{"name":"new"}`),
+	}
+
+	e, err := p.Replay(req)
+	if err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+
+	if e.ParentID != 41 {
+		t.Errorf("ParentID = %d, want 41", e.ParentID)
+	}
+	if len(store.saved) != 1 || store.saved[0] != e {
+		t.Fatalf("saved %d entries, want the replayed entry", len(store.saved))
+	}
+	if e.StatusCode != http.StatusCreated {
+		t.Errorf("status = %d, want 201", e.StatusCode)
+	}
+	if got := e.ResponseHeaders.Get("X-Method"); got != "PUT" {
+		t.Errorf("upstream method = %q, want PUT", got)
+	}
+	if got := e.ResponseHeaders.Get("X-Token"); got != "abc" {
+		t.Errorf("upstream X-Token = %q, want abc", got)
+	}
+	if got := e.ResponseHeaders.Get("X-Length"); got != "14" {
+		t.Errorf("upstream Content-Length = %s, want 14", got)
+	}
+	if string(e.ResponseBody) != `{"name":"new"}` {
+		t.Errorf("response body = %q", e.ResponseBody)
+	}
+}
+
+func TestReplayHostOverride(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Host", r.Host)
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	p := &Proxy{Scope: NewScope([]string{"127.0.0.1"}, nil), Store: &recordingStore{}}
+
+	e, err := p.Replay(&storage.Entry{
+		Method: "GET", Scheme: "http", Host: "127.0.0.1", Port: port, Path: "/",
+		RequestHeaders: http.Header{"Host": {"admin.internal"}},
+	})
+	if err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+	if got := e.ResponseHeaders.Get("X-Host"); got != "admin.internal" {
+		t.Errorf("upstream Host = %q, want admin.internal", got)
+	}
+	if e.Host != "127.0.0.1" || e.RequestHeaders.Get("Host") != "admin.internal" {
+		t.Errorf("entry host %q with Host header %q, want 127.0.0.1 with admin.internal", e.Host, e.RequestHeaders.Get("Host"))
+	}
+}
+
+func TestReplayContextCancelled(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	u, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(u.Port())
+	store := &recordingStore{}
+	p := &Proxy{Scope: NewScope([]string{"127.0.0.1"}, nil), Store: store}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := p.ReplayContext(ctx, &storage.Entry{Method: "GET", Scheme: "http", Host: "127.0.0.1", Port: port, Path: "/slow"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("replay took %v after cancelling", elapsed)
+	}
+	if len(store.saved) != 0 {
+		t.Errorf("saved %d entries for a cancelled replay", len(store.saved))
+	}
+}
+
+func TestReplayOutOfScope(t *testing.T) {
+	store := &recordingStore{}
+	p := &Proxy{Scope: NewScope([]string{"example.com"}, nil), Store: store}
+
+	_, err := p.Replay(&storage.Entry{Method: "GET", Scheme: "https", Host: "evil.test", Path: "/"})
+	if err == nil {
+		t.Fatal("expected out-of-scope replay to be refused")
+	}
+	if len(store.saved) != 0 {
+		t.Errorf("saved %d entries, want 0", len(store.saved))
+	}
+}
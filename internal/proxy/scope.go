@@ -15,6 +15,12 @@ func NewScope(domains, hosts []string) *Scope {
 	}
 }
 
+// Domains returns the domains in scope, matched with their subdomains.
+func (s *Scope) Domains() []string { return s.domains }
+
+// Hosts returns the hosts in scope, matched exactly.
+func (s *Scope) Hosts() []string { return s.hosts }
+
 func (s *Scope) InScope(host string) bool {
 	// Strip port if present
 	if idx := strings.LastIndex(host, ":"); idx != -1 {
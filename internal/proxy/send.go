@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghostsecurity/reaper/internal/exchange"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// RawTarget is the connection a raw request is written to.
+type RawTarget struct {
+	Host     string
+	Port     int
+	TLS      bool
+	Insecure bool // skip TLS certificate verification
+}
+
+// SendRaw writes raw to the target exactly as given, reads one response and
+// stores the exchange as a new entry. It returns the entry and the response
+// bytes as received. Requests to out-of-scope hosts are refused.
+//
+// The stored entry is a raw entry: RawRequest and RawResponse hold the bytes
+// as sent and received, so a trailing second request, duplicate or
+// malformed headers and line endings are all kept. Its other fields are
+// parsed leniently from them; if the request cannot be parsed, its first
+// line gives the method and path, and if the response cannot be parsed, the
+// status is 0.
+func (p *Proxy) SendRaw(target RawTarget, raw []byte) (*storage.Entry, []byte, error) {
+	if !p.Scope.InScope(target.Host) {
+		return nil, nil, fmt.Errorf("host %s is out of scope", target.Host)
+	}
+
+	addr := net.JoinHostPort(target.Host, strconv.Itoa(target.Port))
+	start := time.Now()
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if target.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+			ServerName:         target.Host,
+			NextProtos:         []string{"http/1.1"},
+			InsecureSkipVerify: target.Insecure, //nolint:gosec
+		})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(replayTimeout))
+
+	if _, err := conn.Write(raw); err != nil {
+		return nil, nil, fmt.Errorf("writing request: %w", err)
+	}
+
+	rawResp, err := readRawResponse(conn, requestMethod(raw))
+	if err != nil && len(rawResp) == 0 {
+		return nil, nil, fmt.Errorf("reading response: %w", err)
+	}
+	duration := time.Since(start).Milliseconds()
+
+	scheme := "http"
+	if target.TLS {
+		scheme = "https"
+	}
+	entry := rawEntry(raw, scheme)
+	entry.Host = target.Host
+	entry.Port = target.Port
+	if err := exchange.ParseResponse(rawResp, entry); err != nil {
+		entry.StatusCode = 0
+		entry.ResponseHeaders = http.Header{}
+	}
+	entry.RawRequest = raw
+	entry.RawResponse = rawResp
+	entry.Timestamp = time.Now()
+	entry.DurationMs = duration
+
+	if err := p.Store.Save(entry); err != nil {
+		return nil, nil, fmt.Errorf("saving entry: %w", err)
+	}
+	p.emit(Event{
+		ID:          entry.ID,
+		Method:      entry.Method,
+		Scheme:      entry.Scheme,
+		Host:        entry.Host,
+		Path:        entry.Path,
+		StatusCode:  entry.StatusCode,
+		DurationMs:  duration,
+		Intercepted: true,
+	})
+
+	return entry, rawResp, nil
+}
+
+// readRawResponse reads one HTTP response from r and returns its bytes as
+// received. If the response cannot be framed, everything read until the
+// connection closes or times out is returned along with the error.
+func readRawResponse(r io.Reader, method string) ([]byte, error) {
+	var captured bytes.Buffer
+	br := bufio.NewReader(io.TeeReader(r, &captured))
+
+	resp, err := http.ReadResponse(br, &http.Request{Method: method})
+	if err != nil {
+		_, _ = io.Copy(io.Discard, br)
+		return captured.Bytes(), err
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	// The bufio.Reader may have read past the end of the response, e.g. the
+	// start of a second response to a smuggled request; keep those bytes.
+	return captured.Bytes(), err
+}
+
+// requestMethod returns the method token of a raw request, defaulting to GET.
+func requestMethod(raw []byte) string {
+	line, _, _ := bytes.Cut(raw, []byte("\n"))
+	if fields := strings.Fields(string(line)); len(fields) > 0 {
+		return fields[0]
+	}
+	return http.MethodGet
+}
+
+// rawEntry parses the request half of an entry from raw, falling back to
+// the request line alone when the message cannot be parsed.
+func rawEntry(raw []byte, scheme string) *storage.Entry {
+	if e, err := exchange.ParseRequest(raw, scheme); err == nil {
+		e.Scheme = scheme
+		return e
+	}
+
+	e := &storage.Entry{
+		Method:          requestMethod(raw),
+		Scheme:          scheme,
+		Path:            "/",
+		RequestHeaders:  http.Header{},
+		ResponseHeaders: http.Header{},
+	}
+	line, _, _ := bytes.Cut(raw, []byte("\n"))
+	if fields := strings.Fields(string(line)); len(fields) > 1 {
+		path, query, _ := strings.Cut(fields[1], "?")
+		e.Path, e.Query = path, query
+	}
+	return e
+}
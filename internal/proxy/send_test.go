@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ghostsecurity/reaper/internal/authz"
+	"github.com/ghostsecurity/reaper/internal/diff"
+	"github.com/ghostsecurity/reaper/internal/exchange"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+func TestSendRaw(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// A malformed request: bare LF, odd header case and a conflicting
+	// Transfer-Encoding that an HTTP client would normalize away.
+	raw := []byte("POST /submit HTTP/1.1\nHOST: 127.0.0.1\r\nContent-Length: 4\r\nTransfer-Encoding : chunked\r\n\r\n0\r\n\r\n")
+	response := "HTTP/1.1 400 Bad Request\r\nContent-Length: 3\r\nConnection: close\r\n\r\nbad"
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, len(raw))
+		_, _ = io.ReadFull(bufio.NewReader(conn), buf)
+		received <- buf
+		_, _ = conn.Write([]byte(response))
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	store := &recordingStore{}
+	p := &Proxy{Scope: NewScope([]string{"127.0.0.1"}, nil), Store: store}
+
+	e, rawResp, err := p.SendRaw(RawTarget{Host: "127.0.0.1", Port: port}, raw)
+	if err != nil {
+		t.Fatalf("sending: %v", err)
+	}
+
+	if got := <-received; !bytes.Equal(got, raw) {
+		t.Errorf("upstream received %q, want %q", got, raw)
+	}
+	if string(rawResp) != response {
+		t.Errorf("raw response = %q, want %q", rawResp, response)
+	}
+	if len(store.saved) != 1 {
+		t.Fatalf("saved %d entries, want 1", len(store.saved))
+	}
+	if e.Method != "POST" || e.Path != "/submit" || e.Scheme != "http" {
+		t.Errorf("entry = %s %s %s, want POST http /submit", e.Method, e.Scheme, e.Path)
+	}
+	if e.Port != port {
+		t.Errorf("port = %d, want %d", e.Port, port)
+	}
+	if e.StatusCode != 400 || e.ResponseHeaders.Get("Content-Length") != "3" {
+		t.Errorf("response = %d %v, want 400 with Content-Length 3", e.StatusCode, e.ResponseHeaders)
+	}
+	// The entry keeps what went over the wire beside the parsed messages.
+	if !bytes.Equal(e.RawRequest, raw) || string(e.RawResponse) != response {
+		t.Errorf("stored raw request %q, response %q; want them verbatim", e.RawRequest, e.RawResponse)
+	}
+	if string(e.ResponseBody) != "bad" || e.RequestHeaders.Get("Host") != "" {
+		t.Errorf("parsed response body %q, request headers %v", e.ResponseBody, e.RequestHeaders)
+	}
+}
+
+func TestSendRawOutOfScope(t *testing.T) {
+	p := &Proxy{Scope: NewScope([]string{"example.com"}, nil), Store: &recordingStore{}}
+
+	if _, _, err := p.SendRaw(RawTarget{Host: "evil.test", Port: 80}, []byte("GET / HTTP/1.1\r\n\r\n")); err == nil {
+		t.Fatal("expected out-of-scope send to be refused")
+	}
+}
+
+// TestSendRawEntryFields checks that a sent entry works with the commands
+// reading the parsed fields: params, authz and diff.
+func TestSendRawEntryFields(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for range 2 {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			req, err := http.ReadRequest(bufio.NewReader(conn))
+			if err == nil {
+				body := `{"user":"alice","admin":false}`
+				if req.Header.Get("Authorization") == "" {
+					body = `{"user":"bob","admin":false}`
+				}
+				fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", len(body), body)
+			}
+			conn.Close()
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	p := &Proxy{Scope: NewScope([]string{"127.0.0.1"}, nil), Store: &recordingStore{}}
+	send := func(raw string) *storage.Entry {
+		t.Helper()
+		e, _, err := p.SendRaw(RawTarget{Host: "127.0.0.1", Port: port}, []byte(raw))
+		if err != nil {
+			t.Fatalf("sending: %v", err)
+		}
+		return e
+	}
+	body := `{"id":7}`
+	e := send("POST /profile?fields=name HTTP/1.1\r\nHost: 127.0.0.1\r\nAuthorization: Bearer alice\r\nContent-Type: application/json\r\n" +
+		"Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body)
+
+	var params []string
+	for _, prm := range storage.ExtractParams(e) {
+		params = append(params, prm.Location+":"+prm.Name)
+	}
+	if strings.Join(params, ",") != "query:fields,json:id,header:Authorization" {
+		t.Errorf("params = %v", params)
+	}
+
+	req := authz.Apply(&storage.Identity{Remove: []string{"Authorization"}}, e)
+	if req.RequestHeaders.Get("Authorization") != "" || string(req.RequestBody) != body || req.RawRequest != nil {
+		t.Errorf("authz request: headers %v, body %q", req.RequestHeaders, req.RequestBody)
+	}
+	if dump := string(exchange.DumpRequest(req)); strings.Contains(dump, "Authorization") || !strings.HasSuffix(dump, body) {
+		t.Errorf("authz request dumped as %q", dump)
+	}
+
+	other := send("GET /profile HTTP/1.1\r\nHost: 127.0.0.1\r\n\r\n")
+	r := diff.Compare(e.ResponseHeaders, other.ResponseHeaders, e.ResponseBody, other.ResponseBody, diff.Options{})
+	if !r.JSON || len(r.Fields) != 1 || r.Fields[0].Path != "user" {
+		t.Errorf("diff of the responses: %+v", r)
+	}
+}
@@ -0,0 +1,122 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is one step of a JSON path: a field of an object, or elements of an
+// array.
+type step struct {
+	name      string // field name, or "*" for every field
+	array     bool   // an array step: index selects the element
+	index     int    // element index, or -1 for every element
+	recursive bool   // the field at any depth below, as in $..name
+}
+
+// parsePath parses the subset of JSONPath that selects fields: $.a.b,
+// $.a[0], $.a[*].b, $['a b'], $.* and $..name.
+func parsePath(path string) ([]step, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(path), "$")
+	if !ok {
+		return nil, fmt.Errorf("JSON path %q does not start with $", path)
+	}
+
+	var steps []step
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			name, r := pathName(rest[2:])
+			if name == "" {
+				return nil, fmt.Errorf("JSON path %q: missing field name after ..", path)
+			}
+			steps = append(steps, step{name: name, recursive: true})
+			rest = r
+		case rest[0] == '.':
+			name, r := pathName(rest[1:])
+			if name == "" {
+				return nil, fmt.Errorf("JSON path %q: missing field name after .", path)
+			}
+			steps = append(steps, step{name: name})
+			rest = r
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSON path %q: unclosed [", path)
+			}
+			sel := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case sel == "*":
+				steps = append(steps, step{array: true, index: -1})
+			case len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0]:
+				steps = append(steps, step{name: sel[1 : len(sel)-1]})
+			default:
+				i, err := strconv.Atoi(sel)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf("JSON path %q: bad index [%s]", path, sel)
+				}
+				steps = append(steps, step{array: true, index: i})
+			}
+		default:
+			return nil, fmt.Errorf("JSON path %q: unexpected %q", path, rest[:1])
+		}
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("JSON path selects the whole document")
+	}
+	return steps, nil
+}
+
+// pathName splits a field name off the start of s.
+func pathName(s string) (name, rest string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], s[end:]
+}
+
+// walk calls f on each value path selects in node, replacing the value with
+// what f returns, or removing it if f returns false: a field is deleted and
+// an array element is set to null. It reports whether anything matched.
+func walk(node any, path []step, f func(any) (any, bool)) bool {
+	s, rest := path[0], path[1:]
+	changed := false
+	visit := func(v any, set func(any), remove func()) {
+		if len(rest) > 0 {
+			changed = walk(v, rest, f) || changed
+			return
+		}
+		if nv, keep := f(v); keep {
+			set(nv)
+		} else {
+			remove()
+		}
+		changed = true
+	}
+
+	switch n := node.(type) {
+	case map[string]any:
+		for k, v := range n {
+			if s.recursive {
+				changed = walk(v, path, f) || changed
+			}
+			if !s.array && (s.name == "*" || s.name == k) {
+				visit(v, func(nv any) { n[k] = nv }, func() { delete(n, k) })
+			}
+		}
+	case []any:
+		for i, v := range n {
+			if s.recursive {
+				changed = walk(v, path, f) || changed
+			}
+			if s.array && (s.index < 0 || s.index == i) {
+				visit(v, func(nv any) { n[i] = nv }, func() { n[i] = nil })
+			}
+		}
+	}
+	return changed
+}
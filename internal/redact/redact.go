@@ -0,0 +1,437 @@
+// Package redact removes sensitive data from entries before they are
+// stored: credentials in headers, fields of JSON bodies and values matching
+// patterns such as card numbers, wherever they appear. Only the stored copy
+// is redacted; traffic passes through the proxy unchanged.
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// Modes.
+const (
+	Mask = "mask" // replace the value with [redacted]
+	Hash = "hash" // replace the value with a keyed hash, so equal values stay equal
+	Drop = "drop" // remove the header, JSON field or matched text
+)
+
+// Modes lists the supported modes.
+var Modes = []string{Mask, Hash, Drop}
+
+// masked replaces values in Mask mode.
+const masked = "[redacted]"
+
+// Rule redacts one kind of sensitive data. It names exactly one of a
+// header, a JSON path or a pattern:
+//
+//   - Header matches a request or response header by name. For Cookie and
+//     Set-Cookie only the cookie values are redacted, and for Authorization
+//     only the credentials after the scheme, unless the mode is drop.
+//   - JSONPath matches fields of JSON request and response bodies, e.g.
+//     $.password, $.cards[*].number or $..token for a field at any depth.
+//   - Pattern is a regular expression matched against the path, query,
+//     header values and text bodies. Its group named "value", or the whole
+//     match if it has none, is redacted.
+type Rule struct {
+	ID       string `json:"id"`
+	Header   string `json:"header,omitempty"`
+	JSONPath string `json:"json_path,omitempty"`
+	Pattern  string `json:"pattern,omitempty"`
+	Luhn     bool   `json:"luhn,omitempty"` // pattern matches must pass the Luhn checksum, as card numbers do
+	Mode     string `json:"mode,omitempty"` // default mask
+	Disabled bool   `json:"disabled,omitempty"`
+
+	path  []step
+	re    *regexp.Regexp
+	value int // index of the value group, 0 for the whole match
+}
+
+// DefaultRules are the rules used unless configured otherwise. Credentials
+// are hashed so that requests made as different users can still be told
+// apart.
+var DefaultRules = []Rule{
+	{ID: "authorization", Header: "Authorization", Mode: Hash},
+	{ID: "proxy-authorization", Header: "Proxy-Authorization", Mode: Hash},
+	{ID: "cookie", Header: "Cookie", Mode: Hash},
+	{ID: "set-cookie", Header: "Set-Cookie", Mode: Hash},
+	{ID: "card-number", Pattern: `\b(?:\d[ -]?){12,18}\d\b`, Luhn: true},
+	{ID: "ssn", Pattern: `\b\d{3}-\d{2}-\d{4}\b`},
+	{ID: "email", Pattern: `\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`},
+}
+
+// LoadRules returns DefaultRules combined with the rules in a JSON file
+// holding an array of rules. A rule with the ID of a default rule replaces
+// it, keeping any field it leaves empty; other rules are added.
+// {"id": "email", "disabled": true} turns a default rule off.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading redaction rules: %w", err)
+	}
+	var custom []Rule
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("parsing redaction rules %s: %w", path, err)
+	}
+
+	rules := slices.Clone(DefaultRules)
+	for _, c := range custom {
+		if c.ID == "" {
+			return nil, fmt.Errorf("redaction rule without an id in %s", path)
+		}
+		i := slices.IndexFunc(rules, func(r Rule) bool { return r.ID == c.ID })
+		if i < 0 {
+			rules = append(rules, c)
+			continue
+		}
+		r := &rules[i]
+		if c.Header != "" || c.JSONPath != "" || c.Pattern != "" {
+			r.Header, r.JSONPath, r.Pattern, r.Luhn = c.Header, c.JSONPath, c.Pattern, c.Luhn
+		}
+		if c.Mode != "" {
+			r.Mode = c.Mode
+		}
+		r.Disabled = c.Disabled
+	}
+	if _, err := New(rules, nil); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Redactor applies redaction rules to entries.
+type Redactor struct {
+	headers  []Rule
+	paths    []Rule
+	patterns []Rule
+	key      []byte
+}
+
+// New returns a redactor using the enabled rules. Hashes are HMAC-SHA256
+// keyed with key, so that short values such as SSNs cannot be recovered by
+// hashing every candidate; use the same key to keep hashes comparable
+// across runs.
+func New(rules []Rule, key []byte) (*Redactor, error) {
+	r := &Redactor{key: key}
+	for _, rule := range rules {
+		if rule.Disabled {
+			continue
+		}
+		if rule.Mode == "" {
+			rule.Mode = Mask
+		}
+		if !slices.Contains(Modes, rule.Mode) {
+			return nil, fmt.Errorf("redaction rule %s: unknown mode %q", rule.ID, rule.Mode)
+		}
+
+		n := 0
+		for _, s := range []string{rule.Header, rule.JSONPath, rule.Pattern} {
+			if s != "" {
+				n++
+			}
+		}
+		if n != 1 {
+			return nil, fmt.Errorf("redaction rule %s: want exactly one of header, json_path and pattern", rule.ID)
+		}
+
+		switch {
+		case rule.Header != "":
+			rule.Header = http.CanonicalHeaderKey(rule.Header)
+			r.headers = append(r.headers, rule)
+		case rule.JSONPath != "":
+			path, err := parsePath(rule.JSONPath)
+			if err != nil {
+				return nil, fmt.Errorf("redaction rule %s: %w", rule.ID, err)
+			}
+			rule.path = path
+			r.paths = append(r.paths, rule)
+		default:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("redaction rule %s: %w", rule.ID, err)
+			}
+			rule.re = re
+			if i := re.SubexpIndex("value"); i > 0 {
+				rule.value = i
+			}
+			r.patterns = append(r.patterns, rule)
+		}
+	}
+	return r, nil
+}
+
+// Redact returns a copy of e with the rules applied. e is left unchanged.
+func (r *Redactor) Redact(e *storage.Entry) *storage.Entry {
+	c := *e
+	c.RequestHeaders = r.redactHeaders(e.RequestHeaders)
+	c.ResponseHeaders = r.redactHeaders(e.ResponseHeaders)
+	c.Path = r.redactText(e.Path)
+	c.Query = r.redactText(e.Query)
+	c.RequestBody = r.redactBody(e.RequestBody)
+	c.ResponseBody = r.redactBody(e.ResponseBody)
+	c.RawRequest = r.redactRaw(e.RawRequest)
+	c.RawResponse = r.redactRaw(e.RawResponse)
+	return &c
+}
+
+// redactRaw redacts a message stored as it went over the wire: its header
+// lines as headers, its start line as text and the rest as a body. Line
+// endings and header spelling are kept, malformed or not.
+func (r *Redactor) redactRaw(msg []byte) []byte {
+	if msg == nil {
+		return nil
+	}
+	out := make([]byte, 0, len(msg))
+	for first := true; len(msg) > 0; first = false {
+		line, eol := msg, []byte(nil)
+		if i := bytes.IndexByte(msg, '\n'); i >= 0 {
+			line, eol = msg[:i], msg[i:i+1]
+		}
+		msg = msg[len(line)+len(eol):]
+		if bytes.HasSuffix(line, []byte("\r")) {
+			line, eol = line[:len(line)-1], append([]byte("\r"), eol...)
+		}
+
+		if len(line) == 0 && !first {
+			out = append(append(out, eol...), r.redactBody(msg)...)
+			break
+		}
+		if first {
+			out = append(out, r.redactText(string(line))...)
+		} else if line, ok := r.redactRawHeader(string(line)); ok {
+			out = append(out, line...)
+		} else {
+			continue
+		}
+		out = append(out, eol...)
+	}
+	return out
+}
+
+// redactRawHeader redacts a header line as redactHeaders does a header,
+// reporting false if the line is dropped. The name is matched with any
+// whitespace around it trimmed, as servers lenient about it would read it.
+func (r *Redactor) redactRawHeader(line string) (string, bool) {
+	name, v, ok := strings.Cut(line, ":")
+	if !ok {
+		return r.redactText(line), true
+	}
+	canonical := http.CanonicalHeaderKey(strings.TrimSpace(name))
+	if rule := slices.IndexFunc(r.headers, func(rule Rule) bool { return rule.Header == canonical }); rule >= 0 {
+		mode := r.headers[rule].Mode
+		if mode == Drop {
+			return "", false
+		}
+		value := strings.TrimLeft(v, " \t")
+		v = v[:len(v)-len(value)] + Header(canonical, value, func(s string) string { return r.replace(s, mode) })
+	}
+	return name + ":" + r.redactText(v), true
+}
+
+func (r *Redactor) redactHeaders(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		rule := slices.IndexFunc(r.headers, func(rule Rule) bool { return rule.Header == http.CanonicalHeaderKey(name) })
+		if rule >= 0 && r.headers[rule].Mode == Drop {
+			continue
+		}
+		redacted := make([]string, len(values))
+		for i, v := range values {
+			if rule >= 0 {
+				mode := r.headers[rule].Mode
+				v = Header(name, v, func(s string) string { return r.replace(s, mode) })
+			}
+			redacted[i] = r.redactText(v)
+		}
+		out[name] = redacted
+	}
+	return out
+}
+
+// Header redacts the sensitive part of a header value, keeping what tells
+// requests apart when reading them: the authentication scheme, cookie names
+// and cookie attributes. replace returns what each sensitive part becomes.
+func Header(name, v string, replace func(string) string) string {
+	switch http.CanonicalHeaderKey(name) {
+	case "Cookie":
+		pairs := strings.Split(v, ";")
+		for i, p := range pairs {
+			pairs[i] = redactPair(p, replace)
+		}
+		return strings.Join(pairs, ";")
+	case "Set-Cookie":
+		pair, attrs, ok := strings.Cut(v, ";")
+		if ok {
+			return redactPair(pair, replace) + ";" + attrs
+		}
+		return redactPair(pair, replace)
+	case "Authorization", "Proxy-Authorization":
+		if scheme, credentials, ok := strings.Cut(v, " "); ok {
+			return scheme + " " + replace(credentials)
+		}
+	}
+	return replace(v)
+}
+
+// MaskHeader redacts the sensitive part of a header value as Mask mode
+// does.
+func MaskHeader(name, v string) string {
+	return Header(name, v, func(string) string { return masked })
+}
+
+// redactPair redacts the value of a cookie name=value pair.
+func redactPair(p string, replace func(string) string) string {
+	name, value, ok := strings.Cut(p, "=")
+	if !ok {
+		return replace(p)
+	}
+	return name + "=" + replace(value)
+}
+
+// replace returns what a value redacted in mode is replaced with.
+func (r *Redactor) replace(v, mode string) string {
+	switch mode {
+	case Drop:
+		return ""
+	case Hash:
+		m := hmac.New(sha256.New, r.key)
+		m.Write([]byte(v))
+		return "[hash:" + hex.EncodeToString(m.Sum(nil)[:8]) + "]"
+	}
+	return masked
+}
+
+// redactText applies the pattern rules to s.
+func (r *Redactor) redactText(s string) string {
+	for _, rule := range r.patterns {
+		s = r.redactMatches(s, rule)
+	}
+	return s
+}
+
+func (r *Redactor) redactMatches(s string, rule Rule) string {
+	matches := rule.re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*rule.value], m[2*rule.value+1]
+		if start < 0 || rule.Luhn && !luhn(s[start:end]) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(r.replace(s[start:end], rule.Mode))
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// redactBody applies the JSON path rules to a JSON body and the pattern
+// rules to any text body. Binary bodies are stored as they are.
+func (r *Redactor) redactBody(body []byte) []byte {
+	if len(body) == 0 || !utf8.Valid(body) || bytes.IndexByte(body, 0) >= 0 {
+		return body
+	}
+	if len(r.paths) > 0 {
+		body = r.redactJSON(body)
+	}
+	return []byte(r.redactText(string(body)))
+}
+
+// redactJSON applies the JSON path rules to body if it is a JSON document.
+// The body is re-encoded only if a rule matched, which normalizes its
+// whitespace and key order.
+func (r *Redactor) redactJSON(body []byte) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '{' && trimmed[0] != '[' {
+		return body
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return body
+	}
+
+	changed := false
+	for _, rule := range r.paths {
+		matched := walk(doc, rule.path, func(v any) (any, bool) {
+			if rule.Mode == Drop {
+				return nil, false
+			}
+			s, isString := v.(string)
+			if !isString {
+				b, _ := json.Marshal(v)
+				s = string(b)
+			}
+			return r.replace(s, rule.Mode), true
+		})
+		changed = changed || matched
+	}
+	if !changed {
+		return body
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return body
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// Store is a storage.Store that saves entries redacted. The entry passed to
+// Save is left unchanged, apart from its ID.
+type Store struct {
+	storage.Store
+	Redactor *Redactor
+}
+
+func (s *Store) Save(e *storage.Entry) error {
+	c := s.Redactor.Redact(e)
+	if err := s.Store.Save(c); err != nil {
+		return err
+	}
+	e.ID = c.ID
+	return nil
+}
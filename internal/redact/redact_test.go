@@ -0,0 +1,287 @@
+package redact
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+func testEntry() *storage.Entry {
+	return &storage.Entry{
+		Method: "POST", Scheme: "https", Host: "shop.acme.com", Path: "/users/alice@acme.com/orders",
+		Query: "ssn=123-45-6789&page=2",
+		RequestHeaders: http.Header{
+			"Authorization": {"Bearer tok_abc123"},
+			"Cookie":        {"session=s3cr3t; theme=dark"},
+			"Content-Type":  {"application/json"},
+		},
+		RequestBody: []byte(`{"user":{"password":"hunter2","name":"Alice"},"cards":[{"number":"4111 1111 1111 1111","exp":"12/30"}],"id":1234567890123}`),
+		StatusCode:  200,
+		ResponseHeaders: http.Header{
+			"Set-Cookie":   {"session=n3w; Path=/; HttpOnly"},
+			"Content-Type": {"text/html"},
+		},
+		ResponseBody: []byte("<p>Contact bob@example.org, order 4111111111111112</p>"),
+	}
+}
+
+func TestRedactDefaults(t *testing.T) {
+	r, err := New(DefaultRules, []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := testEntry()
+	got := r.Redact(e)
+
+	if got.Path != "/users/[redacted]/orders" {
+		t.Errorf("path = %q", got.Path)
+	}
+	if got.Query != "ssn=[redacted]&page=2" {
+		t.Errorf("query = %q", got.Query)
+	}
+
+	auth := got.RequestHeaders.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer [hash:") || strings.Contains(auth, "abc123") {
+		t.Errorf("Authorization = %q", auth)
+	}
+	cookie := got.RequestHeaders.Get("Cookie")
+	if !strings.HasPrefix(cookie, "session=[hash:") || !strings.Contains(cookie, "; theme=[hash:") || strings.Contains(cookie, "s3cr3t") {
+		t.Errorf("Cookie = %q", cookie)
+	}
+	if sc := got.ResponseHeaders.Get("Set-Cookie"); !strings.HasPrefix(sc, "session=[hash:") || !strings.HasSuffix(sc, "; Path=/; HttpOnly") {
+		t.Errorf("Set-Cookie = %q", sc)
+	}
+
+	// Hashes are stable, so the same credential is recognisable.
+	if again := r.Redact(testEntry()).RequestHeaders.Get("Authorization"); again != auth {
+		t.Errorf("hash changed: %q, then %q", auth, again)
+	}
+	other, _ := New(DefaultRules, []byte("other key"))
+	if other.Redact(testEntry()).RequestHeaders.Get("Authorization") == auth {
+		t.Error("hash does not depend on the key")
+	}
+
+	if strings.Contains(string(got.RequestBody), "4111 1111 1111 1111") {
+		t.Errorf("card number stored: %s", got.RequestBody)
+	}
+	if !strings.Contains(string(got.RequestBody), `"id":1234567890123`) {
+		t.Errorf("number failing the Luhn check redacted: %s", got.RequestBody)
+	}
+	// 4111111111111112 fails the Luhn check.
+	if want := "<p>Contact [redacted], order 4111111111111112</p>"; string(got.ResponseBody) != want {
+		t.Errorf("response body = %s, want %s", got.ResponseBody, want)
+	}
+
+	// The original is what the proxy forwards: it must not change.
+	orig := testEntry()
+	if e.Path != orig.Path || e.RequestHeaders.Get("Cookie") != orig.RequestHeaders.Get("Cookie") ||
+		string(e.RequestBody) != string(orig.RequestBody) || string(e.ResponseBody) != string(orig.ResponseBody) {
+		t.Error("original entry modified")
+	}
+}
+
+func TestRedactModes(t *testing.T) {
+	r, err := New([]Rule{
+		{ID: "cookie", Header: "cookie", Mode: Drop},
+		{ID: "password", JSONPath: "$.user.password"},
+		{ID: "card", JSONPath: "$.cards[*].number", Mode: Hash},
+		{ID: "exp", JSONPath: "$..exp", Mode: Drop},
+		{ID: "ssn", Pattern: `ssn=(?P<value>[^&]+)`, Mode: Drop},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := r.Redact(testEntry())
+
+	if _, ok := got.RequestHeaders["Cookie"]; ok {
+		t.Error("dropped header still present")
+	}
+	if got.RequestHeaders.Get("Authorization") != "Bearer tok_abc123" {
+		t.Error("header without a rule changed")
+	}
+	if got.Query != "ssn=&page=2" {
+		t.Errorf("query = %q", got.Query)
+	}
+
+	body := string(got.RequestBody)
+	for _, want := range []string{`"password":"[redacted]"`, `"name":"Alice"`, `"number":"[hash:`, `"id":1234567890123`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %s: %s", want, body)
+		}
+	}
+	if strings.Contains(body, "exp") || strings.Contains(body, "hunter2") || strings.Contains(body, "4111") {
+		t.Errorf("body not redacted: %s", body)
+	}
+
+	// Bodies no rule matches are stored byte for byte.
+	e := testEntry()
+	e.RequestBody = []byte("{\n  \"other\": true\n}")
+	if got := r.Redact(e); string(got.RequestBody) != string(e.RequestBody) {
+		t.Errorf("unmatched body re-encoded: %s", got.RequestBody)
+	}
+}
+
+func TestRedactRaw(t *testing.T) {
+	r, err := New(append(slices.Clone(DefaultRules), Rule{ID: "api-key", Header: "X-Api-Key", Mode: Drop}), []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := testEntry()
+	e.RawRequest = []byte("POST /users?ssn=123-45-6789 HTTP/1.1\nHost: shop.acme.com\r\nauthorization:  Bearer tok_abc123\r\n" +
+		"Cookie : session=s3cr3t\r\nx-api-key: k3y\r\nContent-Length: 20\r\n\r\nemail=bob@example.org")
+	e.RawResponse = []byte("HTTP/1.1 200 OK\r\nSet-Cookie: session=n3w; Path=/\r\n\r\nok")
+	got := r.Redact(e)
+
+	req := string(got.RawRequest)
+	for _, secret := range []string{"tok_abc123", "s3cr3t", "k3y", "X-Api-Key", "x-api-key", "123-45-6789", "bob@example.org"} {
+		if strings.Contains(req, secret) {
+			t.Errorf("raw request still contains %s: %q", secret, req)
+		}
+	}
+	for _, want := range []string{
+		"POST /users?ssn=[redacted] HTTP/1.1\nHost: shop.acme.com\r\n",
+		"\r\nauthorization:  Bearer [hash:",
+		"\r\nCookie : session=[hash:",
+		"]\r\nContent-Length: 20\r\n\r\nemail=[redacted]",
+	} {
+		if !strings.Contains(req, want) {
+			t.Errorf("raw request missing %q: %q", want, req)
+		}
+	}
+	if resp := string(got.RawResponse); !strings.HasPrefix(resp, "HTTP/1.1 200 OK\r\nSet-Cookie: session=[hash:") || !strings.HasSuffix(resp, "]; Path=/\r\n\r\nok") {
+		t.Errorf("raw response = %q", resp)
+	}
+	if !strings.Contains(string(e.RawRequest), "tok_abc123") {
+		t.Error("entry passed in was changed")
+	}
+
+	// Entries not sent raw have no raw messages to redact.
+	if got := r.Redact(testEntry()); got.RawRequest != nil || got.RawResponse != nil {
+		t.Errorf("raw messages = %q, %q; want none", got.RawRequest, got.RawResponse)
+	}
+}
+
+func TestMaskHeader(t *testing.T) {
+	tests := []struct{ name, value, want string }{
+		{"Authorization", "Bearer tok_abc123", "Bearer [redacted]"},
+		{"proxy-authorization", "Basic dXNlcjpwYXNz", "Basic [redacted]"},
+		{"Cookie", "session=s3cr3t; theme=dark", "session=[redacted]; theme=[redacted]"},
+		{"Set-Cookie", "sid=abc; Path=/; HttpOnly", "sid=[redacted]; Path=/; HttpOnly"},
+		{"X-Api-Key", "key with spaces", "[redacted]"},
+	}
+	for _, tt := range tests {
+		if got := MaskHeader(tt.name, tt.value); got != tt.want {
+			t.Errorf("MaskHeader(%q, %q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+
+	upper := func(s string) string { return strings.ToUpper(s) }
+	if got := Header("Set-Cookie", "sid=abc; Secure", upper); got != "sid=ABC; Secure" {
+		t.Errorf("Header with a custom replacement = %q", got)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	valid := []string{"$.a", "$.a.b", "$.a[0]", "$.a[*].b", "$['a b'].c", "$.*", "$..token", "$[2]"}
+	for _, p := range valid {
+		if _, err := parsePath(p); err != nil {
+			t.Errorf("parsePath(%q): %v", p, err)
+		}
+	}
+	invalid := []string{"", "$", "a.b", "$.", "$..", "$.a[", "$.a[x]", "$.a[-1]", "$a"}
+	for _, p := range invalid {
+		if _, err := parsePath(p); err == nil {
+			t.Errorf("parsePath(%q) succeeded", p)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, rule := range []Rule{
+		{ID: "none"},
+		{ID: "two", Header: "Cookie", Pattern: "x"},
+		{ID: "mode", Header: "Cookie", Mode: "shred"},
+		{ID: "regex", Pattern: "("},
+		{ID: "path", JSONPath: "a.b"},
+	} {
+		if _, err := New([]Rule{rule}, nil); err == nil {
+			t.Errorf("rule %s accepted", rule.ID)
+		}
+	}
+	if _, err := New([]Rule{{ID: "off", Disabled: true}}, nil); err != nil {
+		t.Errorf("disabled rule checked: %v", err)
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	data := `[
+		{"id": "email", "disabled": true},
+		{"id": "cookie", "mode": "drop"},
+		{"id": "api-key", "header": "X-Api-Key", "mode": "hash"}
+	]`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[string]Rule{}
+	for _, r := range rules {
+		byID[r.ID] = r
+	}
+	if !byID["email"].Disabled || byID["email"].Pattern == "" {
+		t.Errorf("email = %+v, want disabled default", byID["email"])
+	}
+	if c := byID["cookie"]; c.Mode != Drop || c.Header != "Cookie" {
+		t.Errorf("cookie = %+v", c)
+	}
+	if k := byID["api-key"]; k.Header != "X-Api-Key" || len(rules) != len(DefaultRules)+1 {
+		t.Errorf("api-key = %+v, %d rules", k, len(rules))
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"id": "bad", "pattern": "("}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRules(path); err == nil {
+		t.Error("invalid rule accepted")
+	}
+}
+
+func TestStoreSave(t *testing.T) {
+	r, _ := New(DefaultRules, nil)
+	mem := &memStore{}
+	s := &Store{Store: mem, Redactor: r}
+
+	e := testEntry()
+	if err := s.Save(e); err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != 1 {
+		t.Errorf("ID = %d, want the stored copy's", e.ID)
+	}
+	if strings.Contains(mem.saved.RequestHeaders.Get("Cookie"), "s3cr3t") {
+		t.Error("cookie stored verbatim")
+	}
+	if e.RequestHeaders.Get("Cookie") != testEntry().RequestHeaders.Get("Cookie") {
+		t.Error("caller's entry redacted")
+	}
+}
+
+// memStore records the entry saved.
+type memStore struct {
+	storage.Store
+	saved *storage.Entry
+}
+
+func (m *memStore) Save(e *storage.Entry) error {
+	m.saved = e
+	e.ID = 1
+	return nil
+}
@@ -0,0 +1,149 @@
+package report
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Formats.
+const (
+	HTML     = "html"
+	Markdown = "markdown"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{HTML, Markdown}
+
+//go:embed templates
+var templates embed.FS
+
+// FormatFromPath infers the format of a report from its file name.
+func FormatFromPath(path string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return HTML, true
+	case ".md", ".markdown":
+		return Markdown, true
+	}
+	return "", false
+}
+
+// Render writes r to w in format. If templatePath is set, that template is
+// used instead of the built-in one: an html/template for HTML, which
+// escapes the data, and a text/template for Markdown.
+func Render(w io.Writer, r *Report, format, templatePath string) error {
+	name := "templates/report.md.tmpl"
+	switch format {
+	case HTML:
+		name = "templates/report.html.tmpl"
+	case Markdown:
+	default:
+		return fmt.Errorf("unknown format %q: want one of %s", format, strings.Join(Formats, ", "))
+	}
+	var text []byte
+	var err error
+	if templatePath != "" {
+		text, err = os.ReadFile(templatePath)
+		name = templatePath
+	} else {
+		text, err = templates.ReadFile(name)
+	}
+	if err != nil {
+		return fmt.Errorf("reading template: %w", err)
+	}
+
+	name = filepath.Base(name)
+	if format == HTML {
+		t, err := htmltemplate.New(name).Funcs(funcs).Parse(string(text))
+		if err != nil {
+			return fmt.Errorf("parsing template: %w", err)
+		}
+		return t.Execute(w, r)
+	}
+	t, err := template.New(name).Funcs(funcs).Parse(string(text))
+	if err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+	return t.Execute(w, r)
+}
+
+// funcs are available to both built-in and custom templates.
+var funcs = map[string]any{
+	"date":     formatDate,
+	"join":     strings.Join,
+	"statuses": formatStatuses,
+	"title":    title,
+	"plural":   plural,
+	"fence":    fence,
+	"cell":     cell,
+	"quote":    quote,
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func formatStatuses(statuses []int) string {
+	s := make([]string, len(statuses))
+	for i, code := range statuses {
+		s[i] = strconv.Itoa(code)
+	}
+	return strings.Join(s, ", ")
+}
+
+// title upper-cases the first letter of s.
+func title(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// plural formats a count of things, e.g. "1 finding" or "3 findings".
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return strconv.Itoa(n) + " " + word + "s"
+}
+
+// fence returns a Markdown code fence longer than any run of backticks in
+// s, so the block cannot be closed early by the evidence it holds.
+func fence(s string) string {
+	longest, run := 0, 0
+	for _, c := range s {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// quote makes s a Markdown blockquote, however many lines it has.
+func quote(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cell makes s safe to put in a Markdown table cell.
+func cell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,268 @@
+// Package report renders an engagement report from stored traffic and
+// findings: the scope, the hosts and endpoints tested, the findings with
+// evidence and reproduction commands, and a timeline.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ghostsecurity/reaper/internal/exchange"
+	"github.com/ghostsecurity/reaper/internal/redact"
+	"github.com/ghostsecurity/reaper/internal/scanner"
+	"github.com/ghostsecurity/reaper/internal/snippet"
+	"github.com/ghostsecurity/reaper/internal/storage"
+	"github.com/ghostsecurity/reaper/version"
+)
+
+// MaxBody bounds the part of a request or response body shown as evidence.
+const MaxBody = 2048
+
+// sensitiveHeaders have their values replaced in evidence, whatever they
+// hold.
+var sensitiveHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token",
+}
+
+// Scope is the scope the proxy recorded traffic for.
+type Scope struct {
+	Domains []string
+	Hosts   []string
+	Started time.Time // when the proxy started; zero if unknown
+}
+
+// Input is the stored data a report is built from.
+type Input struct {
+	Title     string
+	Scope     Scope
+	Endpoints []storage.Endpoint
+	Findings  []*storage.Finding
+	Entries   map[int64]*storage.Entry // evidence entries by ID; see EvidenceID
+	FuzzRuns  []*storage.FuzzRun
+	Now       time.Time
+}
+
+// Report is the data templates render.
+type Report struct {
+	Title     string
+	Version   string
+	Generated time.Time
+	Scope     Scope
+	Hosts     []Host
+	Endpoints int // endpoints across all hosts
+	Requests  int // requests across all endpoints
+	Groups    []Group
+	Findings  int
+	Timeline  []Event
+}
+
+// Host is a host tested, with its endpoints.
+type Host struct {
+	Name      string
+	Endpoints []storage.Endpoint
+	Requests  int
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// Group holds the findings of one severity.
+type Group struct {
+	Severity string
+	Findings []Finding
+}
+
+// Finding is a finding with its evidence: the request and response of its
+// first entry, with secrets redacted and bodies truncated, and a curl
+// command reproducing the request. The evidence is empty when the entry has
+// been deleted.
+type Finding struct {
+	*storage.Finding
+	Request  string
+	Response string
+	Snippet  string
+}
+
+// Event is a timeline entry.
+type Event struct {
+	Time time.Time
+	Text string
+}
+
+// EvidenceID returns the ID of the entry shown as a finding's evidence, or 0
+// if it has none left.
+func EvidenceID(f *storage.Finding) int64 {
+	if slices.Contains(f.EntryIDs, f.EntryID) || len(f.EntryIDs) == 0 {
+		return f.EntryID
+	}
+	return f.EntryIDs[0]
+}
+
+// Build assembles a report from in.
+func Build(in Input) *Report {
+	r := &Report{
+		Title:     in.Title,
+		Version:   version.Version,
+		Generated: in.Now,
+		Scope:     in.Scope,
+		Findings:  len(in.Findings),
+	}
+	if r.Title == "" {
+		r.Title = "Security assessment report"
+	}
+	if !in.Scope.Started.IsZero() {
+		r.Timeline = append(r.Timeline, Event{in.Scope.Started, "Proxy started"})
+	}
+
+	for _, ep := range in.Endpoints {
+		i := slices.IndexFunc(r.Hosts, func(h Host) bool { return h.Name == ep.Host })
+		if i < 0 {
+			i = len(r.Hosts)
+			r.Hosts = append(r.Hosts, Host{Name: ep.Host, FirstSeen: ep.FirstSeen, LastSeen: ep.LastSeen})
+		}
+		h := &r.Hosts[i]
+		h.Endpoints = append(h.Endpoints, ep)
+		h.Requests += ep.Count
+		if ep.FirstSeen.Before(h.FirstSeen) {
+			h.FirstSeen = ep.FirstSeen
+		}
+		if ep.LastSeen.After(h.LastSeen) {
+			h.LastSeen = ep.LastSeen
+		}
+		r.Endpoints++
+		r.Requests += ep.Count
+	}
+	slices.SortFunc(r.Hosts, func(a, b Host) int { return strings.Compare(a.Name, b.Name) })
+	for _, h := range r.Hosts {
+		r.Timeline = append(r.Timeline, Event{h.FirstSeen, fmt.Sprintf("First request to %s", h.Name)})
+	}
+
+	for i := len(storage.Severities) - 1; i >= 0; i-- {
+		g := Group{Severity: storage.Severities[i]}
+		for _, f := range in.Findings {
+			if f.Severity == g.Severity {
+				g.Findings = append(g.Findings, evidence(f, in.Entries[EvidenceID(f)]))
+			}
+		}
+		if len(g.Findings) > 0 {
+			r.Groups = append(r.Groups, g)
+		}
+	}
+	for _, f := range in.Findings {
+		r.Timeline = append(r.Timeline, Event{f.FirstSeen, fmt.Sprintf("Finding #%d (%s): %s", f.ID, f.Severity, f.Title)})
+	}
+
+	for _, run := range in.FuzzRuns {
+		r.Timeline = append(r.Timeline, Event{run.CreatedAt,
+			fmt.Sprintf("Fuzz run #%d (%s) against entry %d: %d/%d requests, %s", run.ID, run.Mode, run.EntryID, run.Sent, run.Total, run.Status)})
+	}
+	slices.SortStableFunc(r.Timeline, func(a, b Event) int { return a.Time.Compare(b.Time) })
+	return r
+}
+
+// evidence pairs f with the redacted request and response of e. Secrets
+// are hidden in the finding's own evidence too, which may quote them.
+func evidence(f *storage.Finding, e *storage.Entry) Finding {
+	c := *f
+	c.Evidence = scanner.RedactSecrets(f.Evidence)
+	ev := Finding{Finding: &c}
+	if e == nil {
+		return ev
+	}
+	e = redactEntry(e)
+	ev.Request = message(exchange.DumpRequest(e))
+	if e.StatusCode != 0 {
+		ev.Response = message(exchange.DumpResponse(e))
+	}
+	if s, err := snippet.Generate(e, snippet.Curl); err == nil {
+		s = strings.TrimRight(s, "\n")
+		if len(s) > 2*MaxBody {
+			s = truncate(s, 2*MaxBody) + fmt.Sprintf("\n[truncated, %d bytes]", len(s))
+		}
+		ev.Snippet = s
+	}
+	return ev
+}
+
+// redactEntry returns a copy of e with sensitive header values masked and the
+// secrets the scanner recognises hidden in the rest.
+func redactEntry(e *storage.Entry) *storage.Entry {
+	c := *e
+	c.RequestHeaders = redactHeaders(e.RequestHeaders)
+	c.ResponseHeaders = redactHeaders(e.ResponseHeaders)
+	c.Path = scanner.RedactSecrets(e.Path)
+	c.Query = scanner.RedactSecrets(e.Query)
+	c.RequestBody = redactBody(e.RequestBody)
+	c.ResponseBody = redactBody(e.ResponseBody)
+	// The wire messages of a sent entry would bypass the redaction above.
+	c.RawRequest, c.RawResponse = nil, nil
+	return &c
+}
+
+func redactHeaders(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		redacted := make([]string, len(values))
+		for i, v := range values {
+			if slices.Contains(sensitiveHeaders, http.CanonicalHeaderKey(name)) {
+				redacted[i] = redact.MaskHeader(name, v)
+			} else {
+				redacted[i] = scanner.RedactSecrets(v)
+			}
+		}
+		out[name] = redacted
+	}
+	return out
+}
+
+// redactBody hides secrets in a text body. Binary bodies are left alone:
+// message replaces them with their size.
+func redactBody(b []byte) []byte {
+	if len(b) == 0 || !isText(b) {
+		return b
+	}
+	return []byte(scanner.RedactSecrets(string(b)))
+}
+
+// message formats a dumped HTTP message for display: LF line endings and
+// the body cut to MaxBody, or replaced by its size if binary.
+func message(raw []byte) string {
+	head, body, _ := bytes.Cut(raw, []byte("\r\n\r\n"))
+	s := strings.ReplaceAll(string(head), "\r\n", "\n")
+	switch {
+	case len(body) == 0:
+		return s
+	case !isText(body):
+		return s + fmt.Sprintf("\n\n[%d bytes binary body]", len(body))
+	case len(body) > MaxBody:
+		return s + "\n\n" + truncate(string(body), MaxBody) + fmt.Sprintf("\n[truncated, %d bytes]", len(body))
+	}
+	return s + "\n\n" + string(body)
+}
+
+// isText reports whether the start of b is UTF-8 text.
+func isText(b []byte) bool {
+	if len(b) > 512 {
+		b = b[:512]
+		// Drop a character cut at the boundary.
+		for i := 0; i < utf8.UTFMax && !utf8.Valid(b); i++ {
+			b = b[:len(b)-1]
+		}
+	}
+	return utf8.Valid(b) && bytes.IndexByte(b, 0) < 0
+}
+
+// truncate cuts s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ghostsecurity/reaper/internal/redact"
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// sessionCookie matches the names of cookies likely to carry a session or
+// credential, where missing flags matter most.
+var sessionCookie = regexp.MustCompile(`(?i)sess|sid|auth|token|jwt|login|remember|credential`)
+
+// CookieFlags reports cookies set without Secure over HTTPS, session
+// cookies readable by script or without SameSite, and SameSite=None
+// cookies that browsers will reject for lacking Secure.
+type CookieFlags struct{}
+
+func (CookieFlags) Name() string { return "cookie-flags" }
+
+func (CookieFlags) Run(e *storage.Entry) []Issue {
+	var issues []Issue
+	for _, line := range e.ResponseHeaders.Values("Set-Cookie") {
+		c, err := http.ParseSetCookie(line)
+		if err != nil || deleted(c) {
+			continue
+		}
+		session := sessionCookie.MatchString(c.Name)
+		evidence := "Set-Cookie: " + redact.Header("Set-Cookie", line, shorten)
+
+		if e.Scheme == "https" && !c.Secure {
+			sev := storage.SeverityLow
+			if session {
+				sev = storage.SeverityMedium
+			}
+			issues = append(issues, Issue{
+				Key:        c.Name + ":secure",
+				Title:      "Cookie " + c.Name + " without Secure flag",
+				Severity:   sev,
+				Confidence: storage.ConfidenceCertain,
+				Evidence:   evidence,
+			})
+		}
+		if session && !c.HttpOnly {
+			issues = append(issues, Issue{
+				Key:        c.Name + ":httponly",
+				Title:      "Session cookie " + c.Name + " without HttpOnly flag",
+				Severity:   storage.SeverityLow,
+				Confidence: storage.ConfidenceFirm,
+				Evidence:   evidence,
+			})
+		}
+		switch {
+		case c.SameSite == http.SameSiteNoneMode && !c.Secure:
+			issues = append(issues, Issue{
+				Key:        c.Name + ":samesite-none",
+				Title:      "Cookie " + c.Name + " with SameSite=None but no Secure flag",
+				Severity:   storage.SeverityLow,
+				Confidence: storage.ConfidenceCertain,
+				Evidence:   evidence,
+			})
+		case session && (c.SameSite == 0 || c.SameSite == http.SameSiteDefaultMode):
+			issues = append(issues, Issue{
+				Key:        c.Name + ":samesite",
+				Title:      "Session cookie " + c.Name + " without SameSite attribute",
+				Severity:   storage.SeverityInfo,
+				Confidence: storage.ConfidenceFirm,
+				Evidence:   evidence,
+			})
+		}
+	}
+	return issues
+}
+
+// deleted reports whether a Set-Cookie clears the cookie rather than
+// setting it.
+func deleted(c *http.Cookie) bool {
+	return c.Value == "" || c.MaxAge < 0 || (!c.Expires.IsZero() && c.Expires.Before(time.Now()))
+}
+
+// shorten hides most of a cookie value, keeping enough to recognise it.
+func shorten(value string) string {
+	if len(value) <= 4 {
+		return value
+	}
+	n := 4
+	for n > 0 && !utf8.RuneStart(value[n]) {
+		n--
+	}
+	return value[:n] + "..."
+}
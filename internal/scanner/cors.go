@@ -0,0 +1,99 @@
+package scanner
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// CORS reports permissive cross-origin resource sharing: trusting the null
+// origin, reflecting a cross-site request origin, trusting plain-HTTP
+// origins from an HTTPS site, and allowing any origin.
+type CORS struct{}
+
+func (CORS) Name() string { return "cors" }
+
+func (CORS) Run(e *storage.Entry) []Issue {
+	acao := strings.TrimSpace(e.ResponseHeaders.Get("Access-Control-Allow-Origin"))
+	if acao == "" {
+		return nil
+	}
+	creds := strings.EqualFold(strings.TrimSpace(e.ResponseHeaders.Get("Access-Control-Allow-Credentials")), "true")
+	origin := e.RequestHeaders.Get("Origin")
+
+	evidence := "Access-Control-Allow-Origin: " + acao
+	if creds {
+		evidence += ", Access-Control-Allow-Credentials: true"
+	}
+	if origin != "" {
+		evidence = "Origin: " + origin + " → " + evidence
+	}
+
+	// Severity rises when the browser also sends the user's cookies.
+	severity := func(withCreds, without string) string {
+		if creds {
+			return withCreds
+		}
+		return without
+	}
+
+	switch {
+	case acao == "*":
+		// Browsers refuse credentials with a wildcard, so this only exposes
+		// what anyone could fetch.
+		return []Issue{{
+			Key:        "wildcard",
+			Title:      "CORS allows any origin",
+			Severity:   storage.SeverityInfo,
+			Confidence: storage.ConfidenceCertain,
+			Evidence:   evidence,
+		}}
+	case strings.EqualFold(acao, "null"):
+		return []Issue{{
+			Key:        "null",
+			Title:      "CORS trusts the null origin",
+			Severity:   severity(storage.SeverityHigh, storage.SeverityMedium),
+			Confidence: storage.ConfidenceFirm,
+			Evidence:   evidence,
+		}}
+	case origin != "" && acao == origin && crossSite(origin, e.Host):
+		// A reflected origin may still come from an allowlist; only an
+		// active test with a foreign origin can tell.
+		return []Issue{{
+			Key:        "reflected",
+			Title:      "CORS reflects a cross-site origin",
+			Severity:   severity(storage.SeverityMedium, storage.SeverityLow),
+			Confidence: storage.ConfidenceTentative,
+			Evidence:   evidence,
+		}}
+	case e.Scheme == "https" && strings.HasPrefix(strings.ToLower(acao), "http://"):
+		return []Issue{{
+			Key:        "insecure-origin",
+			Title:      "CORS trusts an HTTP origin",
+			Severity:   severity(storage.SeverityMedium, storage.SeverityLow),
+			Confidence: storage.ConfidenceFirm,
+			Evidence:   evidence,
+		}}
+	}
+	return nil
+}
+
+// crossSite reports whether origin belongs to a different site than host,
+// comparing their last two labels. That misjudges hosts under public
+// suffixes like co.uk, which only costs a tentative finding.
+func crossSite(origin, host string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Hostname() == "" {
+		return true
+	}
+	return site(u.Hostname()) != site(host)
+}
+
+func site(host string) string {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(host, ".")), ".")
+	if len(labels) <= 2 {
+		return strings.Join(labels, ".")
+	}
+	return strings.Join(labels[len(labels)-2:], ".")
+}
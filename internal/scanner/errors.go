@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// errorSignature is a pattern that betrays a stack trace, debug page or
+// database error in a response.
+type errorSignature struct {
+	key      string
+	title    string
+	severity string
+	re       *regexp.Regexp
+}
+
+var errorSignatures = []errorSignature{
+	{"java", "Java stack trace", storage.SeverityLow,
+		regexp.MustCompile(`(?m)^\s*at [\w$.]+\.[\w$<>]+\([\w$]+\.(?:java|kt|scala):\d+\)`)},
+	{"dotnet", ".NET stack trace", storage.SeverityLow,
+		regexp.MustCompile(`(?m)^\s*at [\w.` + "`" + `<>]+\(.*\) in .+:line \d+`)},
+	{"python", "Python traceback", storage.SeverityLow,
+		regexp.MustCompile(`Traceback \(most recent call last\):`)},
+	{"php", "PHP error message", storage.SeverityLow,
+		regexp.MustCompile(`(?:Fatal error|Warning|Parse error|Notice)</b>?:? .{1,300}? on line <b>?\d+`)},
+	{"node", "Node.js stack trace", storage.SeverityLow,
+		regexp.MustCompile(`(?m)^\s*at (?:[\w$.<>\[\] ]+ )?\(?(?:/|[A-Z]:\\|file://|node:)[^\s()]+:\d+:\d+\)?$`)},
+	{"ruby", "Ruby stack trace", storage.SeverityLow,
+		regexp.MustCompile(`(?m)\.rb:\d+:in ` + "[`']")},
+	{"go", "Go panic", storage.SeverityLow,
+		regexp.MustCompile(`goroutine \d+ \[running\]:`)},
+	{"debug-page", "Framework debug page", storage.SeverityMedium,
+		regexp.MustCompile(`Werkzeug Debugger|You're seeing this error because you have <code>DEBUG = True</code>|Whoops! There was an error\.|<title>Action Controller: Exception caught</title>`)},
+	{"sql", "Database error message", storage.SeverityMedium,
+		regexp.MustCompile(`You have an error in your SQL syntax|ORA-\d{5}:|PG::\w+Error|SQLSTATE\[\w+\]|Unclosed quotation mark after the character string|Microsoft OLE DB Provider for|SQLite3?::\w*Exception|syntax error at or near "`)},
+}
+
+// VerboseErrors reports stack traces, framework debug pages and database
+// errors in responses, which reveal internals and often injection points.
+type VerboseErrors struct{}
+
+func (VerboseErrors) Name() string { return "verbose-errors" }
+
+func (VerboseErrors) Run(e *storage.Entry) []Issue {
+	if len(e.ResponseBody) == 0 || !isText(e) {
+		return nil
+	}
+	text := body(e)
+
+	var issues []Issue
+	for _, sig := range errorSignatures {
+		loc := sig.re.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+		issues = append(issues, Issue{
+			// Each endpoint that fails is worth knowing about.
+			Key:        sig.key + " " + e.Method + " " + e.Path,
+			Title:      sig.title + " in response",
+			Severity:   sig.severity,
+			Confidence: storage.ConfidenceFirm,
+			Evidence:   e.Method + " " + e.URL() + ": " + lineAround(text, loc[0], loc[1]),
+		})
+	}
+	return issues
+}
+
+// lineAround returns the match at start:end with the rest of its line, up
+// to 100 bytes either side.
+func lineAround(text string, start, end int) string {
+	from := max(start-100, 0)
+	if i := strings.LastIndexByte(text[from:start], '\n'); i >= 0 {
+		from += i + 1
+	}
+	to := min(end+100, len(text))
+	if i := strings.IndexByte(text[end:to], '\n'); i >= 0 {
+		to = end + i
+	}
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from++
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to--
+	}
+	return strings.TrimSpace(text[from:to])
+}
@@ -0,0 +1,150 @@
+package scanner
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// minHSTSAge is the shortest Strict-Transport-Security max-age not reported
+// as weak: 180 days, as most scanners and preload lists require.
+const minHSTSAge = 180 * 24 * 60 * 60
+
+// SecurityHeaders reports missing or weak security headers: HSTS on HTTPS
+// responses, and on successful HTML pages CSP, framing protection and
+// X-Content-Type-Options.
+type SecurityHeaders struct{}
+
+func (SecurityHeaders) Name() string { return "security-headers" }
+
+func (SecurityHeaders) Run(e *storage.Entry) []Issue {
+	var issues []Issue
+	h := e.ResponseHeaders
+	if e.StatusCode == 0 || h == nil {
+		return nil
+	}
+
+	if e.Scheme == "https" {
+		hsts := h.Get("Strict-Transport-Security")
+		if hsts == "" {
+			issues = append(issues, Issue{
+				Key:        "hsts-missing",
+				Title:      "Missing Strict-Transport-Security header",
+				Severity:   storage.SeverityLow,
+				Confidence: storage.ConfidenceCertain,
+				Evidence:   e.Method + " " + e.URL(),
+			})
+		} else if age, ok := hstsMaxAge(hsts); !ok || age < minHSTSAge {
+			issues = append(issues, Issue{
+				Key:        "hsts-weak",
+				Title:      "Short Strict-Transport-Security max-age",
+				Severity:   storage.SeverityLow,
+				Confidence: storage.ConfidenceCertain,
+				Evidence:   "Strict-Transport-Security: " + hsts,
+			})
+		}
+	}
+
+	if !isHTML(e) || e.StatusCode < 200 || e.StatusCode >= 300 {
+		return issues
+	}
+
+	csp := h.Get("Content-Security-Policy")
+	if csp == "" {
+		issues = append(issues, Issue{
+			Key:        "csp-missing",
+			Title:      "Missing Content-Security-Policy header",
+			Severity:   storage.SeverityLow,
+			Confidence: storage.ConfidenceCertain,
+			Evidence:   e.Method + " " + e.URL(),
+		})
+	} else if weak := weakCSP(csp); weak != "" {
+		issues = append(issues, Issue{
+			Key:        "csp-weak",
+			Title:      "Content-Security-Policy allows script injection",
+			Severity:   storage.SeverityLow,
+			Confidence: storage.ConfidenceFirm,
+			Evidence:   weak,
+		})
+	}
+
+	if h.Get("X-Frame-Options") == "" && cspDirective(csp, "frame-ancestors") == nil {
+		issues = append(issues, Issue{
+			Key:        "framing",
+			Title:      "Missing clickjacking protection",
+			Severity:   storage.SeverityLow,
+			Confidence: storage.ConfidenceFirm,
+			Evidence:   "no X-Frame-Options or frame-ancestors on " + e.Method + " " + e.URL(),
+		})
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(h.Get("X-Content-Type-Options")), "nosniff") {
+		issues = append(issues, Issue{
+			Key:        "nosniff",
+			Title:      "Missing X-Content-Type-Options: nosniff",
+			Severity:   storage.SeverityInfo,
+			Confidence: storage.ConfidenceCertain,
+			Evidence:   e.Method + " " + e.URL(),
+		})
+	}
+	return issues
+}
+
+func hstsMaxAge(hsts string) (int, bool) {
+	for _, part := range strings.Split(hsts, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if strings.EqualFold(name, "max-age") {
+			age, err := strconv.Atoi(strings.Trim(value, `"`))
+			return age, err == nil
+		}
+	}
+	return 0, false
+}
+
+// cspDirective returns the sources of a directive, or nil if the policy
+// does not have it.
+func cspDirective(csp, name string) []string {
+	for _, d := range strings.Split(csp, ";") {
+		fields := strings.Fields(d)
+		if len(fields) > 0 && strings.EqualFold(fields[0], name) {
+			return append([]string{}, fields[1:]...)
+		}
+	}
+	return nil
+}
+
+// weakCSP returns the script directive of csp if it lets injected script
+// run: inline script without a nonce or hash, eval, or any host.
+func weakCSP(csp string) string {
+	name := "script-src"
+	sources := cspDirective(csp, name)
+	if sources == nil {
+		name = "default-src"
+		sources = cspDirective(csp, name)
+	}
+	if sources == nil {
+		return "no script-src or default-src in Content-Security-Policy: " + csp
+	}
+
+	strict := false // a nonce or hash makes browsers ignore 'unsafe-inline'
+	for _, s := range sources {
+		s = strings.ToLower(s)
+		if strings.HasPrefix(s, "'nonce-") || strings.HasPrefix(s, "'sha") || s == "'strict-dynamic'" {
+			strict = true
+		}
+	}
+	for _, s := range sources {
+		switch strings.ToLower(s) {
+		case "'unsafe-inline'":
+			if strict {
+				continue
+			}
+		case "'unsafe-eval'", "*", "http:", "https:", "data:":
+		default:
+			continue
+		}
+		return name + " " + strings.Join(sources, " ")
+	}
+	return ""
+}
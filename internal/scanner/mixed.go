@@ -0,0 +1,80 @@
+package scanner
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// maxMixed bounds the mixed-content issues reported for one page.
+const maxMixed = 20
+
+var (
+	// refTag matches the start tag of an element that loads or submits to a
+	// URL.
+	refTag = regexp.MustCompile(`(?i)<(script|iframe|frame|link|object|embed|img|audio|video|source|track|form)\b[^>]*>`)
+	// insecureRef matches an attribute with a plain-HTTP URL.
+	insecureRef = regexp.MustCompile(`(?i)\s(src|href|data|action)\s*=\s*["']?(http://[^"'\s>]+)`)
+)
+
+// activeContent are the elements whose insecure loading lets a network
+// attacker take over the page. Browsers block most of them, but the page
+// is broken or relies on an old browser.
+var activeContent = map[string]bool{
+	"script": true, "iframe": true, "frame": true, "link": true, "object": true, "embed": true,
+}
+
+// MixedContent reports HTTPS pages that load resources or submit forms over
+// plain HTTP.
+type MixedContent struct{}
+
+func (MixedContent) Name() string { return "mixed-content" }
+
+func (MixedContent) Run(e *storage.Entry) []Issue {
+	if e.Scheme != "https" || !isHTML(e) {
+		return nil
+	}
+
+	var issues []Issue
+	for _, m := range refTag.FindAllStringSubmatch(body(e), -1) {
+		tag := strings.ToLower(m[1])
+		ref := insecureRef.FindStringSubmatch(m[0])
+		if ref == nil {
+			continue
+		}
+		attr := strings.ToLower(ref[1])
+		if tag == "link" && !isLoadedLink(m[0]) || attr == "href" && tag != "link" {
+			continue
+		}
+
+		issue := Issue{
+			Key:        tag + " " + ref[2],
+			Title:      "Mixed content: passive resource over HTTP",
+			Severity:   storage.SeverityLow,
+			Confidence: storage.ConfidenceCertain,
+			Evidence:   e.URL() + ": " + m[0],
+		}
+		switch {
+		case tag == "form":
+			issue.Title = "Form submits over HTTP from an HTTPS page"
+			issue.Severity = storage.SeverityMedium
+		case activeContent[tag]:
+			issue.Title = "Mixed content: active resource over HTTP"
+			issue.Severity = storage.SeverityMedium
+		}
+		issues = append(issues, issue)
+		if len(issues) == maxMixed {
+			break
+		}
+	}
+	return issues
+}
+
+// linkRel matches the rel of a <link> that the browser loads, as opposed to
+// canonical and alternate links, which are only references.
+var linkRel = regexp.MustCompile(`(?i)\srel\s*=\s*["']?[^"'>]*\b(stylesheet|icon|preload|modulepreload|prefetch|manifest)\b`)
+
+func isLoadedLink(tag string) bool {
+	return linkRel.MatchString(tag)
+}
@@ -0,0 +1,239 @@
+package scanner
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+const (
+	// minReflected is the shortest parameter value looked for; shorter
+	// values match by chance.
+	minReflected = 4
+	// maxReflections bounds the reflected parameters reported for one entry.
+	maxReflections = 20
+	// maxOccurrences bounds the occurrences of one value examined.
+	maxOccurrences = 50
+)
+
+// Reflection contexts, from most to least promising for injection.
+const (
+	ContextScript    = "script block"
+	ContextAttribute = "HTML attribute"
+	ContextHTML      = "HTML text"
+	ContextHeader    = "header"
+	ContextJSON      = "JSON string"
+	ContextText      = "text"
+)
+
+// locationPath is the location of path segment parameters, which
+// storage.ExtractParams does not report.
+const locationPath = "path"
+
+// Reflection reports request parameter values that appear in the response:
+// query, form, multipart and JSON fields, path segments and notable request
+// headers, found in the response body or headers. Each is a candidate for
+// XSS, header injection or open redirect testing, reported with the
+// context it lands in.
+type Reflection struct{}
+
+func (Reflection) Name() string { return "reflection" }
+
+func (Reflection) Run(e *storage.Entry) []Issue {
+	if e.StatusCode == 0 {
+		return nil
+	}
+	var text, lower string
+	if isText(e) {
+		text = body(e)
+		lower = asciiLower(text)
+	}
+	template := storage.NormalizePath(e.Path)
+
+	var issues []Issue
+	seen := map[string]bool{}
+	for _, p := range reflectableParams(e) {
+		if len(issues) == maxReflections {
+			break
+		}
+		key := p.Location + ":" + p.Name + "@" + e.Method + " " + template
+		if seen[key] {
+			continue
+		}
+
+		contexts, raw, snippet := reflections(e, text, lower, p.Value)
+		if len(contexts) == 0 {
+			continue
+		}
+		seen[key] = true
+
+		severity, confidence := storage.SeverityInfo, storage.ConfidenceTentative
+		switch contexts[0] {
+		case ContextScript, ContextAttribute, ContextHTML, ContextHeader:
+			severity = storage.SeverityLow
+		}
+		encoding := ""
+		if special := strings.ContainsAny(p.Value, `<>"'`); special && raw {
+			// Markup characters came back as sent: worth testing first.
+			if severity == storage.SeverityLow && contexts[0] != ContextHeader {
+				severity, confidence = storage.SeverityMedium, storage.ConfidenceFirm
+			}
+			encoding = ", unencoded"
+		} else if special {
+			encoding = ", HTML-encoded"
+		}
+
+		issues = append(issues, Issue{
+			Key:        key,
+			Title:      fmt.Sprintf("Reflected %s parameter %q", p.Location, p.Name),
+			Severity:   severity,
+			Confidence: confidence,
+			Evidence: fmt.Sprintf("%s=%s reflected in %s%s: %s",
+				p.Name, truncate(p.Value, 60), strings.Join(contexts, ", "), encoding, snippet),
+		})
+	}
+	return issues
+}
+
+// reflectableParams returns the parameters of e's request worth looking for
+// in the response. Cookies and credentials are left out: they are echoed
+// for reasons unrelated to user input and are not attacker-controlled.
+func reflectableParams(e *storage.Entry) []storage.Param {
+	var params []storage.Param
+	for _, p := range storage.ExtractParams(e) {
+		if p.Location == storage.LocationCookie || p.Name == "Authorization" {
+			continue
+		}
+		if reflectable(p.Value) {
+			params = append(params, p)
+		}
+	}
+
+	// Path segments that vary, such as IDs, tokens and email addresses.
+	// Fixed segments are left out: pages link to themselves.
+	segments := strings.Split(strings.Trim(e.Path, "/"), "/")
+	template := strings.Split(strings.Trim(storage.NormalizePath(e.Path), "/"), "/")
+	for i, seg := range segments {
+		if i >= len(template) || seg == template[i] {
+			continue
+		}
+		if v, err := url.PathUnescape(seg); err == nil && reflectable(v) {
+			params = append(params, storage.Param{Location: locationPath, Name: "segment " + strconv.Itoa(i+1), Value: v})
+		}
+	}
+	return params
+}
+
+func reflectable(v string) bool {
+	if len(v) < minReflected {
+		return false
+	}
+	switch strings.ToLower(v) {
+	case "true", "false", "null", "undefined", "http", "https":
+		return false
+	}
+	return true
+}
+
+// reflections returns the contexts value appears in within the response of
+// e, in order of interest; whether it appears as sent rather than only
+// HTML-encoded; and a snippet around the first occurrence. lower is text
+// in lower case.
+func reflections(e *storage.Entry, text, lower, value string) (contexts []string, raw bool, snippet string) {
+	add := func(c string) {
+		if !slices.Contains(contexts, c) {
+			contexts = append(contexts, c)
+		}
+	}
+
+	for _, name := range sortedKeys(e.ResponseHeaders) {
+		if strings.HasPrefix(name, "Access-Control-") || name == "Vary" {
+			continue // reported by the cors check
+		}
+		for _, v := range e.ResponseHeaders[name] {
+			if strings.Contains(v, value) {
+				add(ContextHeader)
+				raw = true
+				if snippet == "" {
+					snippet = name + ": " + truncate(v, 120)
+				}
+			}
+		}
+	}
+
+	if text != "" {
+		needles := []string{value}
+		if escaped := html.EscapeString(value); escaped != value {
+			needles = append(needles, escaped)
+		}
+		for n, needle := range needles {
+			from := 0
+			for range maxOccurrences {
+				i := strings.Index(text[from:], needle)
+				if i < 0 {
+					break
+				}
+				i += from
+				add(bodyContext(e, lower, i))
+				raw = raw || n == 0
+				if snippet == "" {
+					snippet = truncate(lineAround(text, i, i+len(needle)), 150)
+				}
+				from = i + len(needle)
+			}
+		}
+	}
+
+	slices.SortStableFunc(contexts, func(a, b string) int {
+		return contextRank(a) - contextRank(b)
+	})
+	return contexts, raw, snippet
+}
+
+var contextOrder = []string{ContextScript, ContextAttribute, ContextHTML, ContextHeader, ContextJSON, ContextText}
+
+func contextRank(c string) int {
+	return slices.Index(contextOrder, c)
+}
+
+// bodyContext classifies the position i in the response body of e, given
+// in lower case.
+func bodyContext(e *storage.Entry, lower string, i int) string {
+	mt := mediaType(e)
+	switch {
+	case strings.HasSuffix(mt, "json"):
+		return ContextJSON
+	case strings.Contains(mt, "javascript"):
+		return ContextScript
+	case !isHTML(e) && mt != "":
+		return ContextText
+	}
+
+	before := lower[:i]
+	if open := strings.LastIndex(before, "<script"); open >= 0 && open > strings.LastIndex(before, "</script") {
+		if strings.Contains(before[open:], ">") { // past the opening tag
+			return ContextScript
+		}
+	}
+	if strings.LastIndexByte(before, '<') > strings.LastIndexByte(before, '>') {
+		return ContextAttribute
+	}
+	return ContextHTML
+}
+
+// asciiLower lower-cases the ASCII letters in s, keeping byte offsets the
+// same, unlike strings.ToLower.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,79 @@
+package scanner
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+func TestReflection(t *testing.T) {
+	body := `<html><head><script>var q = "shoes<b>";</script></head>
+<body><input name="q" value="shoes&lt;b&gt;">
+<h1>Results for shoes<b></h1>
+<a href="/users/48213/orders">orders</a></body></html>`
+	e := htmlEntry(http.Header{"Location": {"/next?ref=summer-sale"}}, body)
+	e.Path = "/users/48213/search"
+	e.Query = "q=shoes%3Cb%3E&ref=summer-sale&page=1"
+	e.RequestHeaders.Set("Cookie", "session=abcdef123456")
+	e.RequestHeaders.Set("Referer", "https://elsewhere.example/")
+
+	issues := Reflection{}.Run(e)
+	byTitle := map[string]Issue{}
+	for _, i := range issues {
+		byTitle[i.Title] = i
+	}
+	if len(issues) != 3 {
+		t.Fatalf("issues = %+v, want q, ref and the path segment", issues)
+	}
+
+	q := byTitle[`Reflected query parameter "q"`]
+	if q.Severity != storage.SeverityMedium || q.Confidence != storage.ConfidenceFirm {
+		t.Errorf("q = %s/%s, want medium/firm for unencoded markup", q.Severity, q.Confidence)
+	}
+	if !strings.Contains(q.Evidence, "reflected in script block, HTML attribute, HTML text, unencoded") {
+		t.Errorf("q evidence = %s", q.Evidence)
+	}
+	if !strings.HasPrefix(q.Key, "query:q@GET /users/{id}/search") {
+		t.Errorf("q key = %s", q.Key)
+	}
+
+	ref := byTitle[`Reflected query parameter "ref"`]
+	if ref.Severity != storage.SeverityLow || !strings.Contains(ref.Evidence, "reflected in header: Location: /next?ref=summer-sale") {
+		t.Errorf("ref = %+v", ref)
+	}
+
+	if seg, ok := byTitle[`Reflected path parameter "segment 2"`]; !ok || !strings.Contains(seg.Evidence, "HTML attribute") {
+		t.Errorf("path segment = %+v", seg)
+	}
+}
+
+func TestReflectionContexts(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{"application/json", `{"name":"alice-smith"}`, "JSON string"},
+		{"text/plain", "hello alice-smith", "text"},
+		{"application/javascript", `var u = "alice-smith";`, "script block"},
+		{"text/html", `<p>alice-smith</p>`, "HTML text"},
+		{"text/html", `<script src="x.js"></script><p title='alice-smith'>`, "HTML attribute"},
+		{"text/html", `<p>ALICE-SMITH</p>`, ""},
+		{"image/png", `alice-smith`, ""},
+	}
+	for _, tt := range tests {
+		e := htmlEntry(http.Header{"Content-Type": {tt.contentType}}, tt.body)
+		e.Query = "name=alice-smith&id=1"
+		issues := Reflection{}.Run(e)
+		got := ""
+		if len(issues) == 1 {
+			_, got, _ = strings.Cut(issues[0].Evidence, "reflected in ")
+			got, _, _ = strings.Cut(got, ":")
+		}
+		if got != tt.want || len(issues) > 1 {
+			t.Errorf("%s %q: context %q, want %q", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
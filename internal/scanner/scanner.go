@@ -0,0 +1,137 @@
+// Package scanner passively analyses recorded traffic for security issues.
+// Each check inspects one entry at a time and never sends requests.
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// maxBody bounds the part of a body the checks search.
+const maxBody = 1 << 20
+
+// maxEvidence bounds the length of a finding's evidence.
+const maxEvidence = 300
+
+// Check looks for one class of issue in an entry.
+type Check interface {
+	// Name identifies the check in findings, e.g. "cors".
+	Name() string
+	// Run returns the issues found in e, if any.
+	Run(e *storage.Entry) []Issue
+}
+
+// Issue is a problem a check found. Key distinguishes the issues a check
+// can find on a host: the same key seen again, in any entry, is the same
+// issue. A Global issue, such as a leaked secret, is the same on any host.
+type Issue struct {
+	Key        string
+	Global     bool
+	Title      string
+	Severity   string
+	Confidence string
+	Evidence   string
+}
+
+// Builtin returns the built-in checks, with the default secret rules.
+func Builtin() []Check {
+	return []Check{
+		SecurityHeaders{},
+		CookieFlags{},
+		CORS{},
+		VerboseErrors{},
+		MixedContent{},
+		Reflection{},
+		defaultSecrets,
+	}
+}
+
+// Scan runs checks against e and returns the findings, ready to save.
+func Scan(e *storage.Entry, checks []Check) []*storage.Finding {
+	var findings []*storage.Finding
+	for _, c := range checks {
+		for _, issue := range c.Run(e) {
+			host := e.Host
+			if issue.Global {
+				host = ""
+			}
+			findings = append(findings, &storage.Finding{
+				Fingerprint: Fingerprint(c.Name(), host, issue.Key),
+				Check:       c.Name(),
+				Title:       issue.Title,
+				Severity:    issue.Severity,
+				Confidence:  issue.Confidence,
+				Host:        e.Host,
+				EntryID:     e.ID,
+				Evidence:    truncate(issue.Evidence, maxEvidence),
+				LastSeen:    e.Timestamp,
+			})
+		}
+	}
+	return findings
+}
+
+// Fingerprint identifies an issue found by a check on a host.
+func Fingerprint(check, host, key string) string {
+	sum := sha256.Sum256([]byte(check + "\x00" + host + "\x00" + key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// mediaType returns the media type of a response, lower case and without
+// parameters.
+func mediaType(e *storage.Entry) string {
+	ct := e.ResponseHeaders.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err == nil {
+		return mt
+	}
+	return strings.ToLower(strings.TrimSpace(strings.Split(ct, ";")[0]))
+}
+
+func isHTML(e *storage.Entry) bool {
+	mt := mediaType(e)
+	return mt == "text/html" || mt == "application/xhtml+xml"
+}
+
+// isText reports whether a response body is worth searching as text.
+func isText(e *storage.Entry) bool {
+	mt := mediaType(e)
+	return mt == "" || strings.HasPrefix(mt, "text/") || strings.HasSuffix(mt, "json") ||
+		strings.HasSuffix(mt, "xml") || strings.Contains(mt, "javascript")
+}
+
+// body returns the response body, cut to maxBody.
+func body(e *storage.Entry) string {
+	b := e.ResponseBody
+	if len(b) > maxBody {
+		b = b[:maxBody]
+	}
+	return string(b)
+}
+
+func sortedKeys(h http.Header) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// truncate cuts s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	n -= 3
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
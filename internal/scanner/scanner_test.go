@@ -0,0 +1,205 @@
+package scanner
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+func htmlEntry(headers http.Header, body string) *storage.Entry {
+	if headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", "text/html; charset=utf-8")
+	}
+	return &storage.Entry{
+		ID:              7,
+		Method:          "GET",
+		Scheme:          "https",
+		Host:            "shop.acme.com",
+		Path:            "/account",
+		RequestHeaders:  http.Header{},
+		StatusCode:      200,
+		ResponseHeaders: headers,
+		ResponseBody:    []byte(body),
+	}
+}
+
+// keys returns the keys of issues, sorted as found.
+func keys(issues []Issue) string {
+	var out []string
+	for _, i := range issues {
+		out = append(out, i.Key)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	e := htmlEntry(http.Header{}, "<html></html>")
+	if got := keys(SecurityHeaders{}.Run(e)); got != "hsts-missing,csp-missing,framing,nosniff" {
+		t.Errorf("bare page: %s", got)
+	}
+
+	e = htmlEntry(http.Header{
+		"Strict-Transport-Security": {"max-age=63072000; includeSubDomains"},
+		"Content-Security-Policy":   {"default-src 'self'; script-src 'self' 'nonce-abc' 'unsafe-inline'; frame-ancestors 'none'"},
+		"X-Content-Type-Options":    {"nosniff"},
+	}, "")
+	if issues := (SecurityHeaders{}).Run(e); len(issues) != 0 {
+		t.Errorf("hardened page: %s", keys(issues))
+	}
+
+	e = htmlEntry(http.Header{
+		"Strict-Transport-Security": {"max-age=3600"},
+		"Content-Security-Policy":   {"default-src 'self' 'unsafe-inline'"},
+		"X-Frame-Options":           {"DENY"},
+		"X-Content-Type-Options":    {"nosniff"},
+	}, "")
+	issues := SecurityHeaders{}.Run(e)
+	if got := keys(issues); got != "hsts-weak,csp-weak" {
+		t.Fatalf("weak headers: %s", got)
+	}
+	if issues[1].Evidence != "default-src 'self' 'unsafe-inline'" {
+		t.Errorf("csp evidence = %q", issues[1].Evidence)
+	}
+
+	// JSON responses and plain HTTP only get the checks that apply.
+	e = htmlEntry(http.Header{"Content-Type": {"application/json"}}, "{}")
+	e.Scheme = "http"
+	if issues := (SecurityHeaders{}).Run(e); len(issues) != 0 {
+		t.Errorf("http json: %s", keys(issues))
+	}
+}
+
+func TestCookieFlags(t *testing.T) {
+	e := htmlEntry(http.Header{"Set-Cookie": {
+		"SESSIONID=abcdef123456; Path=/",
+		"theme=dark; Path=/; Secure",
+		"tracking=1; SameSite=None",
+		"sid=; Max-Age=0",
+		"auth_token=xyz12345; Secure; HttpOnly; SameSite=Lax",
+	}}, "")
+	issues := CookieFlags{}.Run(e)
+	want := "SESSIONID:secure,SESSIONID:httponly,SESSIONID:samesite,tracking:secure,tracking:samesite-none"
+	if got := keys(issues); got != want {
+		t.Fatalf("keys = %s, want %s", got, want)
+	}
+	if issues[0].Severity != storage.SeverityMedium || issues[3].Severity != storage.SeverityLow {
+		t.Errorf("secure severities = %s, %s; want medium for a session cookie, low otherwise", issues[0].Severity, issues[3].Severity)
+	}
+	if strings.Contains(issues[0].Evidence, "abcdef123456") || !strings.Contains(issues[0].Evidence, "Path=/") {
+		t.Errorf("evidence not redacted: %q", issues[0].Evidence)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		origin, acao, creds string
+		key, severity       string
+	}{
+		{"https://evil.example", "https://evil.example", "true", "reflected", storage.SeverityMedium},
+		{"https://app.acme.com", "https://app.acme.com", "true", "", ""},
+		{"", "null", "true", "null", storage.SeverityHigh},
+		{"", "*", "", "wildcard", storage.SeverityInfo},
+		{"", "http://acme.com", "", "insecure-origin", storage.SeverityLow},
+		{"", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		e := htmlEntry(http.Header{}, "")
+		if tt.origin != "" {
+			e.RequestHeaders.Set("Origin", tt.origin)
+		}
+		if tt.acao != "" {
+			e.ResponseHeaders.Set("Access-Control-Allow-Origin", tt.acao)
+		}
+		if tt.creds != "" {
+			e.ResponseHeaders.Set("Access-Control-Allow-Credentials", tt.creds)
+		}
+		issues := CORS{}.Run(e)
+		if keys(issues) != tt.key || (len(issues) > 0 && issues[0].Severity != tt.severity) {
+			t.Errorf("origin %q acao %q: %+v, want %s %s", tt.origin, tt.acao, issues, tt.key, tt.severity)
+		}
+	}
+}
+
+func TestVerboseErrors(t *testing.T) {
+	tests := map[string]string{
+		"java":       "java.lang.NullPointerException\n\tat com.acme.shop.Cart.total(Cart.java:42)\n",
+		"python":     "Traceback (most recent call last):\n  File \"app.py\", line 3",
+		"node":       "TypeError: x is undefined\n    at Object.<anonymous> (/srv/app/index.js:12:5)\n",
+		"go":         "panic: boom\n\ngoroutine 1 [running]:\nmain.main()",
+		"sql":        "<p>You have an error in your SQL syntax; check the manual</p>",
+		"debug-page": "<title>Werkzeug Debugger</title>",
+		"":           "<p>Something went wrong. Please try again.</p>",
+	}
+	for want, body := range tests {
+		e := htmlEntry(http.Header{}, body)
+		e.StatusCode = 500
+		issues := VerboseErrors{}.Run(e)
+		got := ""
+		if len(issues) > 0 {
+			got, _, _ = strings.Cut(issues[0].Key, " ")
+		}
+		if got != want || len(issues) > 1 {
+			t.Errorf("%s: got %s", want, keys(issues))
+		}
+	}
+
+	e := htmlEntry(http.Header{"Content-Type": {"image/png"}}, "Traceback (most recent call last):")
+	if issues := (VerboseErrors{}).Run(e); len(issues) != 0 {
+		t.Errorf("binary response scanned: %s", keys(issues))
+	}
+}
+
+func TestMixedContent(t *testing.T) {
+	body := `<html><head>
+<link href="http://cdn.acme.com/site.css" rel="stylesheet">
+<link rel="canonical" href="http://acme.com/account">
+<script src="http://cdn.acme.com/app.js"></script>
+<script src="https://cdn.acme.com/ok.js"></script>
+</head><body>
+<a href="http://elsewhere.com/">link</a>
+<img src='http://img.acme.com/logo.png'>
+<form method="post" action="http://acme.com/login"></form>
+</body></html>`
+	issues := MixedContent{}.Run(htmlEntry(http.Header{}, body))
+	want := "link http://cdn.acme.com/site.css,script http://cdn.acme.com/app.js,img http://img.acme.com/logo.png,form http://acme.com/login"
+	if got := keys(issues); got != want {
+		t.Fatalf("keys = %s, want %s", got, want)
+	}
+	if issues[1].Severity != storage.SeverityMedium || issues[2].Severity != storage.SeverityLow {
+		t.Errorf("severities = %s, %s; want medium for script, low for img", issues[1].Severity, issues[2].Severity)
+	}
+
+	plain := htmlEntry(http.Header{}, body)
+	plain.Scheme = "http"
+	if issues := (MixedContent{}).Run(plain); len(issues) != 0 {
+		t.Errorf("http page: %s", keys(issues))
+	}
+}
+
+func TestScanFingerprints(t *testing.T) {
+	a := htmlEntry(http.Header{}, "")
+	b := htmlEntry(http.Header{}, "")
+	b.ID, b.Path = 8, "/cart"
+
+	fa, fb := Scan(a, Builtin()), Scan(b, Builtin())
+	if len(fa) == 0 || len(fa) != len(fb) {
+		t.Fatalf("findings: %d and %d", len(fa), len(fb))
+	}
+	// Missing headers on two pages of a host are the same findings.
+	for i := range fa {
+		if fa[i].Fingerprint != fb[i].Fingerprint {
+			t.Errorf("%s: fingerprints differ between pages", fa[i].Title)
+		}
+		if fa[i].EntryID != 7 || fa[i].Host != "shop.acme.com" || fa[i].Check != "security-headers" {
+			t.Errorf("finding = %+v", fa[i])
+		}
+	}
+
+	other := htmlEntry(http.Header{}, "")
+	other.Host = "api.acme.com"
+	if fo := Scan(other, Builtin()); fo[0].Fingerprint == fa[0].Fingerprint {
+		t.Error("fingerprints equal across hosts")
+	}
+}
@@ -0,0 +1,312 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// maxSecrets bounds the secrets reported for one entry.
+const maxSecrets = 20
+
+// SecretRule detects one kind of secret. The pattern's group named
+// "secret", or the whole match if it has none, is the secret: it is what
+// MinEntropy applies to, what is redacted in evidence, and what identifies
+// the finding.
+type SecretRule struct {
+	ID         string  `json:"id"`
+	Title      string  `json:"title,omitempty"`
+	Severity   string  `json:"severity,omitempty"`   // default medium
+	Confidence string  `json:"confidence,omitempty"` // default firm
+	Pattern    string  `json:"pattern,omitempty"`
+	MinEntropy float64 `json:"min_entropy,omitempty"` // Shannon entropy, in bits per character
+	Disabled   bool    `json:"disabled,omitempty"`
+
+	re     *regexp.Regexp
+	secret int // index of the secret group, 0 for the whole match
+}
+
+// DefaultSecretRules are the rules used unless configured otherwise. Rules
+// are tried in order, and a secret overlapping one found by an earlier rule
+// is not reported again, so the specific rules come first.
+var DefaultSecretRules = []SecretRule{
+	{ID: "aws-access-key", Title: "AWS access key ID", Severity: storage.SeverityHigh,
+		Pattern: `\b(?P<secret>(?:AKIA|ASIA)[0-9A-Z]{16})\b`},
+	{ID: "aws-secret-key", Title: "AWS secret access key", Severity: storage.SeverityCritical,
+		Pattern: `(?i)aws.{0,20}?(?:secret|private).{0,20}?["'=:\s]+(?P<secret>[A-Za-z0-9/+]{40})\b`, MinEntropy: 4},
+	{ID: "gcp-api-key", Title: "Google API key", Severity: storage.SeverityMedium,
+		Pattern: `\b(?P<secret>AIza[0-9A-Za-z_\-]{35})\b`},
+	{ID: "github-token", Title: "GitHub token", Severity: storage.SeverityHigh,
+		Pattern: `\b(?P<secret>(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`},
+	{ID: "gitlab-token", Title: "GitLab personal access token", Severity: storage.SeverityHigh,
+		Pattern: `\b(?P<secret>glpat-[A-Za-z0-9_\-]{20})\b`},
+	{ID: "slack-token", Title: "Slack token", Severity: storage.SeverityHigh,
+		Pattern: `\b(?P<secret>xox[abposr]-[A-Za-z0-9-]{10,})\b`},
+	{ID: "slack-webhook", Title: "Slack webhook URL", Severity: storage.SeverityMedium,
+		Pattern: `(?P<secret>https://hooks\.slack\.com/services/T[A-Za-z0-9_]+/B[A-Za-z0-9_]+/[A-Za-z0-9_]+)`},
+	{ID: "stripe-secret-key", Title: "Stripe live secret key", Severity: storage.SeverityCritical,
+		Pattern: `\b(?P<secret>(?:sk|rk)_live_[A-Za-z0-9]{20,})\b`},
+	{ID: "sendgrid-api-key", Title: "SendGrid API key", Severity: storage.SeverityHigh,
+		Pattern: `\b(?P<secret>SG\.[A-Za-z0-9_\-]{22}\.[A-Za-z0-9_\-]{43})\b`},
+	{ID: "twilio-api-key", Title: "Twilio API key", Severity: storage.SeverityMedium,
+		Pattern: `\b(?P<secret>SK[0-9a-f]{32})\b`, MinEntropy: 3},
+	{ID: "private-key", Title: "Private key", Severity: storage.SeverityCritical,
+		Pattern: `-----BEGIN [A-Z ]*PRIVATE KEY(?: BLOCK)?-----(?P<secret>[A-Za-z0-9+/=\s\\:,-]{64,}?)-----END`},
+	{ID: "jwt", Title: "JSON Web Token", Severity: storage.SeverityLow,
+		Pattern: `\b(?P<secret>eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`},
+	{ID: "bearer-token", Title: "Bearer token", Severity: storage.SeverityMedium,
+		Pattern: `(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]{20,}=*)`, MinEntropy: 3.5},
+	{ID: "url-credentials", Title: "Credentials in URL", Severity: storage.SeverityMedium,
+		Pattern: `\b[a-zA-Z][a-zA-Z0-9+.-]*://(?P<secret>[^/\s:@"'<>]+:[^/\s:@"'<>]+)@[A-Za-z0-9.-]+`, MinEntropy: 2.5},
+	{ID: "generic-secret", Title: "Possible secret in assignment", Confidence: storage.ConfidenceTentative,
+		Pattern:    `(?i)(?:api[_-]?key|apikey|secret|token|passwd|password|access[_-]?key|auth[_-]?key)["']?\s*[:=]\s*["'](?P<secret>[A-Za-z0-9+/_\-.=]{16,})["']`,
+		MinEntropy: 3.5},
+	// Standalone tokens of 32 to 256 base64 or 40 to 256 hex characters,
+	// with neither the token alphabet nor a dot next to them: a longer run
+	// is data rather than a token, and a dot joins the parts of a JWT or a
+	// file name. Random base64 averages about 4.6 bits per character at 32
+	// characters and random hex about 3.7 at 40; identifiers and paths stay
+	// near 4.2, and MD5 hashes such as ETags are too short for the hex rule.
+	{ID: "high-entropy-base64", Title: "Possible secret: high-entropy base64 token", Severity: storage.SeverityLow, Confidence: storage.ConfidenceTentative,
+		Pattern:    `(?:^|[^A-Za-z0-9+/_.\-])(?P<secret>[A-Za-z0-9+/_\-]{32,256}={0,2})(?:$|[^A-Za-z0-9+/=_.\-])`,
+		MinEntropy: 4.3},
+	{ID: "high-entropy-hex", Title: "Possible secret: high-entropy hex token", Severity: storage.SeverityLow, Confidence: storage.ConfidenceTentative,
+		Pattern:    `(?:^|[^A-Za-z0-9+/_.\-])(?P<secret>[0-9A-Fa-f]{40,256})(?:$|[^A-Za-z0-9+/=_.\-])`,
+		MinEntropy: 3.5},
+}
+
+var defaultSecrets = func() *Secrets {
+	s, err := NewSecrets(DefaultSecretRules)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+// LoadSecretRules returns DefaultSecretRules combined with the rules in a
+// JSON file holding an array of rules. A rule with the ID of a default rule
+// replaces it, keeping any field it leaves empty; other rules are added.
+// {"id": "jwt", "disabled": true} turns a default rule off.
+func LoadSecretRules(path string) ([]SecretRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading secret rules: %w", err)
+	}
+	var custom []SecretRule
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("parsing secret rules %s: %w", path, err)
+	}
+
+	rules := slices.Clone(DefaultSecretRules)
+	for _, c := range custom {
+		if c.ID == "" {
+			return nil, fmt.Errorf("secret rule without an id in %s", path)
+		}
+		i := slices.IndexFunc(rules, func(r SecretRule) bool { return r.ID == c.ID })
+		if i < 0 {
+			rules = append(rules, c)
+			continue
+		}
+		r := &rules[i]
+		if c.Title != "" {
+			r.Title = c.Title
+		}
+		if c.Severity != "" {
+			r.Severity = c.Severity
+		}
+		if c.Confidence != "" {
+			r.Confidence = c.Confidence
+		}
+		if c.Pattern != "" {
+			r.Pattern = c.Pattern
+		}
+		if c.MinEntropy != 0 {
+			r.MinEntropy = c.MinEntropy
+		}
+		r.Disabled = c.Disabled
+	}
+	if _, err := NewSecrets(rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Secrets reports API keys, cloud credentials, private keys, tokens and
+// other secrets in request URLs, response headers and response bodies.
+// A secret is one finding wherever it is seen.
+type Secrets struct {
+	rules []SecretRule
+}
+
+// NewSecrets returns a secrets check using the enabled rules.
+func NewSecrets(rules []SecretRule) (*Secrets, error) {
+	s := &Secrets{}
+	for _, r := range rules {
+		if r.Disabled {
+			continue
+		}
+		if r.Pattern == "" {
+			return nil, fmt.Errorf("secret rule %s: pattern is required", r.ID)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("secret rule %s: %w", r.ID, err)
+		}
+		if r.Severity == "" {
+			r.Severity = storage.SeverityMedium
+		} else if !slices.Contains(storage.Severities, r.Severity) {
+			return nil, fmt.Errorf("secret rule %s: unknown severity %q", r.ID, r.Severity)
+		}
+		if r.Confidence == "" {
+			r.Confidence = storage.ConfidenceFirm
+		}
+		if r.Title == "" {
+			r.Title = r.ID
+		}
+		r.re = re
+		r.secret = max(re.SubexpIndex("secret"), 0)
+		s.rules = append(s.rules, r)
+	}
+	return s, nil
+}
+
+func (*Secrets) Name() string { return "secrets" }
+
+func (s *Secrets) Run(e *storage.Entry) []Issue {
+	var issues []Issue
+	seen := map[string]bool{}
+	scan := func(location, text string, lines bool) {
+		var found [][2]int // secrets found in text by earlier rules
+		for _, r := range s.rules {
+			n := len(found)
+			for _, m := range r.re.FindAllStringSubmatchIndex(text, -1) {
+				if len(issues) == maxSecrets {
+					return
+				}
+				start, end := m[2*r.secret], m[2*r.secret+1]
+				if start < 0 {
+					continue
+				}
+				secret := text[start:end]
+				if entropy(secret) < r.MinEntropy {
+					continue
+				}
+				if slices.ContainsFunc(found[:n], func(f [2]int) bool { return start < f[1] && f[0] < end }) {
+					continue
+				}
+				found = append(found, [2]int{start, end})
+				sum := sha256.Sum256([]byte(secret))
+				hash := hex.EncodeToString(sum[:])
+				if seen[hash] {
+					continue
+				}
+				seen[hash] = true
+
+				where := location
+				if lines {
+					where += " line " + strconv.Itoa(strings.Count(text[:start], "\n")+1)
+				}
+				match := text[m[0]:start] + hide(secret) + text[end:m[1]]
+				issues = append(issues, Issue{
+					Key:        hash,
+					Global:     true,
+					Title:      r.Title,
+					Severity:   r.Severity,
+					Confidence: r.Confidence,
+					Evidence:   where + ": " + truncate(match, 200),
+				})
+			}
+		}
+	}
+
+	scan("request URL", e.URL(), false)
+	for _, name := range sortedKeys(e.ResponseHeaders) {
+		for _, v := range e.ResponseHeaders[name] {
+			scan("response header "+name, v, false)
+		}
+	}
+	if len(e.ResponseBody) > 0 && isText(e) {
+		scan("response body", body(e), true)
+	}
+	return issues
+}
+
+// RedactSecrets hides the secrets the default rules find in text.
+func RedactSecrets(text string) string {
+	return defaultSecrets.Redact(text)
+}
+
+// Redact hides the secrets s finds in text, keeping the text around them.
+func (s *Secrets) Redact(text string) string {
+	type span struct{ start, end int }
+	var spans []span
+	for _, r := range s.rules {
+		for _, m := range r.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[2*r.secret], m[2*r.secret+1]
+			if start >= 0 && entropy(text[start:end]) >= r.MinEntropy {
+				spans = append(spans, span{start, end})
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return text
+	}
+	slices.SortFunc(spans, func(a, b span) int { return a.start - b.start })
+
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(spans); {
+		// Merge secrets found by several rules, e.g. a JWT that is also a
+		// bearer token.
+		sp := spans[i]
+		for i++; i < len(spans) && spans[i].start < sp.end; i++ {
+			sp.end = max(sp.end, spans[i].end)
+		}
+		if sp.start < last {
+			continue
+		}
+		b.WriteString(text[last:sp.start])
+		b.WriteString(hide(text[sp.start:sp.end]))
+		last = sp.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// hide hides all but the start of a secret.
+func hide(secret string) string {
+	secret = strings.TrimSpace(secret)
+	show := 0
+	if len(secret) >= 12 {
+		show = 4
+	}
+	return secret[:show] + strings.Repeat("*", 8) + fmt.Sprintf(" (%d chars)", len(secret))
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,179 @@
+package snippet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func python(r *request) string {
+	var b strings.Builder
+	b.WriteString("import requests\n\nresponse = requests.request(\n")
+	fmt.Fprintf(&b, "    %s,\n    %s,\n", pyString(r.method), pyString(r.url))
+	if len(r.headers) > 0 {
+		b.WriteString("    headers={\n")
+		for _, h := range r.headers {
+			fmt.Fprintf(&b, "        %s: %s,\n", pyString(h.name), pyString(h.joined()))
+		}
+		b.WriteString("    },\n")
+	}
+	// requests encodes str bodies as Latin-1, so bodies are always bytes.
+	switch {
+	case len(r.body) == 0:
+	case r.binary || isASCII(r.body):
+		fmt.Fprintf(&b, "    data=%s,\n", pyBytes(r.body))
+	default:
+		fmt.Fprintf(&b, "    data=%s.encode(),\n", pyString(string(r.body)))
+	}
+	b.WriteString(")\nprint(response.status_code)\nprint(response.text)\n")
+	return b.String()
+}
+
+// pyString quotes s, which must be valid UTF-8, as a Python string literal.
+func pyString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x80:
+			writePyByte(&b, byte(r))
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\U%08x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// pyBytes quotes data as a Python bytes literal.
+func pyBytes(data []byte) string {
+	var b strings.Builder
+	b.WriteString(`b"`)
+	for _, c := range data {
+		if c == '"' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		writePyByte(&b, c)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func writePyByte(b *strings.Builder, c byte) {
+	switch {
+	case c == '\n':
+		b.WriteString(`\n`)
+	case c == '\r':
+		b.WriteString(`\r`)
+	case c == '\t':
+		b.WriteString(`\t`)
+	case c < 0x20 || c >= 0x7f:
+		fmt.Fprintf(b, `\x%02x`, c)
+	default:
+		b.WriteByte(c)
+	}
+}
+
+func isASCII(body []byte) bool {
+	for _, c := range body {
+		if c >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func goProgram(r *request) string {
+	var b strings.Builder
+	b.WriteString("package main\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\t\"net/http\"\n")
+	if len(r.body) > 0 {
+		b.WriteString("\t\"strings\"\n")
+	}
+	b.WriteString(")\n\nfunc main() {\n")
+	body := "nil"
+	if len(r.body) > 0 {
+		fmt.Fprintf(&b, "body := strings.NewReader(%s)\n", goString(r.body))
+		body = "body"
+	}
+	fmt.Fprintf(&b, "req, err := http.NewRequest(%s, %s, %s)\n", strconv.Quote(r.method), strconv.Quote(r.url), body)
+	b.WriteString("if err != nil {\npanic(err)\n}\n")
+	for _, h := range r.headers {
+		for _, v := range h.values {
+			fmt.Fprintf(&b, "req.Header.Add(%s, %s)\n", strconv.Quote(h.name), strconv.Quote(v))
+		}
+	}
+	b.WriteString(`resp, err := http.DefaultClient.Do(req)
+if err != nil {
+panic(err)
+}
+defer resp.Body.Close()
+data, err := io.ReadAll(resp.Body)
+if err != nil {
+panic(err)
+}
+fmt.Println(resp.Status)
+fmt.Println(string(data))
+}
+`)
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		// Everything above is quoted, so this is a bug here, not in the
+		// entry; the unformatted program is still worth returning.
+		return b.String()
+	}
+	return string(src)
+}
+
+// goString quotes body as a Go string literal, as a raw string when that
+// keeps a multi-line text body readable.
+func goString(body []byte) string {
+	s := string(body)
+	if strings.Contains(s, "\n") && !strings.ContainsAny(s, "`\r") && isText(body) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+func fetch(r *request) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "const response = await fetch(%s, {\n", jsString(r.url))
+	fmt.Fprintf(&b, "  method: %s,\n", jsString(r.method))
+	if len(r.headers) > 0 {
+		b.WriteString("  headers: {\n")
+		for _, h := range r.headers {
+			fmt.Fprintf(&b, "    %s: %s,\n", jsString(h.name), jsString(h.joined()))
+		}
+		b.WriteString("  },\n")
+	}
+	switch {
+	case len(r.body) == 0:
+	case r.binary:
+		b.WriteString("  body: new Uint8Array([\n")
+		writeByteList(&b, r.body, "    ", "0x%02x")
+		b.WriteString("  ]),\n")
+	default:
+		fmt.Fprintf(&b, "  body: %s,\n", jsString(string(r.body)))
+	}
+	b.WriteString("});\nconsole.log(response.status);\nconsole.log(await response.text());\n")
+	return b.String()
+}
+
+// jsString quotes s as a JavaScript string literal. JSON strings are valid
+// JavaScript; the encoder also escapes U+2028 and U+2029, which older
+// engines reject in literals.
+func jsString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,200 @@
+package snippet
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// continuation joins the arguments of a shell command, one per line.
+const continuation = " \\\n  "
+
+func curl(r *request) string {
+	first := []string{"curl"}
+	switch {
+	case r.method == http.MethodHead:
+		first = append(first, "--head")
+	case r.method == http.MethodGet && len(r.body) == 0:
+	case r.method == http.MethodPost && len(r.body) > 0:
+	default:
+		first = append(first, "-X "+shellQuote(r.method))
+	}
+	if strings.Contains(r.url, "/.") {
+		// Keep ../ and ./ segments, which curl would otherwise resolve.
+		first = append(first, "--path-as-is")
+	}
+	args := []string{strings.Join(append(first, shellQuote(r.url)), " ")}
+	for _, h := range r.headers {
+		for _, v := range h.values {
+			args = append(args, "-H "+shellQuote(curlHeader(h.name, v)))
+		}
+	}
+	if r.gzip {
+		args = append(args, "--compressed")
+	}
+
+	var stdin string
+	switch {
+	case len(r.body) == 0:
+	case r.binary:
+		stdin = printf(r.body)
+		args = append(args, "--data-binary @-")
+	default:
+		args = append(args, "--data-raw "+shellQuote(string(r.body)))
+	}
+	return stdin + strings.Join(args, continuation) + "\n"
+}
+
+// curlHeader formats a header for -H. An empty value is written with a
+// semicolon, since "Name:" tells curl to drop the header.
+func curlHeader(name, value string) string {
+	if value == "" {
+		return name + ";"
+	}
+	return name + ": " + value
+}
+
+func httpie(r *request) string {
+	first := []string{"http"}
+	var stdin, raw string
+	switch {
+	case len(r.body) == 0:
+		first = append(first, "--ignore-stdin")
+	case r.binary:
+		stdin = printf(r.body)
+	default:
+		first = append(first, "--ignore-stdin")
+		raw = "--raw " + shellQuote(string(r.body))
+	}
+	args := []string{strings.Join(append(first, r.method, shellQuote(r.url)), " ")}
+	for _, h := range r.headers {
+		for _, v := range h.values {
+			if v == "" {
+				args = append(args, shellQuote(h.name+";"))
+			} else {
+				args = append(args, shellQuote(h.name+":"+v))
+			}
+		}
+	}
+	if raw != "" {
+		args = append(args, raw)
+	}
+	return stdin + strings.Join(args, continuation) + "\n"
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// printf returns a printf command writing body byte for byte, piped into
+// the command that follows. Octal escapes are the only ones every printf
+// supports.
+func printf(body []byte) string {
+	var b strings.Builder
+	b.WriteString("printf '")
+	for _, c := range body {
+		switch {
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == '%':
+			b.WriteString("%%")
+		case c == '\'':
+			b.WriteString(`'\''`)
+		case c >= 0x20 && c < 0x7f:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, `\%03o`, c)
+		}
+	}
+	b.WriteString("' |" + continuation)
+	return b.String()
+}
+
+// powershellMethods are the methods Invoke-WebRequest takes with -Method;
+// others need -CustomMethod.
+var powershellMethods = map[string]string{
+	http.MethodGet:     "Get",
+	http.MethodHead:    "Head",
+	http.MethodPost:    "Post",
+	http.MethodPut:     "Put",
+	http.MethodDelete:  "Delete",
+	http.MethodTrace:   "Trace",
+	http.MethodOptions: "Options",
+	http.MethodPatch:   "Patch",
+}
+
+func powershell(r *request) string {
+	var b strings.Builder
+	args := []string{"Invoke-WebRequest", "-UseBasicParsing", "-Uri " + psQuote(r.url)}
+	if m, ok := powershellMethods[r.method]; ok {
+		args = append(args, "-Method "+m)
+	} else {
+		args = append(args, "-CustomMethod "+psQuote(r.method))
+	}
+
+	// Content-Type and User-Agent have their own parameters; Windows
+	// PowerShell rejects them in -Headers.
+	var headers []header
+	for _, h := range r.headers {
+		switch h.name {
+		case "Content-Type":
+			args = append(args, "-ContentType "+psQuote(h.joined()))
+		case "User-Agent":
+			args = append(args, "-UserAgent "+psQuote(h.joined()))
+		default:
+			headers = append(headers, h)
+		}
+	}
+	if len(headers) > 0 {
+		b.WriteString("$headers = @{\n")
+		for _, h := range headers {
+			fmt.Fprintf(&b, "    %s = %s\n", psQuote(h.name), psQuote(h.joined()))
+		}
+		b.WriteString("}\n")
+		args = append(args, "-Headers $headers")
+	}
+
+	// Bodies are passed as bytes so that PowerShell does not re-encode them.
+	switch {
+	case len(r.body) == 0:
+	case r.binary:
+		b.WriteString("$body = [byte[]] @(\n")
+		writeByteList(&b, r.body, "    ", "0x%02x")
+		b.WriteString(")\n")
+		args = append(args, "-Body $body")
+	default:
+		fmt.Fprintf(&b, "$body = [System.Text.Encoding]::UTF8.GetBytes(%s)\n", psQuote(string(r.body)))
+		args = append(args, "-Body $body")
+	}
+
+	b.WriteString("$response = " + strings.Join(args, " `\n    ") + "\n")
+	b.WriteString("$response.StatusCode\n$response.Content\n")
+	return b.String()
+}
+
+// psQuotes are the characters PowerShell accepts as a single quote, each
+// escaped by doubling it.
+var psQuotes = strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b")
+
+// psQuote quotes s as a PowerShell verbatim string.
+func psQuote(s string) string {
+	return "'" + psQuotes.Replace(s) + "'"
+}
+
+// writeByteList writes data as a comma-separated list, 16 bytes a line.
+func writeByteList(b *strings.Builder, data []byte, indent, format string) {
+	for i := 0; i < len(data); i += 16 {
+		b.WriteString(indent)
+		for j, c := range data[i:min(i+16, len(data))] {
+			if j > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(b, format, c)
+			if i+j < len(data)-1 {
+				b.WriteString(",")
+			}
+		}
+		b.WriteString("\n")
+	}
+}
@@ -0,0 +1,124 @@
+// Package snippet renders a stored request as code that reproduces it: shell
+// commands and programs in several languages, for handing to developers.
+package snippet
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+// Formats.
+const (
+	Curl           = "curl"
+	HTTPie         = "httpie"
+	PythonRequests = "python-requests"
+	Go             = "go"
+	Fetch          = "fetch"
+	PowerShell     = "powershell"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{Curl, HTTPie, PythonRequests, Go, Fetch, PowerShell}
+
+// skipHeaders are not reproduced: the host and framing come from the URL
+// and body, and the hop-by-hop headers belong to the original connection.
+var skipHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Proxy-Connection":  true,
+	"Keep-Alive":        true,
+	"Te":                true,
+	"Upgrade":           true,
+	"Accept-Encoding":   true, // the stored body is decoded; clients negotiate their own
+}
+
+type header struct {
+	name   string
+	values []string
+}
+
+// joined returns the header's values as one, for clients that take a map.
+func (h header) joined() string {
+	sep := ", "
+	if h.name == "Cookie" {
+		sep = "; "
+	}
+	return strings.Join(h.values, sep)
+}
+
+// request is the part of an entry a snippet reproduces.
+type request struct {
+	method  string
+	url     string
+	headers []header
+	body    []byte
+	binary  bool // body is not printable text
+	gzip    bool // the original asked for a compressed response
+}
+
+// Generate renders the request of e in the given format.
+func Generate(e *storage.Entry, format string) (string, error) {
+	r := newRequest(e)
+	switch format {
+	case Curl:
+		return curl(r), nil
+	case HTTPie:
+		return httpie(r), nil
+	case PythonRequests:
+		return python(r), nil
+	case Go:
+		return goProgram(r), nil
+	case Fetch:
+		return fetch(r), nil
+	case PowerShell:
+		return powershell(r), nil
+	}
+	return "", fmt.Errorf("unknown format %q: want one of %s", format, strings.Join(Formats, ", "))
+}
+
+func newRequest(e *storage.Entry) *request {
+	r := &request{
+		method: e.Method,
+		url:    e.URL(),
+		body:   e.RequestBody,
+		binary: !isText(e.RequestBody),
+		gzip:   e.RequestHeaders.Get("Accept-Encoding") != "",
+	}
+	if r.method == "" {
+		r.method = http.MethodGet
+	}
+
+	names := make([]string, 0, len(e.RequestHeaders))
+	for name := range e.RequestHeaders {
+		// Pseudo-headers are HTTP/2 framing, carried by the method and URL.
+		if !skipHeaders[http.CanonicalHeaderKey(name)] && !strings.HasPrefix(name, ":") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.headers = append(r.headers, header{name: http.CanonicalHeaderKey(name), values: e.RequestHeaders[name]})
+	}
+	return r
+}
+
+// isText reports whether body can be written as a string literal without
+// escapes beyond the usual tab, newline and carriage return.
+func isText(body []byte) bool {
+	if !utf8.Valid(body) {
+		return false
+	}
+	for _, r := range string(body) {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package snippet
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ghostsecurity/reaper/internal/storage"
+)
+
+func testEntry(body []byte) *storage.Entry {
+	return &storage.Entry{
+		Method: "PUT",
+		Scheme: "https",
+		Host:   "api.acme.com",
+		Port:   8443,
+		Path:   "/users/it's",
+		Query:  "q=a%20b",
+		RequestHeaders: http.Header{
+			"Host":            {"api.acme.com:8443"},
+			"Content-Length":  {"99"},
+			"Accept-Encoding": {"gzip"},
+			"Content-Type":    {"application/json"},
+			"Cookie":          {"a=1", "b=2"},
+			"X-Quote":         {`it's "quoted" $HOME`},
+			"X-Empty":         {""},
+		},
+		RequestBody: body,
+	}
+}
+
+func TestGenerateAll(t *testing.T) {
+	e := testEntry([]byte(`{"name":"o'brien"}`))
+	for _, format := range Formats {
+		out, err := Generate(e, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !strings.Contains(out, "api.acme.com:8443") {
+			t.Errorf("%s: port missing:\n%s", format, out)
+		}
+		if strings.Contains(out, "Content-Length") || strings.Contains(out, `"Host"`) || strings.Contains(out, "Host:") {
+			t.Errorf("%s: framing headers kept:\n%s", format, out)
+		}
+	}
+
+	if _, err := Generate(e, "wget"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestCurl(t *testing.T) {
+	out, _ := Generate(testEntry([]byte(`{"name":"o'brien"}`)), Curl)
+	for _, want := range []string{
+		`-X 'PUT'`,
+		`'https://api.acme.com:8443/users/it'\''s?q=a%20b'`,
+		`-H 'Cookie: a=1'`,
+		`-H 'Cookie: b=2'`,
+		`-H 'X-Empty;'`,
+		`-H 'X-Quote: it'\''s "quoted" $HOME'`,
+		`--compressed`,
+		`--data-raw '{"name":"o'\''brien"}'`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in:\n%s", want, out)
+		}
+	}
+}
+
+// TestCurlRuns sends snippets through curl and checks the server sees the
+// exact request.
+func TestCurlRuns(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl not installed")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+
+	var gotBody []byte
+	var gotReq *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotReq = r
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	host, portStr, _ := net.SplitHostPort(u.Host)
+	port, _ := strconv.Atoi(portStr)
+
+	for _, body := range [][]byte{
+		[]byte("line one\nit's 100% \\done\\\n"),
+		{0x00, 0xff, '\'', '%', '\\', '\n', 0x7f, 0x80, 'x'},
+	} {
+		e := testEntry(body)
+		e.Scheme, e.Host, e.Port = "http", host, port
+		out, _ := Generate(e, Curl)
+		cmd := exec.Command("sh", "-c", out)
+		if data, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("curl: %v\n%s\n%s", err, out, data)
+		}
+		if !bytes.Equal(gotBody, body) {
+			t.Errorf("body = %q, want %q\n%s", gotBody, body, out)
+		}
+		if gotReq.Method != "PUT" || gotReq.URL.RawQuery != "q=a%20b" {
+			t.Errorf("request = %s %s", gotReq.Method, gotReq.URL)
+		}
+		if got := gotReq.Header.Get("X-Quote"); got != `it's "quoted" $HOME` {
+			t.Errorf("X-Quote = %q", got)
+		}
+		if v, ok := gotReq.Header["X-Empty"]; !ok || v[0] != "" {
+			t.Errorf("X-Empty = %q, %v", v, ok)
+		}
+	}
+}
+
+func TestGoCompiles(t *testing.T) {
+	for _, body := range [][]byte{nil, []byte("a\nb `c`\n"), []byte("a\nb\n"), {0x00, 0xff, '"'}} {
+		out, _ := Generate(testEntry(body), Go)
+		if _, err := parser.ParseFile(token.NewFileSet(), "main.go", out, 0); err != nil {
+			t.Errorf("body %q: %v\n%s", body, err, out)
+		}
+		if len(body) == 0 && strings.Contains(out, `"strings"`) {
+			t.Errorf("unused strings import:\n%s", out)
+		}
+	}
+}
+
+func TestBinaryBodies(t *testing.T) {
+	e := testEntry([]byte{0x00, 'a', '"', 0xc3, 0xa9, 0xff})
+
+	out, _ := Generate(e, PythonRequests)
+	if !strings.Contains(out, `data=b"\x00a\"\xc3\xa9\xff",`) {
+		t.Errorf("python:\n%s", out)
+	}
+	out, _ = Generate(e, Fetch)
+	if !strings.Contains(out, "0x00, 0x61, 0x22, 0xc3, 0xa9, 0xff\n") {
+		t.Errorf("fetch:\n%s", out)
+	}
+	out, _ = Generate(e, PowerShell)
+	if !strings.Contains(out, "$body = [byte[]] @(\n    0x00, 0x61, 0x22, 0xc3, 0xa9, 0xff\n)") {
+		t.Errorf("powershell:\n%s", out)
+	}
+
+	// Non-ASCII text is encoded explicitly rather than left to requests,
+	// which would use Latin-1.
+	out, _ = Generate(testEntry([]byte("café")), PythonRequests)
+	if !strings.Contains(out, `data="café".encode(),`) {
+		t.Errorf("python text:\n%s", out)
+	}
+}
+
+func TestPowerShellQuoting(t *testing.T) {
+	e := testEntry([]byte("it's"))
+	e.Method = "PURGE"
+	out, _ := Generate(e, PowerShell)
+	for _, want := range []string{
+		`-CustomMethod 'PURGE'`,
+		`-ContentType 'application/json'`,
+		`'X-Quote' = 'it''s "quoted" $HOME'`,
+		`'Cookie' = 'a=1; b=2'`,
+		`GetBytes('it''s')`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in:\n%s", want, out)
+		}
+	}
+}
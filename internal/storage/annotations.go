@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AddTags attaches tags to an entry. Tags already present are ignored.
+func (s *SQLiteStore) AddTags(id int64, tags []string) error {
+	if err := s.requireEntry(id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.Exec(`INSERT OR IGNORE INTO tags (entry_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+			return fmt.Errorf("tagging entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// RemoveTags detaches tags from an entry.
+func (s *SQLiteStore) RemoveTags(id int64, tags []string) error {
+	if err := s.requireEntry(id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := s.db.Exec(`DELETE FROM tags WHERE entry_id = ? AND tag = ?`, id, strings.TrimSpace(tag)); err != nil {
+			return fmt.Errorf("untagging entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetNote replaces an entry's note. An empty note clears it.
+func (s *SQLiteStore) SetNote(id int64, note string) error {
+	if err := s.requireEntry(id); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO annotations (entry_id, note, updated_at) VALUES (?, ?, ?)
+		 ON CONFLICT(entry_id) DO UPDATE SET note = excluded.note, updated_at = excluded.updated_at`,
+		id, note, time.Now().UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("setting note: %w", err)
+	}
+	return nil
+}
+
+// SetHighlight sets an entry's highlight color. An empty color clears it.
+func (s *SQLiteStore) SetHighlight(id int64, color string) error {
+	if color != "" && !slices.Contains(HighlightColors, color) {
+		return fmt.Errorf("unknown highlight color %q (want one of %s)", color, strings.Join(HighlightColors, ", "))
+	}
+	if err := s.requireEntry(id); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO annotations (entry_id, highlight, updated_at) VALUES (?, ?, ?)
+		 ON CONFLICT(entry_id) DO UPDATE SET highlight = excluded.highlight, updated_at = excluded.updated_at`,
+		id, color, time.Now().UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("setting highlight: %w", err)
+	}
+	return nil
+}
+
+// saveAnnotations stores the tags, note and highlight carried by a new entry.
+func saveAnnotations(tx queryExecer, id int64, e *Entry) error {
+	for _, tag := range e.Tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO tags (entry_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+			return fmt.Errorf("tagging entry: %w", err)
+		}
+	}
+
+	if e.Note == "" && e.Highlight == "" {
+		return nil
+	}
+	if e.Highlight != "" && !slices.Contains(HighlightColors, e.Highlight) {
+		return fmt.Errorf("unknown highlight color %q", e.Highlight)
+	}
+	_, err := tx.Exec(`INSERT INTO annotations (entry_id, note, highlight) VALUES (?, ?, ?)`, id, e.Note, e.Highlight)
+	if err != nil {
+		return fmt.Errorf("annotating entry: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) requireEntry(id int64) error {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM entries WHERE id = ?`, id).Scan(&n); err != nil {
+		return fmt.Errorf("looking up entry: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("entry not found")
+	}
+	return nil
+}
+
+// normalizeTag trims a tag and rejects ones that cannot be stored or
+// searched unambiguously.
+func normalizeTag(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return "", fmt.Errorf("empty tag")
+	}
+	if strings.ContainsAny(tag, ", \t\n") {
+		return "", fmt.Errorf("invalid tag %q: tags cannot contain commas or whitespace", tag)
+	}
+	return tag, nil
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Authorization verdicts.
+const (
+	VerdictEnforced  = "enforced"
+	VerdictBypassed  = "bypassed"
+	VerdictUncertain = "uncertain"
+)
+
+// Identity is a set of credentials to replay requests with. Headers are set
+// on the request, replacing existing values; Remove names headers to drop,
+// e.g. Cookie and Authorization for an anonymous identity.
+type Identity struct {
+	Name      string
+	Headers   http.Header
+	Remove    []string
+	CreatedAt time.Time
+}
+
+// AuthzResult is the outcome of replaying an entry as an identity. ReplayID
+// is 0 when the replay failed; Error says why.
+type AuthzResult struct {
+	EntryID        int64
+	Identity       string
+	ReplayID       int64
+	OriginalStatus int
+	Status         int
+	Similarity     float64 // of the replayed response body to the original, 0 to 1
+	Verdict        string
+	Error          string
+	TestedAt       time.Time
+
+	// Request line of the original entry, filled in by AuthzResults.
+	Method string
+	Host   string
+	Path   string
+}
+
+// SaveIdentity creates or replaces an identity.
+func (s *SQLiteStore) SaveIdentity(id *Identity) error {
+	headers, err := json.Marshal(id.Headers)
+	if err != nil {
+		return fmt.Errorf("encoding headers: %w", err)
+	}
+	remove, err := json.Marshal(id.Remove)
+	if err != nil {
+		return fmt.Errorf("encoding removed headers: %w", err)
+	}
+	// Identity headers are live credentials: seal them as entry headers are.
+	sealed, err := s.sealHeaderText(string(headers), "identity headers")
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO identities (name, headers, remove, created_at) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(name) DO UPDATE SET headers = excluded.headers, remove = excluded.remove`,
+		id.Name, sealed, string(remove), time.Now().UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("saving identity: %w", err)
+	}
+	return nil
+}
+
+// DeleteIdentity removes an identity and its results.
+func (s *SQLiteStore) DeleteIdentity(name string) error {
+	result, err := s.db.Exec(`DELETE FROM identities WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("deleting identity: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("identity %q not found", name)
+	}
+	if _, err := s.db.Exec(`DELETE FROM authz_results WHERE identity = ?`, name); err != nil {
+		return fmt.Errorf("deleting identity results: %w", err)
+	}
+	return nil
+}
+
+// ListIdentities returns all identities sorted by name.
+func (s *SQLiteStore) ListIdentities() ([]*Identity, error) {
+	rows, err := s.db.Query(`SELECT name, headers, remove, created_at FROM identities ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("querying identities: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []*Identity
+	for rows.Next() {
+		var id Identity
+		var headers, remove string
+		var createdAt any
+		if err := rows.Scan(&id.Name, &headers, &remove, &createdAt); err != nil {
+			return nil, fmt.Errorf("scanning identity: %w", err)
+		}
+		if headers, err = s.openHeaderText(headers, "identity headers"); err != nil {
+			return nil, fmt.Errorf("identity %s: %w", id.Name, err)
+		}
+		if err := json.Unmarshal([]byte(headers), &id.Headers); err != nil {
+			id.Headers = http.Header{}
+		}
+		_ = json.Unmarshal([]byte(remove), &id.Remove)
+		id.CreatedAt = parseTimestamp(createdAt)
+		identities = append(identities, &id)
+	}
+	return identities, rows.Err()
+}
+
+// SaveAuthzResult records a result, replacing any earlier result for the
+// same entry and identity.
+func (s *SQLiteStore) SaveAuthzResult(r *AuthzResult) error {
+	ts := r.TestedAt
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	_, err := s.db.Exec(
+		`INSERT OR REPLACE INTO authz_results
+		 (entry_id, identity, replay_id, original_status, status, similarity, verdict, error, tested_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.EntryID, r.Identity, r.ReplayID, r.OriginalStatus, r.Status, r.Similarity, r.Verdict, r.Error,
+		ts.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("saving authz result: %w", err)
+	}
+	return nil
+}
+
+// AuthzResults returns the results for entries matching params, ordered by
+// entry and identity. Limit and Offset are ignored.
+func (s *SQLiteStore) AuthzResults(params SearchParams) ([]*AuthzResult, error) {
+	conditions, args, err := s.filterConditions(params)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT a.entry_id, a.identity, a.replay_id, a.original_status, a.status, a.similarity,
+		a.verdict, a.error, a.tested_at, e.method, e.host, e.path
+		FROM authz_results a JOIN entries e ON e.id = a.entry_id`
+	if len(conditions) > 0 {
+		query += ` WHERE a.entry_id IN (SELECT id FROM entries WHERE ` + strings.Join(conditions, " AND ") + `)`
+	}
+	query += ` ORDER BY a.entry_id, a.identity`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying authz results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*AuthzResult
+	for rows.Next() {
+		var r AuthzResult
+		var testedAt any
+		err := rows.Scan(&r.EntryID, &r.Identity, &r.ReplayID, &r.OriginalStatus, &r.Status, &r.Similarity,
+			&r.Verdict, &r.Error, &testedAt, &r.Method, &r.Host, &r.Path)
+		if err != nil {
+			return nil, fmt.Errorf("scanning authz result: %w", err)
+		}
+		r.TestedAt = parseTimestamp(testedAt)
+		results = append(results, &r)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIdentities(t *testing.T) {
+	store := testStore(t)
+
+	admin := &Identity{Name: "admin", Headers: http.Header{"Cookie": {"session=a"}}}
+	anon := &Identity{Name: "anonymous", Remove: []string{"Cookie", "Authorization"}}
+	for _, id := range []*Identity{anon, admin} {
+		if err := store.SaveIdentity(id); err != nil {
+			t.Fatalf("saving identity: %v", err)
+		}
+	}
+
+	admin.Headers.Set("Cookie", "session=b")
+	if err := store.SaveIdentity(admin); err != nil {
+		t.Fatalf("updating identity: %v", err)
+	}
+
+	ids, err := store.ListIdentities()
+	if err != nil {
+		t.Fatalf("listing identities: %v", err)
+	}
+	if len(ids) != 2 || ids[0].Name != "admin" || ids[1].Name != "anonymous" {
+		t.Fatalf("identities = %+v, want admin and anonymous", ids)
+	}
+	if ids[0].Headers.Get("Cookie") != "session=b" {
+		t.Errorf("admin cookie = %q, want updated", ids[0].Headers.Get("Cookie"))
+	}
+	if len(ids[1].Remove) != 2 || ids[1].CreatedAt.IsZero() {
+		t.Errorf("anonymous = %+v", ids[1])
+	}
+
+	if err := store.DeleteIdentity("admin"); err != nil {
+		t.Fatalf("deleting identity: %v", err)
+	}
+	if err := store.DeleteIdentity("admin"); err == nil {
+		t.Error("expected error deleting a missing identity")
+	}
+}
+
+func TestAuthzResults(t *testing.T) {
+	store := testStore(t)
+
+	a := &Entry{Method: "GET", Scheme: "https", Host: "a.example.com", Path: "/admin", StatusCode: 200}
+	b := &Entry{Method: "GET", Scheme: "https", Host: "b.example.com", Path: "/profile", StatusCode: 200}
+	for _, e := range []*Entry{a, b} {
+		if err := store.Save(e); err != nil {
+			t.Fatalf("saving entry: %v", err)
+		}
+	}
+
+	results := []*AuthzResult{
+		{EntryID: a.ID, Identity: "user", OriginalStatus: 200, Status: 200, Similarity: 1, Verdict: VerdictUncertain},
+		{EntryID: a.ID, Identity: "anonymous", OriginalStatus: 200, Status: 401, Verdict: VerdictEnforced},
+		{EntryID: b.ID, Identity: "user", OriginalStatus: 200, Status: 403, Verdict: VerdictEnforced},
+		// replaces the first result
+		{EntryID: a.ID, Identity: "user", OriginalStatus: 200, Status: 200, Similarity: 0.98, Verdict: VerdictBypassed},
+	}
+	for _, r := range results {
+		if err := store.SaveAuthzResult(r); err != nil {
+			t.Fatalf("saving result: %v", err)
+		}
+	}
+
+	all, err := store.AuthzResults(SearchParams{})
+	if err != nil {
+		t.Fatalf("listing results: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("got %d results, want 3", len(all))
+	}
+	if all[0].Identity != "anonymous" || all[1].Identity != "user" || all[1].Verdict != VerdictBypassed {
+		t.Errorf("results not ordered or not replaced: %+v %+v", all[0], all[1])
+	}
+	if all[1].Host != "a.example.com" || all[1].Path != "/admin" || all[1].TestedAt.IsZero() {
+		t.Errorf("result missing entry details: %+v", all[1])
+	}
+
+	filtered, err := store.AuthzResults(SearchParams{Host: "b.example.com"})
+	if err != nil {
+		t.Fatalf("filtering results: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].EntryID != b.ID {
+		t.Errorf("filtered = %+v, want only entry %d", filtered, b.ID)
+	}
+
+	if _, err := store.DeleteWhere(SearchParams{Host: "a.example.com"}); err != nil {
+		t.Fatalf("deleting entry: %v", err)
+	}
+	all, _ = store.AuthzResults(SearchParams{})
+	if len(all) != 1 || all[0].EntryID != b.ID {
+		t.Errorf("results for deleted entry remain: %+v", all)
+	}
+}
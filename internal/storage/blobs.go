@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"bytes"
+	"compress/zlib"
+	"database/sql"
+	"fmt"
+	"io"
+)
+
+// Bodies, and the wire messages of raw entries, are stored once per
+// distinct content in the blobs table, keyed by the SHA-256 of the
+// uncompressed bytes and zlib-compressed. Entries refer to them by hash;
+// refs counts those references and the entries_blob_unref trigger drops a
+// blob when its last entry is deleted. In an encrypted
+// database the key is an HMAC instead and the compressed data is sealed.
+
+const blobSchema = `
+	CREATE TABLE IF NOT EXISTS blobs (
+		hash TEXT PRIMARY KEY,
+		data BLOB NOT NULL,
+		size INTEGER NOT NULL,
+		refs INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TRIGGER IF NOT EXISTS entries_blob_unref AFTER DELETE ON entries BEGIN
+		UPDATE blobs SET refs = refs - 1 WHERE hash = OLD.request_body_hash;
+		UPDATE blobs SET refs = refs - 1 WHERE hash = OLD.response_body_hash;
+		DELETE FROM blobs WHERE refs <= 0 AND hash IN (OLD.request_body_hash, OLD.response_body_hash);
+	END;
+`
+
+// bodyMigrationBatch is the number of legacy rows moved to the blobs table
+// per query.
+const bodyMigrationBatch = 200
+
+type queryExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// putBlob stores body if it is not already present and takes a reference to
+// it, sealing it with keys if they are not nil. It returns nil for an empty
+// body, which is stored as NULL.
+func putBlob(db queryExecer, body []byte, keys *cipherKeys) (any, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	hash := keys.blobHash(body)
+
+	result, err := db.Exec(`UPDATE blobs SET refs = refs + 1 WHERE hash = ?`, hash)
+	if err != nil {
+		return nil, fmt.Errorf("referencing body: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return hash, nil
+	}
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, fmt.Errorf("compressing body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compressing body: %w", err)
+	}
+
+	data := buf.Bytes()
+	if keys != nil {
+		data = keys.seal(data, "body")
+	}
+	_, err = db.Exec(`INSERT INTO blobs (hash, data, size, refs) VALUES (?, ?, ?, 1)`, hash, data, len(body))
+	if err != nil {
+		return nil, fmt.Errorf("storing body: %w", err)
+	}
+	return hash, nil
+}
+
+// readBlob returns the body stored as data in the blobs table.
+func (s *SQLiteStore) readBlob(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte(sealPrefix)) {
+		if s.keys == nil {
+			return nil, ErrLocked
+		}
+		var err error
+		if data, err = s.keys.open(data, "body"); err != nil {
+			return nil, err
+		}
+	}
+	return inflate(data)
+}
+
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing body: %w", err)
+	}
+	defer zr.Close()
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing body: %w", err)
+	}
+	return body, nil
+}
+
+// migrateBodies moves bodies stored inline by older versions into the blobs
+// table, in batches to bound memory use.
+func migrateBodies(tx *sql.Tx) error {
+	for {
+		n, err := migrateBodyBatch(tx)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
+
+func migrateBodyBatch(tx *sql.Tx) (int, error) {
+	rows, err := tx.Query(
+		`SELECT id, request_body, response_body FROM entries
+		 WHERE request_body IS NOT NULL OR response_body IS NOT NULL
+		 LIMIT ?`, bodyMigrationBatch,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("reading legacy bodies: %w", err)
+	}
+
+	type legacyRow struct {
+		id        int64
+		req, resp []byte
+	}
+	var batch []legacyRow
+	for rows.Next() {
+		var r legacyRow
+		if err := rows.Scan(&r.id, &r.req, &r.resp); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("reading legacy bodies: %w", err)
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("reading legacy bodies: %w", err)
+	}
+
+	for _, r := range batch {
+		reqHash, err := putBlob(tx, r.req, nil)
+		if err != nil {
+			return 0, err
+		}
+		respHash, err := putBlob(tx, r.resp, nil)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(
+			`UPDATE entries SET request_body = NULL, response_body = NULL,
+			 request_body_hash = ?, response_body_hash = ? WHERE id = ?`,
+			reqHash, respHash, r.id,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("migrating entry %d: %w", r.id, err)
+		}
+	}
+
+	return len(batch), nil
+}
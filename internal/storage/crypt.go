@@ -0,0 +1,456 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// An encrypted database seals request and response headers and bodies, and
+// the headers of authz identities, with AES-256-GCM. Hosts, paths, queries,
+// statuses and annotations stay in the clear so that filters still run in
+// SQL; searching content decrypts in Go. So do finding evidence and fuzz
+// payloads.
+// The key is derived from a passphrase with PBKDF2, or read from a key
+// file, and checked against a value sealed when encryption was set up.
+// Blobs are keyed by an HMAC of the body instead of its SHA-256, so that
+// identical bodies are still stored once without revealing whether a known
+// body is present.
+
+var (
+	// ErrLocked is returned when reading or writing sealed data before the
+	// database is unlocked.
+	ErrLocked = errors.New("database is encrypted: a passphrase or key file is needed")
+	// ErrWrongKey is returned by Unlock when the secret does not match.
+	ErrWrongKey = errors.New("wrong passphrase or key for this database")
+)
+
+// KeySize is the size of a raw encryption key.
+const KeySize = 32
+
+// kdfIterations is the PBKDF2-SHA256 iteration count for new databases.
+// The count used is stored with the database, so it can be raised later.
+var kdfIterations = 600_000
+
+// sealPrefix marks sealed values, which may sit next to plain ones in a
+// database encrypted after it was created. Plain header columns hold JSON
+// and plain blobs zlib data, neither of which can start with it.
+const sealPrefix = "enc1:"
+
+// Secret is the key material for an encrypted database: a passphrase, or a
+// raw key of KeySize bytes.
+type Secret struct {
+	Passphrase string `json:"passphrase,omitempty"`
+	Key        []byte `json:"key,omitempty"`
+}
+
+func (s Secret) IsZero() bool {
+	return s.Passphrase == "" && len(s.Key) == 0
+}
+
+// ParseKey reads a key file: KeySize raw bytes, or twice as many hex digits.
+func ParseKey(data []byte) ([]byte, error) {
+	if len(data) == KeySize {
+		return data, nil
+	}
+	text := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key must be %d bytes, or %d hex digits (e.g. from openssl rand -hex %d)", KeySize, 2*KeySize, KeySize)
+}
+
+// encryptionSettings are stored in the settings table of an encrypted
+// database.
+type encryptionSettings struct {
+	KDF        string `json:"kdf"` // "pbkdf2-sha256", or "none" for a raw key
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Check      string `json:"check"` // checkText sealed with the key
+}
+
+const checkText = "reaper"
+
+// masterKey returns the key secret gives for these settings.
+func (es *encryptionSettings) masterKey(secret Secret) ([]byte, error) {
+	switch es.KDF {
+	case "pbkdf2-sha256":
+		if secret.Passphrase == "" {
+			return nil, errors.New("database is encrypted with a passphrase, not a key file")
+		}
+		return pbkdf2.Key(sha256.New, secret.Passphrase, es.Salt, es.Iterations, KeySize)
+	case "none":
+		if len(secret.Key) != KeySize {
+			return nil, errors.New("database is encrypted with a key file, not a passphrase")
+		}
+		return secret.Key, nil
+	}
+	return nil, fmt.Errorf("unknown key derivation %q", es.KDF)
+}
+
+// cipherKeys seal values and hash blobs.
+type cipherKeys struct {
+	aead cipher.AEAD
+	mac  []byte
+}
+
+func newCipherKeys(master []byte) (*cipherKeys, error) {
+	encKey, err := hkdf.Key(sha256.New, master, nil, "reaper entry encryption", KeySize)
+	if err != nil {
+		return nil, err
+	}
+	macKey, err := hkdf.Key(sha256.New, master, nil, "reaper blob hashes", KeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cipherKeys{aead: aead, mac: macKey}, nil
+}
+
+// seal encrypts data. field is authenticated with it, so a value cannot be
+// moved to another column.
+func (k *cipherKeys) seal(data []byte, field string) []byte {
+	nonce := make([]byte, k.aead.NonceSize())
+	rand.Read(nonce)
+	out := append([]byte(sealPrefix), nonce...)
+	return k.aead.Seal(out, nonce, data, []byte(field))
+}
+
+func (k *cipherKeys) open(sealed []byte, field string) ([]byte, error) {
+	data := bytes.TrimPrefix(sealed, []byte(sealPrefix))
+	n := k.aead.NonceSize()
+	if len(data) < n {
+		return nil, fmt.Errorf("decrypting %s: too short", field)
+	}
+	plain, err := k.aead.Open(nil, data[:n], data[n:], []byte(field))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: %w", field, err)
+	}
+	return plain, nil
+}
+
+// sealText and openText seal values of text columns, in base64.
+func (k *cipherKeys) sealText(text, field string) string {
+	sealed := k.seal([]byte(text), field)
+	return sealPrefix + base64.StdEncoding.EncodeToString(sealed[len(sealPrefix):])
+}
+
+func (k *cipherKeys) openText(text, field string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(text, sealPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decrypting %s: %w", field, err)
+	}
+	plain, err := k.open(data, field)
+	return string(plain), err
+}
+
+// blobHash returns the key a body is stored under.
+func (k *cipherKeys) blobHash(body []byte) string {
+	if k == nil {
+		sum := sha256.Sum256(body)
+		return hex.EncodeToString(sum[:])
+	}
+	m := hmac.New(sha256.New, k.mac)
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// Encrypted reports whether the database is encrypted.
+func (s *SQLiteStore) Encrypted() bool {
+	return s.encryption != nil
+}
+
+// Unlock supplies the secret of an encrypted database, which is needed to
+// read and write entries.
+func (s *SQLiteStore) Unlock(secret Secret) error {
+	if s.encryption == nil {
+		return errors.New("database is not encrypted")
+	}
+	master, err := s.encryption.masterKey(secret)
+	if err != nil {
+		return err
+	}
+	keys, err := newCipherKeys(master)
+	if err != nil {
+		return err
+	}
+	if check, err := keys.openText(s.encryption.Check, "check"); err != nil || check != checkText {
+		return ErrWrongKey
+	}
+	s.keys = keys
+	return nil
+}
+
+// Encrypt sets up encryption with secret and seals the headers and bodies
+// already stored, then rewrites the database file so that no plain copy is
+// left in free pages. Backups made earlier are not touched.
+func (s *SQLiteStore) Encrypt(secret Secret) error {
+	if s.encryption != nil {
+		return errors.New("database is already encrypted")
+	}
+
+	es := &encryptionSettings{KDF: "none"}
+	switch {
+	case secret.Passphrase != "":
+		es.KDF, es.Iterations = "pbkdf2-sha256", kdfIterations
+		es.Salt = make([]byte, 16)
+		rand.Read(es.Salt)
+	case len(secret.Key) != KeySize:
+		return fmt.Errorf("key must be %d bytes", KeySize)
+	}
+	master, err := es.masterKey(secret)
+	if err != nil {
+		return err
+	}
+	keys, err := newCipherKeys(master)
+	if err != nil {
+		return err
+	}
+	es.Check = keys.sealText(checkText, "check")
+
+	// Zero deleted content from now on, as it may be sensitive.
+	if _, err := s.db.Exec("PRAGMA secure_delete = ON"); err != nil {
+		return fmt.Errorf("configuring database: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	value, _ := json.Marshal(es)
+	if _, err := tx.Exec(`INSERT INTO settings (name, value) VALUES ('encryption', ?)`, string(value)); err != nil {
+		return fmt.Errorf("saving encryption settings: %w", err)
+	}
+	if err := sealHeaders(tx, keys); err != nil {
+		return err
+	}
+	if err := sealIdentities(tx, keys); err != nil {
+		return err
+	}
+	if err := sealBlobs(tx, keys); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing encryption: %w", err)
+	}
+	s.encryption, s.keys = es, keys
+
+	if _, err := s.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("vacuuming database: %w", err)
+	}
+	if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("checkpointing database: %w", err)
+	}
+	return nil
+}
+
+// sealBatch is the number of rows sealed per query when encrypting an
+// existing database.
+const sealBatch = 200
+
+func sealHeaders(tx *sql.Tx, keys *cipherKeys) error {
+	var last int64
+	for {
+		rows, err := tx.Query(`SELECT id, request_headers, COALESCE(response_headers, '{}') FROM entries WHERE id > ? ORDER BY id LIMIT ?`, last, sealBatch)
+		if err != nil {
+			return fmt.Errorf("reading headers: %w", err)
+		}
+		type row struct {
+			id        int64
+			req, resp string
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.req, &r.resp); err != nil {
+				rows.Close()
+				return fmt.Errorf("reading headers: %w", err)
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("reading headers: %w", err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		for _, r := range batch {
+			last = r.id
+			if strings.HasPrefix(r.req, sealPrefix) {
+				continue
+			}
+			_, err := tx.Exec(`UPDATE entries SET request_headers = ?, response_headers = ? WHERE id = ?`,
+				keys.sealText(r.req, "request headers"), keys.sealText(r.resp, "response headers"), r.id)
+			if err != nil {
+				return fmt.Errorf("encrypting entry %d: %w", r.id, err)
+			}
+		}
+	}
+}
+
+// sealIdentities seals the headers of the authz identities, which hold
+// session cookies and tokens.
+func sealIdentities(tx *sql.Tx, keys *cipherKeys) error {
+	rows, err := tx.Query(`SELECT name, headers FROM identities`)
+	if err != nil {
+		return fmt.Errorf("reading identities: %w", err)
+	}
+	plain := map[string]string{}
+	for rows.Next() {
+		var name, headers string
+		if err := rows.Scan(&name, &headers); err != nil {
+			rows.Close()
+			return fmt.Errorf("reading identities: %w", err)
+		}
+		if !strings.HasPrefix(headers, sealPrefix) {
+			plain[name] = headers
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading identities: %w", err)
+	}
+
+	for name, headers := range plain {
+		if _, err := tx.Exec(`UPDATE identities SET headers = ? WHERE name = ?`, keys.sealText(headers, "identity headers"), name); err != nil {
+			return fmt.Errorf("encrypting identity %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// sealBlobs seals every blob and moves it to its HMAC key, updating the
+// entries that refer to it.
+func sealBlobs(tx *sql.Tx, keys *cipherKeys) error {
+	last := ""
+	for {
+		rows, err := tx.Query(`SELECT hash, data FROM blobs WHERE hash > ? ORDER BY hash LIMIT ?`, last, sealBatch)
+		if err != nil {
+			return fmt.Errorf("reading bodies: %w", err)
+		}
+		type blob struct {
+			hash string
+			data []byte
+		}
+		var batch []blob
+		for rows.Next() {
+			var b blob
+			if err := rows.Scan(&b.hash, &b.data); err != nil {
+				rows.Close()
+				return fmt.Errorf("reading bodies: %w", err)
+			}
+			batch = append(batch, b)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("reading bodies: %w", err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		for _, b := range batch {
+			last = b.hash
+			if bytes.HasPrefix(b.data, []byte(sealPrefix)) {
+				continue // moved earlier in this pass
+			}
+			body, err := inflate(b.data)
+			if err != nil {
+				return err
+			}
+			hash := keys.blobHash(body)
+			stmts := []string{
+				`INSERT INTO blobs (hash, data, size, refs) SELECT ?, ?, size, refs FROM blobs WHERE hash = ?`,
+				`UPDATE entries SET request_body_hash = ? WHERE request_body_hash = ?`,
+				`UPDATE entries SET response_body_hash = ? WHERE response_body_hash = ?`,
+				`UPDATE entries SET raw_request_hash = ? WHERE raw_request_hash = ?`,
+				`UPDATE entries SET raw_response_hash = ? WHERE raw_response_hash = ?`,
+				`DELETE FROM blobs WHERE hash = ?`,
+			}
+			args := [][]any{
+				{hash, keys.seal(b.data, "body"), b.hash},
+				{hash, b.hash},
+				{hash, b.hash},
+				{hash, b.hash},
+				{hash, b.hash},
+				{b.hash},
+			}
+			for i, stmt := range stmts {
+				if _, err := tx.Exec(stmt, args[i]...); err != nil {
+					return fmt.Errorf("encrypting body %s: %w", b.hash, err)
+				}
+			}
+		}
+	}
+}
+
+// loadEncryption reads the encryption settings, if any.
+func (s *SQLiteStore) loadEncryption() error {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'settings'`).Scan(&n); err != nil {
+		return fmt.Errorf("inspecting database: %w", err)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM settings WHERE name = 'encryption'`).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading encryption settings: %w", err)
+	}
+	var es encryptionSettings
+	if err := json.Unmarshal([]byte(value), &es); err != nil {
+		return fmt.Errorf("reading encryption settings: %w", err)
+	}
+	s.encryption = &es
+	return nil
+}
+
+// sealHeaderText returns the stored form of a headers column.
+func (s *SQLiteStore) sealHeaderText(text, field string) (string, error) {
+	if s.encryption == nil {
+		return text, nil
+	}
+	if s.keys == nil {
+		return "", ErrLocked
+	}
+	return s.keys.sealText(text, field), nil
+}
+
+// openHeaderText returns the JSON of a stored headers column.
+func (s *SQLiteStore) openHeaderText(text, field string) (string, error) {
+	if !strings.HasPrefix(text, sealPrefix) {
+		return text, nil
+	}
+	if s.keys == nil {
+		return "", ErrLocked
+	}
+	return s.keys.openText(text, field)
+}
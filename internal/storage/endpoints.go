@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxEndpointExamples is the number of example entry IDs kept per endpoint.
+const maxEndpointExamples = 3
+
+// Endpoint aggregates the stored traffic for one host and path template.
+type Endpoint struct {
+	Host       string
+	Template   string // path with variable segments replaced, e.g. /users/{id}
+	Methods    []string
+	Statuses   []int
+	Count      int
+	FirstSeen  time.Time
+	LastSeen   time.Time
+	ExampleIDs []int64 // most recent first
+}
+
+var (
+	uuidSegment  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexSegment   = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+	dateSegment  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	emailSegment = regexp.MustCompile(`^[^@/\s]+@[^@/\s]+\.[A-Za-z]{2,}$`)
+	tokenSegment = regexp.MustCompile(`^[A-Za-z0-9_\-]{20,}={0,2}$`)
+	hasDigit     = regexp.MustCompile(`\d`)
+	hasLetter    = regexp.MustCompile(`[A-Za-z]`)
+)
+
+// NormalizePath collapses path segments that look like identifiers into
+// placeholders, so that /users/42/orders/9f1c...e2 becomes
+// /users/{id}/orders/{uuid}. A file extension on a variable segment is
+// kept: /files/123.json becomes /files/{id}.json.
+func NormalizePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if seg == "" {
+			continue
+		}
+
+		base, ext := seg, ""
+		if dot := strings.LastIndex(seg, "."); dot > 0 && len(seg)-dot <= 5 && !strings.Contains(seg, "@") {
+			base, ext = seg[:dot], seg[dot:]
+		}
+
+		if placeholder := segmentPlaceholder(base); placeholder != "" {
+			segments[i] = placeholder + ext
+		} else if placeholder := segmentPlaceholder(seg); placeholder != "" {
+			segments[i] = placeholder
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func segmentPlaceholder(seg string) string {
+	switch {
+	case isDigits(seg):
+		return "{id}"
+	case uuidSegment.MatchString(seg):
+		return "{uuid}"
+	case dateSegment.MatchString(seg):
+		return "{date}"
+	case hexSegment.MatchString(seg) && hasDigit.MatchString(seg):
+		return "{hash}"
+	case emailSegment.MatchString(seg):
+		return "{email}"
+	case tokenSegment.MatchString(seg) && hasDigit.MatchString(seg) && hasLetter.MatchString(seg):
+		return "{token}"
+	}
+	return ""
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Endpoints aggregates the entries matching params into unique endpoints per
+// host and path template, sorted by host and template. Limit and Offset are
+// ignored.
+func (s *SQLiteStore) Endpoints(params SearchParams) ([]Endpoint, error) {
+	conditions, args, err := s.filterConditions(params)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, method, host, path, status_code, created_at FROM entries`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying entries: %w", err)
+	}
+	defer rows.Close()
+
+	byKey := map[string]*Endpoint{}
+	for rows.Next() {
+		var (
+			id        int64
+			method    string
+			host      string
+			path      string
+			status    int
+			createdAt any
+		)
+		if err := rows.Scan(&id, &method, &host, &path, &status, &createdAt); err != nil {
+			return nil, fmt.Errorf("scanning entry: %w", err)
+		}
+		ts := parseTimestamp(createdAt)
+
+		template := NormalizePath(path)
+		key := host + " " + template
+		ep, ok := byKey[key]
+		if !ok {
+			ep = &Endpoint{Host: host, Template: template, FirstSeen: ts, LastSeen: ts}
+			byKey[key] = ep
+		}
+
+		ep.Count++
+		if !slices.Contains(ep.Methods, method) {
+			ep.Methods = append(ep.Methods, method)
+		}
+		if !slices.Contains(ep.Statuses, status) {
+			ep.Statuses = append(ep.Statuses, status)
+		}
+		if ts.Before(ep.FirstSeen) {
+			ep.FirstSeen = ts
+		}
+		if ts.After(ep.LastSeen) {
+			ep.LastSeen = ts
+		}
+		if len(ep.ExampleIDs) < maxEndpointExamples {
+			ep.ExampleIDs = append(ep.ExampleIDs, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying entries: %w", err)
+	}
+
+	endpoints := make([]Endpoint, 0, len(byKey))
+	for _, ep := range byKey {
+		sort.Strings(ep.Methods)
+		sort.Ints(ep.Statuses)
+		endpoints = append(endpoints, *ep)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Host != endpoints[j].Host {
+			return endpoints[i].Host < endpoints[j].Host
+		}
+		return endpoints[i].Template < endpoints[j].Template
+	})
+	return endpoints, nil
+}
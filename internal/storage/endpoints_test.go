@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"/users", "/users"},
+		{"/users/42", "/users/{id}"},
+		{"/users/42/", "/users/{id}/"},
+		{"/users/42/orders/0b6f8a2e-3c1d-4e5f-9a7b-1c2d3e4f5a6b", "/users/{id}/orders/{uuid}"},
+		{"/blobs/d41d8cd98f00b204e9800998ecf8427e", "/blobs/{hash}"},
+		{"/reports/2024-06-01", "/reports/{date}"},
+		{"/files/123.json", "/files/{id}.json"},
+		{"/accounts/alice@example.com", "/accounts/{email}"},
+		{"/customers/cus_9s6XKzkNRiz8i3Qa7", "/customers/{token}"},
+		{"/api/v2/health", "/api/v2/health"},
+		{"/static/app.min.js", "/static/app.min.js"},
+		{"/settings/two-factor-authentication", "/settings/two-factor-authentication"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizePath(tt.path); got != tt.want {
+			t.Errorf("NormalizePath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	store := testStore(t)
+
+	base := time.Now().Add(-time.Hour)
+	save := func(method, host, path string, status int, offset time.Duration) {
+		t.Helper()
+		err := store.Save(&Entry{
+			Method: method, Scheme: "https", Host: host, Path: path, StatusCode: status,
+			RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: base.Add(offset),
+		})
+		if err != nil {
+			t.Fatalf("saving: %v", err)
+		}
+	}
+	save("GET", "api.acme.com", "/users/1", 200, 0)
+	save("GET", "api.acme.com", "/users/2", 404, time.Minute)
+	save("DELETE", "api.acme.com", "/users/3", 204, 2*time.Minute)
+	save("GET", "api.acme.com", "/users/4", 200, 3*time.Minute)
+	save("GET", "api.acme.com", "/health", 200, 4*time.Minute)
+	save("GET", "web.acme.com", "/users/5", 200, 5*time.Minute)
+
+	endpoints, err := store.Endpoints(SearchParams{})
+	if err != nil {
+		t.Fatalf("listing endpoints: %v", err)
+	}
+	if len(endpoints) != 3 {
+		t.Fatalf("got %d endpoints, want 3", len(endpoints))
+	}
+
+	if endpoints[0].Template != "/health" || endpoints[2].Host != "web.acme.com" {
+		t.Errorf("endpoints not sorted by host and template: %+v", endpoints)
+	}
+
+	users := endpoints[1]
+	if users.Host != "api.acme.com" || users.Template != "/users/{id}" {
+		t.Fatalf("endpoint = %s %s, want api.acme.com /users/{id}", users.Host, users.Template)
+	}
+	if users.Count != 4 {
+		t.Errorf("count = %d, want 4", users.Count)
+	}
+	if len(users.Methods) != 2 || users.Methods[0] != "DELETE" || users.Methods[1] != "GET" {
+		t.Errorf("methods = %v, want [DELETE GET]", users.Methods)
+	}
+	if len(users.Statuses) != 3 || users.Statuses[0] != 200 || users.Statuses[2] != 404 {
+		t.Errorf("statuses = %v, want [200 204 404]", users.Statuses)
+	}
+	if len(users.ExampleIDs) != maxEndpointExamples || users.ExampleIDs[0] != 4 {
+		t.Errorf("example IDs = %v, want 3 newest first", users.ExampleIDs)
+	}
+	if users.FirstSeen.Sub(base).Abs() > time.Second {
+		t.Errorf("first seen = %v, want %v", users.FirstSeen, base)
+	}
+	if users.LastSeen.Sub(base.Add(3*time.Minute)).Abs() > time.Second {
+		t.Errorf("last seen = %v, want %v", users.LastSeen, base.Add(3*time.Minute))
+	}
+
+	filtered, err := store.Endpoints(SearchParams{Host: "web.acme.com"})
+	if err != nil {
+		t.Fatalf("listing filtered endpoints: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].Count != 1 {
+		t.Errorf("filtered endpoints = %+v, want one endpoint with one entry", filtered)
+	}
+}
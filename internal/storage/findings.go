@@ -0,0 +1,345 @@
+package storage
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Finding severities, from least to most severe.
+const (
+	SeverityInfo     = "info"
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Severities lists the severities from least to most severe.
+var Severities = []string{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// Finding confidences.
+const (
+	ConfidenceTentative = "tentative"
+	ConfidenceFirm      = "firm"
+	ConfidenceCertain   = "certain"
+)
+
+// Finding triage statuses.
+const (
+	StatusNew           = "new"
+	StatusConfirmed     = "confirmed"
+	StatusFalsePositive = "false-positive"
+	StatusFixed         = "fixed"
+)
+
+// FindingStatuses lists the triage statuses in workflow order.
+var FindingStatuses = []string{StatusNew, StatusConfirmed, StatusFalsePositive, StatusFixed}
+
+// CheckManual is the check name of findings recorded by hand.
+const CheckManual = "manual"
+
+// Finding is an issue found in traffic. The same issue seen in several
+// entries is one finding: the fingerprint identifies it, EntryID is the
+// entry it was first seen in, EntryIDs are all the entries linked to it and
+// Hits counts the entries.
+type Finding struct {
+	ID          int64
+	Fingerprint string
+	Check       string // name of the check that reported it
+	Title       string
+	Severity    string
+	Confidence  string
+	Host        string
+	EntryID     int64
+	Evidence    string
+	Hits        int
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Status      string
+	Note        string
+	EntryIDs    []int64 // ascending; entries deleted since are dropped
+
+	// Request of the first entry, filled in when reading findings; empty if
+	// the entry has been deleted.
+	Method string
+	Path   string
+	URL    string
+}
+
+// FindingParams filters findings. Severity is a minimum.
+type FindingParams struct {
+	Check    string
+	Host     string // supports glob wildcard (*.domain.com)
+	Severity string
+	Status   string
+	Limit    int
+}
+
+// severityRank is an SQL expression ordering severities, most severe
+// highest.
+var severityRank = func() string {
+	var b strings.Builder
+	b.WriteString("CASE f.severity")
+	for i, s := range Severities {
+		fmt.Fprintf(&b, " WHEN '%s' THEN %d", s, i)
+	}
+	b.WriteString(" ELSE -1 END")
+	return b.String()
+}()
+
+// SaveFinding records a finding reported by a check and links it to
+// f.EntryID. If one with the same fingerprint exists, its hit count and
+// last-seen time are updated instead and f.ID is set to its ID; a finding
+// marked fixed that is seen again goes back to new.
+func (s *SQLiteStore) SaveFinding(f *Finding) error {
+	seen := f.LastSeen
+	if seen.IsZero() {
+		seen = time.Now()
+	}
+	ts := seen.UTC().Format(time.DateTime)
+
+	// Update before inserting: an upsert would use up an ID on every hit.
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("saving finding: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	err = tx.QueryRow(
+		`UPDATE findings SET hits = hits + 1, last_seen = ?,
+		 status = CASE status WHEN '`+StatusFixed+`' THEN '`+StatusNew+`' ELSE status END
+		 WHERE fingerprint = ? RETURNING id, hits`,
+		ts, f.Fingerprint,
+	).Scan(&f.ID, &f.Hits)
+	if errors.Is(err, sql.ErrNoRows) {
+		var result sql.Result
+		result, err = tx.Exec(
+			`INSERT INTO findings
+			 (fingerprint, check_name, title, severity, confidence, host, entry_id, evidence, hits, first_seen, last_seen)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)`,
+			f.Fingerprint, f.Check, f.Title, f.Severity, f.Confidence, f.Host, f.EntryID, f.Evidence, ts, ts,
+		)
+		if err == nil {
+			f.ID, err = result.LastInsertId()
+			f.Hits = 1
+		}
+	}
+	if err == nil && f.EntryID > 0 {
+		_, err = tx.Exec(`INSERT OR IGNORE INTO finding_entries (finding_id, entry_id) VALUES (?, ?)`, f.ID, f.EntryID)
+	}
+	if err != nil {
+		return fmt.Errorf("saving finding: %w", err)
+	}
+	return tx.Commit()
+}
+
+// AddFinding records a finding by hand, linked to f.EntryIDs. Check
+// defaults to CheckManual, Confidence to certain and Status to new; the
+// fingerprint is random, so manual findings are never merged. Host and the
+// first entry are taken from the first linked entry.
+func (s *SQLiteStore) AddFinding(f *Finding) error {
+	if f.Title == "" {
+		return errors.New("finding title is required")
+	}
+	if !slices.Contains(Severities, f.Severity) {
+		return fmt.Errorf("unknown severity %q", f.Severity)
+	}
+	if f.Status == "" {
+		f.Status = StatusNew
+	} else if !slices.Contains(FindingStatuses, f.Status) {
+		return fmt.Errorf("unknown status %q", f.Status)
+	}
+	if f.Check == "" {
+		f.Check = CheckManual
+	}
+	if f.Confidence == "" {
+		f.Confidence = ConfidenceCertain
+	}
+	if f.Fingerprint == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		f.Fingerprint = CheckManual + ":" + hex.EncodeToString(b)
+	}
+	slices.Sort(f.EntryIDs)
+	f.EntryIDs = slices.Compact(f.EntryIDs)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("adding finding: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, id := range f.EntryIDs {
+		var host string
+		if err := tx.QueryRow(`SELECT host FROM entries WHERE id = ?`, id).Scan(&host); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("entry %d not found", id)
+			}
+			return fmt.Errorf("adding finding: %w", err)
+		}
+		if f.EntryID == 0 {
+			f.EntryID, f.Host = id, host
+		}
+	}
+
+	now := time.Now().UTC()
+	ts := now.Format(time.DateTime)
+	result, err := tx.Exec(
+		`INSERT INTO findings
+		 (fingerprint, check_name, title, severity, confidence, host, entry_id, evidence, hits, first_seen, last_seen, status, note)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		f.Fingerprint, f.Check, f.Title, f.Severity, f.Confidence, f.Host, f.EntryID, f.Evidence, len(f.EntryIDs),
+		ts, ts, f.Status, f.Note,
+	)
+	if err != nil {
+		return fmt.Errorf("adding finding: %w", err)
+	}
+	if f.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("adding finding: %w", err)
+	}
+	for _, id := range f.EntryIDs {
+		if _, err := tx.Exec(`INSERT INTO finding_entries (finding_id, entry_id) VALUES (?, ?)`, f.ID, id); err != nil {
+			return fmt.Errorf("linking finding: %w", err)
+		}
+	}
+	f.Hits = len(f.EntryIDs)
+	f.FirstSeen, f.LastSeen = now.Truncate(time.Second), now.Truncate(time.Second)
+	return tx.Commit()
+}
+
+// GetFinding returns the finding with the given ID.
+func (s *SQLiteStore) GetFinding(id int64) (*Finding, error) {
+	findings, err := s.queryFindings(" WHERE f.id = ?", []any{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(findings) == 0 {
+		return nil, fmt.Errorf("finding not found")
+	}
+	return findings[0], nil
+}
+
+// SetFindingStatus sets the triage status of a finding.
+func (s *SQLiteStore) SetFindingStatus(id int64, status string) error {
+	if !slices.Contains(FindingStatuses, status) {
+		return fmt.Errorf("unknown status %q", status)
+	}
+	return s.updateFinding(id, "status", status)
+}
+
+// SetFindingNote replaces the note on a finding; an empty note clears it.
+func (s *SQLiteStore) SetFindingNote(id int64, note string) error {
+	return s.updateFinding(id, "note", note)
+}
+
+func (s *SQLiteStore) updateFinding(id int64, column, value string) error {
+	result, err := s.db.Exec(`UPDATE findings SET `+column+` = ? WHERE id = ?`, value, id)
+	if err != nil {
+		return fmt.Errorf("updating finding: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("finding not found")
+	}
+	return nil
+}
+
+// Findings returns the findings matching params, most severe first.
+func (s *SQLiteStore) Findings(params FindingParams) ([]*Finding, error) {
+	var conditions []string
+	var args []any
+	if params.Check != "" {
+		conditions = append(conditions, "f.check_name = ?")
+		args = append(args, params.Check)
+	}
+	if params.Host != "" {
+		if strings.Contains(params.Host, "*") {
+			conditions = append(conditions, "f.host LIKE ?")
+			args = append(args, strings.ReplaceAll(params.Host, "*", "%"))
+		} else {
+			conditions = append(conditions, "f.host = ?")
+			args = append(args, params.Host)
+		}
+	}
+	if params.Severity != "" {
+		rank := -1
+		for i, sev := range Severities {
+			if sev == params.Severity {
+				rank = i
+			}
+		}
+		if rank < 0 {
+			return nil, fmt.Errorf("unknown severity %q", params.Severity)
+		}
+		conditions = append(conditions, severityRank+" >= ?")
+		args = append(args, rank)
+	}
+	if params.Status != "" {
+		if !slices.Contains(FindingStatuses, params.Status) {
+			return nil, fmt.Errorf("unknown status %q", params.Status)
+		}
+		conditions = append(conditions, "f.status = ?")
+		args = append(args, params.Status)
+	}
+
+	query := ""
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + severityRank + " DESC, f.id"
+	if params.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, params.Limit)
+	}
+	return s.queryFindings(query, args)
+}
+
+// queryFindings reads the findings selected by clauses, the part of the
+// query after FROM.
+func (s *SQLiteStore) queryFindings(clauses string, args []any) ([]*Finding, error) {
+	query := `SELECT f.id, f.fingerprint, f.check_name, f.title, f.severity, f.confidence, f.host, f.entry_id,
+		f.evidence, f.hits, f.first_seen, f.last_seen, f.status, f.note,
+		(SELECT group_concat(entry_id) FROM finding_entries WHERE finding_id = f.id),
+		COALESCE(e.method, ''), COALESCE(e.scheme, ''), COALESCE(e.host, ''), COALESCE(e.port, 0),
+		COALESCE(e.path, ''), COALESCE(e.query, '')
+		FROM findings f LEFT JOIN entries e ON e.id = f.entry_id` + clauses
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying findings: %w", err)
+	}
+	defer rows.Close()
+
+	var findings []*Finding
+	for rows.Next() {
+		var f Finding
+		var firstSeen, lastSeen any
+		var entryIDs sql.NullString
+		var e Entry
+		err := rows.Scan(&f.ID, &f.Fingerprint, &f.Check, &f.Title, &f.Severity, &f.Confidence, &f.Host, &f.EntryID,
+			&f.Evidence, &f.Hits, &firstSeen, &lastSeen, &f.Status, &f.Note, &entryIDs,
+			&e.Method, &e.Scheme, &e.Host, &e.Port, &e.Path, &e.Query)
+		if err != nil {
+			return nil, fmt.Errorf("scanning finding: %w", err)
+		}
+		f.FirstSeen = parseTimestamp(firstSeen)
+		f.LastSeen = parseTimestamp(lastSeen)
+		for _, s := range strings.Split(entryIDs.String, ",") {
+			if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+				f.EntryIDs = append(f.EntryIDs, id)
+			}
+		}
+		slices.Sort(f.EntryIDs)
+		if e.Method != "" {
+			f.Method, f.Path, f.URL = e.Method, e.Path, e.URL()
+		}
+		findings = append(findings, &f)
+	}
+	return findings, rows.Err()
+}
@@ -0,0 +1,153 @@
+package storage
+
+import "testing"
+
+func TestFindings(t *testing.T) {
+	store := testStore(t)
+
+	a := &Entry{Method: "GET", Scheme: "https", Host: "a.example.com", Path: "/login", StatusCode: 200}
+	b := &Entry{Method: "GET", Scheme: "https", Host: "b.example.com", Path: "/", StatusCode: 200}
+	for _, e := range []*Entry{a, b} {
+		if err := store.Save(e); err != nil {
+			t.Fatalf("saving entry: %v", err)
+		}
+	}
+
+	hsts := &Finding{Fingerprint: "f1", Check: "security-headers", Title: "Missing HSTS", Severity: SeverityLow,
+		Confidence: ConfidenceCertain, Host: "a.example.com", EntryID: a.ID}
+	cors := &Finding{Fingerprint: "f2", Check: "cors", Title: "CORS trusts null", Severity: SeverityHigh,
+		Confidence: ConfidenceFirm, Host: "b.example.com", EntryID: b.ID, Evidence: "Access-Control-Allow-Origin: null"}
+	for _, f := range []*Finding{hsts, cors} {
+		if err := store.SaveFinding(f); err != nil {
+			t.Fatalf("saving finding: %v", err)
+		}
+	}
+
+	// The same issue in another entry counts as a hit on the first finding.
+	again := &Finding{Fingerprint: "f1", Check: "security-headers", Title: "Missing HSTS", Severity: SeverityLow,
+		Confidence: ConfidenceCertain, Host: "a.example.com", EntryID: b.ID}
+	if err := store.SaveFinding(again); err != nil {
+		t.Fatalf("saving finding: %v", err)
+	}
+	if again.ID != hsts.ID || again.Hits != 2 {
+		t.Errorf("repeat finding = id %d hits %d, want id %d hits 2", again.ID, again.Hits, hsts.ID)
+	}
+
+	// Hits do not use up IDs.
+	third := &Finding{Fingerprint: "f3", Check: "cors", Title: "CORS allows any origin", Severity: SeverityInfo,
+		Confidence: ConfidenceCertain, Host: "b.example.com", EntryID: b.ID}
+	if err := store.SaveFinding(third); err != nil {
+		t.Fatalf("saving finding: %v", err)
+	}
+	if third.ID != cors.ID+1 || third.Hits != 1 {
+		t.Errorf("third finding = id %d hits %d, want id %d hits 1", third.ID, third.Hits, cors.ID+1)
+	}
+
+	all, err := store.Findings(FindingParams{Severity: SeverityLow})
+	if err != nil {
+		t.Fatalf("listing findings: %v", err)
+	}
+	if len(all) != 2 || all[0].Check != "cors" || all[1].Check != "security-headers" {
+		t.Fatalf("findings = %+v, want cors then security-headers", all)
+	}
+	if f := all[1]; f.EntryID != a.ID || f.Hits != 2 || f.Path != "/login" || f.FirstSeen.IsZero() {
+		t.Errorf("hsts finding = %+v", f)
+	}
+
+	if got, _ := store.Findings(FindingParams{Severity: SeverityMedium}); len(got) != 1 || got[0].Check != "cors" {
+		t.Errorf("severity >= medium = %+v, want cors", got)
+	}
+	if got, _ := store.Findings(FindingParams{Host: "*.example.com", Check: "security-headers"}); len(got) != 1 {
+		t.Errorf("host and check filter = %+v, want 1", got)
+	}
+	if _, err := store.Findings(FindingParams{Severity: "severe"}); err == nil {
+		t.Error("expected error for unknown severity")
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("clearing: %v", err)
+	}
+	if got, _ := store.Findings(FindingParams{}); len(got) != 0 {
+		t.Errorf("findings after clear = %d, want 0", len(got))
+	}
+}
+
+func TestFindingTriage(t *testing.T) {
+	store := testStore(t)
+
+	a := &Entry{Method: "POST", Scheme: "https", Host: "a.example.com", Port: 8443, Path: "/api/users", Query: "id=1", StatusCode: 200}
+	b := &Entry{Method: "GET", Scheme: "https", Host: "a.example.com", Path: "/api/users/2", StatusCode: 200}
+	for _, e := range []*Entry{a, b} {
+		if err := store.Save(e); err != nil {
+			t.Fatalf("saving entry: %v", err)
+		}
+	}
+
+	manual := &Finding{Title: "IDOR on users", Severity: SeverityHigh, Evidence: "user 1 reads user 2", EntryIDs: []int64{b.ID, a.ID, a.ID}}
+	if err := store.AddFinding(manual); err != nil {
+		t.Fatalf("adding finding: %v", err)
+	}
+	got, err := store.GetFinding(manual.ID)
+	if err != nil {
+		t.Fatalf("getting finding: %v", err)
+	}
+	if got.Check != CheckManual || got.Status != StatusNew || got.Confidence != ConfidenceCertain || got.Hits != 2 {
+		t.Errorf("manual finding = %+v", got)
+	}
+	if got.EntryID != a.ID || got.Host != "a.example.com" || len(got.EntryIDs) != 2 || got.EntryIDs[0] != a.ID {
+		t.Errorf("manual finding entries = %d %v on %q", got.EntryID, got.EntryIDs, got.Host)
+	}
+	if got.URL != "https://a.example.com:8443/api/users?id=1" || got.Method != "POST" {
+		t.Errorf("manual finding request = %s %s", got.Method, got.URL)
+	}
+
+	if err := store.AddFinding(&Finding{Title: "x", Severity: SeverityLow, EntryIDs: []int64{999}}); err == nil {
+		t.Error("expected error for unknown entry")
+	}
+	if err := store.AddFinding(&Finding{Title: "x", Severity: "severe"}); err == nil {
+		t.Error("expected error for unknown severity")
+	}
+
+	if err := store.SetFindingStatus(manual.ID, StatusConfirmed); err != nil {
+		t.Fatalf("setting status: %v", err)
+	}
+	if err := store.SetFindingNote(manual.ID, "reported as BUG-12"); err != nil {
+		t.Fatalf("setting note: %v", err)
+	}
+	if err := store.SetFindingStatus(manual.ID, "done"); err == nil {
+		t.Error("expected error for unknown status")
+	}
+	if err := store.SetFindingNote(999, "x"); err == nil {
+		t.Error("expected error for unknown finding")
+	}
+	if got, _ := store.Findings(FindingParams{Status: StatusConfirmed}); len(got) != 1 || got[0].Note != "reported as BUG-12" {
+		t.Errorf("confirmed findings = %+v", got)
+	}
+
+	// A fixed finding seen again is reopened and linked to the new entry.
+	scanned := &Finding{Fingerprint: "f1", Check: "cors", Title: "CORS trusts null", Severity: SeverityHigh,
+		Confidence: ConfidenceFirm, Host: "a.example.com", EntryID: a.ID}
+	if err := store.SaveFinding(scanned); err != nil {
+		t.Fatalf("saving finding: %v", err)
+	}
+	store.SetFindingStatus(scanned.ID, StatusFixed)
+	if err := store.SaveFinding(&Finding{Fingerprint: "f1", Check: "cors", Title: "CORS trusts null",
+		Severity: SeverityHigh, Confidence: ConfidenceFirm, Host: "a.example.com", EntryID: b.ID}); err != nil {
+		t.Fatalf("saving finding: %v", err)
+	}
+	got, _ = store.GetFinding(scanned.ID)
+	if got.Status != StatusNew || len(got.EntryIDs) != 2 {
+		t.Errorf("reopened finding = %s %v", got.Status, got.EntryIDs)
+	}
+
+	// Deleting an entry unlinks it.
+	if _, err := store.DeleteWhere(SearchParams{Path: "/api/users/2"}); err != nil {
+		t.Fatalf("deleting: %v", err)
+	}
+	if got, _ := store.GetFinding(manual.ID); len(got.EntryIDs) != 1 || got.EntryIDs[0] != a.ID {
+		t.Errorf("entries after delete = %v", got.EntryIDs)
+	}
+	if _, err := store.GetFinding(999); err == nil {
+		t.Error("expected error for unknown finding")
+	}
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Fuzz run statuses.
+const (
+	FuzzRunning     = "running"
+	FuzzDone        = "done"
+	FuzzStopped     = "stopped"
+	FuzzInterrupted = "interrupted" // the daemon exited while the run was in progress
+)
+
+// FuzzRun is a batch of requests generated from one stored entry: a fuzz
+// attack, or a race with Mode "race".
+type FuzzRun struct {
+	ID         int64
+	EntryID    int64
+	Mode       string
+	Total      int
+	Sent       int // results recorded so far, including errors
+	Errors     int
+	Status     string
+	CreatedAt  time.Time
+	FinishedAt time.Time // zero while running
+}
+
+// FuzzResult records one request of a fuzz run. EntryID is 0 when the
+// request failed before a response was received; Error says why.
+type FuzzResult struct {
+	RunID      int64
+	Seq        int
+	EntryID    int64
+	Position   int      // 1-based payload position for sniper runs, otherwise 0
+	Payloads   []string // the payload inserted at each position used
+	Error      string
+	StatusCode int
+	Length     int // response body length
+	DurationMs int64
+}
+
+const fuzzRunColumns = `id, entry_id, mode, total,
+	(SELECT COUNT(*) FROM fuzz_results r WHERE r.run_id = fuzz_runs.id),
+	(SELECT COUNT(*) FROM fuzz_results r WHERE r.run_id = fuzz_runs.id AND r.error != ''),
+	status, created_at, finished_at`
+
+// CreateFuzzRun stores a new run and sets its ID.
+func (s *SQLiteStore) CreateFuzzRun(run *FuzzRun) error {
+	result, err := s.db.Exec(
+		`INSERT INTO fuzz_runs (entry_id, mode, total, status, created_at) VALUES (?, ?, ?, ?, ?)`,
+		run.EntryID, run.Mode, run.Total, run.Status, time.Now().UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("creating fuzz run: %w", err)
+	}
+	run.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// FinishFuzzRun records the final status of a run.
+func (s *SQLiteStore) FinishFuzzRun(id int64, status string) error {
+	_, err := s.db.Exec(
+		`UPDATE fuzz_runs SET status = ?, finished_at = ? WHERE id = ?`,
+		status, time.Now().UTC().Format(time.DateTime), id,
+	)
+	if err != nil {
+		return fmt.Errorf("finishing fuzz run: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetFuzzRun(id int64) (*FuzzRun, error) {
+	row := s.db.QueryRow(`SELECT `+fuzzRunColumns+` FROM fuzz_runs WHERE id = ?`, id)
+	run, err := scanFuzzRun(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("fuzz run not found")
+	}
+	return run, err
+}
+
+// ListFuzzRuns returns all runs, newest first.
+func (s *SQLiteStore) ListFuzzRuns() ([]*FuzzRun, error) {
+	rows, err := s.db.Query(`SELECT ` + fuzzRunColumns + ` FROM fuzz_runs ORDER BY id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("querying fuzz runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*FuzzRun
+	for rows.Next() {
+		run, err := scanFuzzRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func scanFuzzRun(row scanner) (*FuzzRun, error) {
+	var run FuzzRun
+	var createdAt, finishedAt any
+	err := row.Scan(&run.ID, &run.EntryID, &run.Mode, &run.Total, &run.Sent, &run.Errors, &run.Status, &createdAt, &finishedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning fuzz run: %w", err)
+	}
+	run.CreatedAt = parseTimestamp(createdAt)
+	run.FinishedAt = parseTimestamp(finishedAt)
+	return &run, nil
+}
+
+// SaveFuzzResult records the outcome of one request of a run.
+func (s *SQLiteStore) SaveFuzzResult(r *FuzzResult) error {
+	payloads, err := json.Marshal(r.Payloads)
+	if err != nil {
+		return fmt.Errorf("encoding payloads: %w", err)
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO fuzz_results (run_id, seq, entry_id, position, payloads, error, status_code, length, duration_ms)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.RunID, r.Seq, r.EntryID, r.Position, string(payloads), r.Error, r.StatusCode, r.Length, r.DurationMs,
+	)
+	if err != nil {
+		return fmt.Errorf("saving fuzz result: %w", err)
+	}
+	return nil
+}
+
+// FuzzResults returns the results recorded for a run in sequence order.
+func (s *SQLiteStore) FuzzResults(runID int64) ([]*FuzzResult, error) {
+	rows, err := s.db.Query(
+		`SELECT run_id, seq, entry_id, position, payloads, error, status_code, length, duration_ms
+		 FROM fuzz_results WHERE run_id = ? ORDER BY seq`, runID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying fuzz results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*FuzzResult
+	for rows.Next() {
+		var r FuzzResult
+		var payloads string
+		if err := rows.Scan(&r.RunID, &r.Seq, &r.EntryID, &r.Position, &payloads, &r.Error, &r.StatusCode, &r.Length, &r.DurationMs); err != nil {
+			return nil, fmt.Errorf("scanning fuzz result: %w", err)
+		}
+		if err := json.Unmarshal([]byte(payloads), &r.Payloads); err != nil {
+			return nil, fmt.Errorf("decoding payloads: %w", err)
+		}
+		results = append(results, &r)
+	}
+	return results, rows.Err()
+}
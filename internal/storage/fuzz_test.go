@@ -0,0 +1,60 @@
+package storage
+
+import "testing"
+
+func TestFuzzRuns(t *testing.T) {
+	store := testStore(t)
+
+	run := &FuzzRun{EntryID: 7, Mode: "sniper", Total: 3, Status: FuzzRunning}
+	if err := store.CreateFuzzRun(run); err != nil {
+		t.Fatalf("creating run: %v", err)
+	}
+
+	results := []*FuzzResult{
+		{RunID: run.ID, Seq: 2, EntryID: 11, Position: 1, Payloads: []string{"b"}, StatusCode: 500, Length: 10, DurationMs: 5},
+		{RunID: run.ID, Seq: 1, EntryID: 10, Position: 1, Payloads: []string{"a"}, StatusCode: 200, Length: 20, DurationMs: 4},
+		{RunID: run.ID, Seq: 3, Position: 2, Payloads: []string{"c"}, Error: "connection refused"},
+	}
+	for _, r := range results {
+		if err := store.SaveFuzzResult(r); err != nil {
+			t.Fatalf("saving result: %v", err)
+		}
+	}
+
+	got, err := store.GetFuzzRun(run.ID)
+	if err != nil {
+		t.Fatalf("getting run: %v", err)
+	}
+	if got.Sent != 3 || got.Errors != 1 || got.Status != FuzzRunning || !got.FinishedAt.IsZero() {
+		t.Errorf("run = %+v, want 3 sent, 1 error, running", got)
+	}
+
+	if err := store.FinishFuzzRun(run.ID, FuzzDone); err != nil {
+		t.Fatalf("finishing run: %v", err)
+	}
+	runs, err := store.ListFuzzRuns()
+	if err != nil {
+		t.Fatalf("listing runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Status != FuzzDone || runs[0].FinishedAt.IsZero() {
+		t.Errorf("runs = %+v, want one finished run", runs)
+	}
+
+	stored, err := store.FuzzResults(run.ID)
+	if err != nil {
+		t.Fatalf("listing results: %v", err)
+	}
+	if len(stored) != 3 || stored[0].Seq != 1 || stored[0].Payloads[0] != "a" || stored[2].Error != "connection refused" {
+		t.Errorf("results not stored in sequence order: %+v", stored)
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("clearing: %v", err)
+	}
+	if _, err := store.GetFuzzRun(run.ID); err == nil {
+		t.Error("expected run to be cleared")
+	}
+	if stored, _ := store.FuzzResults(run.ID); len(stored) != 0 {
+		t.Errorf("%d results left after clear", len(stored))
+	}
+}
@@ -0,0 +1,385 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when opening a database whose schema was
+// written by a newer version of reaper.
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of reaper")
+
+// ErrSchemaTooOld is returned when opening a database read-only whose
+// schema has pending migrations.
+var ErrSchemaTooOld = errors.New("database schema is older than this version of reaper")
+
+// A migration moves the schema from one version to the next. The schema
+// version is the number of migrations applied, recorded in PRAGMA
+// user_version. Databases that predate versioning report version 0, so early
+// migrations must tolerate objects that already exist.
+type migration struct {
+	name        string
+	destructive bool // back up the database before applying
+	up          func(tx *sql.Tx) error
+}
+
+// migrations is append-only: released entries must never be edited or
+// reordered.
+var migrations = []migration{
+	{name: "create entries", up: migrateCreateEntries},
+	{name: "add entry port", up: migrateEntryPort},
+	{name: "add blob storage", up: migrateBlobStorage},
+	{name: "move bodies to blobs", destructive: true, up: migrateBodies},
+	{name: "add annotations", up: migrateAnnotations},
+	{name: "add entry parent", up: migrateEntryParent},
+	{name: "add fuzz runs", up: migrateFuzzRuns},
+	{name: "add authz", up: migrateAuthz},
+	{name: "add findings", up: migrateFindings},
+	{name: "add finding triage", up: migrateFindingTriage},
+	{name: "add settings", up: migrateSettings},
+	{name: "add raw entries", up: migrateRawEntries},
+	{name: "store raw messages apart", up: migrateRawMessages},
+}
+
+// SchemaVersion is the schema version written by this build.
+var SchemaVersion = len(migrations)
+
+// SchemaVersion returns the schema version of the open database.
+func (s *SQLiteStore) SchemaVersion() (int, error) {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Migrate applies pending migrations in order, each in its own transaction,
+// and returns the names of those applied. The database is backed up first
+// if any pending migration is destructive and the database holds data.
+func (s *SQLiteStore) Migrate() ([]string, error) {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("%w: database is at version %d, this reaper supports up to %d", ErrSchemaTooNew, version, SchemaVersion)
+	}
+
+	pending := migrations[version:]
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	destructive := false
+	for _, m := range pending {
+		destructive = destructive || m.destructive
+	}
+	if destructive {
+		empty, err := s.isEmpty()
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			if _, err := s.Backup(s.backupPath(version)); err != nil {
+				return nil, fmt.Errorf("backing up before migration: %w", err)
+			}
+		}
+	}
+
+	var applied []string
+	for i, m := range pending {
+		if err := s.applyMigration(version+i+1, m); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m.name)
+	}
+
+	if destructive {
+		if err := s.Vacuum(); err != nil {
+			return applied, err
+		}
+	}
+	return applied, nil
+}
+
+func (s *SQLiteStore) applyMigration(version int, m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("migration %d (%s): %w", version, m.name, err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := m.up(tx); err != nil {
+		return fmt.Errorf("migration %d (%s): %w", version, m.name, err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return fmt.Errorf("migration %d (%s): %w", version, m.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %d (%s): %w", version, m.name, err)
+	}
+	return nil
+}
+
+// Backup writes a consistent copy of the database to dest, which must not
+// exist, and returns its path. It is safe to call while the database is in
+// use.
+func (s *SQLiteStore) Backup(dest string) (string, error) {
+	if dest == "" {
+		version, err := s.SchemaVersion()
+		if err != nil {
+			return "", err
+		}
+		dest = s.backupPath(version)
+	}
+	if _, err := s.db.Exec("VACUUM INTO ?", dest); err != nil {
+		return "", fmt.Errorf("backing up database: %w", err)
+	}
+	return dest, nil
+}
+
+func (s *SQLiteStore) backupPath(version int) string {
+	return fmt.Sprintf("%s.v%d-%s.bak", s.path, version, time.Now().Format("20060102-150405"))
+}
+
+// isEmpty reports whether the database has no user tables.
+func (s *SQLiteStore) isEmpty() (bool, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("inspecting database: %w", err)
+	}
+	return n == 0, nil
+}
+
+func migrateCreateEntries(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS entries (
+		id               INTEGER PRIMARY KEY AUTOINCREMENT,
+		method           TEXT NOT NULL,
+		scheme           TEXT NOT NULL,
+		host             TEXT NOT NULL,
+		path             TEXT NOT NULL,
+		query            TEXT DEFAULT '',
+		request_headers  TEXT NOT NULL,
+		request_body     BLOB,
+		status_code      INTEGER DEFAULT 0,
+		response_headers TEXT DEFAULT '{}',
+		response_body    BLOB,
+		created_at       DATETIME DEFAULT CURRENT_TIMESTAMP,
+		duration_ms      INTEGER DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_entries_method ON entries(method);
+	CREATE INDEX IF NOT EXISTS idx_entries_host ON entries(host);
+	CREATE INDEX IF NOT EXISTS idx_entries_status ON entries(status_code);
+	CREATE INDEX IF NOT EXISTS idx_entries_created ON entries(created_at);
+	`)
+	return err
+}
+
+func migrateEntryPort(tx *sql.Tx) error {
+	return addColumnIfMissing(tx, "entries", "port", "INTEGER DEFAULT 0")
+}
+
+func migrateBlobStorage(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "entries", "request_body_hash", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "entries", "response_body_hash", "TEXT"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(blobSchema)
+	return err
+}
+
+func migrateAnnotations(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS tags (
+		entry_id INTEGER NOT NULL,
+		tag      TEXT NOT NULL,
+		PRIMARY KEY (entry_id, tag)
+	);
+	CREATE INDEX IF NOT EXISTS idx_tags_tag ON tags(tag);
+	CREATE TABLE IF NOT EXISTS annotations (
+		entry_id   INTEGER PRIMARY KEY,
+		note       TEXT NOT NULL DEFAULT '',
+		highlight  TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TRIGGER IF NOT EXISTS entries_annotations_delete AFTER DELETE ON entries BEGIN
+		DELETE FROM tags WHERE entry_id = OLD.id;
+		DELETE FROM annotations WHERE entry_id = OLD.id;
+	END;
+	`)
+	return err
+}
+
+func migrateEntryParent(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "entries", "parent_id", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_entries_parent ON entries(parent_id)`)
+	return err
+}
+
+func migrateFuzzRuns(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "entries", "run_id", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_entries_run ON entries(run_id);
+	CREATE TABLE IF NOT EXISTS fuzz_runs (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		entry_id    INTEGER NOT NULL,
+		mode        TEXT NOT NULL,
+		total       INTEGER NOT NULL,
+		status      TEXT NOT NULL,
+		created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+		finished_at DATETIME
+	);
+	CREATE TABLE IF NOT EXISTS fuzz_results (
+		run_id      INTEGER NOT NULL,
+		seq         INTEGER NOT NULL,
+		entry_id    INTEGER NOT NULL DEFAULT 0,
+		position    INTEGER NOT NULL DEFAULT 0,
+		payloads    TEXT NOT NULL DEFAULT '[]',
+		error       TEXT NOT NULL DEFAULT '',
+		status_code INTEGER NOT NULL DEFAULT 0,
+		length      INTEGER NOT NULL DEFAULT 0,
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (run_id, seq)
+	);
+	CREATE TRIGGER IF NOT EXISTS fuzz_runs_delete AFTER DELETE ON fuzz_runs BEGIN
+		DELETE FROM fuzz_results WHERE run_id = OLD.id;
+	END;
+	`)
+	return err
+}
+
+func migrateAuthz(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS identities (
+		name       TEXT PRIMARY KEY,
+		headers    TEXT NOT NULL DEFAULT '{}',
+		remove     TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS authz_results (
+		entry_id        INTEGER NOT NULL,
+		identity        TEXT NOT NULL,
+		replay_id       INTEGER NOT NULL DEFAULT 0,
+		original_status INTEGER NOT NULL DEFAULT 0,
+		status          INTEGER NOT NULL DEFAULT 0,
+		similarity      REAL NOT NULL DEFAULT 0,
+		verdict         TEXT NOT NULL,
+		error           TEXT NOT NULL DEFAULT '',
+		tested_at       DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (entry_id, identity)
+	);
+	CREATE TRIGGER IF NOT EXISTS entries_authz_delete AFTER DELETE ON entries BEGIN
+		DELETE FROM authz_results WHERE entry_id = OLD.id;
+	END;
+	`)
+	return err
+}
+
+func migrateFindings(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS findings (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		fingerprint TEXT NOT NULL UNIQUE,
+		check_name  TEXT NOT NULL,
+		title       TEXT NOT NULL,
+		severity    TEXT NOT NULL,
+		confidence  TEXT NOT NULL,
+		host        TEXT NOT NULL DEFAULT '',
+		entry_id    INTEGER NOT NULL DEFAULT 0,
+		evidence    TEXT NOT NULL DEFAULT '',
+		hits        INTEGER NOT NULL DEFAULT 1,
+		first_seen  DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen   DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_findings_host ON findings(host);
+	`)
+	return err
+}
+
+func migrateFindingTriage(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "findings", "status", "TEXT NOT NULL DEFAULT 'new'"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "findings", "note", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS finding_entries (
+		finding_id INTEGER NOT NULL,
+		entry_id   INTEGER NOT NULL,
+		PRIMARY KEY (finding_id, entry_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_finding_entries_entry ON finding_entries(entry_id);
+	INSERT OR IGNORE INTO finding_entries (finding_id, entry_id) SELECT id, entry_id FROM findings WHERE entry_id > 0;
+	CREATE TRIGGER IF NOT EXISTS entries_findings_delete AFTER DELETE ON entries BEGIN
+		DELETE FROM finding_entries WHERE entry_id = OLD.id;
+	END;
+	`)
+	return err
+}
+
+func migrateSettings(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS settings (
+		name  TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+	`)
+	return err
+}
+
+func migrateRawEntries(tx *sql.Tx) error {
+	return addColumnIfMissing(tx, "entries", "raw", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// migrateRawMessages moves the wire messages of raw entries out of their
+// body columns, which the entries from before it used for them, so that the
+// bodies hold parsed bodies for every entry. The parsed bodies of those
+// entries are left empty; the raw column is no longer written.
+func migrateRawMessages(tx *sql.Tx) error {
+	for _, col := range []string{"raw_request_hash", "raw_response_hash"} {
+		if err := addColumnIfMissing(tx, "entries", col, "TEXT"); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(`
+	UPDATE entries SET raw_request_hash = request_body_hash, raw_response_hash = response_body_hash,
+		request_body_hash = NULL, response_body_hash = NULL
+	WHERE raw = 1;
+	DROP TRIGGER IF EXISTS entries_blob_unref;
+	CREATE TRIGGER entries_blob_unref AFTER DELETE ON entries BEGIN
+		UPDATE blobs SET refs = refs - 1 WHERE hash = OLD.request_body_hash;
+		UPDATE blobs SET refs = refs - 1 WHERE hash = OLD.response_body_hash;
+		UPDATE blobs SET refs = refs - 1 WHERE hash = OLD.raw_request_hash;
+		UPDATE blobs SET refs = refs - 1 WHERE hash = OLD.raw_response_hash;
+		DELETE FROM blobs WHERE refs <= 0 AND hash IN (OLD.request_body_hash, OLD.response_body_hash, OLD.raw_request_hash, OLD.raw_response_hash);
+	END;
+	`)
+	return err
+}
+
+// addColumnIfMissing adds a column unless a pre-versioning build already
+// created it.
+func addColumnIfMissing(tx queryExecer, table, column, decl string) error {
+	var n int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("reading %s columns: %w", table, err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl))
+	return err
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	store := testStore(t)
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		t.Fatalf("reading version: %v", err)
+	}
+	if version != SchemaVersion {
+		t.Errorf("version = %d, want %d", version, SchemaVersion)
+	}
+
+	applied, err := store.Migrate()
+	if err != nil {
+		t.Fatalf("re-running migrate: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("applied %v on an up-to-date database, want none", applied)
+	}
+
+	backups, _ := filepath.Glob(store.path + ".*.bak")
+	if len(backups) != 0 {
+		t.Errorf("fresh database was backed up: %v", backups)
+	}
+}
+
+func TestMigrateBacksUpBeforeDestructiveStep(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "v1.db")
+
+	store, err := OpenSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("opening: %v", err)
+	}
+	if err := store.applyMigration(1, migrations[0]); err != nil {
+		t.Fatalf("applying first migration: %v", err)
+	}
+	store.db.Exec(`INSERT INTO entries (method, scheme, host, path, request_headers, request_body) VALUES ('POST', 'https', 'a.com', '/', '{}', 'x=1')`)
+
+	applied, err := store.Migrate()
+	if err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	if len(applied) != SchemaVersion-1 {
+		t.Errorf("applied %d migrations, want %d", len(applied), SchemaVersion-1)
+	}
+	store.Close()
+
+	backups, _ := filepath.Glob(dbPath + ".v1-*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("got backups %v, want one v1 backup", backups)
+	}
+
+	backup, err := OpenSQLiteStore(backups[0])
+	if err != nil {
+		t.Fatalf("opening backup: %v", err)
+	}
+	defer backup.Close()
+	if v, _ := backup.SchemaVersion(); v != 1 {
+		t.Errorf("backup version = %d, want 1", v)
+	}
+}
+
+func TestOpenRefusesNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "future.db")
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("PRAGMA user_version = 9999")
+	db.Close()
+
+	_, err = NewSQLiteStore(dbPath)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("err = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "ro.db")
+
+	old, err := OpenSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := old.applyMigration(1, migrations[0]); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+	if _, err := OpenSQLiteStoreReadOnly(dbPath); !errors.Is(err, ErrSchemaTooOld) {
+		t.Fatalf("err = %v, want ErrSchemaTooOld", err)
+	}
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := secretEntry("/ro", "")
+	if err := store.Save(e); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	ro, err := OpenSQLiteStoreReadOnly(dbPath)
+	if err != nil {
+		t.Fatalf("opening read-only: %v", err)
+	}
+	defer ro.Close()
+	if got, err := ro.Get(e.ID); err != nil || got.Path != "/ro" {
+		t.Errorf("Get = %v, %v", got, err)
+	}
+	if err := ro.Save(secretEntry("/new", "")); err == nil {
+		t.Error("Save on a read-only store succeeded")
+	}
+
+	missing := filepath.Join(filepath.Dir(dbPath), "missing.db")
+	if _, err := OpenSQLiteStoreReadOnly(missing); err == nil {
+		t.Error("opening a missing database read-only succeeded")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("read-only open created %s", missing)
+	}
+}
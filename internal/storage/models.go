@@ -1,15 +1,21 @@
 package storage
 
 import (
+	"bytes"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Entry struct {
 	ID              int64
+	ParentID        int64 // entry this one was replayed from, or 0
+	RunID           int64 // fuzz run that sent this entry, or 0
 	Method          string
 	Scheme          string // "http" or "https"
 	Host            string
+	Port            int // 0 when unknown; see EffectivePort
 	Path            string
 	Query           string
 	RequestHeaders  http.Header
@@ -19,6 +25,82 @@ type Entry struct {
 	ResponseBody    []byte
 	Timestamp       time.Time
 	DurationMs      int64
+	Tags            []string
+	Note            string
+	Highlight       string // one of HighlightColors, or empty
+
+	// RawRequest and RawResponse hold the messages of an entry sent with
+	// send byte for byte as they went over the wire, start lines and headers
+	// included. The other fields are parsed from them where possible, as
+	// for any entry. They are nil for entries not sent raw.
+	RawRequest  []byte
+	RawResponse []byte
+}
+
+// contains reports whether the headers or bodies of e contain text.
+func (e *Entry) contains(text string) bool {
+	for _, h := range []http.Header{e.RequestHeaders, e.ResponseHeaders} {
+		for name, values := range h {
+			for _, v := range values {
+				if strings.Contains(name+": "+v, text) {
+					return true
+				}
+			}
+		}
+	}
+	return bytes.Contains(e.RequestBody, []byte(text)) || bytes.Contains(e.ResponseBody, []byte(text))
+}
+
+// HighlightColors are the colors an entry can be highlighted with.
+var HighlightColors = []string{"red", "orange", "yellow", "green", "cyan", "blue", "pink", "magenta", "gray"}
+
+// EffectivePort returns the entry's port, falling back to the scheme default
+// for entries recorded before ports were stored.
+func (e *Entry) EffectivePort() int {
+	if e.Port > 0 {
+		return e.Port
+	}
+	return DefaultPort(e.Scheme)
+}
+
+// Authority returns the host, with the port appended when it is not the
+// default for the scheme. It is suitable for a Host header or URL; IPv6
+// addresses, stored bare, are bracketed.
+func (e *Entry) Authority() string {
+	host := e.Host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	port := e.EffectivePort()
+	if port == DefaultPort(e.Scheme) {
+		return host
+	}
+	return host + ":" + strconv.Itoa(port)
+}
+
+// URL returns the absolute URL of the request.
+func (e *Entry) URL() string {
+	scheme := e.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	u := scheme + "://" + e.Authority() + path
+	if e.Query != "" {
+		u += "?" + e.Query
+	}
+	return u
+}
+
+// DefaultPort returns the well-known port for scheme.
+func DefaultPort(scheme string) int {
+	if scheme == "https" {
+		return 443
+	}
+	return 80
 }
 
 type SearchParams struct {
@@ -27,6 +109,27 @@ type SearchParams struct {
 	Domains []string // suffix match
 	Path    string   // prefix or glob
 	Status  int      // exact match
-	Limit   int
-	Offset  int
+	Tag     string   // exact match
+	// Contains matches entries whose request or response headers or body
+	// contain the text, case-sensitively.
+	Contains string
+	Limit    int
+	Offset   int
+}
+
+// HasFilter reports whether any filter field is set.
+func (p SearchParams) HasFilter() bool {
+	return p.Method != "" || p.Host != "" || len(p.Domains) > 0 || p.Path != "" || p.Status != 0 || p.Tag != "" ||
+		p.Contains != ""
+}
+
+// RetentionPolicy bounds how much traffic is kept. Zero fields are unlimited.
+type RetentionPolicy struct {
+	MaxAge     time.Duration // entries older than this are removed
+	MaxEntries int           // only the newest MaxEntries are kept
+	MaxSize    int64         // bytes of database pages in use
+}
+
+func (p RetentionPolicy) IsZero() bool {
+	return p.MaxAge <= 0 && p.MaxEntries <= 0 && p.MaxSize <= 0
 }
@@ -0,0 +1,356 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parameter locations reported by ExtractParams.
+const (
+	LocationQuery     = "query"
+	LocationForm      = "form"
+	LocationMultipart = "multipart"
+	LocationJSON      = "json"
+	LocationCookie    = "cookie"
+	LocationHeader    = "header"
+)
+
+// locationOrder ranks locations for sorting parameter inventories.
+var locationOrder = map[string]int{
+	LocationQuery:     0,
+	LocationForm:      1,
+	LocationMultipart: 2,
+	LocationJSON:      3,
+	LocationCookie:    4,
+	LocationHeader:    5,
+}
+
+const (
+	maxParamSamples     = 5
+	maxParamSampleLen   = 64
+	maxParamExamples    = 3
+	maxJSONParamsPerDoc = 500
+)
+
+// Param is a single parameter occurrence in a request.
+type Param struct {
+	Location string
+	Name     string // dotted path for nested JSON keys, e.g. user.address.city
+	Value    string
+	Type     string
+}
+
+// ParamSummary aggregates the occurrences of one parameter on one endpoint.
+type ParamSummary struct {
+	Host       string
+	Endpoint   string // normalized path template, see NormalizePath
+	Location   string
+	Name       string
+	Methods    []string
+	Types      []string
+	Samples    []string // distinct values, truncated
+	Count      int
+	ExampleIDs []int64 // most recent first
+}
+
+// notableHeaders are request headers reported as parameters in addition to
+// any X- prefixed header not listed in ignoredHeaders.
+var notableHeaders = map[string]bool{
+	"Authorization": true,
+	"Origin":        true,
+	"Referer":       true,
+}
+
+var ignoredHeaders = map[string]bool{
+	"X-Forwarded-For":   true,
+	"X-Forwarded-Host":  true,
+	"X-Forwarded-Port":  true,
+	"X-Forwarded-Proto": true,
+	"X-Real-Ip":         true,
+}
+
+// ExtractParams returns the parameters in the request half of e: query string
+// values, url-encoded, multipart and JSON body fields, cookies and notable
+// headers. Nested JSON keys use dotted paths; array elements are named with
+// a [] suffix.
+func ExtractParams(e *Entry) []Param {
+	var params []Param
+
+	if q, err := url.ParseQuery(e.Query); err == nil {
+		params = appendValues(params, LocationQuery, q)
+	}
+
+	params = append(params, bodyParams(e.RequestHeaders.Get("Content-Type"), e.RequestBody)...)
+
+	for _, c := range (&http.Request{Header: e.RequestHeaders}).Cookies() {
+		params = append(params, Param{Location: LocationCookie, Name: c.Name, Value: c.Value, Type: inferType(c.Value)})
+	}
+
+	for name, values := range e.RequestHeaders {
+		canonical := http.CanonicalHeaderKey(name)
+		if !notableHeaders[canonical] && (!strings.HasPrefix(canonical, "X-") || ignoredHeaders[canonical]) {
+			continue
+		}
+		for _, v := range values {
+			params = append(params, Param{Location: LocationHeader, Name: canonical, Value: v, Type: inferType(v)})
+		}
+	}
+
+	return params
+}
+
+func bodyParams(contentType string, body []byte) []Param {
+	if len(body) == 0 {
+		return nil
+	}
+
+	mediaType, mediaParams, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil
+		}
+		return appendValues(nil, LocationForm, values)
+	case mediaType == "multipart/form-data":
+		return multipartParams(body, mediaParams["boundary"])
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"),
+		mediaType == "" && json.Valid(body):
+		var doc any
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return nil
+		}
+		switch doc.(type) {
+		case map[string]any, []any:
+		default:
+			return nil
+		}
+		var params []Param
+		walkJSON(&params, "", doc)
+		return params
+	}
+	return nil
+}
+
+func appendValues(params []Param, location string, values url.Values) []Param {
+	for name, vv := range values {
+		for _, v := range vv {
+			params = append(params, Param{Location: location, Name: name, Value: v, Type: inferType(v)})
+		}
+	}
+	return params
+}
+
+func multipartParams(body []byte, boundary string) []Param {
+	if boundary == "" {
+		return nil
+	}
+
+	var params []Param
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		if filename := part.FileName(); filename != "" {
+			params = append(params, Param{Location: LocationMultipart, Name: name, Value: filename, Type: "file"})
+			continue
+		}
+		value, err := io.ReadAll(io.LimitReader(part, 4096))
+		if err != nil {
+			break
+		}
+		params = append(params, Param{Location: LocationMultipart, Name: name, Value: string(value), Type: inferType(string(value))})
+	}
+	return params
+}
+
+// walkJSON appends a parameter for every scalar in v, and for empty objects
+// and arrays, naming each by its dotted path from the document root.
+func walkJSON(params *[]Param, name string, v any) {
+	if len(*params) >= maxJSONParamsPerDoc {
+		return
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 && name != "" {
+			*params = append(*params, Param{Location: LocationJSON, Name: name, Value: "{}", Type: "object"})
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := k
+			if name != "" {
+				child = name + "." + k
+			}
+			walkJSON(params, child, v[k])
+		}
+	case []any:
+		if len(v) == 0 && name != "" {
+			*params = append(*params, Param{Location: LocationJSON, Name: name, Value: "[]", Type: "array"})
+		}
+		for _, elem := range v {
+			walkJSON(params, name+"[]", elem)
+		}
+	case json.Number:
+		typ := "float"
+		if _, err := v.Int64(); err == nil {
+			typ = "int"
+		}
+		*params = append(*params, Param{Location: LocationJSON, Name: name, Value: v.String(), Type: typ})
+	case bool:
+		*params = append(*params, Param{Location: LocationJSON, Name: name, Value: strconv.FormatBool(v), Type: "bool"})
+	case nil:
+		if name != "" {
+			*params = append(*params, Param{Location: LocationJSON, Name: name, Value: "null", Type: "null"})
+		}
+	case string:
+		typ := inferType(v)
+		if typ == "int" || typ == "float" || typ == "bool" {
+			// a JSON string is a string, however numeric it looks
+			typ = "string"
+		}
+		*params = append(*params, Param{Location: LocationJSON, Name: name, Value: v, Type: typ})
+	}
+}
+
+// inferType classifies a textual parameter value.
+func inferType(v string) string {
+	switch {
+	case v == "":
+		return "empty"
+	case isDigits(strings.TrimPrefix(v, "-")):
+		return "int"
+	case v == "true" || v == "false":
+		return "bool"
+	case uuidSegment.MatchString(v):
+		return "uuid"
+	case emailSegment.MatchString(v):
+		return "email"
+	case strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://"):
+		return "url"
+	case strings.Count(v, ".") == 2 && strings.HasPrefix(v, "eyJ"):
+		return "jwt"
+	}
+	// ParseFloat also accepts Inf, NaN and hex floats
+	if _, err := strconv.ParseFloat(v, 64); err == nil && strings.Trim(v, "0123456789.-+eE") == "" {
+		return "float"
+	}
+	return "string"
+}
+
+// Params builds a parameter inventory from the entries matching params. If
+// endpoint is set, only entries whose normalized path equals its normalized
+// form are included, so both /users/42 and /users/{id} select the same
+// endpoint. Limit and Offset are ignored.
+func (s *SQLiteStore) Params(params SearchParams, endpoint string) ([]ParamSummary, error) {
+	if endpoint != "" {
+		endpoint = NormalizePath(endpoint)
+	}
+
+	conditions, args, err := s.filterConditions(params)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + entryColumns + ` FROM ` + entryTables
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying entries: %w", err)
+	}
+	defer rows.Close()
+
+	byKey := map[string]*ParamSummary{}
+	for rows.Next() {
+		e, err := s.scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		template := NormalizePath(e.Path)
+		if endpoint != "" && template != endpoint {
+			continue
+		}
+
+		for _, p := range ExtractParams(e) {
+			key := strings.Join([]string{e.Host, template, p.Location, p.Name}, "\x00")
+			sum, ok := byKey[key]
+			if !ok {
+				sum = &ParamSummary{Host: e.Host, Endpoint: template, Location: p.Location, Name: p.Name}
+				byKey[key] = sum
+			}
+			sum.Count++
+			if !slices.Contains(sum.Methods, e.Method) {
+				sum.Methods = append(sum.Methods, e.Method)
+			}
+			if !slices.Contains(sum.Types, p.Type) {
+				sum.Types = append(sum.Types, p.Type)
+			}
+			sample := p.Value
+			if len(sample) > maxParamSampleLen {
+				// Cut between runes, so the sample stays valid UTF-8.
+				n := maxParamSampleLen - 3
+				for n > 0 && !utf8.RuneStart(sample[n]) {
+					n--
+				}
+				sample = sample[:n] + "..."
+			}
+			if len(sum.Samples) < maxParamSamples && !slices.Contains(sum.Samples, sample) {
+				sum.Samples = append(sum.Samples, sample)
+			}
+			if len(sum.ExampleIDs) < maxParamExamples && !slices.Contains(sum.ExampleIDs, e.ID) {
+				sum.ExampleIDs = append(sum.ExampleIDs, e.ID)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("querying entries: %w", err)
+	}
+
+	summaries := make([]ParamSummary, 0, len(byKey))
+	for _, sum := range byKey {
+		sort.Strings(sum.Methods)
+		sort.Strings(sum.Types)
+		summaries = append(summaries, *sum)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		if a.Location != b.Location {
+			return locationOrder[a.Location] < locationOrder[b.Location]
+		}
+		return a.Name < b.Name
+	})
+	return summaries, nil
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxSizePasses bounds the delete/measure iterations used to bring the
+// database under RetentionPolicy.MaxSize.
+const maxSizePasses = 20
+
+// Prune deletes entries matching filter that fall outside policy, then
+// reclaims the freed space. A zero policy deletes every matching entry.
+// The filter's Limit and Offset are ignored.
+func (s *SQLiteStore) Prune(filter SearchParams, policy RetentionPolicy) (int64, error) {
+	conditions, args, err := s.filterConditions(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	del := func(extra string, extraArgs ...any) error {
+		conds := append(append([]string{}, conditions...), extra)
+		result, err := s.db.Exec(
+			"DELETE FROM entries WHERE "+strings.Join(conds, " AND "),
+			append(append([]any{}, args...), extraArgs...)...,
+		)
+		if err != nil {
+			return fmt.Errorf("pruning entries: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		deleted += n
+		return nil
+	}
+
+	if policy.IsZero() {
+		return s.DeleteWhere(filter)
+	}
+
+	if policy.MaxAge > 0 {
+		cutoff := time.Now().Add(-policy.MaxAge).UTC().Format(time.DateTime)
+		if err := del("created_at < ?", cutoff); err != nil {
+			return deleted, err
+		}
+	}
+
+	if policy.MaxEntries > 0 {
+		keep := "id NOT IN (SELECT id FROM entries"
+		if len(conditions) > 0 {
+			keep += " WHERE " + strings.Join(conditions, " AND ")
+		}
+		keep += " ORDER BY id DESC LIMIT ?)"
+		if err := del(keep, append(append([]any{}, args...), policy.MaxEntries)...); err != nil {
+			return deleted, err
+		}
+	}
+
+	if policy.MaxSize > 0 {
+		for range maxSizePasses {
+			size, err := s.Size()
+			if err != nil {
+				return deleted, err
+			}
+			if size <= policy.MaxSize {
+				break
+			}
+
+			count, err := s.count(conditions, args)
+			if err != nil {
+				return deleted, err
+			}
+			if count == 0 {
+				break
+			}
+
+			// Delete the oldest share of entries proportional to the overage.
+			n := int64(float64(count)*float64(size-policy.MaxSize)/float64(size)) + 1
+			oldest := "id IN (SELECT id FROM entries"
+			if len(conditions) > 0 {
+				oldest += " WHERE " + strings.Join(conditions, " AND ")
+			}
+			oldest += " ORDER BY id ASC LIMIT ?)"
+			if err := del(oldest, append(append([]any{}, args...), n)...); err != nil {
+				return deleted, err
+			}
+		}
+	}
+
+	if deleted > 0 {
+		if err := s.Vacuum(); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// Size returns the number of bytes used by database pages, excluding pages
+// on the freelist.
+func (s *SQLiteStore) Size() (int64, error) {
+	var pageCount, freeCount, pageSize int64
+	if err := s.db.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, fmt.Errorf("reading page count: %w", err)
+	}
+	if err := s.db.QueryRow("PRAGMA freelist_count").Scan(&freeCount); err != nil {
+		return 0, fmt.Errorf("reading freelist count: %w", err)
+	}
+	if err := s.db.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, fmt.Errorf("reading page size: %w", err)
+	}
+	return (pageCount - freeCount) * pageSize, nil
+}
+
+// Vacuum returns free pages to the filesystem. Databases in incremental
+// auto-vacuum mode are trimmed in place; others get a full VACUUM, which
+// also switches them to incremental mode for next time.
+func (s *SQLiteStore) Vacuum() error {
+	var mode int
+	if err := s.db.QueryRow("PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return fmt.Errorf("reading auto_vacuum mode: %w", err)
+	}
+
+	stmt := "VACUUM"
+	if mode == 2 {
+		stmt = "PRAGMA incremental_vacuum"
+	}
+	if _, err := s.db.Exec(stmt); err != nil {
+		return fmt.Errorf("vacuuming database: %w", err)
+	}
+
+	// Shrink the WAL so the reclaimed space shows up on disk immediately.
+	if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("checkpointing database: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) count(conditions []string, args []any) (int64, error) {
+	query := "SELECT COUNT(*) FROM entries"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	var n int64
+	if err := s.db.QueryRow(query, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("counting entries: %w", err)
+	}
+	return n, nil
+}
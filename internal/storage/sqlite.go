@@ -5,55 +5,123 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+// entryColumns is the column list read by scanEntry, selected from
+// entryTables. Bodies come from the blobs table, or from the inline
+// columns for rows not yet migrated; raw messages only from the blobs
+// table.
+const entryColumns = `id, COALESCE(parent_id, 0), COALESCE(run_id, 0), method, scheme, host, port, path, query, request_headers, COALESCE(rb.data, request_body), rb.hash IS NOT NULL,
+	status_code, response_headers, COALESCE(sb.data, response_body), sb.hash IS NOT NULL, created_at, duration_ms,
+	(SELECT group_concat(tag, ',') FROM tags WHERE tags.entry_id = entries.id), COALESCE(an.note, ''), COALESCE(an.highlight, ''), qb.data, pb.data`
+
+const entryTables = `entries
+	LEFT JOIN blobs rb ON rb.hash = entries.request_body_hash
+	LEFT JOIN blobs sb ON sb.hash = entries.response_body_hash
+	LEFT JOIN blobs qb ON qb.hash = entries.raw_request_hash
+	LEFT JOIN blobs pb ON pb.hash = entries.raw_response_hash
+	LEFT JOIN annotations an ON an.entry_id = entries.id`
+
 type SQLiteStore struct {
-	db *sql.DB
+	db   *sql.DB
+	path string
+
+	encryption *encryptionSettings // nil if the database is not encrypted
+	keys       *cipherKeys         // nil until unlocked
 }
 
+// NewSQLiteStore opens the database at dbPath, creating it if needed, and
+// applies any pending schema migrations.
 func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
-	db, err := sql.Open("sqlite", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	store, err := OpenSQLiteStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := store.Migrate(); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// OpenSQLiteStore opens the database at dbPath without migrating it. It
+// fails if the database was written by a newer version of reaper.
+func OpenSQLiteStore(dbPath string) (*SQLiteStore, error) {
+	return openSQLiteStore(dbPath, false)
+}
+
+// OpenSQLiteStoreReadOnly opens the database at dbPath for reading, so
+// that entries can be inspected while no daemon is running. The file is
+// opened read-only and never created or reconfigured. It fails if the
+// database needs migrating, which only a writer may do.
+func OpenSQLiteStoreReadOnly(dbPath string) (*SQLiteStore, error) {
+	store, err := openSQLiteStore(dbPath, true)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if version < SchemaVersion {
+		store.Close()
+		return nil, fmt.Errorf("%w: database is at version %d, run 'reaper db migrate' to upgrade it to %d", ErrSchemaTooOld, version, SchemaVersion)
+	}
+
+	return store, nil
+}
+
+func openSQLiteStore(dbPath string, readOnly bool) (*SQLiteStore, error) {
+	dsn := dbPath + "?_journal_mode=WAL&_busy_timeout=5000"
+	if readOnly {
+		dsn = "file:" + dbPath + "?mode=ro&_busy_timeout=5000"
+	}
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
 	db.SetMaxOpenConns(1)
 
-	if err := createSchema(db); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("creating schema: %w", err)
+	if !readOnly {
+		// Only takes effect for new databases; Vacuum converts existing ones.
+		if _, err := db.Exec("PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("configuring database: %w", err)
+		}
 	}
 
-	return &SQLiteStore{db: db}, nil
-}
+	store := &SQLiteStore{db: db, path: dbPath}
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version > SchemaVersion {
+		db.Close()
+		return nil, fmt.Errorf("%w: database is at version %d, this reaper supports up to %d", ErrSchemaTooNew, version, SchemaVersion)
+	}
+	if err := store.loadEncryption(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if store.encryption != nil && !readOnly {
+		if _, err := db.Exec("PRAGMA secure_delete = ON"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("configuring database: %w", err)
+		}
+	}
 
-func createSchema(db *sql.DB) error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS entries (
-		id               INTEGER PRIMARY KEY AUTOINCREMENT,
-		method           TEXT NOT NULL,
-		scheme           TEXT NOT NULL,
-		host             TEXT NOT NULL,
-		path             TEXT NOT NULL,
-		query            TEXT DEFAULT '',
-		request_headers  TEXT NOT NULL,
-		request_body     BLOB,
-		status_code      INTEGER DEFAULT 0,
-		response_headers TEXT DEFAULT '{}',
-		response_body    BLOB,
-		created_at       DATETIME DEFAULT CURRENT_TIMESTAMP,
-		duration_ms      INTEGER DEFAULT 0
-	);
-	CREATE INDEX IF NOT EXISTS idx_entries_method ON entries(method);
-	CREATE INDEX IF NOT EXISTS idx_entries_host ON entries(host);
-	CREATE INDEX IF NOT EXISTS idx_entries_status ON entries(status_code);
-	CREATE INDEX IF NOT EXISTS idx_entries_created ON entries(created_at);
-	`
-	_, err := db.Exec(schema)
-	return err
+	return store, nil
 }
 
 func (s *SQLiteStore) Save(entry *Entry) error {
@@ -66,42 +134,86 @@ func (s *SQLiteStore) Save(entry *Entry) error {
 	if err != nil {
 		return fmt.Errorf("marshaling response headers: %w", err)
 	}
+	reqText, err := s.sealHeaderText(string(reqHeaders), "request headers")
+	if err != nil {
+		return err
+	}
+	respText, err := s.sealHeaderText(string(respHeaders), "response headers")
+	if err != nil {
+		return err
+	}
 
 	ts := entry.Timestamp
 	if ts.IsZero() {
 		ts = time.Now()
 	}
 
-	result, err := s.db.Exec(
-		`INSERT INTO entries (method, scheme, host, path, query, request_headers, request_body, status_code, response_headers, response_body, created_at, duration_ms)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	reqHash, err := putBlob(tx, entry.RequestBody, s.keys)
+	if err != nil {
+		return err
+	}
+	respHash, err := putBlob(tx, entry.ResponseBody, s.keys)
+	if err != nil {
+		return err
+	}
+	rawReqHash, err := putBlob(tx, entry.RawRequest, s.keys)
+	if err != nil {
+		return err
+	}
+	rawRespHash, err := putBlob(tx, entry.RawResponse, s.keys)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(
+		`INSERT INTO entries (parent_id, run_id, method, scheme, host, port, path, query, request_headers, request_body_hash, status_code, response_headers, response_body_hash, created_at, duration_ms, raw_request_hash, raw_response_hash)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ParentID,
+		entry.RunID,
 		entry.Method,
 		entry.Scheme,
 		entry.Host,
+		entry.Port,
 		entry.Path,
 		entry.Query,
-		string(reqHeaders),
-		entry.RequestBody,
+		reqText,
+		reqHash,
 		entry.StatusCode,
-		string(respHeaders),
-		entry.ResponseBody,
+		respText,
+		respHash,
 		ts.UTC().Format(time.DateTime),
 		entry.DurationMs,
+		rawReqHash,
+		rawRespHash,
 	)
 	if err != nil {
 		return fmt.Errorf("inserting entry: %w", err)
 	}
 
-	entry.ID, _ = result.LastInsertId()
+	id, _ := result.LastInsertId()
+	if err := saveAnnotations(tx, id, entry); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing entry: %w", err)
+	}
+
+	entry.ID = id
 	return nil
 }
 
 func (s *SQLiteStore) Get(id int64) (*Entry, error) {
 	row := s.db.QueryRow(
-		`SELECT id, method, scheme, host, path, query, request_headers, request_body, status_code, response_headers, response_body, created_at, duration_ms
-		 FROM entries WHERE id = ?`, id,
+		`SELECT `+entryColumns+`
+		 FROM `+entryTables+` WHERE id = ?`, id,
 	)
-	return scanEntry(row)
+	return s.scanEntry(row)
 }
 
 func (s *SQLiteStore) List(limit, offset int) ([]*Entry, error) {
@@ -109,14 +221,14 @@ func (s *SQLiteStore) List(limit, offset int) ([]*Entry, error) {
 		limit = 50
 	}
 	rows, err := s.db.Query(
-		`SELECT id, method, scheme, host, path, query, request_headers, request_body, status_code, response_headers, response_body, created_at, duration_ms
-		 FROM entries ORDER BY id DESC LIMIT ? OFFSET ?`, limit, offset,
+		`SELECT `+entryColumns+`
+		 FROM `+entryTables+` ORDER BY id DESC LIMIT ? OFFSET ?`, limit, offset,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("querying entries: %w", err)
 	}
 	defer rows.Close()
-	return scanEntries(rows)
+	return s.scanEntries(rows)
 }
 
 func (s *SQLiteStore) ListAfter(afterID int64, limit int) ([]*Entry, error) {
@@ -124,25 +236,131 @@ func (s *SQLiteStore) ListAfter(afterID int64, limit int) ([]*Entry, error) {
 		limit = 100
 	}
 	rows, err := s.db.Query(
-		`SELECT id, method, scheme, host, path, query, request_headers, request_body, status_code, response_headers, response_body, created_at, duration_ms
-		 FROM entries WHERE id > ? ORDER BY id ASC LIMIT ?`, afterID, limit,
+		`SELECT `+entryColumns+`
+		 FROM `+entryTables+` WHERE id > ? ORDER BY id ASC LIMIT ?`, afterID, limit,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("querying entries: %w", err)
 	}
 	defer rows.Close()
-	return scanEntries(rows)
+	return s.scanEntries(rows)
 }
 
 func (s *SQLiteStore) Clear() error {
-	_, err := s.db.Exec("DELETE FROM entries")
+	_, err := s.db.Exec("DELETE FROM entries; DELETE FROM blobs; DELETE FROM fuzz_runs; DELETE FROM findings; DELETE FROM finding_entries")
 	if err != nil {
 		return fmt.Errorf("clearing entries: %w", err)
 	}
 	return nil
 }
 
+// DeleteWhere deletes the entries matching params, ignoring Limit and
+// Offset, and reclaims the freed space.
+func (s *SQLiteStore) DeleteWhere(params SearchParams) (int64, error) {
+	conditions, args, err := s.filterConditions(params)
+	if err != nil {
+		return 0, err
+	}
+
+	query := "DELETE FROM entries"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("deleting entries: %w", err)
+	}
+
+	deleted, _ := result.RowsAffected()
+	if deleted > 0 {
+		if err := s.Vacuum(); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// Count returns the number of entries matching params, ignoring Limit and
+// Offset.
+func (s *SQLiteStore) Count(params SearchParams) (int64, error) {
+	conditions, args, err := s.filterConditions(params)
+	if err != nil {
+		return 0, err
+	}
+	return s.count(conditions, args)
+}
+
 func (s *SQLiteStore) Search(params SearchParams) ([]*Entry, error) {
+	conditions, args, err := s.filterConditions(params)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + entryColumns + ` FROM ` + entryTables
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, params.Offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching entries: %w", err)
+	}
+	defer rows.Close()
+	return s.scanEntries(rows)
+}
+
+// filterConditions returns the SQL conditions on the entries table that
+// select the entries matching params, to be joined with AND.
+func (s *SQLiteStore) filterConditions(params SearchParams) ([]string, []any, error) {
+	conditions, args := searchConditions(params)
+	if params.Contains == "" {
+		return conditions, args, nil
+	}
+	ids, err := s.containing(conditions, args, params.Contains)
+	if err != nil {
+		return nil, nil, err
+	}
+	idList, _ := json.Marshal(ids)
+	return append(conditions, "id IN (SELECT value FROM json_each(?))"), append(args, string(idList)), nil
+}
+
+// containing returns the IDs of the entries matching conditions whose
+// headers or bodies contain text. Bodies are compressed and may be
+// encrypted, so each candidate entry is read and searched in Go.
+func (s *SQLiteStore) containing(conditions []string, args []any, text string) ([]int64, error) {
+	query := `SELECT ` + entryColumns + ` FROM ` + entryTables
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching entries: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		e, err := s.scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		if e.contains(text) {
+			ids = append(ids, e.ID)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// searchConditions translates the filter fields of params that map to
+// columns into SQL conditions on the entries table, to be joined with AND.
+func searchConditions(params SearchParams) ([]string, []any) {
 	var conditions []string
 	var args []any
 
@@ -184,24 +402,12 @@ func (s *SQLiteStore) Search(params SearchParams) ([]*Entry, error) {
 		args = append(args, params.Status)
 	}
 
-	query := `SELECT id, method, scheme, host, path, query, request_headers, request_body, status_code, response_headers, response_body, created_at, duration_ms FROM entries`
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
-	}
-	query += " ORDER BY id DESC"
-
-	limit := params.Limit
-	if limit <= 0 {
-		limit = 100
+	if params.Tag != "" {
+		conditions = append(conditions, "id IN (SELECT entry_id FROM tags WHERE tag = ?)")
+		args = append(args, params.Tag)
 	}
-	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, params.Offset)
 
-	rows, err := s.db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("searching entries: %w", err)
-	}
-	defer rows.Close()
-	return scanEntries(rows)
+	return conditions, args
 }
 
 func (s *SQLiteStore) Close() error {
@@ -212,16 +418,18 @@ type scanner interface {
 	Scan(dest ...any) error
 }
 
-func scanEntry(row scanner) (*Entry, error) {
+func (s *SQLiteStore) scanEntry(row scanner) (*Entry, error) {
 	var e Entry
 	var reqHeaders, respHeaders string
-	var createdAt string
-	var reqBody, respBody []byte
+	var createdAt any
+	var reqBody, respBody, rawReq, rawResp []byte
+	var reqStored, respStored bool
+	var tags sql.NullString
 
 	err := row.Scan(
-		&e.ID, &e.Method, &e.Scheme, &e.Host, &e.Path, &e.Query,
-		&reqHeaders, &reqBody, &e.StatusCode, &respHeaders, &respBody,
-		&createdAt, &e.DurationMs,
+		&e.ID, &e.ParentID, &e.RunID, &e.Method, &e.Scheme, &e.Host, &e.Port, &e.Path, &e.Query,
+		&reqHeaders, &reqBody, &reqStored, &e.StatusCode, &respHeaders, &respBody, &respStored,
+		&createdAt, &e.DurationMs, &tags, &e.Note, &e.Highlight, &rawReq, &rawResp,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -231,8 +439,34 @@ func scanEntry(row scanner) (*Entry, error) {
 	}
 
 	e.RequestBody = reqBody
+	if reqStored {
+		if e.RequestBody, err = s.readBlob(reqBody); err != nil {
+			return nil, err
+		}
+	}
 	e.ResponseBody = respBody
+	if respStored {
+		if e.ResponseBody, err = s.readBlob(respBody); err != nil {
+			return nil, err
+		}
+	}
+	if rawReq != nil {
+		if e.RawRequest, err = s.readBlob(rawReq); err != nil {
+			return nil, err
+		}
+	}
+	if rawResp != nil {
+		if e.RawResponse, err = s.readBlob(rawResp); err != nil {
+			return nil, err
+		}
+	}
 
+	if reqHeaders, err = s.openHeaderText(reqHeaders, "request headers"); err != nil {
+		return nil, err
+	}
+	if respHeaders, err = s.openHeaderText(respHeaders, "response headers"); err != nil {
+		return nil, err
+	}
 	if err := json.Unmarshal([]byte(reqHeaders), &e.RequestHeaders); err != nil {
 		e.RequestHeaders = http.Header{}
 	}
@@ -240,15 +474,36 @@ func scanEntry(row scanner) (*Entry, error) {
 		e.ResponseHeaders = http.Header{}
 	}
 
-	e.Timestamp, _ = time.Parse(time.DateTime, createdAt)
+	e.Timestamp = parseTimestamp(createdAt)
+
+	if tags.String != "" {
+		e.Tags = strings.Split(tags.String, ",")
+		sort.Strings(e.Tags)
+	}
 
 	return &e, nil
 }
 
-func scanEntries(rows *sql.Rows) ([]*Entry, error) {
+// parseTimestamp converts a DATETIME column value, which the driver returns
+// either as time.Time or as the stored text.
+func parseTimestamp(v any) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case string:
+		ts, _ := time.Parse(time.DateTime, t)
+		return ts
+	case []byte:
+		ts, _ := time.Parse(time.DateTime, string(t))
+		return ts
+	}
+	return time.Time{}
+}
+
+func (s *SQLiteStore) scanEntries(rows *sql.Rows) ([]*Entry, error) {
 	var entries []*Entry
 	for rows.Next() {
-		e, err := scanEntry(rows)
+		e, err := s.scanEntry(rows)
 		if err != nil {
 			return nil, err
 		}
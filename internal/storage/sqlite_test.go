@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -65,6 +69,9 @@ func TestSaveAndGet(t *testing.T) {
 	if got.StatusCode != 200 {
 		t.Errorf("status = %d, want 200", got.StatusCode)
 	}
+	if !got.Timestamp.Equal(entry.Timestamp.Truncate(time.Second)) {
+		t.Errorf("timestamp = %v, want %v", got.Timestamp, entry.Timestamp.Truncate(time.Second))
+	}
 	if got.DurationMs != 42 {
 		t.Errorf("duration = %d, want 42", got.DurationMs)
 	}
@@ -196,3 +203,384 @@ func TestCertGeneration(t *testing.T) {
 		t.Error("database file was not created")
 	}
 }
+
+func TestSavePort(t *testing.T) {
+	store := testStore(t)
+
+	entry := &Entry{Method: "GET", Scheme: "https", Host: "a.com", Port: 8443, Path: "/", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: time.Now()}
+	if err := store.Save(entry); err != nil {
+		t.Fatalf("saving entry: %v", err)
+	}
+
+	got, err := store.Get(entry.ID)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if got.Port != 8443 {
+		t.Errorf("port = %d, want 8443", got.Port)
+	}
+	if got.URL() != "https://a.com:8443/" {
+		t.Errorf("url = %q, want https://a.com:8443/", got.URL())
+	}
+
+	v6 := &Entry{Scheme: "http", Host: "::1", Port: 8080, Path: "/x"}
+	if v6.URL() != "http://[::1]:8080/x" {
+		t.Errorf("url = %q, want http://[::1]:8080/x", v6.URL())
+	}
+}
+
+func TestSaveParent(t *testing.T) {
+	store := testStore(t)
+
+	original := &Entry{Method: "GET", Scheme: "https", Host: "a.com", Path: "/", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: time.Now()}
+	if err := store.Save(original); err != nil {
+		t.Fatalf("saving entry: %v", err)
+	}
+	replayed := &Entry{ParentID: original.ID, Method: "GET", Scheme: "https", Host: "a.com", Path: "/", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: time.Now()}
+	if err := store.Save(replayed); err != nil {
+		t.Fatalf("saving entry: %v", err)
+	}
+
+	got, err := store.Get(replayed.ID)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if got.ParentID != original.ID {
+		t.Errorf("parent = %d, want %d", got.ParentID, original.ID)
+	}
+	if got, _ := store.Get(original.ID); got.ParentID != 0 {
+		t.Errorf("original parent = %d, want 0", got.ParentID)
+	}
+}
+
+func TestLegacySchemaMigrated(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE entries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, method TEXT NOT NULL, scheme TEXT NOT NULL, host TEXT NOT NULL,
+		path TEXT NOT NULL, query TEXT DEFAULT '', request_headers TEXT NOT NULL, request_body BLOB,
+		status_code INTEGER DEFAULT 0, response_headers TEXT DEFAULT '{}', response_body BLOB,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP, duration_ms INTEGER DEFAULT 0);
+		INSERT INTO entries (method, scheme, host, path, request_headers, response_body) VALUES ('GET', 'http', 'old.com', '/', '{}', 'legacy body');
+		INSERT INTO entries (method, scheme, host, path, request_headers, response_body) VALUES ('GET', 'http', 'old.com', '/', '{}', 'legacy body');`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("opening legacy database: %v", err)
+	}
+	defer store.Close()
+
+	got, err := store.Get(1)
+	if err != nil {
+		t.Fatalf("getting legacy entry: %v", err)
+	}
+	if got.EffectivePort() != 80 {
+		t.Errorf("effective port = %d, want 80", got.EffectivePort())
+	}
+	if string(got.ResponseBody) != "legacy body" {
+		t.Errorf("response body = %q, want legacy body", got.ResponseBody)
+	}
+
+	var inline, blobs int
+	store.db.QueryRow("SELECT COUNT(*) FROM entries WHERE response_body IS NOT NULL").Scan(&inline)
+	store.db.QueryRow("SELECT COUNT(*) FROM blobs").Scan(&blobs)
+	if inline != 0 || blobs != 1 {
+		t.Errorf("inline bodies = %d blobs = %d, want 0 and 1", inline, blobs)
+	}
+}
+
+func TestPruneByFilter(t *testing.T) {
+	store := testStore(t)
+
+	store.Save(&Entry{Method: "GET", Scheme: "https", Host: "a.com", Path: "/health", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: time.Now()})
+	store.Save(&Entry{Method: "GET", Scheme: "https", Host: "a.com", Path: "/users", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: time.Now()})
+
+	deleted, err := store.Prune(SearchParams{Path: "/health"}, RetentionPolicy{})
+	if err != nil {
+		t.Fatalf("pruning: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted = %d, want 1", deleted)
+	}
+
+	entries, _ := store.List(10, 0)
+	if len(entries) != 1 || entries[0].Path != "/users" {
+		t.Errorf("remaining entries = %v, want only /users", entries)
+	}
+}
+
+func TestPruneRetention(t *testing.T) {
+	store := testStore(t)
+
+	old := time.Now().Add(-48 * time.Hour)
+	for i := 0; i < 3; i++ {
+		store.Save(&Entry{Method: "GET", Scheme: "https", Host: "a.com", Path: "/old", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: old})
+	}
+	for i := 0; i < 5; i++ {
+		store.Save(&Entry{Method: "GET", Scheme: "https", Host: "a.com", Path: "/new", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: time.Now()})
+	}
+
+	deleted, err := store.Prune(SearchParams{}, RetentionPolicy{MaxAge: 24 * time.Hour, MaxEntries: 4})
+	if err != nil {
+		t.Fatalf("pruning: %v", err)
+	}
+	if deleted != 4 {
+		t.Errorf("deleted = %d, want 4", deleted)
+	}
+
+	entries, _ := store.List(10, 0)
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+	for _, e := range entries {
+		if e.Path != "/new" {
+			t.Errorf("kept %s, want only /new", e.Path)
+		}
+	}
+}
+
+func TestPruneMaxSize(t *testing.T) {
+	store := testStore(t)
+
+	for i := 0; i < 40; i++ {
+		// random bodies defeat both compression and deduplication
+		body := make([]byte, 64*1024)
+		rand.Read(body)
+		store.Save(&Entry{Method: "GET", Scheme: "https", Host: "a.com", Path: "/", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, ResponseBody: body, Timestamp: time.Now()})
+	}
+
+	limit := int64(1024 * 1024)
+	if _, err := store.Prune(SearchParams{}, RetentionPolicy{MaxSize: limit}); err != nil {
+		t.Fatalf("pruning: %v", err)
+	}
+
+	size, err := store.Size()
+	if err != nil {
+		t.Fatalf("reading size: %v", err)
+	}
+	if size > limit {
+		t.Errorf("size = %d, want <= %d", size, limit)
+	}
+
+	entries, _ := store.List(100, 0)
+	if len(entries) == 0 || len(entries) == 40 {
+		t.Errorf("got %d entries, want some but not all pruned", len(entries))
+	}
+}
+
+func TestBodiesDeduplicated(t *testing.T) {
+	store := testStore(t)
+
+	bundle := []byte(strings.Repeat("function(){return 1};", 10000))
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		e := &Entry{Method: "GET", Scheme: "https", Host: "cdn.acme.com", Path: "/app.js", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, ResponseBody: bundle, Timestamp: time.Now()}
+		if err := store.Save(e); err != nil {
+			t.Fatalf("saving entry: %v", err)
+		}
+		ids = append(ids, e.ID)
+	}
+
+	var blobs, refs int
+	store.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(refs), 0) FROM blobs").Scan(&blobs, &refs)
+	if blobs != 1 || refs != 3 {
+		t.Errorf("blobs = %d refs = %d, want 1 blob with 3 refs", blobs, refs)
+	}
+
+	got, err := store.Get(ids[1])
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if string(got.ResponseBody) != string(bundle) {
+		t.Error("response body does not round-trip")
+	}
+
+	if _, err := store.Prune(SearchParams{}, RetentionPolicy{MaxEntries: 1}); err != nil {
+		t.Fatalf("pruning: %v", err)
+	}
+	store.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(refs), 0) FROM blobs").Scan(&blobs, &refs)
+	if blobs != 1 || refs != 1 {
+		t.Errorf("after prune blobs = %d refs = %d, want 1 blob with 1 ref", blobs, refs)
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("clearing: %v", err)
+	}
+	store.db.QueryRow("SELECT COUNT(*) FROM blobs").Scan(&blobs)
+	if blobs != 0 {
+		t.Errorf("after clear blobs = %d, want 0", blobs)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	store := testStore(t)
+
+	a := &Entry{Method: "GET", Scheme: "https", Host: "a.com", Path: "/users/1", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: time.Now()}
+	b := &Entry{Method: "GET", Scheme: "https", Host: "a.com", Path: "/users/2", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: time.Now()}
+	store.Save(a)
+	store.Save(b)
+
+	if err := store.AddTags(a.ID, []string{"idor-candidate", "auth"}); err != nil {
+		t.Fatalf("tagging: %v", err)
+	}
+	if err := store.SetNote(a.ID, "check with second account"); err != nil {
+		t.Fatalf("setting note: %v", err)
+	}
+	if err := store.SetHighlight(a.ID, "red"); err != nil {
+		t.Fatalf("setting highlight: %v", err)
+	}
+	if err := store.SetHighlight(a.ID, "chartreuse"); err == nil {
+		t.Error("expected error for unknown color")
+	}
+	if err := store.AddTags(999, []string{"x"}); err == nil {
+		t.Error("expected error tagging missing entry")
+	}
+
+	got, err := store.Get(a.ID)
+	if err != nil {
+		t.Fatalf("getting entry: %v", err)
+	}
+	if strings.Join(got.Tags, ",") != "auth,idor-candidate" {
+		t.Errorf("tags = %v, want [auth idor-candidate]", got.Tags)
+	}
+	if got.Note != "check with second account" || got.Highlight != "red" {
+		t.Errorf("note = %q highlight = %q", got.Note, got.Highlight)
+	}
+
+	entries, err := store.Search(SearchParams{Tag: "idor-candidate"})
+	if err != nil {
+		t.Fatalf("searching: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ID != a.ID {
+		t.Errorf("search by tag returned %d entries, want only %d", len(entries), a.ID)
+	}
+
+	if err := store.RemoveTags(a.ID, []string{"auth"}); err != nil {
+		t.Fatalf("untagging: %v", err)
+	}
+	got, _ = store.Get(a.ID)
+	if len(got.Tags) != 1 {
+		t.Errorf("tags after removal = %v", got.Tags)
+	}
+
+	store.Clear()
+	var n int
+	store.db.QueryRow("SELECT (SELECT COUNT(*) FROM tags) + (SELECT COUNT(*) FROM annotations)").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d annotation rows left after clear", n)
+	}
+}
+
+func TestDeleteWhere(t *testing.T) {
+	store := testStore(t)
+
+	store.Save(&Entry{Method: "GET", Scheme: "https", Host: "api.acme.com", Path: "/health", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: time.Now()})
+	store.Save(&Entry{Method: "GET", Scheme: "https", Host: "web.acme.com", Path: "/health", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: time.Now()})
+	store.Save(&Entry{Method: "GET", Scheme: "https", Host: "api.acme.com", Path: "/users", RequestHeaders: http.Header{}, ResponseHeaders: http.Header{}, Timestamp: time.Now()})
+
+	filter := SearchParams{Host: "api.acme.com", Path: "/health", Limit: 1}
+	n, err := store.Count(filter)
+	if err != nil {
+		t.Fatalf("counting: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("count = %d, want 1", n)
+	}
+
+	deleted, err := store.DeleteWhere(filter)
+	if err != nil {
+		t.Fatalf("deleting: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted = %d, want 1", deleted)
+	}
+
+	if n, _ := store.Count(SearchParams{}); n != 2 {
+		t.Errorf("remaining = %d, want 2", n)
+	}
+}
+
+func TestRawMessages(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "raw.db")
+	store, err := OpenSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("opening: %v", err)
+	}
+	defer store.Close()
+	for i, m := range migrations[:SchemaVersion-1] {
+		if err := store.applyMigration(i+1, m); err != nil {
+			t.Fatalf("applying %q: %v", m.name, err)
+		}
+	}
+
+	// Before the raw columns, a raw entry kept its wire messages in its bodies.
+	oldReq, oldResp := []byte("GET /old HTTP/1.1\r\n\r\n"), []byte("HTTP/1.1 204 No Content\r\n\r\n")
+	tx, _ := store.db.Begin()
+	reqHash, _ := putBlob(tx, oldReq, nil)
+	respHash, _ := putBlob(tx, oldResp, nil)
+	_, err = tx.Exec(`INSERT INTO entries (method, scheme, host, path, request_headers, request_body_hash, response_body_hash, raw)
+		VALUES ('GET', 'http', 'old.com', '/old', '{}', ?, ?, 1)`, reqHash, respHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+	if _, err := store.Migrate(); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	old, err := store.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(old.RawRequest, oldReq) || !bytes.Equal(old.RawResponse, oldResp) || old.RequestBody != nil || old.ResponseBody != nil {
+		t.Errorf("migrated entry: raw %q %q, bodies %q %q", old.RawRequest, old.RawResponse, old.RequestBody, old.ResponseBody)
+	}
+
+	// A sent entry has its parsed fields like any other.
+	raw := []byte("POST /login?next=%2Fhome HTTP/1.1\r\nHost: acme.com\r\nContent-Type: application/x-www-form-urlencoded\r\nContent-Length: 10\r\n\r\nuser=alice")
+	sent := &Entry{
+		Method: "POST", Scheme: "https", Host: "acme.com", Path: "/login", Query: "next=%2Fhome",
+		RequestHeaders: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, RequestBody: []byte("user=alice"),
+		StatusCode: 200, ResponseHeaders: http.Header{}, ResponseBody: []byte("ok"), Timestamp: time.Now(),
+		RawRequest: raw, RawResponse: []byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"),
+	}
+	if err := store.Save(sent); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	got, err := store.Get(sent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.RawRequest, raw) || !bytes.Equal(got.RawResponse, sent.RawResponse) || string(got.RequestBody) != "user=alice" {
+		t.Errorf("sent entry: raw %q %q, request body %q", got.RawRequest, got.RawResponse, got.RequestBody)
+	}
+	params, err := store.Params(SearchParams{Host: "acme.com"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range params {
+		names = append(names, p.Location+":"+p.Name)
+	}
+	if strings.Join(names, ",") != "query:next,form:user" {
+		t.Errorf("params of the sent entry = %v", names)
+	}
+
+	// Deleting the entries releases their raw messages.
+	if _, err := store.db.Exec("DELETE FROM entries"); err != nil {
+		t.Fatal(err)
+	}
+	var blobs int
+	store.db.QueryRow("SELECT COUNT(*) FROM blobs").Scan(&blobs)
+	if blobs != 0 {
+		t.Errorf("%d blobs left after deleting every entry", blobs)
+	}
+}
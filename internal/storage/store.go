@@ -6,6 +6,32 @@ type Store interface {
 	List(limit, offset int) ([]*Entry, error)
 	ListAfter(afterID int64, limit int) ([]*Entry, error)
 	Search(params SearchParams) ([]*Entry, error)
+	Count(params SearchParams) (int64, error)
+	Endpoints(params SearchParams) ([]Endpoint, error)
+	Params(params SearchParams, endpoint string) ([]ParamSummary, error)
+	AddTags(id int64, tags []string) error
+	RemoveTags(id int64, tags []string) error
+	SetNote(id int64, note string) error
+	SetHighlight(id int64, color string) error
+	CreateFuzzRun(run *FuzzRun) error
+	FinishFuzzRun(id int64, status string) error
+	GetFuzzRun(id int64) (*FuzzRun, error)
+	ListFuzzRuns() ([]*FuzzRun, error)
+	SaveFuzzResult(r *FuzzResult) error
+	FuzzResults(runID int64) ([]*FuzzResult, error)
+	SaveIdentity(id *Identity) error
+	DeleteIdentity(name string) error
+	ListIdentities() ([]*Identity, error)
+	SaveAuthzResult(r *AuthzResult) error
+	AuthzResults(params SearchParams) ([]*AuthzResult, error)
+	SaveFinding(f *Finding) error
+	Findings(params FindingParams) ([]*Finding, error)
+	AddFinding(f *Finding) error
+	GetFinding(id int64) (*Finding, error)
+	SetFindingStatus(id int64, status string) error
+	SetFindingNote(id int64, note string) error
+	Prune(filter SearchParams, policy RetentionPolicy) (int64, error)
+	DeleteWhere(params SearchParams) (int64, error)
 	Clear() error
 	Close() error
 }